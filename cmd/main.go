@@ -2,24 +2,44 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	"mm-go-agent/internal/adapter/llm"
 	"mm-go-agent/internal/adapter/renderer"
+	"mm-go-agent/internal/config"
+	"mm-go-agent/internal/eval"
+	"mm-go-agent/internal/lsp"
+	"mm-go-agent/internal/progress"
 	"mm-go-agent/internal/repository"
 	fileOutputRepo "mm-go-agent/internal/repository/file"
 	"mm-go-agent/internal/service"
 	"mm-go-agent/internal/service/diagram"
+	"mm-go-agent/internal/textdiff"
+	"mm-go-agent/internal/usage"
 	pkgllm "mm-go-agent/pkg/llm"
+	"mm-go-agent/pkg/mermaid"
+	"mm-go-agent/pkg/prompt"
 )
 
 func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newRootCmd creates the mm-gen command with its subcommands
+func newRootCmd() *cobra.Command {
 	var rootCmd = &cobra.Command{
 		Use:   "mm-gen",
 		Short: "Generate Mermaid diagrams from Go code",
@@ -28,7 +48,7 @@ func main() {
 	// Command for generating diagram for a single file
 	var fileCmd = &cobra.Command{
 		Use:   "file [diagram-type] [file]",
-		Short: "Generate Mermaid diagram from a single Go file",
+		Short: "Generate Mermaid diagram from a single Go or .proto file",
 		Args:  cobra.ExactArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
 			diagramType := args[0]
@@ -38,7 +58,7 @@ func main() {
 			svgFormat, _ := cmd.Flags().GetBool("svg")
 			renderer, _ := cmd.Flags().GetString("renderer")
 
-			generateAndPrintDiagram(diagramType, filePath, "", outDir, svgFormat, false, renderer)
+			generateAndPrintDiagram(cmd, diagramType, filePath, "", outDir, svgFormat, false, renderer)
 		},
 	}
 
@@ -57,15 +77,68 @@ func main() {
 			svgFormat, _ := cmd.Flags().GetBool("svg")
 			renderer, _ := cmd.Flags().GetString("renderer")
 
-			generateAndPrintDiagram(diagramType, "", fmt.Sprintf("%s:%s", componentType, componentName), outDir, svgFormat, false, renderer)
+			generateAndPrintDiagram(cmd, diagramType, "", fmt.Sprintf("%s:%s", componentType, componentName), outDir, svgFormat, false, renderer)
+		},
+	}
+
+	// Command for generating diagram for packages or a symbol
+	var targetCmd = &cobra.Command{
+		Use:   "target [diagram-type] [target]",
+		Short: "Generate Mermaid diagram for Go packages or a single type or function",
+		Long: `Generate Mermaid diagram for a target resolved with go/packages, regardless of how files are named:
+
+  ./internal/service/...           packages matching a pattern
+  mm-go-agent/internal/service     a package by import path
+  service.DiagramService           a type and the types of the module it uses, by package name
+  ./internal/service.NewValidationService
+                                   a function, by package pattern or import path`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			diagramType := args[0]
+			target := args[1]
+
+			outDir, _ := cmd.Flags().GetString("outDir")
+			svgFormat, _ := cmd.Flags().GetBool("svg")
+			renderer, _ := cmd.Flags().GetString("renderer")
+
+			generateAndPrintDiagram(cmd, diagramType, "", target, outDir, svgFormat, false, renderer)
+		},
+	}
+
+	// Command for generating diagram around a type
+	var focusCmd = &cobra.Command{
+		Use:   "focus [diagram-type]",
+		Short: "Generate Mermaid diagram of the types around a type, with the type highlighted",
+		Long: `Build the graph of the types of the module with go/types, where a type is related to the types
+used by its fields, embedded types and method signatures and to the interfaces it implements,
+and generate a diagram of the types within --depth hops of --symbol.
+
+Directions:
+  out  - the types the symbol uses and implements
+  in   - the types using or implementing the symbol
+  both - either way (default)`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			diagramType := args[0]
+
+			symbol, _ := cmd.Flags().GetString("symbol")
+			outDir, _ := cmd.Flags().GetString("outDir")
+			svgFormat, _ := cmd.Flags().GetBool("svg")
+			renderer, _ := cmd.Flags().GetString("renderer")
+
+			generateAndPrintDiagram(cmd, diagramType, "", symbol, outDir, svgFormat, false, renderer)
 		},
 	}
+	focusCmd.Flags().String("symbol", "", "Type to focus on, e.g. service.diagramService")
+	focusCmd.Flags().Int("depth", 1, "Number of hops from the symbol")
+	focusCmd.Flags().String("direction", "both", "Relations to follow: out, in or both")
+	_ = focusCmd.MarkFlagRequired("symbol")
 
 	// Command for mapping the project
 	var mapCmd = &cobra.Command{
 		Use:   "map [diagram-type]",
 		Short: "Generate project-wide Mermaid diagrams",
-		Long:  "Generate project-wide Mermaid diagrams. Diagram types: sequence (component interactions), class (all components), config (config interactions), adapters (inbound/outbound communications)",
+		Long:  "Generate project-wide Mermaid diagrams. Diagram types: sequence (component interactions), class (all components), config (config interactions), adapters (inbound/outbound communications), proto (gRPC services and messages from .proto files), grpc (gRPC client/server wiring), concurrency (goroutines and channels), implements (interfaces and their implementations)",
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			diagramType := args[0]
@@ -75,7 +148,7 @@ func main() {
 			splitOutput, _ := cmd.Flags().GetBool("split")
 			renderer, _ := cmd.Flags().GetString("renderer")
 
-			generateAndPrintDiagram(diagramType, "", "map", outDir, svgFormat, splitOutput, renderer)
+			generateAndPrintDiagram(cmd, diagramType, "", "map", outDir, svgFormat, splitOutput, renderer)
 		},
 	}
 
@@ -84,6 +157,8 @@ func main() {
 	mapCmd.Flags().BoolP("svg", "s", false, "Generate diagram in SVG format")
 	mapCmd.Flags().BoolP("split", "p", false, "Split project map into separate files by component type")
 	mapCmd.Flags().StringP("renderer", "r", "default", "SVG renderer to use (mermaid)")
+	mapCmd.Flags().BoolP("markdown", "m", false, "Also output a Markdown table (implements diagram only)")
+	mapCmd.Flags().String("mode", "llm", "Class diagram generation mode: llm, ast (types extracted from code) or hybrid (AST skeleton annotated by the LLM)")
 
 	// Add same flags to other commands
 	fileCmd.Flags().StringP("outDir", "o", "", "Output directory for generated diagrams")
@@ -92,6 +167,30 @@ func main() {
 	componentCmd.Flags().StringP("outDir", "o", "", "Output directory for generated diagrams")
 	componentCmd.Flags().BoolP("svg", "s", false, "Generate diagram in SVG format")
 	componentCmd.Flags().StringP("renderer", "r", "default", "SVG renderer to use (mermaid)")
+	targetCmd.Flags().StringP("outDir", "o", "", "Output directory for generated diagrams")
+	targetCmd.Flags().BoolP("svg", "s", false, "Generate diagram in SVG format")
+	targetCmd.Flags().StringP("renderer", "r", "default", "SVG renderer to use (mermaid)")
+	focusCmd.Flags().StringP("outDir", "o", "", "Output directory for generated diagrams")
+	focusCmd.Flags().BoolP("svg", "s", false, "Generate diagram in SVG format")
+	focusCmd.Flags().StringP("renderer", "r", "default", "SVG renderer to use (mermaid)")
+	focusCmd.Flags().String("mode", "llm", "Class diagram generation mode: llm, ast (types extracted from code) or hybrid (AST skeleton annotated by the LLM)")
+	targetCmd.Flags().String("mode", "llm", "Class diagram generation mode: llm, ast (types extracted from code) or hybrid (AST skeleton annotated by the LLM)")
+	fileCmd.Flags().String("mode", "llm", "Class diagram generation mode: llm, ast (types extracted from code) or hybrid (AST skeleton annotated by the LLM)")
+	componentCmd.Flags().String("mode", "llm", "Class diagram generation mode: llm, ast (types extracted from code) or hybrid (AST skeleton annotated by the LLM)")
+	for _, c := range []*cobra.Command{componentCmd, mapCmd} {
+		addFilterFlags(c)
+	}
+	for _, c := range []*cobra.Command{fileCmd, componentCmd, targetCmd, focusCmd, mapCmd} {
+		c.Flags().String("root", ".", "Directory the source paths and component directories are relative to")
+		c.Flags().Bool("verify", false, "Check the generated diagram against the symbols in the source code")
+		c.Flags().Bool("strict", false, "Verify and ask the LLM to correct entities not found in the source code")
+		c.Flags().String("notes", "", "Notes about the code added to the prompts, e.g. domain terms or what to leave out")
+		c.Flags().Bool("structured", false, "Ask the LLM for a JSON graph rendered into Mermaid, for class, sequence and flowchart diagrams")
+		c.Flags().Bool("progress", true, "Stream LLM completions and show their progress on stderr")
+		c.Flags().Bool("json", false, "Print the diagram and the LLM usage as JSON")
+		addUsageFlags(c)
+		addPromptFlags(c)
+	}
 
 	// Command for validating Mermaid diagram syntax
 	var validateCmd = &cobra.Command{
@@ -140,28 +239,624 @@ func main() {
 	// Add retries flag to set the maximum number of retries
 	retriesFlag := 0
 	validateCmd.Flags().IntVarP(&retriesFlag, "retries", "r", 0, "Maximum number of retries for fixing (0 = use default/env var)")
+	addPromptFlags(validateCmd)
+	addUsageFlags(validateCmd)
 
-	rootCmd.AddCommand(fileCmd, componentCmd, mapCmd, validateCmd)
+	var lintCmd = &cobra.Command{
+		Use:   "lint [file...]",
+		Short: "Check Mermaid diagrams for readability problems",
+		Long: `Check Mermaid diagrams for problems beyond syntax errors, such as duplicate node IDs,
+orphan nodes, overly long labels or inconsistent arrow styles. Reads from stdin without files.
 
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
+Rules are configured in .mm-gen.yaml and can be disabled within a diagram with a
+"%% mm-gen-disable rule" comment. Exits with status 1 when an error is found.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			lintDiagrams(cmd, args)
+		},
+	}
+	lintCmd.Flags().String("config", "", "Configuration file (default: .mm-gen.yaml if present)")
+	lintCmd.Flags().Bool("json", false, "Print issues as JSON")
+	lintCmd.Flags().Bool("rules", false, "List the available rules")
+
+	var lspCmd = &cobra.Command{
+		Use:   "lsp",
+		Short: "Run a language server for Mermaid files over stdio",
+		Long: `Run a Language Server Protocol server over stdin and stdout for editing .mmd files.
+It publishes validation and lint diagnostics, offers automatic and LLM fixes as code actions,
+and provides hover, go-to-definition, document symbols and formatting.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runLanguageServer(cmd)
+		},
+	}
+	lspCmd.Flags().String("config", "", "Configuration file (default: .mm-gen.yaml if present)")
+	addPromptFlags(lspCmd)
+
+	var fmtCmd = &cobra.Command{
+		Use:   "fmt [file...]",
+		Short: "Format Mermaid diagrams",
+		Long: `Format Mermaid diagrams with canonical layout: two space indentation, one statement
+per line and single spaces around arrows. Without flags the formatted diagrams are printed;
+without files the diagram is read from stdin.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			formatDiagrams(cmd, args)
+		},
+	}
+	fmtCmd.Flags().BoolP("write", "w", false, "Write the result to the files instead of printing it")
+	fmtCmd.Flags().BoolP("check", "c", false, "List the files that aren't formatted and exit with status 1 if any")
+	fmtCmd.Flags().BoolP("diff", "d", false, "Print diffs instead of the formatted diagrams")
+	fmtCmd.Flags().Bool("sort-members", false, "Order class members, fields before methods, alphabetically")
+	fmtCmd.Flags().Bool("sort-nodes", false, "Order classes and flowchart nodes by ID")
+	fmtCmd.Flags().String("config", "", "Configuration file (default: .mm-gen.yaml if present)")
+
+	var diffCmd = &cobra.Command{
+		Use:   "diff [old-file] [new-file] | diff --git [old-rev]..[new-rev] [diagram-type] [path]",
+		Short: "Show the semantic changes between two Mermaid diagrams",
+		Long: `Parse two diagrams of the same kind and report the added, removed and changed nodes,
+edges, classes, members, relations, participants and messages. Elements are matched by ID,
+so reordered statements aren't reported.
+
+With --git the diagrams are generated from a Go or .proto file or a directory as they were at
+two git revisions, e.g. "mm-gen diff --git main..HEAD class internal/service". A missing new
+revision defaults to HEAD, a single revision is compared with the working tree. Class diagrams are generated from the AST unless --mode says
+otherwise, so that the diff only shows changes of the code.
+
+Formats:
+  text    - one change per line (default)
+  json    - the changes as JSON
+  mermaid - the new diagram with additions in green, removals in red and changes in yellow`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			if revRange, _ := cmd.Flags().GetString("git"); revRange != "" {
+				diffRevisions(cmd, revRange, args[0], args[1])
+				return
+			}
+			diffDiagrams(cmd, args[0], args[1])
+		},
+	}
+	diffCmd.Flags().String("format", "text", "Output format: text, json or mermaid")
+	diffCmd.Flags().String("git", "", "Generate both diagrams at a revision range (old..new, or old for the working tree) instead of reading them")
+	diffCmd.Flags().String("root", ".", "Directory the path is relative to with --git")
+	addFilterFlags(diffCmd)
+	diffCmd.Flags().String("mode", "", "Generation mode with --git: llm, ast or hybrid (default: ast for class diagrams, llm otherwise)")
+	addPromptFlags(diffCmd)
+
+	var promptsCmd = &cobra.Command{
+		Use:   "prompts",
+		Short: "List, show and export the prompt templates",
+		Long: `The prompts sent to the LLM are rendered from Go templates built into mm-gen. A directory
+set with --templates, the MM_GEN_TEMPLATES environment variable or prompts.templates in
+.mm-gen.yaml shadows them: each *.tmpl file in it replaces the built-in template of the same
+name. Export the built-in templates to start customizing them.
+
+Few-shot examples of Go code and the expected diagram are read from the directory set with
+--examples or prompts.examplesDir, laid out as <type>/<name>.go and <type>/<name>.mmd, and
+from prompts.examples in .mm-gen.yaml. Prompts get the examples of their diagram type whose
+code is closest in size to the code of the prompt, at most prompts.maxExamples (default 2).`,
+	}
+
+	var promptsListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the prompt templates and where they are read from",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			listPrompts(cmd)
+		},
+	}
+
+	var promptsShowCmd = &cobra.Command{
+		Use:   "show [name]",
+		Short: "Print a prompt template, e.g. class_diagram",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			showPrompt(cmd, args[0])
+		},
+	}
+
+	var promptsExportCmd = &cobra.Command{
+		Use:   "export [dir]",
+		Short: "Write the built-in prompt templates to a directory (default: templates)",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			dir := "templates"
+			if len(args) > 0 {
+				dir = args[0]
+			}
+			exportPrompts(cmd, dir)
+		},
+	}
+	promptsExportCmd.Flags().Bool("force", false, "Replace existing files")
+
+	var promptsExamplesCmd = &cobra.Command{
+		Use:   "examples [diagram-type]",
+		Short: "List the few-shot examples, or those of a diagram type",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			diagramType := ""
+			if len(args) > 0 {
+				diagramType = args[0]
+			}
+			listExamples(cmd, diagramType)
+		},
+	}
+	for _, c := range []*cobra.Command{promptsListCmd, promptsShowCmd, promptsExamplesCmd} {
+		c.Flags().String("config", "", "Configuration file (default: .mm-gen.yaml if present)")
+		addPromptFlags(c)
+	}
+	promptsCmd.AddCommand(promptsListCmd, promptsShowCmd, promptsExportCmd, promptsExamplesCmd)
+
+	var evalCmd = &cobra.Command{
+		Use:   "eval",
+		Short: "Score the prompt templates on a corpus of Go fixtures",
+		Long: `Generate every diagram type for every fixture of a corpus and score the diagrams on syntax
+validity, the number of fix loop iterations needed, and, for class and sequence diagrams, the
+precision and recall of their symbols against the types declared in the code.
+
+Every Go file directly in the corpus is diagrammed on its own and every subdirectory as a whole.
+With --cassette the completions are replayed from a file instead of calling the LLM; with
+--record as well they are requested from the LLM and written to it. With --baseline the
+report shows the changes from an earlier JSON report and the command exits with status 1 if
+a metric regressed.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runEvaluation(cmd)
+		},
+	}
+	evalCmd.Flags().String("corpus", "testdata/eval", "Directory of Go fixtures")
+	evalCmd.Flags().StringSlice("types", eval.DefaultDiagramTypes, "Diagram types to generate")
+	evalCmd.Flags().String("cassette", "", "Replay completions from this file instead of calling the LLM")
+	evalCmd.Flags().Bool("record", false, "Call the LLM and record the completions to --cassette")
+	evalCmd.Flags().String("model", "", "Claude model to evaluate (default: the default model of mm-gen)")
+	evalCmd.Flags().StringP("outDir", "o", "", "Write eval.json and eval.md to this directory instead of printing the report")
+	evalCmd.Flags().String("baseline", "", "JSON report of an earlier evaluation to compare with")
+	evalCmd.Flags().String("config", "", "Configuration file (default: .mm-gen.yaml if present)")
+	addPromptFlags(evalCmd)
+	addUsageFlags(evalCmd)
+
+	rootCmd.AddCommand(fileCmd, componentCmd, targetCmd, focusCmd, mapCmd, validateCmd, lintCmd, lspCmd, fmtCmd, diffCmd, promptsCmd, evalCmd)
+	return rootCmd
+}
+
+// targetFileName turns a target into a file name, e.g. ./internal/service/... into internal_service
+func targetFileName(target string) string {
+	name := strings.Trim(strings.TrimSuffix(target, "..."), "./")
+	return strings.NewReplacer("/", "_", ".", "_").Replace(name)
+}
+
+// addFilterFlags adds the flags selecting the source files found in directories
+func addFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("include", nil, "Only use source files matching these globs (gitignore syntax, ** for any directories)")
+	cmd.Flags().StringSlice("exclude", nil, "Skip source files matching these globs, in addition to "+repository.IgnoreFile)
+	cmd.Flags().Bool("include-tests", false, "Also use _test.go files")
+}
+
+// addPromptFlags adds the flags selecting the directories of prompt templates and few-shot examples
+func addPromptFlags(cmd *cobra.Command) {
+	cmd.Flags().String("templates", "", "Directory of prompt templates shadowing the built-in ones (default: prompts.templates in .mm-gen.yaml)")
+	cmd.Flags().String("examples", "", "Directory of few-shot examples laid out as <type>/<name>.go and <type>/<name>.mmd (default: prompts.examplesDir in .mm-gen.yaml)")
+}
+
+// addUsageFlags adds the flags limiting the LLM calls of a command
+func addUsageFlags(cmd *cobra.Command) {
+	cmd.Flags().Float64("budget-usd", 0, "Abort once the estimated cost of the LLM calls reaches this amount in USD")
+	cmd.Flags().Int("max-calls", 0, "Abort once this number of LLM calls has been made")
+}
+
+// newMeter creates the meter recording the LLM usage of a command, with the limits set by the
+// flags of addUsageFlags. Calls are priced with the model that served them, the first model prices
+// calls that don't report theirs.
+func newMeter(cmd *cobra.Command, models []string) *usage.Meter {
+	budget, _ := cmd.Flags().GetFloat64("budget-usd")
+	maxCalls, _ := cmd.Flags().GetInt("max-calls")
+	meter := usage.NewMeter(models[0], usage.WithBudget(budget), usage.WithMaxCalls(maxCalls))
+	if budget <= 0 {
+		return meter
+	}
+
+	var unpriced []string
+	for _, model := range models {
+		if _, ok := usage.PriceOf(model); !ok {
+			unpriced = append(unpriced, model)
+		}
+	}
+	if len(unpriced) == len(models) {
+		fmt.Fprintf(os.Stderr, "Error: no price known for model %s, --budget-usd can't be enforced\n", strings.Join(unpriced, ", "))
+		os.Exit(1)
+	}
+	if len(unpriced) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: no price known for model %s, its calls don't count towards --budget-usd\n", strings.Join(unpriced, ", "))
+	}
+	return meter
+}
+
+// printUsage prints the LLM usage summary on stderr, if any call was made
+func printUsage(report usage.Report) {
+	if report.Total.Calls > 0 {
+		fmt.Fprintln(os.Stderr, report)
+	}
+}
+
+// loadConfig loads the configuration file set with --config, if the command has the flag, or the
+// default one
+func loadConfig(cmd *cobra.Command) (*config.Config, error) {
+	configPath := ""
+	if cmd.Flags().Lookup("config") != nil {
+		configPath, _ = cmd.Flags().GetString("config")
+	}
+	return config.Load(configPath)
+}
+
+// newLLMAdapter creates the LLM adapter of a command: Claude, or the fallback chain of the
+// providers in the configuration file, retrying rate limited calls and transient errors with the
+// retry policy of the configuration file. It returns the models of the providers for pricing.
+func newLLMAdapter(cmd *cobra.Command) (llm.LLMAdapter, []string, error) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	adapter, models, err := newProviderChain(cfg.LLM)
+	if err != nil {
+		return nil, nil, err
+	}
+	return withRetries(adapter, cfg.LLM.Retry), models, nil
+}
+
+// withRetries wraps the adapter with the retry policy, retries are reported on stderr
+func withRetries(adapter llm.LLMAdapter, retry config.RetryConfig) llm.LLMAdapter {
+	policy := llm.RetryPolicy{Attempts: retry.Attempts, BaseDelay: retry.BaseDelay, MaxDelay: retry.MaxDelay}
+	return llm.NewRetryAdapter(adapter, policy, llm.WithRetryLog(os.Stderr))
+}
+
+// withLimits wraps the adapter with the process-wide limiter of the provider and model
+func withLimits(adapter llm.LLMAdapter, provider, model string, limits config.LimitsConfig) llm.LLMAdapter {
+	limiter := llm.SharedLimiter(provider+":"+model, llm.Limits{
+		RequestsPerMinute: limits.RequestsPerMinute,
+		TokensPerMinute:   limits.TokensPerMinute,
+		MaxConcurrent:     limits.Concurrency,
+	})
+	return limiter.Adapter(adapter)
+}
+
+// newProviderChain creates Claude, or the fallback chain of the configured providers, each
+// within its limits, and returns the models of the providers in order
+func newProviderChain(cfg config.LLMConfig) (llm.LLMAdapter, []string, error) {
+	if len(cfg.Providers) == 0 {
+		adapter, err := llm.NewClaudeAdapter("")
+		if err != nil {
+			return nil, nil, err
+		}
+		return withLimits(adapter, "claude", llm.DefaultModel, cfg.Limits), []string{llm.DefaultModel}, nil
+	}
+
+	// A provider that can't be initialized, e.g. Claude without an API key, is left out of the chain
+	var providers []llm.Provider
+	var errs []error
+	var models []string
+	for _, provider := range cfg.Providers {
+		adapter, err := llm.NewProviderAdapter(provider.Type, provider.Model, provider.URL)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Type, err))
+			continue
+		}
+		providerModel := provider.Model
+		if providerModel == "" && provider.Type == "claude" {
+			providerModel = llm.DefaultModel
+		}
+		if providerModel == "" {
+			providerModel = llm.DefaultOllamaModel
+		}
+		models = append(models, providerModel)
+
+		limits := cfg.Limits
+		if provider.Limits != nil {
+			limits = *provider.Limits
+		}
+		providers = append(providers, llm.Provider{Name: provider.Type, Adapter: withLimits(adapter, provider.Type, providerModel, limits)})
+	}
+	if len(providers) == 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "Warning: LLM provider skipped: %v\n", err)
+	}
+
+	breaker := cfg.Breaker
+	return llm.NewFallbackAdapter(providers, llm.WithBreaker(breaker.Failures, breaker.Cooldown), llm.WithFailoverLog(os.Stderr)), models, nil
+}
+
+// loadPrompts loads the prompt templates, shadowed by the directory set with --templates, in the
+// configuration file or in MM_GEN_TEMPLATES, and the few-shot examples. The services are given
+// the returned manager so that they all use the same templates.
+func loadPrompts(cmd *cobra.Command) (*prompt.TemplateManager, error) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := cmd.Flags().GetString("templates")
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		dir = cfg.Prompts.Templates
+	}
+	if dir == "" {
+		dir = os.Getenv(prompt.EnvTemplatesDir)
+	}
+
+	promptMgr, err := prompt.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Examples of the directory come before the ones in the configuration file
+	examples := cfg.Prompts.Examples
+	examplesDir, err := cmd.Flags().GetString("examples")
+	if err != nil {
+		return nil, err
+	}
+	if examplesDir == "" {
+		examplesDir = cfg.Prompts.ExamplesDir
+	}
+	if examplesDir != "" {
+		dirExamples, err := prompt.LoadExamples(examplesDir)
+		if err != nil {
+			return nil, err
+		}
+		examples = append(dirExamples, examples...)
+	}
+	promptMgr.UseExamples(examples, cfg.Prompts.MaxExamples)
+
+	return promptMgr, nil
+}
+
+// usePrompts loads the prompt templates for the services and exits on errors, so that broken
+// templates aren't silently replaced by the default prompts
+func usePrompts(cmd *cobra.Command) *prompt.TemplateManager {
+	promptMgr, err := loadPrompts(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return promptMgr
+}
+
+func listPrompts(cmd *cobra.Command) {
+	promptMgr, err := loadPrompts(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, name := range promptMgr.Names() {
+		source := "built-in"
+		if file := promptMgr.Source(name); file != "" {
+			source = file
+		}
+		fmt.Printf("%-24s %s\n", strings.TrimSuffix(name, ".tmpl"), source)
+	}
+}
+
+func showPrompt(cmd *cobra.Command, name string) {
+	promptMgr, err := loadPrompts(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	text, err := promptMgr.Text(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Print(text)
+}
+
+func runEvaluation(cmd *cobra.Command) {
+	corpus, _ := cmd.Flags().GetString("corpus")
+	diagramTypes, _ := cmd.Flags().GetStringSlice("types")
+	cassettePath, _ := cmd.Flags().GetString("cassette")
+	record, _ := cmd.Flags().GetBool("record")
+	model, _ := cmd.Flags().GetString("model")
+	outDir, _ := cmd.Flags().GetString("outDir")
+	baselinePath, _ := cmd.Flags().GetString("baseline")
+
+	if model == "" {
+		model = llm.DefaultModel
+	}
+	if record && cassettePath == "" {
+		fmt.Fprintf(os.Stderr, "Error: --record needs --cassette\n")
+		os.Exit(1)
+	}
+	promptMgr := usePrompts(cmd)
+
+	var baseline *eval.Report
+	if baselinePath != "" {
+		var err error
+		if baseline, err = eval.LoadReport(baselinePath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Replaying only needs the cassette, recording starts a new one
+	var adapter llm.LLMAdapter
+	var cassette *eval.Cassette
+	if cassettePath != "" && !record {
+		var err error
+		if cassette, err = eval.LoadCassette(cassettePath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		adapter = cassette.Replayer()
+		model = cassette.Model
+	} else {
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		claudeAdapter, err := llm.NewClaudeAdapter(model)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error initializing LLM: %v\n", err)
+			os.Exit(1)
+		}
+		adapter = withRetries(withLimits(claudeAdapter, "claude", model, cfg.LLM.Limits), cfg.LLM.Retry)
+		if record {
+			cassette = &eval.Cassette{Model: model}
+			adapter = cassette.Recorder(adapter)
+		}
+	}
+
+	// Replayed completions have no token counts, their usage is estimated
+	meter := newMeter(cmd, []string{model})
+	adapter = meter.Adapter(adapter)
+
+	// Progress and the messages of the services go to stderr, the report to stdout
+	evaluator := eval.NewEvaluator(corpus, adapter, eval.WithDiagramTypes(diagramTypes), eval.WithPrompts(promptMgr), eval.WithProgress(os.Stderr))
+	report, err := evaluator.Run(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	report.Model = model
+	usageReport := meter.Report()
+	report.Usage = &usageReport
+	printUsage(usageReport)
+	if err := meter.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if record {
+		if err := cassette.Save(cassettePath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Recorded %d completions to %s\n", len(cassette.Interactions), cassettePath)
+	}
+
+	markdown := report.Markdown(baseline)
+	if outDir == "" {
+		fmt.Print(markdown)
+	} else {
+		content, err := report.JSON()
+		if err == nil {
+			err = os.MkdirAll(outDir, 0755)
+		}
+		if err == nil {
+			err = os.WriteFile(filepath.Join(outDir, "eval.json"), content, 0644)
+		}
+		if err == nil {
+			err = os.WriteFile(filepath.Join(outDir, "eval.md"), []byte(markdown), 0644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing report: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Report written to %s\n", outDir)
+	}
+
+	if baseline != nil {
+		if regressions := report.Regressions(baseline); len(regressions) > 0 {
+			fmt.Fprintf(os.Stderr, "Regressions from %s:\n  %s\n", baselinePath, strings.Join(regressions, "\n  "))
+			os.Exit(1)
+		}
+	}
+}
+
+func listExamples(cmd *cobra.Command, diagramType string) {
+	promptMgr, err := loadPrompts(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, example := range promptMgr.Examples() {
+		if diagramType != "" && example.DiagramType != diagramType {
+			continue
+		}
+		fmt.Printf("%-12s %-24s %6d chars  %s\n", example.DiagramType, example.Name, len(example.Code)+len(example.Diagram), example.Source)
+	}
+}
+
+func exportPrompts(cmd *cobra.Command, dir string) {
+	force, _ := cmd.Flags().GetBool("force")
+	written, err := prompt.Export(dir, force)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+
+	for _, file := range written {
+		fmt.Println(file)
+	}
+	fmt.Printf("Exported %d templates, use them with --templates %s\n", len(written), dir)
 }
 
-func generateAndPrintDiagram(diagramType, filePath, target, outDir string, svgFormat bool, splitOutput bool, rendererType string) {
+// sourceFilter returns the filter set by the flags of addFilterFlags
+func sourceFilter(cmd *cobra.Command) repository.Filter {
+	var filter repository.Filter
+	filter.Include, _ = cmd.Flags().GetStringSlice("include")
+	filter.Exclude, _ = cmd.Flags().GetStringSlice("exclude")
+	filter.IncludeTests, _ = cmd.Flags().GetBool("include-tests")
+	return filter
+}
+
+func generateAndPrintDiagram(cmd *cobra.Command, diagramType, filePath, target, outDir string, svgFormat bool, splitOutput bool, rendererType string) {
+	promptMgr := usePrompts(cmd)
+
 	// Initialize the file repository
-	fileRepoForDiagram := repository.NewFileRepository()
+	root, _ := cmd.Flags().GetString("root")
+	fileRepoForDiagram := repository.NewFileRepository(root, repository.WithFilter(sourceFilter(cmd)))
 
-	// Initialize LLM adapter
-	llmAdapter, err := llm.NewClaudeAdapter("")
+	modeName, _ := cmd.Flags().GetString("mode")
+	mode, err := service.ParseGenerationMode(modeName)
 	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Initialize LLM adapter, static diagram types and AST mode are rendered without one
+	llmAdapter, models, err := newLLMAdapter(cmd)
+	if err != nil && service.RequiresLLM(diagramType, filePath) && mode != service.ModeAST {
 		fmt.Fprintf(os.Stderr, "Error initializing LLM: %v\n", err)
 		os.Exit(1)
 	}
+	if len(models) == 0 {
+		// No calls are made without an LLM
+		models = []string{""}
+	}
+
+	// Record the usage of LLM calls and stop them at the limits
+	meter := newMeter(cmd, models)
+	if llmAdapter != nil {
+		llmAdapter = meter.Adapter(llmAdapter)
+	}
+
+	// Show the progress of LLM calls on stderr, stdout only gets the diagram
+	var display *progress.Display
+	if showProgress, _ := cmd.Flags().GetBool("progress"); showProgress && llmAdapter != nil {
+		display = progress.New(os.Stderr)
+		llmAdapter = display.Adapter(llmAdapter)
+	}
+
+	opts := []service.Option{service.WithMode(mode), service.WithPackageDir(root), service.WithPrompts(promptMgr)}
+	if notes, _ := cmd.Flags().GetString("notes"); notes != "" {
+		opts = append(opts, service.WithNotes(notes))
+	}
+	if structured, _ := cmd.Flags().GetBool("structured"); structured {
+		opts = append(opts, service.WithStructuredOutput())
+	}
+	verify, _ := cmd.Flags().GetBool("verify")
+	strict, _ := cmd.Flags().GetBool("strict")
+	if verify || strict {
+		opts = append(opts, service.WithVerification(strict))
+	}
 
 	// Initialize diagram generation service
-	diagramService := service.NewDiagramService(fileRepoForDiagram, llmAdapter)
+	diagramService := service.NewDiagramService(fileRepoForDiagram, llmAdapter, opts...)
 
 	// Initialize output service components
 	diagramProcessor := diagram.NewProcessor()
@@ -173,28 +868,69 @@ func generateAndPrintDiagram(diagramType, filePath, target, outDir string, svgFo
 
 	fileOutputRepo := fileOutputRepo.NewOutputRepository()
 
-	// Initialize output service
-	outputService := diagram.NewOutputService(diagramProcessor, svgRenderer, fileOutputRepo)
+	cfg, err := config.Load("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Initialize output service, saved diagrams are formatted
+	outputService := diagram.NewOutputService(diagramProcessor, svgRenderer, fileOutputRepo, cfg.FormatOptions())
 
 	// Generate diagram
 	ctx := context.Background()
-	var diagramContent string
+	var diagramContent, table string
+	markdown, _ := cmd.Flags().GetBool("markdown")
 
 	if filePath != "" {
 		// Generate diagram for a single file
 		diagramContent, err = diagramService.GenerateDiagram(ctx, filePath, diagramType)
+	} else if target == "map" && markdown && diagramType == "implements" {
+		// Generate the implementation matrix as a diagram and a Markdown table
+		diagramContent, table, err = diagramService.GenerateImplementsMatrix(ctx)
 	} else if target == "map" {
 		// Generate project-wide diagram
 		diagramContent, err = diagramService.GenerateProjectDiagram(ctx, diagramType)
+	} else if cmd.Name() == "target" {
+		// Generate diagram for packages or a symbol
+		diagramContent, err = diagramService.GenerateTargetDiagram(ctx, target, diagramType)
+	} else if cmd.Name() == "focus" {
+		// Generate diagram of the types around a symbol
+		depth, _ := cmd.Flags().GetInt("depth")
+		direction, _ := cmd.Flags().GetString("direction")
+		diagramContent, err = diagramService.GenerateFocusDiagram(ctx, target, depth, direction, diagramType)
 	} else {
 		// Generate component diagram
 		diagramContent, err = diagramService.GenerateComponentDiagram(ctx, target, diagramType)
 	}
+	if display != nil {
+		display.Stop()
+	}
 
+	// A run stopped by the budget or call limit is aborted, its diagram may be incomplete
+	usageReport := meter.Report()
+	asJSON, _ := cmd.Flags().GetBool("json")
+	if err == nil {
+		err = meter.Err()
+	}
+	if err != nil || !asJSON {
+		printUsage(usageReport)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	output := generationOutput{Usage: usageReport}
+	if table != "" {
+		if outDir != "" {
+			if err := fileOutputRepo.SaveDiagramFile(outDir, "project_implements", table, "md"); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving Markdown table: %v\n", err)
+				os.Exit(1)
+			}
+		} else {
+			output.Table = table
+		}
+	}
 
 	// If this is a project map and split is requested, handle separately
 	if target == "map" && splitOutput && outDir != "" {
@@ -202,6 +938,9 @@ func generateAndPrintDiagram(diagramType, filePath, target, outDir string, svgFo
 			fmt.Fprintf(os.Stderr, "Error splitting diagram: %v\n", err)
 			os.Exit(1)
 		}
+		if asJSON {
+			printJSON(output)
+		}
 		return
 	}
 
@@ -214,37 +953,344 @@ func generateAndPrintDiagram(diagramType, filePath, target, outDir string, svgFo
 			filename = fmt.Sprintf("%s_%s", baseName, diagramType)
 		} else if target == "map" {
 			filename = fmt.Sprintf("project_%s", diagramType)
+		} else if cmd.Name() == "target" {
+			filename = fmt.Sprintf("target_%s_%s", targetFileName(target), diagramType)
+		} else if cmd.Name() == "focus" {
+			filename = fmt.Sprintf("focus_%s_%s", targetFileName(target), diagramType)
 		} else {
 			filename = fmt.Sprintf("component_%s", diagramType)
 		}
 
-		// Clean the diagram content
-		cleanedContent := diagramProcessor.CleanDiagramOutput(diagramContent)
+		// Save the formatted diagram, with an SVG rendering if requested
+		if err := outputService.SaveDiagram(filename, outDir, diagramContent, svgFormat); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving diagram: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		output.Diagram = diagramProcessor.CleanDiagramOutput(diagramContent)
+	}
+
+	if asJSON {
+		printJSON(output)
+		return
+	}
+	if output.Diagram != "" {
+		// Print diagram to stdout
+		fmt.Println(output.Diagram)
+	}
+	if output.Table != "" {
+		fmt.Println()
+		fmt.Println(output.Table)
+	}
+}
+
+// generationOutput is the JSON output of the generation commands, the diagram and the Markdown
+// table of "map implements --markdown" are left out when they're saved to files
+type generationOutput struct {
+	Diagram string       `json:"diagram,omitempty"`
+	Table   string       `json:"table,omitempty"`
+	Usage   usage.Report `json:"usage"`
+}
+
+// printJSON prints a value as indented JSON
+func printJSON(v any) {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(out))
+}
+
+// lintDiagrams lints Mermaid files, or stdin without files, and exits with status 1 when a
+// rule reports an error
+func lintDiagrams(cmd *cobra.Command, files []string) {
+	if listRules, _ := cmd.Flags().GetBool("rules"); listRules {
+		for _, rule := range mermaid.LintRules {
+			fmt.Printf("%-24s %-8s %s\n", rule.Name, rule.Severity, rule.Description)
+		}
+		return
+	}
+
+	configPath, _ := cmd.Flags().GetString("config")
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	lintCfg, err := cfg.LintConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
-		// Save diagram
-		if svgFormat {
-			// Convert to SVG
-			svgContent, err := svgRenderer.ConvertToSVG(cleanedContent)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error converting to SVG: %v\n", err)
-				os.Exit(1)
-			}
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
 
-			// Save both MMD and SVG files
-			if err := fileOutputRepo.SaveDiagramFiles(outDir, filename, cleanedContent, svgContent); err != nil {
-				fmt.Fprintf(os.Stderr, "Error saving diagram files: %v\n", err)
-				os.Exit(1)
+	results := make(map[string][]mermaid.LintIssue)
+	failed := false
+	for _, file := range files {
+		var content []byte
+		if file == "-" {
+			content, err = io.ReadAll(os.Stdin)
+		} else {
+			content, err = os.ReadFile(file)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", file, err)
+			os.Exit(1)
+		}
+
+		issues, err := mermaid.Lint(string(content), lintCfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing %s: %v\n", file, err)
+			os.Exit(1)
+		}
+		results[file] = issues
+		failed = failed || mermaid.HasErrors(issues)
+	}
+
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		out, _ := json.MarshalIndent(results, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		for _, file := range files {
+			if len(results[file]) > 0 {
+				fmt.Println(mermaid.FormatLintIssues(file, results[file]))
 			}
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func formatDiagrams(cmd *cobra.Command, files []string) {
+	write, _ := cmd.Flags().GetBool("write")
+	check, _ := cmd.Flags().GetBool("check")
+	showDiff, _ := cmd.Flags().GetBool("diff")
+
+	configPath, _ := cmd.Flags().GetString("config")
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	opts := cfg.FormatOptions()
+	if cmd.Flags().Changed("sort-members") {
+		opts.SortMembers, _ = cmd.Flags().GetBool("sort-members")
+	}
+	if cmd.Flags().Changed("sort-nodes") {
+		opts.SortNodes, _ = cmd.Flags().GetBool("sort-nodes")
+	}
+
+	if len(files) == 0 {
+		if write {
+			fmt.Fprintln(os.Stderr, "Error: --write needs files to write to")
+			os.Exit(1)
+		}
+		files = []string{"-"}
+	}
+
+	unformatted := false
+	for _, file := range files {
+		var content []byte
+		if file == "-" {
+			content, err = io.ReadAll(os.Stdin)
 		} else {
-			// Save just the MMD file
-			if err := fileOutputRepo.SaveDiagramFile(outDir, filename, cleanedContent, "mmd"); err != nil {
-				fmt.Fprintf(os.Stderr, "Error saving diagram file: %v\n", err)
+			content, err = os.ReadFile(file)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", file, err)
+			os.Exit(1)
+		}
+
+		formatted, err := mermaid.Format(string(content), opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing %s: %v\n", file, err)
+			os.Exit(1)
+		}
+		changed := formatted != string(content)
+		unformatted = unformatted || changed
+
+		switch {
+		case showDiff:
+			fmt.Print(textdiff.Unified(file, file+" (formatted)", string(content), formatted))
+		case check:
+			if changed {
+				fmt.Println(file)
+			}
+		case write:
+			if changed {
+				if err := os.WriteFile(file, []byte(formatted), 0644); err != nil {
+					fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", file, err)
+					os.Exit(1)
+				}
+			}
+		default:
+			fmt.Print(formatted)
+		}
+	}
+
+	if check && unformatted {
+		os.Exit(1)
+	}
+}
+
+func diffDiagrams(cmd *cobra.Command, oldFile, newFile string) {
+	format, _ := cmd.Flags().GetString("format")
+
+	var diagrams []*mermaid.Diagram
+	for _, file := range []string{oldFile, newFile} {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", file, err)
+			os.Exit(1)
+		}
+		d, err := mermaid.Parse(string(content))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing %s: %v\n", file, err)
+			os.Exit(1)
+		}
+		diagrams = append(diagrams, d)
+	}
+
+	printDiagramDiff(diagrams[0], diagrams[1], format)
+}
+
+// diffRevisions generates the same diagram at two git revisions and prints their semantic diff
+func diffRevisions(cmd *cobra.Command, revRange, diagramType, path string) {
+	promptMgr := usePrompts(cmd)
+	format, _ := cmd.Flags().GetString("format")
+
+	// A single revision is compared with the working tree
+	oldRev, newRev, isRange := strings.Cut(revRange, "..")
+	if oldRev == "" {
+		fmt.Fprintf(os.Stderr, "Error: invalid revision range: %s (should be 'old..new' or 'old')\n", revRange)
+		os.Exit(1)
+	}
+	if isRange && newRev == "" {
+		newRev = "HEAD"
+	}
+
+	// Diagrams generated by the LLM differ between runs, the AST only changes with the code
+	mode := service.ModeLLM
+	if diagramType == "class" {
+		mode = service.ModeAST
+	}
+	if modeName, _ := cmd.Flags().GetString("mode"); modeName != "" {
+		var err error
+		if mode, err = service.ParseGenerationMode(modeName); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	llmAdapter, _, err := newLLMAdapter(cmd)
+	if err != nil && service.RequiresLLM(diagramType, path) && mode != service.ModeAST {
+		fmt.Fprintf(os.Stderr, "Error initializing LLM: %v\n", err)
+		os.Exit(1)
+	}
+
+	root, _ := cmd.Flags().GetString("root")
+	ctx := context.Background()
+	processor := diagram.NewProcessor()
+
+	var diagrams []*mermaid.Diagram
+	for _, rev := range []string{oldRev, newRev} {
+		filter := repository.WithFilter(sourceFilter(cmd))
+		fileRepo := repository.NewFileRepository(root, filter)
+		if rev != "" {
+			if fileRepo, err = repository.NewGitFileRepository(root, rev, filter); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				os.Exit(1)
 			}
 		}
+		diagramService := service.NewDiagramService(fileRepo, llmAdapter, service.WithMode(mode), service.WithPrompts(promptMgr))
+
+		var content string
+		if repository.IsSourceFile(path) {
+			content, err = diagramService.GenerateDiagram(ctx, path, diagramType)
+		} else {
+			content, err = diagramService.GenerateDirectoryDiagram(ctx, path, diagramType)
+		}
+		if rev == "" {
+			rev = "the working tree"
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating diagram at %s: %v\n", rev, err)
+			os.Exit(1)
+		}
+
+		d, err := mermaid.Parse(processor.CleanDiagramOutput(content))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing diagram at %s: %v\n", rev, err)
+			os.Exit(1)
+		}
+		diagrams = append(diagrams, d)
+	}
+
+	printDiagramDiff(diagrams[0], diagrams[1], format)
+}
+
+// printDiagramDiff prints the semantic diff of two diagrams in the given format
+func printDiagramDiff(oldDiagram, newDiagram *mermaid.Diagram, format string) {
+	dd, err := mermaid.Diff(oldDiagram, newDiagram)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch format {
+	case "text":
+		fmt.Println(dd.String())
+	case "json":
+		out, _ := json.MarshalIndent(dd, "", "  ")
+		fmt.Println(string(out))
+	case "mermaid":
+		d, err := dd.Diagram()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(d.String())
+	default:
+		fmt.Fprintf(os.Stderr, "Error: invalid format: %s (should be 'text', 'json' or 'mermaid')\n", format)
+		os.Exit(1)
+	}
+}
+
+func runLanguageServer(cmd *cobra.Command) {
+	configPath, _ := cmd.Flags().GetString("config")
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	lintCfg, err := cfg.LintConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	promptMgr := usePrompts(cmd)
+	logger := log.New(os.Stderr, "mm-gen lsp: ", log.LstdFlags)
+
+	// Fixing with the LLM is optional, the automatic fixes work without it
+	var llmClient pkgllm.Client
+	if llmAdapter, _, err := newLLMAdapter(cmd); err != nil {
+		logger.Printf("LLM not available, only automatic fixes are offered: %v", err)
 	} else {
-		// Print diagram to stdout
-		fmt.Println(diagramProcessor.CleanDiagramOutput(diagramContent))
+		llmClient = llm.NewClientAdapter(llmAdapter)
+	}
+
+	// Stdout carries the protocol, progress printed by the services goes to stderr
+	server := lsp.NewServer(llmClient, promptMgr, lintCfg, cfg.FormatOptions(), logger)
+	if err := server.Run(context.Background(), os.Stdin, os.Stdout); err != nil {
+		logger.Printf("%v", err)
+		os.Exit(1)
 	}
 }
 
@@ -255,6 +1301,7 @@ func validateDiagram(diagram string, cmd *cobra.Command) {
 	fixFlag, _ := cmd.Flags().GetBool("fix")
 	verboseFlag, _ := cmd.Flags().GetBool("verbose")
 	retriesFlag, _ := cmd.Flags().GetInt("retries")
+	promptMgr := usePrompts(cmd)
 
 	// If retries flag is set, use it to override the environment variable
 	if retriesFlag > 0 {
@@ -264,21 +1311,33 @@ func validateDiagram(diagram string, cmd *cobra.Command) {
 		}
 	}
 
-	// Initialize Claude adapter if we need to fix or explain errors
-	var llmClient pkgllm.Client
+	// Initialize the LLM adapter if we need to fix or explain errors
+	var llmAdapter llm.LLMAdapter
+	models := []string{llm.DefaultModel}
 	if explainFlag || fixFlag {
-		claudeAdapter, err := llm.NewClaudeAdapter("")
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error initializing Claude LLM: %v\n", err)
+		adapter, adapterModels, err := newLLMAdapter(cmd)
+		if err != nil && explainFlag {
+			fmt.Fprintf(os.Stderr, "Error initializing LLM: %v\n", err)
 			os.Exit(1)
 		}
 
-		// Create a client adapter that bridges LLMAdapter and Client interfaces
-		llmClient = llm.NewClientAdapter(claudeAdapter)
+		if err != nil {
+			// Fixing can still apply the automatic fixes
+			fmt.Fprintf(os.Stderr, "Warning: LLM not available, only automatic fixes will be applied: %v\n", err)
+		} else {
+			llmAdapter, models = adapter, adapterModels
+		}
+	}
+
+	// Create a client adapter that bridges LLMAdapter and Client interfaces
+	var llmClient pkgllm.Client
+	meter := newMeter(cmd, models)
+	if llmAdapter != nil {
+		llmClient = llm.NewClientAdapter(meter.Adapter(llmAdapter))
 	}
 
 	// Create validation service
-	validationService := service.NewValidationService(llmClient)
+	validationService := service.NewValidationService(llmClient, service.WithValidationPrompts(promptMgr))
 
 	// Validate the diagram
 	validationResult, validationErr := validationService.ValidateMermaidDiagram(diagram)
@@ -337,6 +1396,13 @@ func validateDiagram(diagram string, cmd *cobra.Command) {
 		}
 	}
 
+	// A fix stopped by the budget or call limit is reported like an aborted generation
+	printUsage(meter.Report())
+	if err := meter.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Exit with non-zero code if the diagram is invalid
 	if !validationResult.IsValid {
 		os.Exit(1)
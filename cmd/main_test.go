@@ -0,0 +1,199 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/suite"
+)
+
+//go:embed testdata/user.go.tmpl
+var userSource string
+
+// userTemplate renders the user.go file of the project, in the variant set by userVariant
+var userTemplate = template.Must(template.New("user.go").Parse(userSource))
+
+// userVariant selects the declarations of user.go
+type userVariant struct {
+	// Find adds the UserService.Find method
+	Find bool
+	// Store adds the Store type and the UserService field referencing it
+	Store bool
+}
+
+// MainTestSuite is a test suite for the mm-gen commands against a fake Ollama server
+type MainTestSuite struct {
+	suite.Suite
+	server *httptest.Server
+	wd     string
+	// completion is returned for every chat request
+	completion string
+}
+
+// SetupTest creates a project configured with the fake Ollama server and makes it the working directory
+func (s *MainTestSuite) SetupTest() {
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal("/api/chat", r.URL.Path)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"model":             "llama3.1",
+			"message":           map[string]string{"role": "assistant", "content": s.completion},
+			"done":              true,
+			"prompt_eval_count": 120,
+			"eval_count":        40,
+		})
+	}))
+
+	dir := s.T().TempDir()
+	config := "llm:\n  providers:\n    - type: ollama\n      model: llama3.1\n      url: " + s.server.URL + "\n"
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, ".mm-gen.yaml"), []byte(config), 0644))
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/users\n\ngo 1.22\n"), 0644))
+
+	wd, err := os.Getwd()
+	s.Require().NoError(err)
+	s.wd = wd
+	s.Require().NoError(os.Chdir(dir))
+	s.writeUsers(userVariant{Find: true})
+}
+
+// writeUsers writes the variant of user.go to the project
+func (s *MainTestSuite) writeUsers(variant userVariant) {
+	f, err := os.Create("user.go")
+	s.Require().NoError(err)
+	defer f.Close()
+	s.Require().NoError(userTemplate.Execute(f, variant))
+}
+
+// TearDownTest restores the working directory and stops the server
+func (s *MainTestSuite) TearDownTest() {
+	s.Require().NoError(os.Chdir(s.wd))
+	s.server.Close()
+}
+
+// run runs mm-gen with the arguments and returns what it wrote to stdout and stderr
+func (s *MainTestSuite) run(args ...string) (string, string) {
+	stdout, stderr := os.Stdout, os.Stderr
+	defer func() { os.Stdout, os.Stderr = stdout, stderr }()
+
+	capture := func(f **os.File) <-chan string {
+		r, w, err := os.Pipe()
+		s.Require().NoError(err)
+		*f = w
+		out := make(chan string)
+		go func() {
+			content, _ := io.ReadAll(r)
+			out <- string(content)
+		}()
+		return out
+	}
+	outCh, errCh := capture(&os.Stdout), capture(&os.Stderr)
+
+	cmd := newRootCmd()
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	os.Stdout.Close()
+	os.Stderr.Close()
+	s.Require().NoError(err)
+	return <-outCh, <-errCh
+}
+
+// TestJSONOutput checks that a JSON run prints only the JSON document on stdout, while the fix
+// and verification messages of the services go to stderr
+func (s *MainTestSuite) TestJSONOutput() {
+	s.completion = "```mermaid\nclassDiagram\n  class UserService {\n    +Find(id string) (string, error)\n  }\n  class Ghost\n```"
+
+	stdout, stderr := s.run("file", "class", "user.go", "--json", "--verify", "--progress=false")
+
+	var output generationOutput
+	s.Require().NoError(json.Unmarshal([]byte(stdout), &output), stdout)
+	s.Contains(output.Diagram, "class UserService")
+	s.Equal("llama3.1", output.Usage.Model)
+	s.Equal([]string{"llama3.1"}, output.Usage.Models)
+	s.Equal(1, output.Usage.Total.Calls)
+	s.Equal(120, output.Usage.Total.InputTokens)
+
+	s.Contains(stderr, "has syntax errors, attempting to fix...")
+	s.Contains(stderr, "Ghost")
+}
+
+// TestImplementsMarkdown checks that the implements map prints the diagram and the matrix as
+// Markdown without calling the LLM
+func (s *MainTestSuite) TestImplementsMarkdown() {
+	s.Require().NoError(os.WriteFile("store.go", []byte(`package users
+
+// Finder finds users
+type Finder interface {
+	Find(id string) (string, error)
+}
+`), 0644))
+
+	stdout, _ := s.run("map", "implements", "--markdown", "--json", "--progress=false")
+
+	var output generationOutput
+	s.Require().NoError(json.Unmarshal([]byte(stdout), &output), stdout)
+	s.Contains(output.Diagram, "UserService ..|> Finder")
+	s.Contains(output.Table, "| `Finder` | `example.com/users` | `*users.UserService` |")
+	s.Zero(output.Usage.Total.Calls)
+}
+
+// TestExamplesFlag checks that the few-shot examples are read from the directory set with --examples
+func (s *MainTestSuite) TestExamplesFlag() {
+	s.Require().NoError(os.MkdirAll(filepath.Join("house", "class"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join("house", "class", "store.go"), []byte("package store\n\ntype Store struct{}\n"), 0644))
+	s.Require().NoError(os.WriteFile(filepath.Join("house", "class", "store.mmd"), []byte("classDiagram\n  class Store\n"), 0644))
+
+	stdout, _ := s.run("prompts", "examples", "class", "--examples", "house")
+	s.Contains(stdout, "class        store")
+	s.Contains(stdout, filepath.Join("house", "class", "store.go"))
+}
+
+// git runs a git command in the project
+func (s *MainTestSuite) git(args ...string) {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	s.Require().NoError(err, string(out))
+}
+
+// TestDiffRevisions checks that diagrams generated at two revisions, or at a revision and the
+// working tree, are compared from the files of each revision without an LLM call
+func (s *MainTestSuite) TestDiffRevisions() {
+	if _, err := exec.LookPath("git"); err != nil {
+		s.T().Skip("git is not installed")
+	}
+	s.git("init", "-q")
+	s.git("add", "-A")
+	s.git("commit", "-q", "-m", "first")
+
+	s.writeUsers(userVariant{Find: true, Store: true})
+	s.git("commit", "-q", "-am", "second")
+
+	// Uncommitted changes only show up against the working tree
+	s.writeUsers(userVariant{})
+
+	stdout, _ := s.run("diff", "--git", "HEAD~1..", "class", "user.go")
+	s.Equal(`3 added, 0 removed, 0 changed
++ member UserService.store *Store: -store *Store
++ class Store
++ relation UserService -> Store: UserService --> Store
+`, stdout)
+
+	stdout, _ = s.run("diff", "--git", "HEAD", "class", ".")
+	s.Equal(`0 added, 4 removed, 0 changed
+- member UserService.Find(): +Find(id string) (string, error)
+- member UserService.store *Store: -store *Store
+- class Store
+- relation UserService -> Store: UserService --> Store
+`, stdout)
+}
+
+// TestMainTestSuite runs the command test suite
+func TestMainTestSuite(t *testing.T) {
+	suite.Run(t, new(MainTestSuite))
+}
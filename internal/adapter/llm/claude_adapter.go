@@ -2,17 +2,45 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/llms/anthropic"
 )
 
+// DefaultModel is the Claude model used when none is given
+const DefaultModel = "claude-3-7-sonnet-20250219"
+
 // LLMAdapter defines the interface for LLM interactions
 type LLMAdapter interface {
 	GenerateCompletion(ctx context.Context, prompt string) (string, error)
 }
 
+// Schema describes the JSON document a structured completion returns
+type Schema struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the document
+	Parameters json.RawMessage
+}
+
+// StructuredAdapter is implemented by adapters that can constrain a completion to a JSON schema,
+// e.g. with tool use. Other adapters are asked for the JSON document in the prompt.
+type StructuredAdapter interface {
+	GenerateStructured(ctx context.Context, prompt string, schema Schema) (string, error)
+}
+
+// GenerateStructured generates a completion constrained to the schema where the adapter supports
+// it, otherwise the prompt has to ask for the JSON document
+func GenerateStructured(ctx context.Context, adapter LLMAdapter, prompt string, schema Schema) (string, error) {
+	if structured, ok := adapter.(StructuredAdapter); ok {
+		return structured.GenerateStructured(ctx, prompt, schema)
+	}
+	return adapter.GenerateCompletion(ctx, prompt)
+}
+
 // claudeAdapter implements LLMAdapter for Claude
 type claudeAdapter struct {
 	model string
@@ -22,11 +50,12 @@ type claudeAdapter struct {
 // NewClaudeAdapter creates a new Claude adapter
 func NewClaudeAdapter(model string) (LLMAdapter, error) {
 	if model == "" {
-		model = "claude-3-7-sonnet-20250219" // Default to Claude 3.7 Sonnet
+		model = DefaultModel
 	}
 
 	llm, err := anthropic.New(
 		anthropic.WithModel(model),
+		anthropic.WithHTTPClient(newHTTPClient()),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize Claude: %w", err)
@@ -40,10 +69,66 @@ func NewClaudeAdapter(model string) (LLMAdapter, error) {
 
 // GenerateCompletion generates a completion from Claude
 func (a *claudeAdapter) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
-	completion, err := llms.GenerateFromSinglePrompt(ctx, a.llm, prompt)
+	return a.generate(ctx, prompt)
+}
+
+// generate returns the text completion of a single prompt
+func (a *claudeAdapter) generate(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	resp, err := a.send(ctx, prompt, options...)
+	if err != nil {
+		return "", err
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("error generating completion: empty response")
+	}
+	return resp.Choices[0].Content, nil
+}
+
+// send sends a single prompt to Claude, reports the token usage of the call and returns typed
+// errors for failed calls and refusals
+func (a *claudeAdapter) send(ctx context.Context, prompt string, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	ctx, response := recordResponse(ctx)
+	resp, err := a.llm.GenerateContent(ctx, []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, prompt)}, options...)
+	if err != nil {
+		return nil, response.typeError(fmt.Errorf("error generating completion: %w", err))
+	}
+	reportResponseUsage(ctx, a.model, resp)
+
+	for _, choice := range resp.Choices {
+		if choice.StopReason == "refusal" {
+			return nil, &Error{Kind: ErrContentFiltered, Err: fmt.Errorf("error generating completion: Claude refused to answer")}
+		}
+	}
+	return resp, nil
+}
+
+// GenerateStructured asks Claude to answer with a call of a tool taking the schema as input, and
+// returns the tool input. A text answer is returned as is for the caller to extract the JSON from.
+func (a *claudeAdapter) GenerateStructured(ctx context.Context, prompt string, schema Schema) (string, error) {
+	tool := llms.Tool{
+		Type: "function",
+		Function: &llms.FunctionDefinition{
+			Name:        schema.Name,
+			Description: schema.Description,
+			Parameters:  schema.Parameters,
+		},
+	}
+	resp, err := a.send(ctx, prompt, llms.WithTools([]llms.Tool{tool}))
 	if err != nil {
-		return "", fmt.Errorf("error generating completion: %w", err)
+		return "", err
 	}
 
-	return completion, nil
+	var text []string
+	for _, choice := range resp.Choices {
+		for _, call := range choice.ToolCalls {
+			if call.FunctionCall != nil && call.FunctionCall.Name == schema.Name {
+				return call.FunctionCall.Arguments, nil
+			}
+		}
+		if choice.Content != "" {
+			text = append(text, choice.Content)
+		}
+	}
+	return strings.Join(text, "\n"), nil
 }
@@ -0,0 +1,173 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of provider errors, matched with errors.Is
+var (
+	// ErrRateLimited is a rate limit of the provider, the Error may tell when to retry
+	ErrRateLimited = errors.New("rate limited")
+	// ErrContextTooLong is a prompt exceeding the context window of the model
+	ErrContextTooLong = errors.New("context too long")
+	// ErrAuth is a missing, invalid or unauthorized API key
+	ErrAuth = errors.New("authentication failed")
+	// ErrTransient is a server error, an overloaded provider, a timeout or a failed connection
+	ErrTransient = errors.New("transient server error")
+	// ErrContentFiltered is a completion the model refused or the provider filtered
+	ErrContentFiltered = errors.New("content filtered")
+)
+
+// Error is a failed LLM call of a known kind
+type Error struct {
+	// Kind is one of ErrRateLimited, ErrContextTooLong, ErrAuth, ErrTransient or ErrContentFiltered
+	Kind error
+	// StatusCode is the HTTP status of the response, or 0
+	StatusCode int
+	// RetryAfter is the delay the provider asked for with a Retry-After header, or 0
+	RetryAfter time.Duration
+	Err        error
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the kind and the underlying error
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// Classify returns the error as an Error when its kind is known, e.g. from the HTTP status in the
+// message of an adapter that doesn't return typed errors, and the error as is otherwise
+func Classify(err error) error {
+	var typed *Error
+	if err == nil || errors.As(err, &typed) {
+		return err
+	}
+	return newError(err, statusCode(err), 0)
+}
+
+// newError types the error of a call that got a response with the status, or none if it's 0
+func newError(err error, status int, retryAfter time.Duration) error {
+	kind := errorKind(err, status)
+	if kind == nil {
+		return err
+	}
+	return &Error{Kind: kind, StatusCode: status, RetryAfter: retryAfter, Err: err}
+}
+
+// errorKind returns the kind of a failed call, or nil
+func errorKind(err error, status int) error {
+	message := strings.ToLower(err.Error())
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return nil
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrAuth
+	case status == http.StatusRequestEntityTooLarge, strings.Contains(message, "prompt is too long"),
+		strings.Contains(message, "context length"), strings.Contains(message, "context window"):
+		return ErrContextTooLong
+	case status >= http.StatusInternalServerError:
+		return ErrTransient
+	case status == 0 && (errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr)):
+		return ErrTransient
+	}
+	return nil
+}
+
+// statusPattern matches the HTTP status codes providers put in their error messages, e.g.
+// "API returned unexpected status code: 529" or "503 Service Unavailable"
+var statusPattern = regexp.MustCompile(`status code: (\d{3})|\b(\d{3}) ([A-Z][A-Za-z ]+)`)
+
+// statusCode returns the HTTP status code in the error message, or 0
+func statusCode(err error) int {
+	for _, match := range statusPattern.FindAllStringSubmatch(err.Error(), -1) {
+		if match[1] != "" {
+			code, _ := strconv.Atoi(match[1])
+			return code
+		}
+		code, _ := strconv.Atoi(match[2])
+		if text := http.StatusText(code); text != "" && strings.HasPrefix(match[3], text) {
+			return code
+		}
+	}
+	return 0
+}
+
+// responseKey is the context key of the response recorded for an LLM call
+type responseKey struct{}
+
+// response is the status and Retry-After header of the last HTTP response of an LLM call, which
+// the langchaingo clients don't return with their errors
+type response struct {
+	mu         sync.Mutex
+	status     int
+	retryAfter time.Duration
+}
+
+// recordResponse returns a context the HTTP responses of an LLM call are recorded with
+func recordResponse(ctx context.Context) (context.Context, *response) {
+	r := &response{}
+	return context.WithValue(ctx, responseKey{}, r), r
+}
+
+// typeError types the error of an LLM call with its recorded response
+func (r *response) typeError(err error) error {
+	r.mu.Lock()
+	status, retryAfter := r.status, r.retryAfter
+	r.mu.Unlock()
+	if status == 0 {
+		status = statusCode(err)
+	}
+	return newError(err, status, retryAfter)
+}
+
+// recordingTransport records the responses of the requests made with a context from
+// recordResponse
+type recordingTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if r, ok := req.Context().Value(responseKey{}).(*response); ok && err == nil {
+		r.mu.Lock()
+		r.status = resp.StatusCode
+		r.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		r.mu.Unlock()
+	}
+	return resp, err
+}
+
+// newHTTPClient creates the HTTP client of the adapters, recording the responses of LLM calls
+func newHTTPClient() *http.Client {
+	return &http.Client{Transport: &recordingTransport{base: http.DefaultTransport}}
+}
+
+// parseRetryAfter parses a Retry-After header, given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
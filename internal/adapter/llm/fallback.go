@@ -0,0 +1,247 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is the error of a provider skipped because its circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// Default circuit breaker settings of a fallback adapter
+const (
+	DefaultBreakerThreshold = 3
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
+// Provider is an adapter of a fallback chain
+type Provider struct {
+	Name    string
+	Adapter LLMAdapter
+}
+
+// NewProviderAdapter creates the adapter of a provider type, claude or ollama. The server URL is
+// only used by Ollama.
+func NewProviderAdapter(providerType, model, serverURL string) (LLMAdapter, error) {
+	switch providerType {
+	case "claude":
+		return NewClaudeAdapter(model)
+	case "ollama":
+		return NewOllamaAdapter(model, serverURL)
+	default:
+		return nil, fmt.Errorf("unknown LLM provider: %s", providerType)
+	}
+}
+
+// FallbackOption configures a fallback adapter
+type FallbackOption func(*fallbackAdapter)
+
+// WithBreaker opens the circuit breaker of a provider after threshold consecutive failures, the
+// provider is skipped until the cooldown has passed and then tried with a single call
+func WithBreaker(threshold int, cooldown time.Duration) FallbackOption {
+	return func(a *fallbackAdapter) {
+		if threshold > 0 {
+			a.threshold = threshold
+		}
+		if cooldown > 0 {
+			a.cooldown = cooldown
+		}
+	}
+}
+
+// WithFailoverLog writes a line to w whenever a provider fails and the next one is tried
+func WithFailoverLog(w io.Writer) FallbackOption {
+	return func(a *fallbackAdapter) {
+		a.log = w
+	}
+}
+
+// fallbackAdapter tries its providers in order, moving on to the next one when a provider is
+// rate limited, times out or fails with a server error
+type fallbackAdapter struct {
+	providers []*breaker
+	threshold int
+	cooldown  time.Duration
+	log       io.Writer
+	now       func() time.Time
+}
+
+// breaker is the circuit breaker of a provider
+type breaker struct {
+	Provider
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	// probing is set while the single call after the cooldown is in flight
+	probing bool
+}
+
+// NewFallbackAdapter creates an adapter trying the providers in order. A single provider is
+// still wrapped, so that its circuit breaker fails calls fast while its endpoint is down.
+func NewFallbackAdapter(providers []Provider, opts ...FallbackOption) LLMAdapter {
+	a := &fallbackAdapter{
+		threshold: DefaultBreakerThreshold,
+		cooldown:  DefaultBreakerCooldown,
+		log:       io.Discard,
+		now:       time.Now,
+	}
+	for _, provider := range providers {
+		a.providers = append(a.providers, &breaker{Provider: provider})
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// GenerateCompletion implements LLMAdapter
+func (a *fallbackAdapter) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	return a.call(ctx, func(adapter LLMAdapter) (string, bool, error) {
+		completion, err := adapter.GenerateCompletion(ctx, prompt)
+		return completion, false, err
+	})
+}
+
+// GenerateCompletionStream implements StreamingAdapter. Once a provider has streamed part of the
+// completion the call can't fail over anymore, since the chunks were already passed on.
+func (a *fallbackAdapter) GenerateCompletionStream(ctx context.Context, prompt string, onChunk func(chunk string)) (string, error) {
+	return a.call(ctx, func(adapter LLMAdapter) (string, bool, error) {
+		streamed := false
+		completion, err := GenerateStream(ctx, adapter, prompt, func(chunk string) {
+			streamed = true
+			onChunk(chunk)
+		})
+		return completion, streamed, err
+	})
+}
+
+// GenerateStructured implements StructuredAdapter
+func (a *fallbackAdapter) GenerateStructured(ctx context.Context, prompt string, schema Schema) (string, error) {
+	return a.call(ctx, func(adapter LLMAdapter) (string, bool, error) {
+		completion, err := GenerateStructured(ctx, adapter, prompt, schema)
+		return completion, false, err
+	})
+}
+
+// call runs generate with the providers in order until one succeeds or fails with an error
+// another provider wouldn't avoid. generate reports whether the failed call already had effects.
+func (a *fallbackAdapter) call(ctx context.Context, generate func(adapter LLMAdapter) (string, bool, error)) (string, error) {
+	failed := &FallbackError{}
+	for i, provider := range a.providers {
+		if !provider.allow(a.now(), a.cooldown) {
+			failed.Errors = append(failed.Errors, fmt.Errorf("%s: %w", provider.Name, ErrCircuitOpen))
+			continue
+		}
+
+		completion, streamed, err := generate(provider.Adapter)
+		if err == nil {
+			provider.succeed()
+			return completion, nil
+		}
+		if ctx.Err() != nil {
+			provider.release()
+			return "", err
+		}
+		if !ShouldFailover(err) {
+			// The provider answered, the request is the problem
+			provider.succeed()
+			return "", err
+		}
+
+		if provider.fail(a.now(), a.threshold) {
+			fmt.Fprintf(a.log, "%s: circuit breaker opened for %s\n", provider.Name, a.cooldown)
+		}
+		if streamed {
+			return "", err
+		}
+		failed.Errors = append(failed.Errors, fmt.Errorf("%s: %w", provider.Name, err))
+		if i < len(a.providers)-1 {
+			fmt.Fprintf(a.log, "%s failed, trying %s: %v\n", provider.Name, a.providers[i+1].Name, err)
+		}
+	}
+
+	if len(failed.Errors) == 0 {
+		return "", fmt.Errorf("no LLM provider configured")
+	}
+	return "", failed
+}
+
+// FallbackError is returned when every provider of a fallback adapter failed
+type FallbackError struct {
+	// Errors holds the error of each provider, prefixed with its name
+	Errors []error
+}
+
+// Error implements error
+func (e *FallbackError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return "all LLM providers failed: " + strings.Join(messages, "; ")
+}
+
+// Unwrap returns the errors of the providers
+func (e *FallbackError) Unwrap() []error {
+	return e.Errors
+}
+
+// allow reports whether a call can be sent to the provider. After the cooldown a single call is
+// let through to probe whether the endpoint recovered.
+func (b *breaker) allow(now time.Time, cooldown time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openedAt.IsZero() {
+		return true
+	}
+	if b.probing || now.Sub(b.openedAt) < cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// succeed closes the breaker
+func (b *breaker) succeed() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures, b.openedAt, b.probing = 0, time.Time{}, false
+}
+
+// release ends a call canceled by the caller, which neither proved nor disproved the provider's
+// health
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// fail records a failed call and reports whether it opened the breaker
+func (b *breaker) fail(now time.Time, threshold int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.probing {
+		// The probe failed, the breaker stays open for another cooldown
+		b.probing, b.openedAt = false, now
+		return false
+	}
+	if b.openedAt.IsZero() && b.failures >= threshold {
+		b.openedAt = now
+		return true
+	}
+	return false
+}
+
+// ShouldFailover reports whether a failed call may succeed with another provider: the provider
+// is rate limited, timed out, unreachable, failed with a server error or its breaker is open
+func ShouldFailover(err error) bool {
+	err = Classify(err)
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrTransient) || errors.Is(err, ErrCircuitOpen)
+}
@@ -0,0 +1,142 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// failingLLM fails with its errors in order, then answers with its completion
+type failingLLM struct {
+	errs       []error
+	completion string
+	calls      int
+}
+
+func (l *failingLLM) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	l.calls++
+	if len(l.errs) > 0 {
+		err := l.errs[0]
+		l.errs = l.errs[1:]
+		return "", err
+	}
+	return l.completion, nil
+}
+
+// brokenStreamLLM streams part of a completion before the connection fails
+type brokenStreamLLM struct{ failingLLM }
+
+func (l *brokenStreamLLM) GenerateCompletionStream(ctx context.Context, prompt string, onChunk func(chunk string)) (string, error) {
+	onChunk("flowchart")
+	return "", errors.New("API returned unexpected status code: 502: bad gateway")
+}
+
+// FallbackTestSuite is a test suite for provider fallback chains
+type FallbackTestSuite struct {
+	suite.Suite
+}
+
+// TestFailover checks which errors move on to the next provider
+func (s *FallbackTestSuite) TestFailover() {
+	ctx := context.Background()
+	rateLimited := fmt.Errorf("error generating completion: %w", errors.New("API returned unexpected status code: 429: rate limited"))
+	claude := &failingLLM{errs: []error{rateLimited}, completion: "claude"}
+	ollama := &failingLLM{completion: "ollama"}
+	var log strings.Builder
+	adapter := NewFallbackAdapter([]Provider{{Name: "claude", Adapter: claude}, {Name: "ollama", Adapter: ollama}}, WithFailoverLog(&log))
+
+	completion, err := adapter.GenerateCompletion(ctx, "prompt")
+	s.Require().NoError(err)
+	s.Equal("ollama", completion)
+	s.Contains(log.String(), "claude failed, trying ollama")
+
+	completion, err = adapter.GenerateCompletion(ctx, "prompt")
+	s.Require().NoError(err)
+	s.Equal("claude", completion)
+
+	// A bad request fails the same way with every provider
+	claude.errs = []error{errors.New("API returned unexpected status code: 400: prompt is too long")}
+	_, err = adapter.GenerateCompletion(ctx, "prompt")
+	s.ErrorContains(err, "prompt is too long")
+	s.Equal(1, ollama.calls)
+
+	ollama.errs = []error{errors.New("503 Service Unavailable")}
+	claude.errs = []error{rateLimited}
+	_, err = adapter.GenerateCompletion(ctx, "prompt")
+	var fallbackErr *FallbackError
+	s.Require().ErrorAs(err, &fallbackErr)
+	s.EqualError(err, "all LLM providers failed: claude: error generating completion: API returned unexpected status code: 429: rate limited; ollama: 503 Service Unavailable")
+}
+
+// TestBreaker checks that a failing provider is skipped until a probe after the cooldown succeeds
+func (s *FallbackTestSuite) TestBreaker() {
+	ctx := context.Background()
+	serverErr := errors.New("API returned unexpected status code: 529: overloaded")
+	claude := &failingLLM{errs: []error{serverErr, serverErr, serverErr}, completion: "claude"}
+	ollama := &failingLLM{completion: "ollama"}
+	now := time.Now()
+	adapter := NewFallbackAdapter([]Provider{{Name: "claude", Adapter: claude}, {Name: "ollama", Adapter: ollama}}, WithBreaker(2, time.Minute)).(*fallbackAdapter)
+	adapter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		completion, err := adapter.GenerateCompletion(ctx, "prompt")
+		s.Require().NoError(err)
+		s.Equal("ollama", completion)
+	}
+	s.Equal(2, claude.calls, "the breaker opens after 2 failures")
+
+	// The probe after the cooldown fails and keeps the breaker open
+	now = now.Add(time.Minute)
+	_, err := adapter.GenerateCompletion(ctx, "prompt")
+	s.Require().NoError(err)
+	s.Equal(3, claude.calls)
+	_, err = adapter.GenerateCompletion(ctx, "prompt")
+	s.Require().NoError(err)
+	s.Equal(3, claude.calls)
+
+	now = now.Add(time.Minute)
+	completion, err := adapter.GenerateCompletion(ctx, "prompt")
+	s.Require().NoError(err)
+	s.Equal("claude", completion)
+	s.Equal(5, ollama.calls)
+
+	// With every breaker open calls fail fast
+	single := NewFallbackAdapter([]Provider{{Name: "claude", Adapter: &failingLLM{errs: []error{serverErr}}}}, WithBreaker(1, time.Minute))
+	_, err = single.GenerateCompletion(ctx, "prompt")
+	s.Require().Error(err)
+	_, err = single.GenerateCompletion(ctx, "prompt")
+	s.ErrorIs(err, ErrCircuitOpen)
+}
+
+// TestStream checks that a call can't fail over once chunks were streamed
+func (s *FallbackTestSuite) TestStream() {
+	ollama := &failingLLM{completion: "ollama"}
+	adapter := NewFallbackAdapter([]Provider{{Name: "claude", Adapter: &brokenStreamLLM{}}, {Name: "ollama", Adapter: ollama}})
+
+	var chunks []string
+	_, err := GenerateStream(context.Background(), adapter, "prompt", func(chunk string) { chunks = append(chunks, chunk) })
+	s.ErrorContains(err, "bad gateway")
+	s.Equal([]string{"flowchart"}, chunks)
+	s.Equal(0, ollama.calls)
+}
+
+// TestShouldFailover checks the classification of provider errors
+func (s *FallbackTestSuite) TestShouldFailover() {
+	s.True(ShouldFailover(errors.New("API returned unexpected status code: 500: internal error")))
+	s.True(ShouldFailover(errors.New("429 Too Many Requests")))
+	s.True(ShouldFailover(fmt.Errorf("send request: %w", context.DeadlineExceeded)))
+	s.False(ShouldFailover(errors.New("API returned unexpected status code: 401: invalid x-api-key")))
+	s.False(ShouldFailover(errors.New("diagram has 500 nodes")))
+	s.False(ShouldFailover(context.Canceled))
+	s.False(ShouldFailover(nil))
+}
+
+// TestFallbackTestSuite runs the fallback test suite
+func TestFallbackTestSuite(t *testing.T) {
+	suite.Run(t, new(FallbackTestSuite))
+}
@@ -0,0 +1,203 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultMaxConcurrent is the number of concurrent calls to a provider when its limits don't set one
+const DefaultMaxConcurrent = 2
+
+// Limits are the rate limits of a provider. Zero requests or tokens per minute don't limit, zero
+// concurrent calls means DefaultMaxConcurrent.
+type Limits struct {
+	RequestsPerMinute int
+	// TokensPerMinute limits the input and output tokens of the calls
+	TokensPerMinute int
+	MaxConcurrent   int
+}
+
+// Limiter keeps the calls to a provider within its limits
+type Limiter struct {
+	slots chan struct{}
+
+	mu       sync.Mutex
+	requests *bucket
+	tokens   *bucket
+	now      func() time.Time
+	sleep    func(ctx context.Context, d time.Duration) error
+}
+
+// NewLimiter creates a limiter with the limits
+func NewLimiter(limits Limits) *Limiter {
+	if limits.MaxConcurrent <= 0 {
+		limits.MaxConcurrent = DefaultMaxConcurrent
+	}
+	l := &Limiter{slots: make(chan struct{}, limits.MaxConcurrent), now: time.Now, sleep: sleep}
+	if limits.RequestsPerMinute > 0 {
+		l.requests = newBucket(limits.RequestsPerMinute, l.now())
+	}
+	if limits.TokensPerMinute > 0 {
+		l.tokens = newBucket(limits.TokensPerMinute, l.now())
+	}
+	return l
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = make(map[string]*Limiter)
+)
+
+// SharedLimiter returns the process-wide limiter of a provider, so that every adapter calling it
+// shares its quota. The limits of the first call for a provider are used.
+func SharedLimiter(provider string, limits Limits) *Limiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	if l, ok := limiters[provider]; ok {
+		return l
+	}
+	l := NewLimiter(limits)
+	limiters[provider] = l
+	return l
+}
+
+// Adapter returns an adapter whose calls wait until the limits allow them
+func (l *Limiter) Adapter(adapter LLMAdapter) LLMAdapter {
+	return &limitedAdapter{limiter: l, adapter: adapter}
+}
+
+// acquire waits for a call slot and for the request and the estimated tokens of a call to be
+// within the limits. The returned function ends the call with the number of tokens it used.
+func (l *Limiter) acquire(ctx context.Context, estimate int) (func(used int), error) {
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	// Calls take from the buckets in order and wait until their share is refilled
+	l.mu.Lock()
+	now := l.now()
+	var wait time.Duration
+	if l.requests != nil {
+		wait = max(wait, l.requests.take(now, 1))
+	}
+	if l.tokens != nil {
+		wait = max(wait, l.tokens.take(now, float64(estimate)))
+	}
+	l.mu.Unlock()
+
+	if wait > 0 {
+		if err := l.sleep(ctx, wait); err != nil {
+			l.settle(1, estimate)
+			<-l.slots
+			return nil, err
+		}
+	}
+
+	return func(used int) {
+		l.settle(0, estimate-used)
+		<-l.slots
+	}, nil
+}
+
+// settle returns requests and tokens taken for a call to the buckets, tokens are negative when
+// the call used more than estimated
+func (l *Limiter) settle(requests, tokens int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if l.requests != nil && requests != 0 {
+		l.requests.put(now, float64(requests))
+	}
+	if l.tokens != nil && tokens != 0 {
+		l.tokens.put(now, float64(tokens))
+	}
+}
+
+// bucket is a token bucket refilled with its capacity every minute. Takes may leave it in debt,
+// later takes then wait until the debt is refilled.
+type bucket struct {
+	capacity  float64
+	available float64
+	last      time.Time
+}
+
+func newBucket(perMinute int, now time.Time) *bucket {
+	return &bucket{capacity: float64(perMinute), available: float64(perMinute), last: now}
+}
+
+// take removes n from the bucket and returns how long it takes until the bucket is out of debt
+func (b *bucket) take(now time.Time, n float64) time.Duration {
+	b.refill(now)
+	b.available -= n
+	if b.available >= 0 {
+		return 0
+	}
+	return time.Duration(-b.available / b.capacity * float64(time.Minute))
+}
+
+// put adds n to the bucket, up to its capacity
+func (b *bucket) put(now time.Time, n float64) {
+	b.refill(now)
+	b.available = min(b.capacity, b.available+n)
+}
+
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.available = min(b.capacity, b.available+elapsed.Minutes()*b.capacity)
+		b.last = now
+	}
+}
+
+// limitedAdapter waits for a limiter before every call of an adapter
+type limitedAdapter struct {
+	limiter *Limiter
+	adapter LLMAdapter
+}
+
+// GenerateCompletion implements LLMAdapter
+func (a *limitedAdapter) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	return a.call(ctx, prompt, func(ctx context.Context) (string, error) {
+		return a.adapter.GenerateCompletion(ctx, prompt)
+	})
+}
+
+// GenerateCompletionStream implements StreamingAdapter
+func (a *limitedAdapter) GenerateCompletionStream(ctx context.Context, prompt string, onChunk func(chunk string)) (string, error) {
+	return a.call(ctx, prompt, func(ctx context.Context) (string, error) {
+		return GenerateStream(ctx, a.adapter, prompt, onChunk)
+	})
+}
+
+// GenerateStructured implements StructuredAdapter
+func (a *limitedAdapter) GenerateStructured(ctx context.Context, prompt string, schema Schema) (string, error) {
+	return a.call(ctx, prompt, func(ctx context.Context) (string, error) {
+		return GenerateStructured(ctx, a.adapter, prompt, schema)
+	})
+}
+
+// call runs generate within the limits. The tokens of the call are estimated from the prompt
+// beforehand and charged with the usage the provider reports afterwards.
+func (a *limitedAdapter) call(ctx context.Context, prompt string, generate func(ctx context.Context) (string, error)) (string, error) {
+	estimate := EstimateTokens(len(prompt))
+	done, err := a.limiter.acquire(ctx, estimate)
+	if err != nil {
+		return "", err
+	}
+
+	var reported *Usage
+	callCtx := WithUsageFunc(ctx, func(usage Usage) {
+		reported = &usage
+		ReportUsage(ctx, usage)
+	})
+	completion, err := generate(callCtx)
+
+	used := estimate + EstimateTokens(len(completion))
+	if reported != nil && reported.Counted() {
+		used = reported.InputTokens + reported.OutputTokens
+	}
+	done(used)
+	return completion, err
+}
@@ -0,0 +1,153 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// blockingLLM blocks its calls until released and records how many run at once
+type blockingLLM struct {
+	release chan struct{}
+
+	mu      sync.Mutex
+	running int
+	peak    int
+}
+
+func (l *blockingLLM) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	l.mu.Lock()
+	l.running++
+	l.peak = max(l.peak, l.running)
+	l.mu.Unlock()
+
+	<-l.release
+
+	l.mu.Lock()
+	l.running--
+	l.mu.Unlock()
+	return "flowchart TD", nil
+}
+
+func (l *blockingLLM) runningCalls() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.running
+}
+
+// usageLLM reports a fixed token usage for every call
+type usageLLM struct {
+	usage Usage
+}
+
+func (l usageLLM) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	ReportUsage(ctx, l.usage)
+	return "classDiagram", nil
+}
+
+// LimiterTestSuite is a test suite for limiting LLM calls
+type LimiterTestSuite struct {
+	suite.Suite
+	now    time.Time
+	waits  []time.Duration
+	waitMu sync.Mutex
+}
+
+// SetupTest resets the clock
+func (s *LimiterTestSuite) SetupTest() {
+	s.now = time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	s.waits = nil
+}
+
+// limiter creates a limiter whose waits advance the test clock
+func (s *LimiterTestSuite) limiter(limits Limits) *Limiter {
+	l := NewLimiter(limits)
+	l.now = func() time.Time {
+		s.waitMu.Lock()
+		defer s.waitMu.Unlock()
+		return s.now
+	}
+	l.requests, l.tokens = nil, nil
+	if limits.RequestsPerMinute > 0 {
+		l.requests = newBucket(limits.RequestsPerMinute, s.now)
+	}
+	if limits.TokensPerMinute > 0 {
+		l.tokens = newBucket(limits.TokensPerMinute, s.now)
+	}
+	l.sleep = func(ctx context.Context, d time.Duration) error {
+		s.waitMu.Lock()
+		defer s.waitMu.Unlock()
+		s.waits = append(s.waits, d)
+		s.now = s.now.Add(d)
+		return nil
+	}
+	return l
+}
+
+// TestConcurrency checks that no more calls than allowed run at once
+func (s *LimiterTestSuite) TestConcurrency() {
+	provider := &blockingLLM{release: make(chan struct{})}
+	adapter := s.limiter(Limits{MaxConcurrent: 2}).Adapter(provider)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := adapter.GenerateCompletion(context.Background(), "prompt")
+			s.NoError(err)
+		}()
+	}
+	s.Eventually(func() bool { return provider.runningCalls() == 2 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	s.Equal(2, provider.runningCalls())
+
+	for i := 0; i < 5; i++ {
+		provider.release <- struct{}{}
+	}
+	wg.Wait()
+	s.Equal(2, provider.peak)
+}
+
+// TestRequestsPerMinute checks that calls beyond the rate wait for the bucket to refill
+func (s *LimiterTestSuite) TestRequestsPerMinute() {
+	adapter := s.limiter(Limits{RequestsPerMinute: 2}).Adapter(usageLLM{})
+
+	for i := 0; i < 4; i++ {
+		_, err := adapter.GenerateCompletion(context.Background(), "prompt")
+		s.Require().NoError(err)
+	}
+	s.Equal([]time.Duration{30 * time.Second, 30 * time.Second}, s.waits)
+}
+
+// TestTokensPerMinute checks that calls are charged with the usage the provider reports
+func (s *LimiterTestSuite) TestTokensPerMinute() {
+	var reported []Usage
+	ctx := WithUsageFunc(context.Background(), func(usage Usage) { reported = append(reported, usage) })
+	adapter := s.limiter(Limits{TokensPerMinute: 1000}).Adapter(usageLLM{usage: Usage{InputTokens: 900, OutputTokens: 600}})
+
+	_, err := adapter.GenerateCompletion(ctx, "prompt")
+	s.Require().NoError(err)
+	s.Empty(s.waits, "the prompt is estimated within the limit")
+
+	// The first call used 1500 tokens, the bucket is 500 tokens in debt
+	_, err = adapter.GenerateCompletion(ctx, "prompt")
+	s.Require().NoError(err)
+	s.Equal([]time.Duration{30*time.Second + 120*time.Millisecond}, s.waits)
+	s.Len(reported, 2, "usage is still reported to the caller")
+}
+
+// TestSharedLimiter checks that a provider has one limiter per process
+func (s *LimiterTestSuite) TestSharedLimiter() {
+	limiter := SharedLimiter("test:model", Limits{RequestsPerMinute: 10})
+	s.Same(limiter, SharedLimiter("test:model", Limits{RequestsPerMinute: 20}))
+	s.NotSame(limiter, SharedLimiter("test:other", Limits{}))
+}
+
+// TestLimiterTestSuite runs the limiter test suite
+func TestLimiterTestSuite(t *testing.T) {
+	suite.Run(t, new(LimiterTestSuite))
+}
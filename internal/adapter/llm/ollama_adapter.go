@@ -0,0 +1,66 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/ollama"
+)
+
+// DefaultOllamaModel is the Ollama model used when none is given
+const DefaultOllamaModel = "llama3.1"
+
+// ollamaAdapter implements LLMAdapter for a model served by Ollama
+type ollamaAdapter struct {
+	model string
+	llm   llms.Model
+}
+
+// NewOllamaAdapter creates an adapter for an Ollama model. An empty server URL uses OLLAMA_HOST,
+// or the local default server.
+func NewOllamaAdapter(model, serverURL string) (LLMAdapter, error) {
+	if model == "" {
+		model = DefaultOllamaModel
+	}
+
+	opts := []ollama.Option{ollama.WithModel(model), ollama.WithHTTPClient(newHTTPClient())}
+	if serverURL != "" {
+		opts = append(opts, ollama.WithServerURL(serverURL))
+	}
+	llm, err := ollama.New(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Ollama: %w", err)
+	}
+
+	return &ollamaAdapter{model: model, llm: llm}, nil
+}
+
+// GenerateCompletion generates a completion from the Ollama model
+func (a *ollamaAdapter) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	return a.generate(ctx, prompt)
+}
+
+// GenerateCompletionStream streams a completion from the Ollama model
+func (a *ollamaAdapter) GenerateCompletionStream(ctx context.Context, prompt string, onChunk func(chunk string)) (string, error) {
+	return a.generate(ctx, prompt, llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+		onChunk(string(chunk))
+		return nil
+	}))
+}
+
+// generate sends a single prompt to the Ollama model, reports the token usage of the call and
+// returns typed errors for failed calls
+func (a *ollamaAdapter) generate(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	ctx, response := recordResponse(ctx)
+	resp, err := a.llm.GenerateContent(ctx, []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, prompt)}, options...)
+	if err != nil {
+		return "", response.typeError(fmt.Errorf("error generating completion: %w", err))
+	}
+	reportResponseUsage(ctx, a.model, resp)
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("error generating completion: empty response")
+	}
+	return resp.Choices[0].Content, nil
+}
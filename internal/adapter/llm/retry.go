@@ -0,0 +1,162 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy decides how failed LLM calls are retried. Rate limits and transient server errors
+// are retried, other errors are returned at once.
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts of a call, including the first one
+	Attempts int
+	// BaseDelay is the delay before the first retry, doubled for every further retry
+	BaseDelay time.Duration
+	// MaxDelay caps the delay before a retry. A call is given up when the provider asks with
+	// Retry-After for a longer delay.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is used for the fields of a policy that aren't set
+var DefaultRetryPolicy = RetryPolicy{Attempts: 3, BaseDelay: 2 * time.Second, MaxDelay: time.Minute}
+
+// withDefaults returns the policy with DefaultRetryPolicy for the fields that aren't set
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.Attempts <= 0 {
+		p.Attempts = DefaultRetryPolicy.Attempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultRetryPolicy.BaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultRetryPolicy.MaxDelay
+	}
+	return p
+}
+
+// Delay returns the delay before retrying a call after its failed attempt, counted from 1, and
+// whether the call should be retried at all
+func (p RetryPolicy) Delay(attempt int, err error) (time.Duration, bool) {
+	p = p.withDefaults()
+	err = Classify(err)
+	if attempt >= p.Attempts || !(errors.Is(err, ErrRateLimited) || errors.Is(err, ErrTransient)) {
+		return 0, false
+	}
+
+	var typed *Error
+	if errors.As(err, &typed) && typed.RetryAfter > 0 {
+		return typed.RetryAfter, typed.RetryAfter <= p.MaxDelay
+	}
+
+	// Exponential backoff with jitter, so that concurrent calls don't retry at the same time
+	delay := p.BaseDelay << (attempt - 1)
+	delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	if delay > p.MaxDelay || delay <= 0 {
+		delay = p.MaxDelay
+	}
+	return delay, true
+}
+
+// RetryOption configures a retry adapter
+type RetryOption func(*retryAdapter)
+
+// WithRetryLog writes a line to w whenever a call is retried
+func WithRetryLog(w io.Writer) RetryOption {
+	return func(a *retryAdapter) {
+		a.log = w
+	}
+}
+
+// retryAdapter retries the failed calls of an adapter with a retry policy
+type retryAdapter struct {
+	adapter LLMAdapter
+	policy  RetryPolicy
+	log     io.Writer
+	sleep   func(ctx context.Context, d time.Duration) error
+}
+
+// NewRetryAdapter creates an adapter retrying the failed calls of the adapter with the policy
+func NewRetryAdapter(adapter LLMAdapter, policy RetryPolicy, opts ...RetryOption) LLMAdapter {
+	a := &retryAdapter{adapter: adapter, policy: policy.withDefaults(), log: io.Discard, sleep: sleep}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// GenerateCompletion implements LLMAdapter
+func (a *retryAdapter) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	return a.call(ctx, func() (string, bool, error) {
+		completion, err := a.adapter.GenerateCompletion(ctx, prompt)
+		return completion, false, err
+	})
+}
+
+// GenerateCompletionStream implements StreamingAdapter. A call that streamed part of the
+// completion isn't retried, since the chunks were already passed on.
+func (a *retryAdapter) GenerateCompletionStream(ctx context.Context, prompt string, onChunk func(chunk string)) (string, error) {
+	return a.call(ctx, func() (string, bool, error) {
+		streamed := false
+		completion, err := GenerateStream(ctx, a.adapter, prompt, func(chunk string) {
+			streamed = true
+			onChunk(chunk)
+		})
+		return completion, streamed, err
+	})
+}
+
+// GenerateStructured implements StructuredAdapter
+func (a *retryAdapter) GenerateStructured(ctx context.Context, prompt string, schema Schema) (string, error) {
+	return a.call(ctx, func() (string, bool, error) {
+		completion, err := GenerateStructured(ctx, a.adapter, prompt, schema)
+		return completion, false, err
+	})
+}
+
+// call runs generate until it succeeds or the policy gives up. generate reports whether the
+// failed attempt already had effects.
+func (a *retryAdapter) call(ctx context.Context, generate func() (string, bool, error)) (string, error) {
+	for attempt := 1; ; attempt++ {
+		completion, streamed, err := generate()
+		if err == nil {
+			return completion, nil
+		}
+		err = Classify(err)
+
+		delay, retry := a.policy.Delay(attempt, err)
+		if !retry || streamed || ctx.Err() != nil {
+			if attempt > 1 {
+				return "", fmt.Errorf("failed after %d attempts: %w", attempt, err)
+			}
+			return "", err
+		}
+
+		// Only retried errors get here, they are typed
+		var typed *Error
+		errors.As(err, &typed)
+		label := Label(ctx)
+		if label == "" {
+			label = "LLM call"
+		}
+		fmt.Fprintf(a.log, "%s: %v, retrying in %v (attempt %d/%d)\n", label, typed.Kind, delay.Round(time.Millisecond), attempt+1, a.policy.Attempts)
+		if err := a.sleep(ctx, delay); err != nil {
+			return "", fmt.Errorf("context canceled while waiting to retry: %w", err)
+		}
+	}
+}
+
+// sleep waits for the delay or until the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
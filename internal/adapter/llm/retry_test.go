@@ -0,0 +1,114 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// RetryTestSuite is a test suite for typed LLM errors and retries
+type RetryTestSuite struct {
+	suite.Suite
+}
+
+// TestClassify checks the kinds of provider errors
+func (s *RetryTestSuite) TestClassify() {
+	kinds := map[string]error{
+		"API returned unexpected status code: 429: rate limited":                               ErrRateLimited,
+		"API returned unexpected status code: 401: invalid x-api-key":                          ErrAuth,
+		"API returned unexpected status code: 400: prompt is too long: 210000 tokens > 200000": ErrContextTooLong,
+		"API returned unexpected status code: 529: overloaded":                                 ErrTransient,
+		"503 Service Unavailable":                                                              ErrTransient,
+	}
+	for message, kind := range kinds {
+		err := Classify(fmt.Errorf("error generating completion: %w", errors.New(message)))
+		s.ErrorIs(err, kind, message)
+		s.EqualError(err, "error generating completion: "+message)
+	}
+
+	s.ErrorIs(Classify(fmt.Errorf("send request: %w", context.DeadlineExceeded)), ErrTransient)
+	s.NotErrorIs(Classify(errors.New("API returned unexpected status code: 400: invalid request")), ErrTransient)
+	s.Equal(context.Canceled, Classify(context.Canceled))
+	s.Nil(Classify(nil))
+
+	now := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	s.Equal(20*time.Second, parseRetryAfter("20", now))
+	s.Equal(90*time.Second, parseRetryAfter("Thu, 02 Jan 2025 10:01:30 GMT", now))
+	s.Zero(parseRetryAfter("soon", now))
+}
+
+// TestRecordedResponse checks that the status and Retry-After header of a response type the error
+func (s *RetryTestSuite) TestRecordedResponse() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, response := recordResponse(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, nil)
+	s.Require().NoError(err)
+	resp, err := newHTTPClient().Do(req)
+	s.Require().NoError(err)
+	resp.Body.Close()
+
+	err = response.typeError(errors.New("error generating completion: unexpected response"))
+	var typed *Error
+	s.Require().ErrorAs(err, &typed)
+	s.Equal(ErrRateLimited, typed.Kind)
+	s.Equal(http.StatusTooManyRequests, typed.StatusCode)
+	s.Equal(7*time.Second, typed.RetryAfter)
+}
+
+// TestRetry checks that rate limits and transient errors are retried with the policy
+func (s *RetryTestSuite) TestRetry() {
+	ctx := WithLabel(context.Background(), "service")
+	rateLimited := &Error{Kind: ErrRateLimited, StatusCode: http.StatusTooManyRequests, RetryAfter: 5 * time.Second, Err: errors.New("rate limited")}
+	serverErr := errors.New("API returned unexpected status code: 500: internal error")
+	provider := &failingLLM{errs: []error{rateLimited, serverErr}, completion: "classDiagram"}
+
+	var delays []time.Duration
+	var log strings.Builder
+	adapter := NewRetryAdapter(provider, RetryPolicy{Attempts: 3, BaseDelay: time.Second}, WithRetryLog(&log)).(*retryAdapter)
+	adapter.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	completion, err := adapter.GenerateCompletion(ctx, "prompt")
+	s.Require().NoError(err)
+	s.Equal("classDiagram", completion)
+	s.Equal(3, provider.calls)
+	s.Require().Len(delays, 2)
+	s.Equal(5*time.Second, delays[0], "Retry-After is honored")
+	s.GreaterOrEqual(delays[1], 2*time.Second)
+	s.Less(delays[1], 3*time.Second)
+	s.Contains(log.String(), "service: rate limited, retrying in 5s (attempt 2/3)")
+
+	// Other errors aren't retried, the last attempt gives up
+	provider.errs = []error{errors.New("API returned unexpected status code: 401: invalid x-api-key")}
+	_, err = adapter.GenerateCompletion(ctx, "prompt")
+	s.ErrorIs(err, ErrAuth)
+	s.Equal(4, provider.calls)
+
+	provider.errs = []error{serverErr, serverErr, serverErr}
+	_, err = adapter.GenerateCompletion(ctx, "prompt")
+	s.EqualError(err, "failed after 3 attempts: "+serverErr.Error())
+	s.ErrorIs(err, ErrTransient)
+
+	// A Retry-After longer than the maximum delay gives up at once
+	_, retry := RetryPolicy{MaxDelay: time.Second}.Delay(1, rateLimited)
+	s.False(retry)
+}
+
+// TestRetryTestSuite runs the retry test suite
+func TestRetryTestSuite(t *testing.T) {
+	suite.Run(t, new(RetryTestSuite))
+}
@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"context"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// StreamingAdapter is implemented by adapters that can deliver a completion while it's generated
+type StreamingAdapter interface {
+	// GenerateCompletionStream calls onChunk with every piece of text received and returns the
+	// whole completion
+	GenerateCompletionStream(ctx context.Context, prompt string, onChunk func(chunk string)) (string, error)
+}
+
+// GenerateStream generates a completion, streamed where the adapter supports it. Otherwise the
+// whole completion is passed to onChunk once it's received.
+func GenerateStream(ctx context.Context, adapter LLMAdapter, prompt string, onChunk func(chunk string)) (string, error) {
+	if streaming, ok := adapter.(StreamingAdapter); ok {
+		return streaming.GenerateCompletionStream(ctx, prompt, onChunk)
+	}
+
+	completion, err := adapter.GenerateCompletion(ctx, prompt)
+	if err == nil {
+		onChunk(completion)
+	}
+	return completion, err
+}
+
+// labelKey is the context key of the label of LLM calls
+type labelKey struct{}
+
+// WithLabel labels the LLM calls made with the context, e.g. with the component they generate a
+// diagram for, so that progress and usage can be reported per label
+func WithLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, labelKey{}, label)
+}
+
+// Label returns the label of the LLM calls made with the context, or an empty string
+func Label(ctx context.Context) string {
+	label, _ := ctx.Value(labelKey{}).(string)
+	return label
+}
+
+// GenerateCompletionStream streams a completion from Claude
+func (a *claudeAdapter) GenerateCompletionStream(ctx context.Context, prompt string, onChunk func(chunk string)) (string, error) {
+	return a.generate(ctx, prompt, llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+		onChunk(string(chunk))
+		return nil
+	}))
+}
@@ -0,0 +1,63 @@
+package llm
+
+import (
+	"context"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Usage is the number of tokens of an LLM call and the model that served it. The token counts are
+// zero when the provider didn't return them.
+type Usage struct {
+	Model        string
+	InputTokens  int
+	OutputTokens int
+}
+
+// usageKey is the context key of the function LLM calls report their usage to
+type usageKey struct{}
+
+// WithUsageFunc sets the function adapters report the token usage of the LLM calls made with the
+// context to
+func WithUsageFunc(ctx context.Context, fn func(Usage)) context.Context {
+	return context.WithValue(ctx, usageKey{}, fn)
+}
+
+// ReportUsage reports the token usage of an LLM call to the function set with WithUsageFunc
+func ReportUsage(ctx context.Context, usage Usage) {
+	if fn, ok := ctx.Value(usageKey{}).(func(Usage)); ok {
+		fn(usage)
+	}
+}
+
+// reportResponseUsage reports the model and the token counts langchaingo returns in the generation
+// info, named InputTokens and OutputTokens by Anthropic and PromptTokens and CompletionTokens by
+// Ollama
+func reportResponseUsage(ctx context.Context, model string, resp *llms.ContentResponse) {
+	usage := Usage{Model: model}
+	for _, choice := range resp.Choices {
+		input, inputOK := choice.GenerationInfo["InputTokens"].(int)
+		output, outputOK := choice.GenerationInfo["OutputTokens"].(int)
+		if !inputOK || !outputOK {
+			input, inputOK = choice.GenerationInfo["PromptTokens"].(int)
+			output, outputOK = choice.GenerationInfo["CompletionTokens"].(int)
+		}
+		if inputOK && outputOK {
+			// Every choice of a response repeats the usage of the whole message
+			usage.InputTokens, usage.OutputTokens = input, output
+		}
+	}
+	ReportUsage(ctx, usage)
+}
+
+// Counted reports whether the provider returned the token counts of the call
+func (u Usage) Counted() bool {
+	return u.InputTokens > 0 || u.OutputTokens > 0
+}
+
+// EstimateTokens estimates the number of tokens of a text from its length in bytes, about four
+// characters per token for English and code. It stands in for the counts of providers that don't
+// return them.
+func EstimateTokens(chars int) int {
+	return (chars + 3) / 4
+}
@@ -1,23 +1,28 @@
 package renderer
 
 import (
+	"sync"
+
 	"github.com/anz-bank/mermaid-go/mermaid"
 )
 
 // MermaidRenderer implements Renderer using mermaid-go library
 type MermaidRenderer struct {
+	once      sync.Once
 	generator *mermaid.Generator
 }
 
-// NewMermaidRenderer creates a new MermaidRenderer
+// NewMermaidRenderer creates a new MermaidRenderer. The browser rendering the diagrams is only
+// started by the first conversion, so commands printing Mermaid text don't need it.
 func NewMermaidRenderer() *MermaidRenderer {
-	return &MermaidRenderer{
-		generator: mermaid.Init(),
-	}
+	return &MermaidRenderer{}
 }
 
 // ConvertToSVG converts Mermaid diagram syntax to SVG format
 func (r *MermaidRenderer) ConvertToSVG(mermaidContent string) (string, error) {
+	r.once.Do(func() {
+		r.generator = mermaid.Init()
+	})
 	svg := r.generator.Execute(mermaidContent)
 	return svg, nil
 }
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"mm-go-agent/pkg/mermaid"
+	"mm-go-agent/pkg/prompt"
+)
+
+// DefaultFile is the configuration file looked up in the working directory
+const DefaultFile = ".mm-gen.yaml"
+
+// Config is the mm-gen configuration file
+type Config struct {
+	Lint    LintConfig    `yaml:"lint"`
+	Format  FormatConfig  `yaml:"format"`
+	Prompts PromptsConfig `yaml:"prompts"`
+	LLM     LLMConfig     `yaml:"llm"`
+}
+
+// LintConfig configures the lint rules
+type LintConfig struct {
+	// Rules maps rule names to a severity: error, warning, info or off
+	Rules          map[string]string `yaml:"rules"`
+	MaxLabelLength int               `yaml:"maxLabelLength"`
+	MaxNodes       int               `yaml:"maxNodes"`
+}
+
+// FormatConfig configures the formatter
+type FormatConfig struct {
+	SortMembers bool `yaml:"sortMembers"`
+	SortNodes   bool `yaml:"sortNodes"`
+}
+
+// PromptsConfig configures the prompt templates
+type PromptsConfig struct {
+	// Templates is a directory of templates shadowing the built-in ones, relative to the
+	// configuration file
+	Templates string `yaml:"templates"`
+	// ExamplesDir is a directory of few-shot examples, relative to the configuration file
+	ExamplesDir string           `yaml:"examplesDir"`
+	Examples    []prompt.Example `yaml:"examples"`
+	// MaxExamples is the maximum number of examples added to a prompt
+	MaxExamples int `yaml:"maxExamples"`
+}
+
+// LLMConfig configures the LLM providers
+type LLMConfig struct {
+	// Providers are tried in order, a call falls back to the next provider when one is rate
+	// limited, times out or fails with a server error. Claude alone is used when it's empty.
+	Providers []ProviderConfig `yaml:"providers"`
+	Breaker   BreakerConfig    `yaml:"breaker"`
+	Retry     RetryConfig      `yaml:"retry"`
+	// Limits apply to Claude when no providers are set, and to providers without limits
+	Limits LimitsConfig `yaml:"limits"`
+}
+
+// ProviderConfig configures an LLM provider
+type ProviderConfig struct {
+	// Type is claude or ollama
+	Type  string `yaml:"type"`
+	Model string `yaml:"model"`
+	// URL is the server of an Ollama provider
+	URL    string        `yaml:"url"`
+	Limits *LimitsConfig `yaml:"limits"`
+}
+
+// LimitsConfig configures the rate limits of a provider, shared by all the calls of the process
+type LimitsConfig struct {
+	RequestsPerMinute int `yaml:"requestsPerMinute"`
+	// TokensPerMinute limits the input and output tokens of the calls
+	TokensPerMinute int `yaml:"tokensPerMinute"`
+	// Concurrency is the maximum number of concurrent calls, 2 by default
+	Concurrency int `yaml:"concurrency"`
+}
+
+// BreakerConfig configures the circuit breaker of each provider
+type BreakerConfig struct {
+	// Failures is the number of consecutive failures opening the breaker
+	Failures int `yaml:"failures"`
+	// Cooldown is how long a provider is skipped once its breaker is open, e.g. 1m
+	Cooldown time.Duration `yaml:"cooldown"`
+}
+
+// RetryConfig configures the retries of rate limited calls and transient server errors
+type RetryConfig struct {
+	// Attempts is the maximum number of attempts of a call, including the first one
+	Attempts int `yaml:"attempts"`
+	// BaseDelay is the delay before the first retry, doubled for every further retry
+	BaseDelay time.Duration `yaml:"baseDelay"`
+	// MaxDelay caps the delay before a retry, e.g. 1m
+	MaxDelay time.Duration `yaml:"maxDelay"`
+}
+
+// FormatOptions returns the formatter options
+func (c *Config) FormatOptions() mermaid.FormatOptions {
+	return mermaid.FormatOptions{SortMembers: c.Format.SortMembers, SortNodes: c.Format.SortNodes}
+}
+
+// Load reads the configuration file at path. An empty path loads DefaultFile if it exists
+// and returns an empty configuration otherwise.
+func Load(path string) (*Config, error) {
+	explicit := path != ""
+	if !explicit {
+		path = DefaultFile
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return &Config{}, nil
+		}
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(content, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	for _, dir := range []*string{&cfg.Prompts.Templates, &cfg.Prompts.ExamplesDir} {
+		if *dir != "" && !filepath.IsAbs(*dir) {
+			*dir = filepath.Join(filepath.Dir(path), *dir)
+		}
+	}
+	for i, example := range cfg.Prompts.Examples {
+		if example.DiagramType == "" || example.Code == "" || example.Diagram == "" {
+			return nil, fmt.Errorf("config %s: example %d needs a type, code and diagram", path, i+1)
+		}
+		if example.Name == "" {
+			cfg.Prompts.Examples[i].Name = fmt.Sprintf("%s example %d", example.DiagramType, i+1)
+		}
+		cfg.Prompts.Examples[i].Source = path
+	}
+	for i, provider := range cfg.LLM.Providers {
+		if provider.Type != "claude" && provider.Type != "ollama" {
+			return nil, fmt.Errorf("config %s: provider %d has type %q, should be claude or ollama", path, i+1, provider.Type)
+		}
+	}
+	return &cfg, nil
+}
+
+// LintConfig returns the lint configuration with the defaults for unset values
+func (c *Config) LintConfig() (mermaid.LintConfig, error) {
+	cfg := mermaid.DefaultLintConfig()
+	if c.Lint.MaxLabelLength > 0 {
+		cfg.MaxLabelLength = c.Lint.MaxLabelLength
+	}
+	if c.Lint.MaxNodes > 0 {
+		cfg.MaxNodes = c.Lint.MaxNodes
+	}
+
+	for name, value := range c.Lint.Rules {
+		if !knownRule(name) {
+			return cfg, fmt.Errorf("unknown lint rule: %s", name)
+		}
+		severity, err := mermaid.ParseSeverity(value)
+		if err != nil {
+			return cfg, fmt.Errorf("lint rule %s: %w", name, err)
+		}
+		cfg.Severities[name] = severity
+	}
+	return cfg, nil
+}
+
+func knownRule(name string) bool {
+	for _, rule := range mermaid.LintRules {
+		if rule.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package eval
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"mm-go-agent/internal/adapter/llm"
+)
+
+// Interaction is a prompt and the completion the LLM returned for it
+type Interaction struct {
+	PromptSHA256 string `json:"prompt_sha256"`
+	Prompt       string `json:"prompt"`
+	Completion   string `json:"completion"`
+}
+
+// Cassette holds recorded LLM interactions, so that evaluations can be replayed without a provider
+type Cassette struct {
+	Model        string        `json:"model,omitempty"`
+	Interactions []Interaction `json:"interactions"`
+
+	mu sync.Mutex
+	// replayed counts the completions replayed per prompt hash, a prompt sent several times
+	// replays its recorded completions in order
+	replayed map[string]int
+}
+
+// LoadCassette reads a cassette file
+func LoadCassette(path string) (*Cassette, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette %s: %w", path, err)
+	}
+
+	var c Cassette
+	if err := json.Unmarshal(content, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to a file
+func (c *Cassette) Save(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	content, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	if err := os.WriteFile(path, append(content, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write cassette %s: %w", path, err)
+	}
+	return nil
+}
+
+// Recorder returns an adapter that sends prompts to the LLM and records the completions
+func (c *Cassette) Recorder(adapter llm.LLMAdapter) llm.LLMAdapter {
+	return &cassetteAdapter{cassette: c, adapter: adapter}
+}
+
+// Replayer returns an adapter that answers prompts with the recorded completions only
+func (c *Cassette) Replayer() llm.LLMAdapter {
+	return &cassetteAdapter{cassette: c}
+}
+
+// cassetteAdapter records completions of an adapter, or replays them without one
+type cassetteAdapter struct {
+	cassette *Cassette
+	adapter  llm.LLMAdapter
+}
+
+// GenerateCompletion implements llm.LLMAdapter
+func (a *cassetteAdapter) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	if a.adapter == nil {
+		return a.cassette.replay(prompt)
+	}
+
+	completion, err := a.adapter.GenerateCompletion(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	a.cassette.record(prompt, completion)
+	return completion, nil
+}
+
+func (c *Cassette) record(prompt, completion string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Interactions = append(c.Interactions, Interaction{PromptSHA256: promptHash(prompt), Prompt: prompt, Completion: completion})
+}
+
+func (c *Cassette) replay(prompt string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.replayed == nil {
+		c.replayed = make(map[string]int)
+	}
+
+	hash := promptHash(prompt)
+	seen := 0
+	for _, interaction := range c.Interactions {
+		if interaction.PromptSHA256 != hash {
+			continue
+		}
+		if seen == c.replayed[hash] {
+			c.replayed[hash]++
+			return interaction.Completion, nil
+		}
+		seen++
+	}
+	if seen == 0 {
+		return "", fmt.Errorf("no recorded completion for prompt %s, record the cassette again", hash[:12])
+	}
+	return "", fmt.Errorf("prompt %s was recorded %d times but sent more often", hash[:12], seen)
+}
+
+// promptHash identifies a prompt in a cassette
+func promptHash(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:])
+}
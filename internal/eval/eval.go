@@ -0,0 +1,229 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mm-go-agent/internal/adapter/llm"
+	"mm-go-agent/internal/repository"
+	"mm-go-agent/internal/service"
+	"mm-go-agent/internal/service/diagram"
+	"mm-go-agent/pkg/analysis"
+	"mm-go-agent/pkg/mermaid"
+	"mm-go-agent/pkg/prompt"
+)
+
+// DefaultDiagramTypes are the diagram types generated by the LLM from a single prompt template
+var DefaultDiagramTypes = []string{"basic", "class", "sequence", "flowchart", "project", "config", "adapters"}
+
+// Option configures an Evaluator
+type Option func(*Evaluator)
+
+// WithDiagramTypes sets the diagram types generated for every fixture
+func WithDiagramTypes(diagramTypes []string) Option {
+	return func(e *Evaluator) {
+		e.diagramTypes = diagramTypes
+	}
+}
+
+// WithPrompts sets the prompt templates and examples being evaluated
+func WithPrompts(promptMgr *prompt.TemplateManager) Option {
+	return func(e *Evaluator) {
+		e.promptMgr = promptMgr
+	}
+}
+
+// WithProgress prints a line per evaluated case to w
+func WithProgress(w io.Writer) Option {
+	return func(e *Evaluator) {
+		e.progress = w
+	}
+}
+
+// Evaluator generates diagrams for a corpus of Go fixtures and scores them
+type Evaluator struct {
+	corpus       string
+	adapter      *countingAdapter
+	diagramTypes []string
+	promptMgr    *prompt.TemplateManager
+	progress     io.Writer
+}
+
+// NewEvaluator creates an evaluator for the fixtures in the corpus directory. Every Go file
+// directly in the corpus is a file fixture and every subdirectory a directory fixture.
+func NewEvaluator(corpus string, adapter llm.LLMAdapter, opts ...Option) *Evaluator {
+	e := &Evaluator{
+		corpus:       corpus,
+		adapter:      &countingAdapter{adapter: adapter},
+		diagramTypes: DefaultDiagramTypes,
+		progress:     io.Discard,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Fixtures returns the fixtures of the corpus, sorted
+func (e *Evaluator) Fixtures() ([]string, error) {
+	entries, err := os.ReadDir(e.corpus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read corpus: %w", err)
+	}
+
+	var fixtures []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || (filepath.Ext(name) == ".go" && !strings.HasSuffix(name, "_test.go")) {
+			fixtures = append(fixtures, name)
+		}
+	}
+	if len(fixtures) == 0 {
+		return nil, fmt.Errorf("no Go files or directories found in corpus %s", e.corpus)
+	}
+	sort.Strings(fixtures)
+	return fixtures, nil
+}
+
+// Run generates every diagram type for every fixture, one at a time so that the LLM calls of
+// each case can be counted
+func (e *Evaluator) Run(ctx context.Context) (*Report, error) {
+	fixtures, err := e.Fixtures()
+	if err != nil {
+		return nil, err
+	}
+
+	repo := repository.NewFileRepository(e.corpus)
+	opts := []service.Option{service.WithPackageDir(e.corpus)}
+	if e.promptMgr != nil {
+		opts = append(opts, service.WithPrompts(e.promptMgr))
+	}
+	diagramService := service.NewDiagramService(repo, e.adapter, opts...)
+
+	report := &Report{Corpus: e.corpus, Date: time.Now().UTC()}
+	for _, fixture := range fixtures {
+		for _, diagramType := range e.diagramTypes {
+			result := e.evaluate(ctx, repo, diagramService, fixture, diagramType)
+			fmt.Fprintf(e.progress, "%s\n", result.summary())
+			report.Results = append(report.Results, result)
+		}
+	}
+	report.summarize()
+	return report, nil
+}
+
+// evaluate generates and scores the diagram of one fixture
+func (e *Evaluator) evaluate(ctx context.Context, repo repository.FileRepository, diagramService service.DiagramService, fixture, diagramType string) Result {
+	result := Result{Fixture: fixture, DiagramType: diagramType}
+
+	var files []string
+	var generated string
+	var err error
+	e.adapter.reset()
+	start := time.Now()
+	if filepath.Ext(fixture) == ".go" {
+		files = []string{fixture}
+		generated, err = diagramService.GenerateDiagram(ctx, fixture, diagramType)
+	} else {
+		if files, err = repo.FindSourceFiles(fixture); err == nil {
+			generated, err = diagramService.GenerateDirectoryDiagram(ctx, fixture, diagramType)
+		}
+	}
+	result.Duration = time.Since(start).Round(time.Millisecond).Seconds()
+	result.LLMCalls = e.adapter.count()
+	if result.LLMCalls > 1 {
+		result.FixIterations = result.LLMCalls - 1
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	generated = diagram.NewProcessor().CleanDiagramOutput(generated)
+	result.Diagram = generated
+	validation := mermaid.ValidateSyntax(generated)
+	result.Valid = validation.IsValid
+	if !result.Valid {
+		result.Error = fmt.Sprintf("%d syntax errors remain", len(validation.Errors))
+		return result
+	}
+
+	if err := e.score(&result, files); err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// score checks the diagram against the symbols of the fixture files, the ground truth
+func (e *Evaluator) score(result *Result, files []string) error {
+	var sources []analysis.SourceFile
+	for _, file := range files {
+		content, err := os.ReadFile(filepath.Join(e.corpus, file))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		sources = append(sources, analysis.SourceFile{Path: file, Content: string(content)})
+	}
+
+	symbols, err := analysis.CollectSymbols(sources)
+	if err != nil {
+		return fmt.Errorf("failed to collect symbols: %w", err)
+	}
+	parsed, err := mermaid.Parse(result.Diagram)
+	if err != nil {
+		return fmt.Errorf("failed to parse diagram: %w", err)
+	}
+
+	verification := analysis.Verify(parsed, symbols)
+	if !verification.Supported {
+		return nil
+	}
+
+	precision := 1.0
+	if verification.Checked > 0 {
+		precision = float64(verification.Matched) / float64(verification.Checked)
+	}
+	recall := verification.Coverage / 100
+	result.Precision = &precision
+	result.Recall = &recall
+	for _, finding := range verification.Hallucinated {
+		result.Hallucinated = append(result.Hallucinated, finding.Kind+" "+finding.Entity)
+	}
+	result.MissingTypes = verification.MissingTypes
+	return nil
+}
+
+// countingAdapter counts the completions requested from an adapter
+type countingAdapter struct {
+	adapter llm.LLMAdapter
+
+	mu    sync.Mutex
+	calls int
+}
+
+// GenerateCompletion implements llm.LLMAdapter
+func (a *countingAdapter) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	a.mu.Lock()
+	a.calls++
+	a.mu.Unlock()
+	return a.adapter.GenerateCompletion(ctx, prompt)
+}
+
+func (a *countingAdapter) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.calls = 0
+}
+
+func (a *countingAdapter) count() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.calls
+}
@@ -0,0 +1,137 @@
+package eval
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// scriptedLLM answers class prompts with a diagram showing one type that isn't in the code,
+// flowchart prompts with invalid syntax and fix prompts with a valid flowchart
+type scriptedLLM struct {
+	prompts []string
+}
+
+func (l *scriptedLLM) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	l.prompts = append(l.prompts, prompt)
+	switch {
+	case strings.Contains(prompt, "syntax expert"):
+		return "flowchart TD\n  A --> B", nil
+	case strings.Contains(prompt, "flowchart"):
+		return "flowchart TD\n  A[Start --> B(", nil
+	default:
+		return "classDiagram\n  class Order {\n    +Total() int64\n  }\n  class Invoice", nil
+	}
+}
+
+// EvalTestSuite is a test suite for evaluating prompts on a corpus
+type EvalTestSuite struct {
+	suite.Suite
+	corpus string
+}
+
+// SetupTest writes the corpus
+func (s *EvalTestSuite) SetupTest() {
+	s.corpus = s.T().TempDir()
+	files := map[string]string{
+		"order.go":         "package shop\n\n// Order is an order\ntype Order struct{ ID string }\n\nfunc (o *Order) Total() int64 { return 0 }\n\n// Item is a line\ntype Item struct{}\n",
+		"order_test.go":    "package shop\n",
+		"billing/bill.go":  "package billing\n\n// Bill is a bill\ntype Bill struct{}\n",
+		"notes.txt":        "not a fixture",
+		"billing/notes.md": "not a source",
+	}
+	for name, content := range files {
+		path := filepath.Join(s.corpus, name)
+		s.Require().NoError(os.MkdirAll(filepath.Dir(path), 0o755))
+		s.Require().NoError(os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+// TestRun checks the scores of valid, fixed and hallucinating diagrams
+func (s *EvalTestSuite) TestRun() {
+	llm := &scriptedLLM{}
+	evaluator := NewEvaluator(s.corpus, llm, WithDiagramTypes([]string{"class", "flowchart"}))
+
+	fixtures, err := evaluator.Fixtures()
+	s.Require().NoError(err)
+	s.Equal([]string{"billing", "order.go"}, fixtures)
+
+	report, err := evaluator.Run(context.Background())
+	s.Require().NoError(err)
+	s.Require().Len(report.Results, 4)
+
+	class := report.Results[2]
+	s.Equal("order.go", class.Fixture)
+	s.True(class.Valid)
+	s.Equal(0, class.FixIterations)
+	s.Require().NotNil(class.Precision)
+	s.InDelta(2.0/3, *class.Precision, 0.001)
+	s.InDelta(0.5, *class.Recall, 0.001)
+	s.Equal([]string{"class Invoice"}, class.Hallucinated)
+	s.Equal([]string{"Item"}, class.MissingTypes)
+
+	flowchart := report.Results[3]
+	s.True(flowchart.Valid)
+	s.Equal(2, flowchart.LLMCalls)
+	s.Equal(1, flowchart.FixIterations)
+	s.Nil(flowchart.Precision)
+
+	s.Require().Len(report.Summaries, 2)
+	s.Equal("class", report.Summaries[0].DiagramType)
+	s.Equal(2, report.Summaries[0].Cases)
+	s.Equal(1.0, report.Summaries[1].ValidRate)
+	s.Equal(1.0, report.Summaries[1].AvgFixIterations)
+
+	markdown := report.Markdown(nil)
+	s.Contains(markdown, "| flowchart | 2 | 100% | 1.00 | - | - |")
+	s.Contains(markdown, "not in code: class Invoice")
+}
+
+// TestBaseline checks the changes and regressions from a baseline report
+func (s *EvalTestSuite) TestBaseline() {
+	precision, basePrecision := 0.5, 0.75
+	report := &Report{Summaries: []Summary{{DiagramType: "class", Cases: 2, ValidRate: 1, AvgFixIterations: 0.5, Precision: &precision, Recall: &precision}}}
+	baseline := &Report{Summaries: []Summary{{DiagramType: "class", Cases: 2, ValidRate: 1, AvgFixIterations: 1, Precision: &basePrecision, Recall: &precision}}}
+
+	s.Contains(report.Markdown(baseline), "| class | 2 | 100% | 0.50 (-0.50) | 50% (-25) | 50% |")
+	s.Equal([]string{"class: precision"}, report.Regressions(baseline))
+	s.Equal([]string{"class: fix iterations"}, baseline.Regressions(report))
+}
+
+// TestCassette checks that recorded completions are replayed in order
+func (s *EvalTestSuite) TestCassette() {
+	path := filepath.Join(s.T().TempDir(), "cassette.json")
+	llm := &scriptedLLM{}
+
+	_, err := LoadCassette(path)
+	s.Error(err)
+
+	cassette := &Cassette{Model: "test-model"}
+	report, err := NewEvaluator(s.corpus, cassette.Recorder(llm), WithDiagramTypes([]string{"flowchart"})).Run(context.Background())
+	s.Require().NoError(err)
+	s.Require().NoError(cassette.Save(path))
+	calls := len(llm.prompts)
+
+	replayed, err := LoadCassette(path)
+	s.Require().NoError(err)
+	s.Equal("test-model", replayed.Model)
+	replay, err := NewEvaluator(s.corpus, replayed.Replayer(), WithDiagramTypes([]string{"flowchart"})).Run(context.Background())
+	s.Require().NoError(err)
+	s.Equal(calls, len(llm.prompts), "replaying shouldn't call the LLM")
+	for i := range report.Results {
+		report.Results[i].Duration, replay.Results[i].Duration = 0, 0
+	}
+	s.Equal(report.Results, replay.Results)
+
+	_, err = replayed.Replayer().GenerateCompletion(context.Background(), "unknown prompt")
+	s.ErrorContains(err, "no recorded completion")
+}
+
+// TestEvalTestSuite runs the eval test suite
+func TestEvalTestSuite(t *testing.T) {
+	suite.Run(t, new(EvalTestSuite))
+}
@@ -0,0 +1,263 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"mm-go-agent/internal/usage"
+)
+
+// Result is the score of the diagram generated for a fixture
+type Result struct {
+	Fixture     string `json:"fixture"`
+	DiagramType string `json:"diagram_type"`
+	// Valid reports whether the final diagram, after the fix loop, has valid syntax
+	Valid bool `json:"valid"`
+	// LLMCalls counts the completions, FixIterations those after the first one
+	LLMCalls      int `json:"llm_calls"`
+	FixIterations int `json:"fix_iterations"`
+	// Precision is the share of diagram entities found in the code and Recall the share of
+	// exported types shown, for class and sequence diagrams only
+	Precision    *float64 `json:"precision,omitempty"`
+	Recall       *float64 `json:"recall,omitempty"`
+	Hallucinated []string `json:"hallucinated,omitempty"`
+	MissingTypes []string `json:"missing_types,omitempty"`
+	// Duration is the generation time in seconds
+	Duration float64 `json:"duration"`
+	Error    string  `json:"error,omitempty"`
+	Diagram  string  `json:"diagram,omitempty"`
+}
+
+// summary formats the result on one line
+func (r Result) summary() string {
+	status := "valid"
+	if !r.Valid {
+		status = "invalid"
+	}
+	line := fmt.Sprintf("%s %s: %s, %d fix iterations", r.Fixture, r.DiagramType, status, r.FixIterations)
+	if r.Precision != nil {
+		line += fmt.Sprintf(", precision %.2f, recall %.2f", *r.Precision, *r.Recall)
+	}
+	if r.Error != "" {
+		line += " (" + r.Error + ")"
+	}
+	return line
+}
+
+// Summary aggregates the results of a diagram type
+type Summary struct {
+	DiagramType string  `json:"diagram_type"`
+	Cases       int     `json:"cases"`
+	ValidRate   float64 `json:"valid_rate"`
+	// AvgFixIterations averages all cases, Precision and Recall the scored ones
+	AvgFixIterations float64  `json:"avg_fix_iterations"`
+	Precision        *float64 `json:"precision,omitempty"`
+	Recall           *float64 `json:"recall,omitempty"`
+}
+
+// Report is the result of an evaluation
+type Report struct {
+	Corpus    string    `json:"corpus"`
+	Model     string    `json:"model,omitempty"`
+	Date      time.Time `json:"date"`
+	Summaries []Summary `json:"summaries"`
+	Results   []Result  `json:"results"`
+	// Usage is the token usage of the LLM calls of the evaluation
+	Usage *usage.Report `json:"usage,omitempty"`
+}
+
+// summarize computes the summary of each diagram type, in the order of the results
+func (r *Report) summarize() {
+	r.Summaries = nil
+	index := make(map[string]int)
+	scored := make(map[string]int)
+	for _, result := range r.Results {
+		i, ok := index[result.DiagramType]
+		if !ok {
+			i = len(r.Summaries)
+			index[result.DiagramType] = i
+			r.Summaries = append(r.Summaries, Summary{DiagramType: result.DiagramType})
+		}
+
+		s := &r.Summaries[i]
+		s.Cases++
+		if result.Valid {
+			s.ValidRate++
+		}
+		s.AvgFixIterations += float64(result.FixIterations)
+		if result.Precision != nil {
+			if s.Precision == nil {
+				s.Precision, s.Recall = new(float64), new(float64)
+			}
+			*s.Precision += *result.Precision
+			*s.Recall += *result.Recall
+			scored[result.DiagramType]++
+		}
+	}
+
+	for i := range r.Summaries {
+		s := &r.Summaries[i]
+		s.ValidRate /= float64(s.Cases)
+		s.AvgFixIterations /= float64(s.Cases)
+		if n := scored[s.DiagramType]; n > 0 {
+			*s.Precision /= float64(n)
+			*s.Recall /= float64(n)
+		}
+	}
+}
+
+// LoadReport reads a JSON report, e.g. a baseline to compare with
+func LoadReport(path string) (*Report, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report %s: %w", path, err)
+	}
+
+	var r Report
+	if err := json.Unmarshal(content, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse report %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// JSON encodes the report
+func (r *Report) JSON() ([]byte, error) {
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode report: %w", err)
+	}
+	return append(content, '\n'), nil
+}
+
+// Markdown formats the report, with the changes from a baseline report if one is given
+func (r *Report) Markdown(baseline *Report) string {
+	var b strings.Builder
+	b.WriteString("# Prompt evaluation\n\n")
+	b.WriteString(fmt.Sprintf("Corpus `%s`", r.Corpus))
+	if r.Model != "" {
+		b.WriteString(fmt.Sprintf(", model `%s`", r.Model))
+	}
+	b.WriteString(fmt.Sprintf(", %s\n\n", r.Date.Format(time.RFC3339)))
+	if r.Usage != nil {
+		b.WriteString(fmt.Sprintf("LLM usage: %s\n\n", r.Usage.Total.Summary(r.Usage.Priced)))
+	}
+
+	b.WriteString("## Summary\n\n")
+	b.WriteString("| Diagram type | Cases | Valid | Fix iterations | Precision | Recall |\n")
+	b.WriteString("|---|---|---|---|---|---|\n")
+	for _, s := range r.Summaries {
+		var base *Summary
+		if baseline != nil {
+			base = baseline.summary(s.DiagramType)
+		}
+		b.WriteString(fmt.Sprintf("| %s | %d | %s | %s | %s | %s |\n", s.DiagramType, s.Cases,
+			withChange(percent(&s.ValidRate), s.ValidRate, baseValue(base, func(s *Summary) *float64 { return &s.ValidRate }), true),
+			withChange(fmt.Sprintf("%.2f", s.AvgFixIterations), s.AvgFixIterations, baseValue(base, func(s *Summary) *float64 { return &s.AvgFixIterations }), false),
+			withChange(percent(s.Precision), value(s.Precision), baseValue(base, func(s *Summary) *float64 { return s.Precision }), true),
+			withChange(percent(s.Recall), value(s.Recall), baseValue(base, func(s *Summary) *float64 { return s.Recall }), true)))
+	}
+
+	b.WriteString("\n## Cases\n\n")
+	b.WriteString("| Fixture | Diagram type | Valid | Fix iterations | Precision | Recall | Notes |\n")
+	b.WriteString("|---|---|---|---|---|---|---|\n")
+	for _, result := range r.Results {
+		valid := "yes"
+		if !result.Valid {
+			valid = "no"
+		}
+		var notes []string
+		if result.Error != "" {
+			notes = append(notes, result.Error)
+		}
+		if len(result.Hallucinated) > 0 {
+			notes = append(notes, "not in code: "+strings.Join(result.Hallucinated, ", "))
+		}
+		if len(result.MissingTypes) > 0 {
+			notes = append(notes, "missing: "+strings.Join(result.MissingTypes, ", "))
+		}
+		b.WriteString(fmt.Sprintf("| %s | %s | %s | %d | %s | %s | %s |\n", result.Fixture, result.DiagramType, valid,
+			result.FixIterations, percent(result.Precision), percent(result.Recall), markdownCell(strings.Join(notes, "; "))))
+	}
+	return b.String()
+}
+
+// summary returns the summary of a diagram type, or nil
+func (r *Report) summary(diagramType string) *Summary {
+	for i := range r.Summaries {
+		if r.Summaries[i].DiagramType == diagramType {
+			return &r.Summaries[i]
+		}
+	}
+	return nil
+}
+
+// Regressions lists the diagram types whose validity, precision or recall dropped, or whose
+// fix iterations rose, compared with a baseline
+func (r *Report) Regressions(baseline *Report) []string {
+	var regressions []string
+	for _, s := range r.Summaries {
+		base := baseline.summary(s.DiagramType)
+		if base == nil {
+			continue
+		}
+		var metrics []string
+		if s.ValidRate < base.ValidRate {
+			metrics = append(metrics, "valid")
+		}
+		if s.AvgFixIterations > base.AvgFixIterations {
+			metrics = append(metrics, "fix iterations")
+		}
+		if s.Precision != nil && base.Precision != nil && *s.Precision < *base.Precision {
+			metrics = append(metrics, "precision")
+		}
+		if s.Recall != nil && base.Recall != nil && *s.Recall < *base.Recall {
+			metrics = append(metrics, "recall")
+		}
+		if len(metrics) > 0 {
+			regressions = append(regressions, fmt.Sprintf("%s: %s", s.DiagramType, strings.Join(metrics, ", ")))
+		}
+	}
+	return regressions
+}
+
+func percent(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", *v*100)
+}
+
+func value(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// baseValue returns a metric of a baseline summary, or nil
+func baseValue(base *Summary, metric func(*Summary) *float64) *float64 {
+	if base == nil {
+		return nil
+	}
+	return metric(base)
+}
+
+// withChange appends the change from the baseline value to a formatted metric. Rates are
+// shown in percentage points.
+func withChange(formatted string, current float64, base *float64, rate bool) string {
+	if base == nil || formatted == "-" || current == *base {
+		return formatted
+	}
+	if rate {
+		return fmt.Sprintf("%s (%+.0f)", formatted, (current-*base)*100)
+	}
+	return fmt.Sprintf("%s (%+.2f)", formatted, current-*base)
+}
+
+// markdownCell escapes a value for a Markdown table cell
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
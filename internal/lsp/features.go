@@ -0,0 +1,389 @@
+package lsp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"mm-go-agent/pkg/mermaid"
+)
+
+// diagnosticSource is the source shown with every diagnostic
+const diagnosticSource = "mm-gen"
+
+// syntaxCode is the code of diagnostics reported by the validator
+const syntaxCode = "syntax"
+
+// diagnostics validates a document and runs the lint rules on it
+func (s *Server) diagnostics(text string) []Diagnostic {
+	lines := splitLines(text)
+	diagnostics := []Diagnostic{}
+
+	result := mermaid.ValidateSyntax(text)
+	for _, e := range result.Errors {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    lineRange(lines, e.Line),
+			Severity: SeverityError,
+			Code:     syntaxCode,
+			Source:   diagnosticSource,
+			Message:  e.Message,
+		})
+	}
+	if !result.IsValid && len(result.Errors) == 0 {
+		diagnostics = append(diagnostics, Diagnostic{
+			Severity: SeverityError,
+			Code:     syntaxCode,
+			Source:   diagnosticSource,
+			Message:  strings.TrimSpace(result.ErrorMsg),
+		})
+	}
+
+	issues, err := mermaid.Lint(text, s.lintCfg)
+	if err != nil {
+		return diagnostics
+	}
+	for _, issue := range issues {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    lineRange(lines, issue.Line),
+			Severity: diagnosticSeverity(issue.Severity),
+			Code:     issue.Rule,
+			Source:   diagnosticSource,
+			Message:  issue.Message,
+		})
+	}
+	return diagnostics
+}
+
+func diagnosticSeverity(severity mermaid.Severity) DiagnosticSeverity {
+	switch severity {
+	case mermaid.SeverityError:
+		return SeverityError
+	case mermaid.SeverityWarning:
+		return SeverityWarning
+	default:
+		return SeverityInformation
+	}
+}
+
+// codeActions offers the automatic fixes and, for syntax errors, a fix by the LLM
+func (s *Server) codeActions(params CodeActionParams, text string) []CodeAction {
+	actions := []CodeAction{}
+	uri := params.TextDocument.URI
+
+	if fixed, applied := mermaid.AutoFix(text); len(applied) > 0 {
+		actions = append(actions, CodeAction{
+			Title:       fmt.Sprintf("Apply automatic fixes (%s)", strings.Join(applied, ", ")),
+			Kind:        "quickfix",
+			Diagnostics: params.Context.Diagnostics,
+			Edit:        &WorkspaceEdit{Changes: map[string][]TextEdit{uri: {replaceAll(text, fixed)}}},
+		})
+	}
+
+	var syntaxErrors []Diagnostic
+	for _, d := range params.Context.Diagnostics {
+		if d.Code == syntaxCode {
+			syntaxErrors = append(syntaxErrors, d)
+		}
+	}
+	if len(syntaxErrors) > 0 && s.llmClient != nil {
+		actions = append(actions, CodeAction{
+			Title:       "Fix syntax errors with LLM",
+			Kind:        "quickfix",
+			Diagnostics: syntaxErrors,
+			Command:     &Command{Title: "Fix syntax errors with LLM", Command: CommandFixWithLLM, Arguments: []any{uri}},
+		})
+	}
+	return actions
+}
+
+// format prints the document in the canonical layout
+func format(text string, opts mermaid.FormatOptions) ([]TextEdit, error) {
+	formatted, err := mermaid.Format(text, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse diagram: %w", err)
+	}
+
+	if formatted == text {
+		return []TextEdit{}, nil
+	}
+	return []TextEdit{replaceAll(text, formatted)}, nil
+}
+
+// replaceAll is an edit replacing the whole text
+func replaceAll(text, newText string) TextEdit {
+	lines := splitLines(text)
+	last := lines[len(lines)-1]
+	return TextEdit{
+		Range:   Range{End: Position{Line: len(lines) - 1, Character: utf16Len(last)}},
+		NewText: newText,
+	}
+}
+
+func splitLines(text string) []string {
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
+
+// entity is a diagram element that can be referenced by its ID
+type entity struct {
+	id    string
+	line  int
+	hover string
+}
+
+// lookup finds the node, class, participant, subgraph or namespace with the ID
+func lookup(d *mermaid.Diagram, id string) *entity {
+	if id == "" {
+		return nil
+	}
+	if sg := d.Subgraph(id); sg != nil {
+		return &entity{id: id, line: sg.Line, hover: subgraphHover(d, sg)}
+	}
+	if n := d.Node(id); n != nil {
+		return &entity{id: id, line: n.Line, hover: nodeHover(d, n)}
+	}
+	if c := d.Class(id); c != nil {
+		return &entity{id: id, line: c.Line, hover: classHover(d, c)}
+	}
+	if p := d.Participant(id); p != nil {
+		return &entity{id: id, line: p.Line, hover: participantHover(d, p)}
+	}
+	if ns := d.Namespace(id); ns != nil {
+		return &entity{id: id, line: ns.Line, hover: fmt.Sprintf("**namespace** `%s`\n\nClasses: %s", ns.Name, codeList(ns.Classes))}
+	}
+	return nil
+}
+
+func hover(text string, pos Position) *Hover {
+	d, err := mermaid.Parse(text)
+	if err != nil {
+		return nil
+	}
+	lines := splitLines(text)
+	word := wordAt(lines, pos)
+	e := lookup(d, word)
+	if e == nil {
+		return nil
+	}
+
+	r := wordRange(lines, pos.Line+1, word)
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: e.hover}, Range: &r}
+}
+
+func definition(uri, text string, pos Position) []Location {
+	d, err := mermaid.Parse(text)
+	if err != nil {
+		return nil
+	}
+	lines := splitLines(text)
+	e := lookup(d, wordAt(lines, pos))
+	if e == nil || e.line == 0 {
+		return nil
+	}
+	return []Location{{URI: uri, Range: wordRange(lines, e.line, e.id)}}
+}
+
+func nodeHover(d *mermaid.Diagram, n *mermaid.Node) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("**node** `%s`", n.ID))
+	if n.Label != "" {
+		b.WriteString(fmt.Sprintf(" %s", n.Label))
+	}
+	if n.Subgraph != "" {
+		b.WriteString(fmt.Sprintf("\n\nIn subgraph `%s`", n.Subgraph))
+	}
+
+	var out, in []string
+	for _, e := range d.Edges {
+		if e.From == n.ID {
+			out = append(out, e.To)
+		}
+		if e.To == n.ID {
+			in = append(in, e.From)
+		}
+	}
+	if len(out) > 0 {
+		b.WriteString(fmt.Sprintf("\n\nLinks to: %s", codeList(out)))
+	}
+	if len(in) > 0 {
+		b.WriteString(fmt.Sprintf("\n\nLinked from: %s", codeList(in)))
+	}
+	return b.String()
+}
+
+func subgraphHover(d *mermaid.Diagram, sg *mermaid.Subgraph) string {
+	var nodes []string
+	for _, n := range d.Nodes {
+		if n.Subgraph == sg.ID {
+			nodes = append(nodes, n.ID)
+		}
+	}
+	title := fmt.Sprintf("**subgraph** `%s`", sg.ID)
+	if sg.Label != "" {
+		title += " " + sg.Label
+	}
+	return fmt.Sprintf("%s\n\nNodes: %s", title, codeList(nodes))
+}
+
+func classHover(d *mermaid.Diagram, c *mermaid.ClassEntity) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("**class** `%s`", c.ID))
+	for _, a := range c.Annotations {
+		b.WriteString(fmt.Sprintf(" <<%s>>", a))
+	}
+	if c.Label != "" {
+		b.WriteString(fmt.Sprintf(" %s", c.Label))
+	}
+	if c.Namespace != "" {
+		b.WriteString(fmt.Sprintf("\n\nIn namespace `%s`", c.Namespace))
+	}
+
+	if len(c.Members) > 0 {
+		b.WriteString("\n\n```\n")
+		for _, m := range c.Members {
+			b.WriteString(strings.TrimSpace(m.Text) + "\n")
+		}
+		b.WriteString("```")
+	}
+
+	var relations []string
+	for _, r := range d.Relations {
+		if r.From == c.ID || r.To == c.ID {
+			relations = append(relations, fmt.Sprintf("`%s %s %s`", r.From, r.Arrow, r.To))
+		}
+	}
+	if len(relations) > 0 {
+		b.WriteString("\n\nRelations: " + strings.Join(relations, ", "))
+	}
+	return b.String()
+}
+
+func participantHover(d *mermaid.Diagram, p *mermaid.Participant) string {
+	kind := p.Type
+	if kind == "" {
+		kind = "participant"
+	}
+	title := fmt.Sprintf("**%s** `%s`", kind, p.ID)
+	if p.Alias != "" {
+		title += " " + p.Alias
+	}
+
+	sent, received := 0, 0
+	for _, m := range d.Messages() {
+		if m.From == p.ID {
+			sent++
+		}
+		if m.To == p.ID {
+			received++
+		}
+	}
+	return fmt.Sprintf("%s\n\nSends %d and receives %d messages", title, sent, received)
+}
+
+// codeList formats IDs as a sorted list of code spans
+func codeList(ids []string) string {
+	if len(ids) == 0 {
+		return "none"
+	}
+	sorted := append([]string(nil), ids...)
+	sort.Strings(sorted)
+	for i, id := range sorted {
+		sorted[i] = "`" + id + "`"
+	}
+	return strings.Join(sorted, ", ")
+}
+
+func documentSymbols(text string) []DocumentSymbol {
+	d, err := mermaid.Parse(text)
+	if err != nil {
+		return nil
+	}
+	lines := splitLines(text)
+	symbol := func(name, detail string, kind SymbolKind, line int) DocumentSymbol {
+		return DocumentSymbol{
+			Name:           name,
+			Detail:         detail,
+			Kind:           kind,
+			Range:          lineRange(lines, line),
+			SelectionRange: wordRange(lines, line, name),
+		}
+	}
+
+	symbols := []DocumentSymbol{}
+	switch d.Kind {
+	case mermaid.KindFlowchart:
+		var children func(subgraph string) []DocumentSymbol
+		children = func(subgraph string) []DocumentSymbol {
+			var result []DocumentSymbol
+			for _, sg := range d.Subgraphs {
+				if sg.Parent != subgraph {
+					continue
+				}
+				sym := symbol(sg.ID, sg.Label, SymbolNamespace, sg.Line)
+				if sg.EndLine > sg.Line {
+					sym.Range.End = lineRange(lines, sg.EndLine).End
+				}
+				sym.Children = children(sg.ID)
+				result = append(result, sym)
+			}
+			for _, n := range d.Nodes {
+				if n.Subgraph == subgraph && d.Subgraph(n.ID) == nil {
+					result = append(result, symbol(n.ID, n.Label, SymbolObject, n.Line))
+				}
+			}
+			return result
+		}
+		symbols = append(symbols, children("")...)
+
+	case mermaid.KindClass:
+		namespaces := make(map[string]int)
+		for _, ns := range d.Namespaces {
+			namespaces[ns.Name] = len(symbols)
+			symbols = append(symbols, symbol(ns.Name, "", SymbolNamespace, ns.Line))
+		}
+		for _, c := range d.Classes {
+			sym := classSymbol(c, symbol)
+			if idx, ok := namespaces[c.Namespace]; ok {
+				symbols[idx].Children = append(symbols[idx].Children, sym)
+			} else {
+				symbols = append(symbols, sym)
+			}
+		}
+
+	case mermaid.KindSequence:
+		for _, p := range d.Participants {
+			symbols = append(symbols, symbol(p.ID, p.Alias, SymbolObject, p.Line))
+		}
+	}
+	return symbols
+}
+
+func classSymbol(c *mermaid.ClassEntity, symbol func(name, detail string, kind SymbolKind, line int) DocumentSymbol) DocumentSymbol {
+	kind := SymbolClass
+	for _, a := range c.Annotations {
+		if strings.EqualFold(a, "interface") {
+			kind = SymbolInterface
+		}
+	}
+
+	sym := symbol(c.ID, c.Label, kind, c.Line)
+	for _, m := range c.Members {
+		memberKind := SymbolField
+		if m.IsMethod {
+			memberKind = SymbolMethod
+		}
+		name := m.Name
+		if name == "" {
+			name = strings.TrimSpace(m.Text)
+		}
+		member := symbol(name, strings.TrimSpace(m.Text), memberKind, m.Line)
+		sym.Children = append(sym.Children, member)
+		if member.Range.End.Line > sym.Range.End.Line {
+			sym.Range.End = member.Range.End
+		}
+	}
+	// The closing brace of a class block follows its last member
+	if len(c.Members) > 0 && sym.Range.End.Line > sym.Range.Start.Line {
+		sym.Range.End = Position{Line: sym.Range.End.Line + 1}
+	}
+	return sym
+}
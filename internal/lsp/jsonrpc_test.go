@@ -0,0 +1,63 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"mm-go-agent/pkg/mermaid"
+)
+
+// JSONRPCTestSuite is a test suite for the JSON-RPC framing of the language server
+type JSONRPCTestSuite struct {
+	suite.Suite
+}
+
+// frame frames a message body with its Content-Length header
+func frame(body string) string {
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// TestMalformedJSON checks that a body that isn't JSON is answered with a parse error and a null id
+func (s *JSONRPCTestSuite) TestMalformedJSON() {
+	var out bytes.Buffer
+	server := NewServer(nil, nil, mermaid.DefaultLintConfig(), mermaid.FormatOptions{}, log.New(io.Discard, "", 0))
+	in := frame(`{"jsonrpc": "2.0", "id": 1, "method":`) + frame(`{"jsonrpc": "2.0", "method": "exit"}`)
+	s.Require().NoError(server.Run(context.Background(), strings.NewReader(in), &out))
+
+	body, ok := strings.CutPrefix(out.String(), "Content-Length: ")
+	s.Require().True(ok, out.String())
+	_, body, ok = strings.Cut(body, "\r\n\r\n")
+	s.Require().True(ok, out.String())
+
+	var response map[string]json.RawMessage
+	s.Require().NoError(json.Unmarshal([]byte(body), &response))
+	s.Equal(`"2.0"`, string(response["jsonrpc"]))
+	s.Require().Contains(response, "id")
+	s.Equal("null", string(response["id"]))
+	s.NotContains(response, "result")
+
+	var rpcErr responseError
+	s.Require().NoError(json.Unmarshal(response["error"], &rpcErr))
+	s.Equal(codeParseError, rpcErr.Code)
+}
+
+// TestReply checks that a successful reply has its id and a null result
+func (s *JSONRPCTestSuite) TestReply() {
+	var out bytes.Buffer
+	id := json.RawMessage("7")
+	s.Require().NoError(newConn(strings.NewReader(""), &out).reply(&id, nil))
+	s.Equal(frame(`{"jsonrpc":"2.0","id":7,"result":null}`), out.String())
+}
+
+// TestJSONRPCTestSuite runs the JSON-RPC test suite
+func TestJSONRPCTestSuite(t *testing.T) {
+	suite.Run(t, new(JSONRPCTestSuite))
+}
@@ -0,0 +1,273 @@
+package lsp
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// The subset of the Language Server Protocol types used by the server.
+// Positions are zero-based and characters count UTF-16 code units.
+
+// Position is a zero-based position in a document
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a range in a document, the end is exclusive
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location is a range in a document
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// DiagnosticSeverity is the severity of a diagnostic
+type DiagnosticSeverity int
+
+// Diagnostic severities
+const (
+	SeverityError       DiagnosticSeverity = 1
+	SeverityWarning     DiagnosticSeverity = 2
+	SeverityInformation DiagnosticSeverity = 3
+	SeverityHint        DiagnosticSeverity = 4
+)
+
+// Diagnostic is a problem reported for a document
+type Diagnostic struct {
+	Range    Range              `json:"range"`
+	Severity DiagnosticSeverity `json:"severity"`
+	Code     string             `json:"code,omitempty"`
+	Source   string             `json:"source"`
+	Message  string             `json:"message"`
+}
+
+// PublishDiagnosticsParams is sent with textDocument/publishDiagnostics
+type PublishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Version     int          `json:"version,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// TextDocumentItem is an opened document
+type TextDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Version    int    `json:"version"`
+	Text       string `json:"text"`
+}
+
+// TextDocumentIdentifier identifies a document
+type TextDocumentIdentifier struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version,omitempty"`
+}
+
+// TextDocumentPositionParams is a position in a document
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+// DidOpenTextDocumentParams is sent with textDocument/didOpen
+type DidOpenTextDocumentParams struct {
+	TextDocument TextDocumentItem `json:"textDocument"`
+}
+
+// TextDocumentContentChangeEvent is a full document change
+type TextDocumentContentChangeEvent struct {
+	Text string `json:"text"`
+}
+
+// DidChangeTextDocumentParams is sent with textDocument/didChange
+type DidChangeTextDocumentParams struct {
+	TextDocument   TextDocumentIdentifier           `json:"textDocument"`
+	ContentChanges []TextDocumentContentChangeEvent `json:"contentChanges"`
+}
+
+// DocumentParams is sent with requests and notifications about a whole document
+type DocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// CodeActionParams is sent with textDocument/codeAction
+type CodeActionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+	Context      struct {
+		Diagnostics []Diagnostic `json:"diagnostics"`
+	} `json:"context"`
+}
+
+// TextEdit replaces a range of a document
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// WorkspaceEdit changes documents
+type WorkspaceEdit struct {
+	Changes map[string][]TextEdit `json:"changes"`
+}
+
+// Command is a command executed by the server
+type Command struct {
+	Title     string `json:"title"`
+	Command   string `json:"command"`
+	Arguments []any  `json:"arguments,omitempty"`
+}
+
+// CodeAction is a fix offered for a document
+type CodeAction struct {
+	Title       string         `json:"title"`
+	Kind        string         `json:"kind"`
+	Diagnostics []Diagnostic   `json:"diagnostics,omitempty"`
+	Edit        *WorkspaceEdit `json:"edit,omitempty"`
+	Command     *Command       `json:"command,omitempty"`
+}
+
+// ExecuteCommandParams is sent with workspace/executeCommand
+type ExecuteCommandParams struct {
+	Command   string   `json:"command"`
+	Arguments []string `json:"arguments"`
+}
+
+// ApplyWorkspaceEditParams is sent with workspace/applyEdit
+type ApplyWorkspaceEditParams struct {
+	Label string        `json:"label"`
+	Edit  WorkspaceEdit `json:"edit"`
+}
+
+// ShowMessageParams is sent with window/showMessage
+type ShowMessageParams struct {
+	Type    int    `json:"type"`
+	Message string `json:"message"`
+}
+
+// MarkupContent is Markdown shown by the client
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Hover is the result of textDocument/hover
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
+}
+
+// SymbolKind is the kind of a document symbol
+type SymbolKind int
+
+// Symbol kinds used for diagram entities
+const (
+	SymbolNamespace SymbolKind = 3
+	SymbolClass     SymbolKind = 5
+	SymbolMethod    SymbolKind = 6
+	SymbolField     SymbolKind = 8
+	SymbolInterface SymbolKind = 11
+	SymbolObject    SymbolKind = 19
+)
+
+// DocumentSymbol is an entity of a diagram
+type DocumentSymbol struct {
+	Name           string           `json:"name"`
+	Detail         string           `json:"detail,omitempty"`
+	Kind           SymbolKind       `json:"kind"`
+	Range          Range            `json:"range"`
+	SelectionRange Range            `json:"selectionRange"`
+	Children       []DocumentSymbol `json:"children,omitempty"`
+}
+
+// utf16Len returns the length of s in UTF-16 code units
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// byteOffset converts a UTF-16 character offset in a line to a byte offset
+func byteOffset(line string, character int) int {
+	units := 0
+	for i, r := range line {
+		if units >= character {
+			return i
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return len(line)
+}
+
+// lineRange returns the range of the text of a one-based line, trimmed of indentation
+func lineRange(lines []string, line int) Range {
+	if line < 1 || line > len(lines) {
+		return Range{}
+	}
+	text := lines[line-1]
+	indent := len(text) - len(strings.TrimLeft(text, " \t"))
+	return Range{
+		Start: Position{Line: line - 1, Character: utf16Len(text[:indent])},
+		End:   Position{Line: line - 1, Character: utf16Len(strings.TrimRight(text, " \t\r"))},
+	}
+}
+
+// wordRange returns the range of the first occurrence of word on a one-based line, or
+// the whole line when the word isn't found
+func wordRange(lines []string, line int, word string) Range {
+	if line < 1 || line > len(lines) {
+		return Range{}
+	}
+	text := lines[line-1]
+	idx := indexWord(text, word)
+	if idx < 0 {
+		return lineRange(lines, line)
+	}
+	start := utf16Len(text[:idx])
+	return Range{
+		Start: Position{Line: line - 1, Character: start},
+		End:   Position{Line: line - 1, Character: start + utf16Len(word)},
+	}
+}
+
+// indexWord finds word in text where it isn't part of a longer identifier
+func indexWord(text, word string) int {
+	for offset := 0; offset <= len(text); {
+		idx := strings.Index(text[offset:], word)
+		if idx < 0 {
+			return -1
+		}
+		idx += offset
+		end := idx + len(word)
+		if (idx == 0 || !isWordByte(text[idx-1])) && (end == len(text) || !isWordByte(text[end])) {
+			return idx
+		}
+		offset = idx + 1
+	}
+	return -1
+}
+
+// isWordByte reports whether b can be part of an ID. Dashes can't, so the IDs of links
+// like "A-->B" aren't joined.
+func isWordByte(b byte) bool {
+	return b == '_' || b == '.' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= 0x80
+}
+
+// wordAt returns the identifier under a position in the text
+func wordAt(lines []string, pos Position) string {
+	if pos.Line < 0 || pos.Line >= len(lines) {
+		return ""
+	}
+	text := lines[pos.Line]
+	offset := byteOffset(text, pos.Character)
+
+	start, end := offset, offset
+	for start > 0 && isWordByte(text[start-1]) {
+		start--
+	}
+	for end < len(text) && isWordByte(text[end]) {
+		end++
+	}
+	return strings.Trim(text[start:end], ".")
+}
@@ -0,0 +1,358 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"mm-go-agent/internal/service"
+	"mm-go-agent/pkg/llm"
+	"mm-go-agent/pkg/mermaid"
+	"mm-go-agent/pkg/prompt"
+)
+
+// CommandFixWithLLM asks the LLM to fix the syntax errors of a document
+const CommandFixWithLLM = "mm-gen.fixWithLLM"
+
+// diagnosticsDelay debounces validation while the user is typing
+const diagnosticsDelay = 300 * time.Millisecond
+
+// Server is a Language Server Protocol server for Mermaid diagrams
+type Server struct {
+	conn       *conn
+	validation *service.ValidationService
+	llmClient  llm.Client
+	lintCfg    mermaid.LintConfig
+	formatOpts mermaid.FormatOptions
+	logger     *log.Logger
+
+	mu   sync.Mutex
+	docs map[string]*document
+}
+
+// document is an open text document
+type document struct {
+	version int
+	text    string
+	timer   *time.Timer
+}
+
+// NewServer creates a new language server fixing diagrams with the prompt templates. Without an
+// LLM client only the automatic fixes are offered as code actions.
+func NewServer(llmClient llm.Client, promptMgr *prompt.TemplateManager, lintCfg mermaid.LintConfig, formatOpts mermaid.FormatOptions, logger *log.Logger) *Server {
+	return &Server{
+		validation: service.NewValidationService(llmClient, service.WithValidationPrompts(promptMgr)),
+		llmClient:  llmClient,
+		lintCfg:    lintCfg,
+		formatOpts: formatOpts,
+		logger:     logger,
+		docs:       make(map[string]*document),
+	}
+}
+
+// Run serves requests read from in until the client sends exit or closes the input
+func (s *Server) Run(ctx context.Context, in io.Reader, out io.Writer) error {
+	s.conn = newConn(in, out)
+
+	for {
+		msg, err := s.conn.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var rpcErr *responseError
+		if errors.As(err, &rpcErr) {
+			s.conn.replyError(nil, rpcErr.Code, rpcErr)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.isResponse() {
+			continue
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		result, err := s.handle(ctx, msg)
+		if msg.ID == nil {
+			if err != nil {
+				s.logger.Printf("%s: %v", msg.Method, err)
+			}
+			continue
+		}
+		if err != nil {
+			code := codeInternalError
+			if errors.As(err, &rpcErr) {
+				code = rpcErr.Code
+			}
+			s.conn.replyError(msg.ID, code, err)
+			continue
+		}
+		if result == asyncReply {
+			continue
+		}
+		s.conn.reply(msg.ID, result)
+	}
+}
+
+// asyncReply is returned by handlers that reply to the request from another goroutine
+var asyncReply = &struct{}{}
+
+func (s *Server) handle(ctx context.Context, msg *message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		return s.initialize(), nil
+	case "initialized", "shutdown":
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Version, params.TextDocument.Text, 0)
+		return nil, nil
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) > 0 {
+			// The server asks for full document sync, the last change is the document
+			text := params.ContentChanges[len(params.ContentChanges)-1].Text
+			s.update(params.TextDocument.URI, params.TextDocument.Version, text, diagnosticsDelay)
+		}
+		return nil, nil
+	case "textDocument/didSave":
+		var params DocumentParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		if doc, ok := s.document(params.TextDocument.URI); ok {
+			s.update(params.TextDocument.URI, doc.version, doc.text, 0)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params DocumentParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		s.close(params.TextDocument.URI)
+		return nil, nil
+
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.withDocument(params.TextDocument.URI, func(doc document) (any, error) {
+			return hover(doc.text, params.Position), nil
+		})
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.withDocument(params.TextDocument.URI, func(doc document) (any, error) {
+			return definition(params.TextDocument.URI, doc.text, params.Position), nil
+		})
+	case "textDocument/documentSymbol":
+		var params DocumentParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.withDocument(params.TextDocument.URI, func(doc document) (any, error) {
+			return documentSymbols(doc.text), nil
+		})
+	case "textDocument/formatting":
+		var params DocumentParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.withDocument(params.TextDocument.URI, func(doc document) (any, error) {
+			return format(doc.text, s.formatOpts)
+		})
+	case "textDocument/codeAction":
+		var params CodeActionParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.withDocument(params.TextDocument.URI, func(doc document) (any, error) {
+			return s.codeActions(params, doc.text), nil
+		})
+	case "workspace/executeCommand":
+		var params ExecuteCommandParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		if params.Command != CommandFixWithLLM || len(params.Arguments) != 1 {
+			return nil, &responseError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown command: %s", params.Command)}
+		}
+		// Fixing takes as long as the LLM, other requests are served meanwhile
+		go func() {
+			s.fixWithLLM(ctx, params.Arguments[0])
+			s.conn.reply(msg.ID, nil)
+		}()
+		return asyncReply, nil
+	}
+
+	if msg.ID != nil {
+		return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not supported: %s", msg.Method)}
+	}
+	return nil, nil
+}
+
+func (s *Server) initialize() any {
+	return map[string]any{
+		"capabilities": map[string]any{
+			"textDocumentSync": map[string]any{
+				"openClose": true,
+				"change":    1,
+				"save":      true,
+			},
+			"hoverProvider":              true,
+			"definitionProvider":         true,
+			"documentSymbolProvider":     true,
+			"documentFormattingProvider": true,
+			"codeActionProvider": map[string]any{
+				"codeActionKinds": []string{"quickfix"},
+			},
+			"executeCommandProvider": map[string]any{
+				"commands": []string{CommandFixWithLLM},
+			},
+		},
+		"serverInfo": map[string]any{"name": "mm-gen"},
+	}
+}
+
+func decode(params json.RawMessage, v any) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// update stores a new version of a document and publishes its diagnostics after delay
+func (s *Server) update(uri string, version int, text string, delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, ok := s.docs[uri]
+	if !ok {
+		doc = &document{}
+		s.docs[uri] = doc
+	}
+	doc.version = version
+	doc.text = text
+
+	if doc.timer != nil {
+		doc.timer.Stop()
+	}
+	doc.timer = time.AfterFunc(delay, func() { s.publishDiagnostics(uri) })
+}
+
+func (s *Server) close(uri string) {
+	s.mu.Lock()
+	if doc, ok := s.docs[uri]; ok && doc.timer != nil {
+		doc.timer.Stop()
+	}
+	delete(s.docs, uri)
+	s.mu.Unlock()
+
+	s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: []Diagnostic{}})
+}
+
+// document returns a copy of an open document
+func (s *Server) document(uri string) (document, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, ok := s.docs[uri]
+	if !ok {
+		return document{}, false
+	}
+	return document{version: doc.version, text: doc.text}, true
+}
+
+func (s *Server) withDocument(uri string, fn func(doc document) (any, error)) (any, error) {
+	doc, ok := s.document(uri)
+	if !ok {
+		return nil, &responseError{Code: codeInvalidParams, Message: fmt.Sprintf("document not open: %s", uri)}
+	}
+	return fn(doc)
+}
+
+func (s *Server) publishDiagnostics(uri string) {
+	doc, ok := s.document(uri)
+	if !ok {
+		return
+	}
+
+	diagnostics := s.diagnostics(doc.text)
+
+	// Drop diagnostics of a version that changed while validating
+	if current, ok := s.document(uri); !ok || current.version != doc.version {
+		return
+	}
+	params := PublishDiagnosticsParams{URI: uri, Version: doc.version, Diagnostics: diagnostics}
+	if err := s.conn.notify("textDocument/publishDiagnostics", params); err != nil {
+		s.logger.Printf("failed to publish diagnostics: %v", err)
+	}
+}
+
+// fixWithLLM fixes the syntax errors of a document and asks the client to apply the fix
+func (s *Server) fixWithLLM(ctx context.Context, uri string) {
+	doc, ok := s.document(uri)
+	if !ok {
+		return
+	}
+
+	result := mermaid.ValidateSyntax(doc.text)
+	if result.IsValid {
+		s.showMessage(messageInfo, "The diagram has no syntax errors")
+		return
+	}
+
+	fixed, err := s.validation.FixMermaidDiagramWithLLM(ctx, doc.text, result)
+	if err != nil {
+		if fixed == "" || fixed == doc.text {
+			s.showMessage(messageError, fmt.Sprintf("Failed to fix the diagram: %v", err))
+			return
+		}
+		s.showMessage(messageWarning, "The fixed diagram still has errors")
+	}
+
+	// Don't overwrite edits made while the LLM was working
+	if current, ok := s.document(uri); !ok || current.version != doc.version {
+		s.showMessage(messageWarning, "The diagram changed while it was being fixed, the fix was discarded")
+		return
+	}
+
+	edit := ApplyWorkspaceEditParams{
+		Label: "Fix diagram with LLM",
+		Edit:  WorkspaceEdit{Changes: map[string][]TextEdit{uri: {replaceAll(doc.text, fixed)}}},
+	}
+	if err := s.conn.request("workspace/applyEdit", edit); err != nil {
+		s.logger.Printf("failed to apply fix: %v", err)
+	}
+}
+
+// Message types of window/showMessage
+const (
+	messageError   = 1
+	messageWarning = 2
+	messageInfo    = 3
+)
+
+func (s *Server) showMessage(messageType int, text string) {
+	if err := s.conn.notify("window/showMessage", ShowMessageParams{Type: messageType, Message: text}); err != nil {
+		s.logger.Printf("failed to show message: %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"mm-go-agent/pkg/mermaid"
+)
+
+const testURI = "file:///tmp/diagram.mmd"
+
+const testDiagram = `flowchart TD
+    A[Start] --> B[Run]
+    subgraph S1
+      B --> C
+    end
+    D
+`
+
+// ServerTestSuite is a test suite for the language server, talking to it over pipes
+type ServerTestSuite struct {
+	suite.Suite
+	client *conn
+	in     *io.PipeWriter
+	done   chan error
+	nextID int
+}
+
+// SetupTest starts a server and initializes it
+func (s *ServerTestSuite) SetupTest() {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	s.in = clientOut
+	s.client = newConn(clientIn, clientOut)
+	s.done = make(chan error, 1)
+
+	server := NewServer(nil, nil, mermaid.DefaultLintConfig(), mermaid.FormatOptions{}, log.New(io.Discard, "", 0))
+	go func() { s.done <- server.Run(context.Background(), serverIn, serverOut) }()
+
+	var result map[string]any
+	s.call("initialize", map[string]any{}, &result)
+	s.Contains(result, "capabilities")
+	s.Require().NoError(s.client.notify("initialized", map[string]any{}))
+}
+
+// TearDownTest shuts the server down
+func (s *ServerTestSuite) TearDownTest() {
+	s.call("shutdown", nil, nil)
+	s.Require().NoError(s.client.notify("exit", nil))
+	s.NoError(<-s.done)
+	s.in.Close()
+}
+
+// call sends a request and decodes the result, skipping notifications sent meanwhile
+func (s *ServerTestSuite) call(method string, params, result any) {
+	s.nextID++
+	id := json.RawMessage(strconv.Itoa(s.nextID))
+	raw, err := json.Marshal(params)
+	s.Require().NoError(err)
+	s.Require().NoError(s.client.write(&message{ID: &id, Method: method, Params: raw}))
+
+	for {
+		msg, err := s.client.read()
+		s.Require().NoError(err)
+		if msg.ID == nil || string(*msg.ID) != string(id) {
+			continue
+		}
+		s.Require().Nil(msg.Error)
+		if result != nil {
+			encoded, err := json.Marshal(msg.Result)
+			s.Require().NoError(err)
+			s.Require().NoError(json.Unmarshal(encoded, result))
+		}
+		return
+	}
+}
+
+// open opens a document and returns its first published diagnostics
+func (s *ServerTestSuite) open(text string) []Diagnostic {
+	s.Require().NoError(s.client.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: testURI, LanguageID: "mermaid", Version: 1, Text: text},
+	}))
+
+	for {
+		msg, err := s.client.read()
+		s.Require().NoError(err)
+		if msg.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var params PublishDiagnosticsParams
+		s.Require().NoError(json.Unmarshal(msg.Params, &params))
+		s.Equal(testURI, params.URI)
+		return params.Diagnostics
+	}
+}
+
+// TestDiagnostics checks that lint issues are published
+func (s *ServerTestSuite) TestDiagnostics() {
+	diagnostics := s.open(testDiagram)
+
+	var orphan *Diagnostic
+	for i := range diagnostics {
+		if diagnostics[i].Code == "orphan-nodes" {
+			orphan = &diagnostics[i]
+		}
+	}
+	s.Require().NotNil(orphan)
+	s.Equal(SeverityWarning, orphan.Severity)
+	s.Equal(Range{Start: Position{Line: 5, Character: 4}, End: Position{Line: 5, Character: 5}}, orphan.Range)
+}
+
+// TestHoverAndDefinition checks hovering and jumping from a link to a node declaration
+func (s *ServerTestSuite) TestHoverAndDefinition() {
+	s.open(testDiagram)
+	position := TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: testURI}, Position: Position{Line: 3, Character: 6}}
+
+	var h Hover
+	s.call("textDocument/hover", position, &h)
+	s.Contains(h.Contents.Value, "**node** `B` Run")
+	s.Contains(h.Contents.Value, "Links to: `C`")
+
+	var locations []Location
+	s.call("textDocument/definition", position, &locations)
+	s.Require().Len(locations, 1)
+	s.Equal(Range{Start: Position{Line: 1, Character: 17}, End: Position{Line: 1, Character: 18}}, locations[0].Range)
+}
+
+// TestDocumentSymbols checks that nodes are nested in their subgraphs
+func (s *ServerTestSuite) TestDocumentSymbols() {
+	s.open(testDiagram)
+
+	var symbols []DocumentSymbol
+	s.call("textDocument/documentSymbol", DocumentParams{TextDocument: TextDocumentIdentifier{URI: testURI}}, &symbols)
+
+	var names []string
+	for _, sym := range symbols {
+		names = append(names, sym.Name)
+	}
+	s.Equal([]string{"S1", "A", "B", "D"}, names)
+	s.Require().Len(symbols[0].Children, 1)
+	s.Equal("C", symbols[0].Children[0].Name)
+}
+
+// TestFormattingAndCodeActions checks the formatting edit and the automatic fix action
+func (s *ServerTestSuite) TestFormattingAndCodeActions() {
+	text := "flowchart TD\n  A-->B\n  % comment\n"
+	s.open(text)
+
+	var edits []TextEdit
+	s.call("textDocument/formatting", DocumentParams{TextDocument: TextDocumentIdentifier{URI: testURI}}, &edits)
+	s.Require().Len(edits, 1)
+	s.Equal(Position{Line: 3}, edits[0].Range.End)
+
+	var actions []CodeAction
+	s.call("textDocument/codeAction", CodeActionParams{TextDocument: TextDocumentIdentifier{URI: testURI}}, &actions)
+	s.Require().Len(actions, 1)
+	s.Contains(actions[0].Title, "percent-comments")
+	s.Contains(actions[0].Edit.Changes[testURI][0].NewText, "%% comment")
+}
+
+// TestServerTestSuite runs the language server test suite
+func TestServerTestSuite(t *testing.T) {
+	suite.Run(t, new(ServerTestSuite))
+}
@@ -0,0 +1,229 @@
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mm-go-agent/internal/adapter/llm"
+)
+
+// defaultLabel is the task of LLM calls made without a label
+const defaultLabel = "diagram"
+
+// refreshInterval is the time between redraws of the status line
+const refreshInterval = 200 * time.Millisecond
+
+// Display reports the progress of LLM calls, grouped in tasks by their label. It writes to
+// stderr in the CLI, so that stdout only holds the diagram.
+type Display struct {
+	w io.Writer
+	// live redraws a status line with the running tasks, for terminals
+	live bool
+	now  func() time.Time
+
+	mu    sync.Mutex
+	tasks []*task
+	start time.Time
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// task accumulates the calls of a label
+type task struct {
+	label   string
+	calls   int
+	running int
+	// chars counts the characters received, tokens are estimated from it
+	chars int
+	start time.Time
+}
+
+// call is a call of a task, calls of the same label may run concurrently
+type call struct {
+	task *task
+	// n numbers the calls of the task from 1
+	n     int
+	chars int
+	start time.Time
+}
+
+// New creates a display writing to w. When w is a terminal, a status line showing the running
+// tasks is redrawn until Stop is called.
+func New(w io.Writer) *Display {
+	d := &Display{w: w, live: isTerminal(w), now: time.Now}
+	d.start = d.now()
+	if d.live {
+		d.stop = make(chan struct{})
+		d.done = make(chan struct{})
+		go d.refresh()
+	}
+	return d
+}
+
+// isTerminal reports whether w is a character device
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (d *Display) refresh() {
+	defer close(d.done)
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			d.mu.Lock()
+			d.drawStatus()
+			d.mu.Unlock()
+		}
+	}
+}
+
+// Stop clears the status line and prints the totals of all tasks
+func (d *Display) Stop() {
+	if d.live {
+		close(d.stop)
+		<-d.done
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	calls, chars := 0, 0
+	for _, t := range d.tasks {
+		calls += t.calls
+		chars += t.chars
+	}
+	if calls > 0 {
+		d.println(fmt.Sprintf("%d LLM calls, ~%d tokens received in %s", calls, llm.EstimateTokens(chars), d.since(d.start)))
+	}
+}
+
+// Adapter returns an adapter reporting the calls of adapter on the display, with completions
+// streamed where adapter supports it
+func (d *Display) Adapter(adapter llm.LLMAdapter) llm.LLMAdapter {
+	return &progressAdapter{display: d, adapter: adapter}
+}
+
+// progressAdapter reports the calls of an adapter on a display
+type progressAdapter struct {
+	display *Display
+	adapter llm.LLMAdapter
+}
+
+// GenerateCompletion implements llm.LLMAdapter
+func (a *progressAdapter) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	c := a.display.begin(llm.Label(ctx))
+	completion, err := llm.GenerateStream(ctx, a.adapter, prompt, func(chunk string) {
+		a.display.receive(c, chunk)
+	})
+	a.display.finish(c, err)
+	return completion, err
+}
+
+// GenerateStructured implements llm.StructuredAdapter, structured completions aren't streamed
+func (a *progressAdapter) GenerateStructured(ctx context.Context, prompt string, schema llm.Schema) (string, error) {
+	c := a.display.begin(llm.Label(ctx))
+	completion, err := llm.GenerateStructured(ctx, a.adapter, prompt, schema)
+	a.display.receive(c, completion)
+	a.display.finish(c, err)
+	return completion, err
+}
+
+// begin starts a call of the task of a label
+func (d *Display) begin(label string) *call {
+	if label == "" {
+		label = defaultLabel
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var t *task
+	for _, existing := range d.tasks {
+		if existing.label == label {
+			t = existing
+		}
+	}
+	if t == nil {
+		t = &task{label: label, start: d.now()}
+		d.tasks = append(d.tasks, t)
+	}
+	t.calls++
+	t.running++
+	c := &call{task: t, n: t.calls, start: d.now()}
+
+	if !d.live {
+		d.println(fmt.Sprintf("%s: generating (call %d)", label, c.n))
+	}
+	d.drawStatus()
+	return c
+}
+
+// receive adds a chunk of a completion to a call and its task
+func (d *Display) receive(c *call, chunk string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c.chars += len(chunk)
+	c.task.chars += len(chunk)
+}
+
+// finish ends a call, reporting its own tokens and duration
+func (d *Display) finish(c *call, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	t := c.task
+	t.running--
+	elapsed := d.since(c.start)
+
+	if err != nil {
+		d.println(fmt.Sprintf("%s: call %d failed after %s: %v", t.label, c.n, elapsed, err))
+	} else {
+		d.println(fmt.Sprintf("%s: call %d done, ~%d tokens in %s", t.label, c.n, llm.EstimateTokens(c.chars), elapsed))
+	}
+	d.drawStatus()
+}
+
+// println prints a line above the status line
+func (d *Display) println(line string) {
+	if d.live {
+		fmt.Fprint(d.w, "\r\033[K")
+	}
+	fmt.Fprintln(d.w, line)
+}
+
+// drawStatus redraws the status line with the running tasks, on terminals only
+func (d *Display) drawStatus() {
+	if !d.live {
+		return
+	}
+
+	var running []string
+	for _, t := range d.tasks {
+		if t.running > 0 {
+			running = append(running, fmt.Sprintf("%s ~%d tokens %s", t.label, llm.EstimateTokens(t.chars), d.since(t.start)))
+		}
+	}
+	sort.Strings(running)
+	if len(running) == 0 {
+		fmt.Fprint(d.w, "\r\033[K")
+		return
+	}
+	fmt.Fprintf(d.w, "\r\033[Kgenerating %s, %s", strings.Join(running, ", "), d.since(d.start))
+}
+
+// since returns the time elapsed since start, rounded for display
+func (d *Display) since(start time.Time) time.Duration {
+	return d.now().Sub(start).Round(100 * time.Millisecond)
+}
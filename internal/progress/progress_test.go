@@ -0,0 +1,118 @@
+package progress
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"mm-go-agent/internal/adapter/llm"
+)
+
+// streamingLLM streams its completion in chunks, advancing the clock by a second per chunk
+type streamingLLM struct {
+	clock  *time.Time
+	chunks []string
+	err    error
+}
+
+func (l *streamingLLM) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	return "", errors.New("unexpected call without streaming")
+}
+
+func (l *streamingLLM) GenerateCompletionStream(ctx context.Context, prompt string, onChunk func(chunk string)) (string, error) {
+	for _, chunk := range l.chunks {
+		*l.clock = l.clock.Add(time.Second)
+		onChunk(chunk)
+	}
+	return strings.Join(l.chunks, ""), l.err
+}
+
+// plainLLM returns its completion at once
+type plainLLM struct {
+	completion string
+}
+
+func (l *plainLLM) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	return l.completion, nil
+}
+
+// ProgressTestSuite is a test suite for the progress display
+type ProgressTestSuite struct {
+	suite.Suite
+	out     *bytes.Buffer
+	clock   time.Time
+	display *Display
+}
+
+// SetupTest creates a display writing to a buffer with a fake clock
+func (s *ProgressTestSuite) SetupTest() {
+	s.out = &bytes.Buffer{}
+	s.clock = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.display = New(s.out)
+	s.display.now = func() time.Time { return s.clock }
+	s.display.start = s.clock
+}
+
+// TestStreaming checks that streamed chunks are counted per call and in total
+func (s *ProgressTestSuite) TestStreaming() {
+	adapter := s.display.Adapter(&streamingLLM{clock: &s.clock, chunks: []string{"classDiagram\n", "  class UserService"}})
+	ctx := llm.WithLabel(context.Background(), "service")
+
+	completion, err := adapter.GenerateCompletion(ctx, "prompt")
+	s.Require().NoError(err)
+	s.Equal("classDiagram\n  class UserService", completion)
+	_, err = adapter.GenerateCompletion(ctx, "fix prompt")
+	s.Require().NoError(err)
+	s.display.Stop()
+
+	s.Equal(`service: generating (call 1)
+service: call 1 done, ~8 tokens in 2s
+service: generating (call 2)
+service: call 2 done, ~8 tokens in 2s
+2 LLM calls, ~16 tokens received in 4s
+`, s.out.String())
+}
+
+// TestWithoutStreaming checks adapters without streaming and failed calls
+func (s *ProgressTestSuite) TestWithoutStreaming() {
+	_, err := s.display.Adapter(&plainLLM{completion: "flowchart TD\n  A --> B"}).GenerateCompletion(context.Background(), "prompt")
+	s.Require().NoError(err)
+	_, err = s.display.Adapter(&streamingLLM{clock: &s.clock, err: errors.New("overloaded")}).GenerateCompletion(llm.WithLabel(context.Background(), "relationships"), "prompt")
+	s.Error(err)
+
+	s.Contains(s.out.String(), "diagram: call 1 done, ~6 tokens in 0s\n")
+	s.Contains(s.out.String(), "relationships: call 1 failed after 0s: overloaded\n")
+	s.False(s.display.live, "a buffer isn't a terminal")
+}
+
+// TestConcurrentCalls checks that calls of the same label running at the same time are each
+// reported with their own number, tokens and duration
+func (s *ProgressTestSuite) TestConcurrentCalls() {
+	first := s.display.begin("service")
+	s.clock = s.clock.Add(time.Second)
+	second := s.display.begin("service")
+	s.display.receive(first, strings.Repeat("a", 40))
+	s.display.receive(second, strings.Repeat("b", 8))
+	s.clock = s.clock.Add(time.Second)
+	s.display.finish(second, nil)
+	s.clock = s.clock.Add(time.Second)
+	s.display.finish(first, errors.New("overloaded"))
+	s.display.Stop()
+
+	s.Equal(`service: generating (call 1)
+service: generating (call 2)
+service: call 2 done, ~2 tokens in 1s
+service: call 1 failed after 3s: overloaded
+2 LLM calls, ~12 tokens received in 3s
+`, s.out.String())
+}
+
+// TestProgressTestSuite runs the progress test suite
+func TestProgressTestSuite(t *testing.T) {
+	suite.Run(t, new(ProgressTestSuite))
+}
@@ -26,7 +26,7 @@ func (r *OutputRepository) SaveDiagramFiles(outDir, filename, mmdContent, svgCon
 	if err := os.WriteFile(mmdOutputPath, []byte(mmdContent), 0644); err != nil {
 		return fmt.Errorf("error writing MMD file: %v", err)
 	}
-	fmt.Printf("Original diagram saved to %s\n", mmdOutputPath)
+	fmt.Fprintf(os.Stderr, "Original diagram saved to %s\n", mmdOutputPath)
 
 	// If SVG content is provided, save it as well
 	if svgContent != "" {
@@ -34,7 +34,7 @@ func (r *OutputRepository) SaveDiagramFiles(outDir, filename, mmdContent, svgCon
 		if err := os.WriteFile(svgOutputPath, []byte(svgContent), 0644); err != nil {
 			return fmt.Errorf("error writing SVG file: %v", err)
 		}
-		fmt.Printf("SVG diagram saved to %s\n", svgOutputPath)
+		fmt.Fprintf(os.Stderr, "SVG diagram saved to %s\n", svgOutputPath)
 	}
 
 	return nil
@@ -55,6 +55,6 @@ func (r *OutputRepository) SaveDiagramFile(outDir, filename, content, extension
 		return fmt.Errorf("error writing file: %v", err)
 	}
 
-	fmt.Printf("Diagram saved to %s\n", outputPath)
+	fmt.Fprintf(os.Stderr, "Diagram saved to %s\n", outputPath)
 	return nil
 }
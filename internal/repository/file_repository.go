@@ -1,26 +1,144 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+
+	"golang.org/x/mod/modfile"
 )
 
 // FileRepository defines the interface for file operations
 type FileRepository interface {
 	ReadGoFile(path string) (string, error)
 	ValidateGoFile(path string) error
+	ReadSourceFile(path string) (string, error)
+	ValidateSourceFile(path string) error
 	FindComponentFiles(componentType, componentName string) ([]string, error)
 	FindAllComponentFiles(componentTypes []string) ([]string, error)
+	FindSourceFiles(dir string) ([]string, error)
+	ModulePath() (string, error)
+}
+
+// sourceExtensions lists the file extensions that can be used as diagram sources
+var sourceExtensions = map[string]bool{
+	".go":    true,
+	".proto": true,
+}
+
+// IsSourceFile reports whether the path has a supported source extension (.go or .proto)
+func IsSourceFile(path string) bool {
+	return sourceExtensions[filepath.Ext(path)]
+}
+
+// fileRepository implements FileRepository over a file system. Paths are slash separated and
+// relative to the root of the file system.
+type fileRepository struct {
+	fsys fs.FS
+	// root is the directory the file system is read from, if any, absolute paths inside it are accepted
+	root   string
+	filter Filter
+
+	once     sync.Once
+	compiled *compiledFilter
+	err      error
 }
 
-// fileRepository implements FileRepository
-type fileRepository struct{}
+// NewFileRepository creates a file repository for the files below the root directory
+func NewFileRepository(root string, opts ...Option) FileRepository {
+	return newFileRepository(os.DirFS(root), root, opts)
+}
 
-// NewFileRepository creates a new file repository
-func NewFileRepository() FileRepository {
-	return &fileRepository{}
+// NewFSFileRepository creates a file repository over a file system such as an embed.FS or fstest.MapFS
+func NewFSFileRepository(fsys fs.FS, opts ...Option) FileRepository {
+	return newFileRepository(fsys, "", opts)
+}
+
+func newFileRepository(fsys fs.FS, root string, opts []Option) *fileRepository {
+	r := &fileRepository{fsys: fsys, root: root}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// sourceFilter returns the compiled filter, the ignore file is read on first use
+func (r *fileRepository) sourceFilter() (*compiledFilter, error) {
+	r.once.Do(func() {
+		r.compiled, r.err = r.filter.compile(r.fsys)
+	})
+	return r.compiled, r.err
+}
+
+// keep reports whether a source file found in a directory is used for diagrams
+func (r *fileRepository) keep(filter *compiledFilter, name string) (bool, error) {
+	if filter.skipFile(name) {
+		return false, nil
+	}
+	if path.Ext(name) != ".go" {
+		return true, nil
+	}
+
+	content, err := fs.ReadFile(r.fsys, name)
+	if err != nil {
+		return false, err
+	}
+	return !isGenerated(string(content)), nil
+}
+
+// name converts a path to the name of the file in the file system
+func (r *fileRepository) name(p string) (string, error) {
+	if filepath.IsAbs(p) && r.root != "" {
+		root, err := filepath.Abs(r.root)
+		if err != nil {
+			return "", err
+		}
+		if p, err = filepath.Rel(root, p); err != nil {
+			return "", err
+		}
+	}
+
+	name := path.Clean(filepath.ToSlash(p))
+	if !fs.ValidPath(name) {
+		return "", fmt.Errorf("path %s is outside of the repository", p)
+	}
+
+	return name, nil
+}
+
+// stat returns the file info of a path
+func (r *fileRepository) stat(p string) (fs.FileInfo, error) {
+	name, err := r.name(p)
+	if err != nil {
+		return nil, fmt.Errorf("error accessing file: %w", err)
+	}
+
+	info, err := fs.Stat(r.fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("error accessing file: %w", err)
+	}
+
+	return info, nil
+}
+
+// read returns the content of a path
+func (r *fileRepository) read(p string) (string, error) {
+	name, err := r.name(p)
+	if err != nil {
+		return "", fmt.Errorf("error reading file: %w", err)
+	}
+
+	content, err := fs.ReadFile(r.fsys, name)
+	if err != nil {
+		return "", fmt.Errorf("error reading file: %w", err)
+	}
+
+	return string(content), nil
 }
 
 // ValidateGoFile checks if a file exists and has a .go extension
@@ -29,12 +147,8 @@ func (r *fileRepository) ValidateGoFile(path string) error {
 		return fmt.Errorf("file must be a Go file (.go extension)")
 	}
 
-	_, err := os.Stat(path)
-	if err != nil {
-		return fmt.Errorf("error accessing file: %w", err)
-	}
-
-	return nil
+	_, err := r.stat(path)
+	return err
 }
 
 // ReadGoFile reads the content of a go file
@@ -43,15 +157,43 @@ func (r *fileRepository) ReadGoFile(path string) (string, error) {
 		return "", err
 	}
 
-	content, err := os.ReadFile(path)
+	return r.read(path)
+}
+
+// ValidateSourceFile checks if a file exists and is a supported source file (.go or .proto)
+func (r *fileRepository) ValidateSourceFile(path string) error {
+	if !IsSourceFile(path) {
+		return fmt.Errorf("file must be a Go (.go) or protobuf (.proto) file")
+	}
+
+	_, err := r.stat(path)
+	return err
+}
+
+// ReadSourceFile reads the content of a Go or protobuf file
+func (r *fileRepository) ReadSourceFile(path string) (string, error) {
+	if err := r.ValidateSourceFile(path); err != nil {
+		return "", err
+	}
+
+	return r.read(path)
+}
+
+// ModulePath returns the module path declared in the go.mod file at the root of the repository
+func (r *fileRepository) ModulePath() (string, error) {
+	content, err := r.read("go.mod")
 	if err != nil {
-		return "", fmt.Errorf("error reading file: %w", err)
+		return "", err
 	}
 
-	return string(content), nil
+	modulePath := modfile.ModulePath([]byte(content))
+	if modulePath == "" {
+		return "", fmt.Errorf("no module path in go.mod")
+	}
+	return modulePath, nil
 }
 
-// FindComponentFiles finds all Go files for a specific component
+// FindComponentFiles finds all Go and protobuf files for a specific component
 func (r *fileRepository) FindComponentFiles(componentType, componentName string) ([]string, error) {
 	var basePath string
 
@@ -71,34 +213,47 @@ func (r *fileRepository) FindComponentFiles(componentType, componentName string)
 		return nil, fmt.Errorf("unsupported component type: %s", componentType)
 	}
 
+	filter, err := r.sourceFilter()
+	if err != nil {
+		return nil, err
+	}
+
 	// Create search pattern for the component name
 	namePattern := strings.ToLower(componentName)
 
 	var files []string
-	err := filepath.Walk(basePath, func(path string, info os.FileInfo, err error) error {
+	err = fs.WalkDir(r.fsys, basePath, func(p string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if info.IsDir() {
+		if d.IsDir() {
+			if p != basePath && filter.skipDir(p) {
+				return fs.SkipDir
+			}
+
 			// Special handling for adapters which are typically organized in subdirectories
-			if componentType == "adapter" && strings.HasSuffix(path, namePattern) {
+			if componentType == "adapter" && strings.HasSuffix(p, namePattern) {
 				return nil // Continue descending into matching adapter directories
 			}
 
 			// Skip non-matching directories for other component types
-			if filepath.Dir(path) != basePath && path != basePath {
-				return filepath.SkipDir
+			if path.Dir(p) != basePath && p != basePath {
+				return fs.SkipDir
 			}
 			return nil
 		}
 
 		// Check if the file matches the component name
-		fileName := strings.ToLower(info.Name())
-		if filepath.Ext(fileName) == ".go" &&
+		fileName := strings.ToLower(d.Name())
+		if IsSourceFile(fileName) &&
 			(strings.Contains(fileName, namePattern) ||
 				strings.Contains(fileName, strings.ToLower(componentType))) {
-			files = append(files, path)
+			if keep, err := r.keep(filter, p); err != nil {
+				return err
+			} else if keep {
+				files = append(files, filepath.FromSlash(p))
+			}
 		}
 
 		return nil
@@ -111,7 +266,7 @@ func (r *fileRepository) FindComponentFiles(componentType, componentName string)
 	return files, nil
 }
 
-// FindAllComponentFiles finds all Go files for the specified component types
+// FindAllComponentFiles finds all Go and protobuf files for the specified component types
 func (r *fileRepository) FindAllComponentFiles(componentTypes []string) ([]string, error) {
 	var allFiles []string
 
@@ -135,27 +290,60 @@ func (r *fileRepository) FindAllComponentFiles(componentTypes []string) ([]strin
 		}
 
 		// Check if the directory exists
-		if _, err := os.Stat(basePath); os.IsNotExist(err) {
+		if _, err := fs.Stat(r.fsys, basePath); errors.Is(err, fs.ErrNotExist) {
 			continue // Skip non-existent directories
 		}
 
-		// Find all Go files in the directory
-		err := filepath.Walk(basePath, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
+		// Find all source files in the directory
+		files, err := r.FindSourceFiles(basePath)
+		if err != nil {
+			return nil, err
+		}
+		allFiles = append(allFiles, files...)
+	}
 
-			if !info.IsDir() && filepath.Ext(path) == ".go" {
-				allFiles = append(allFiles, path)
-			}
+	return allFiles, nil
+}
 
-			return nil
-		})
+// FindSourceFiles finds the Go and protobuf files in a directory and its subdirectories that pass the filter
+func (r *fileRepository) FindSourceFiles(dir string) ([]string, error) {
+	root, err := r.name(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error walking directory %s: %w", dir, err)
+	}
 
+	filter, err := r.sourceFilter()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	err = fs.WalkDir(r.fsys, root, func(p string, d fs.DirEntry, err error) error {
 		if err != nil {
-			return nil, fmt.Errorf("error walking directory %s: %w", basePath, err)
+			return err
+		}
+
+		if d.IsDir() {
+			if p != root && filter.skipDir(p) {
+				return fs.SkipDir
+			}
+			return nil
 		}
+
+		if IsSourceFile(p) {
+			if keep, err := r.keep(filter, p); err != nil {
+				return err
+			} else if keep {
+				files = append(files, filepath.FromSlash(p))
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("error walking directory %s: %w", dir, err)
 	}
 
-	return allFiles, nil
+	return files, nil
 }
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// FileRepositoryTestSuite is a test suite for the file repository over an in-memory file system
+type FileRepositoryTestSuite struct {
+	suite.Suite
+	repo FileRepository
+}
+
+// SetupTest creates a repository over a small project
+func (s *FileRepositoryTestSuite) SetupTest() {
+	s.repo = NewFSFileRepository(fstest.MapFS{
+		"main.go":                                 {Data: []byte("package main\n")},
+		"README.md":                               {Data: []byte("# readme\n")},
+		"internal/services/user_service.go":       {Data: []byte("package services\n")},
+		"internal/services/order_service.go":      {Data: []byte("package services\n")},
+		"internal/services/helpers/helpers.go":    {Data: []byte("package helpers\n")},
+		"internal/adapters/postgres/postgres.go":  {Data: []byte("package postgres\n")},
+		"internal/adapters/postgres/schema.proto": {Data: []byte("syntax = \"proto3\";\n")},
+		"internal/adapters/redis/redis.go":        {Data: []byte("package redis\n")},
+	})
+}
+
+// TestReadSourceFile checks reading and validating files
+func (s *FileRepositoryTestSuite) TestReadSourceFile() {
+	content, err := s.repo.ReadSourceFile("./internal/services/user_service.go")
+	s.Require().NoError(err)
+	s.Equal("package services\n", content)
+
+	_, err = s.repo.ReadGoFile("internal/adapters/postgres/schema.proto")
+	s.ErrorContains(err, "must be a Go file")
+
+	_, err = s.repo.ReadSourceFile("README.md")
+	s.ErrorContains(err, "must be a Go (.go) or protobuf (.proto) file")
+
+	_, err = s.repo.ReadSourceFile("missing.go")
+	s.ErrorContains(err, "error accessing file")
+
+	_, err = s.repo.ReadSourceFile("../outside.go")
+	s.ErrorContains(err, "outside of the repository")
+}
+
+// TestModulePath checks that the module path is read from go.mod
+func (s *FileRepositoryTestSuite) TestModulePath() {
+	_, err := s.repo.ModulePath()
+	s.ErrorContains(err, "error reading file")
+
+	repo := NewFSFileRepository(fstest.MapFS{"go.mod": {Data: []byte("module example.com/shop\n\ngo 1.22\n")}})
+	modulePath, err := repo.ModulePath()
+	s.Require().NoError(err)
+	s.Equal("example.com/shop", modulePath)
+}
+
+// TestFindComponentFiles checks that component files are matched by name
+func (s *FileRepositoryTestSuite) TestFindComponentFiles() {
+	files, err := s.repo.FindComponentFiles("service", "user")
+	s.Require().NoError(err)
+	s.Equal([]string{"internal/services/order_service.go", "internal/services/user_service.go"}, files)
+
+	files, err = s.repo.FindComponentFiles("adapter", "postgres")
+	s.Require().NoError(err)
+	s.Equal([]string{"internal/adapters/postgres/postgres.go"}, files)
+
+	_, err = s.repo.FindComponentFiles("widget", "user")
+	s.ErrorContains(err, "unsupported component type")
+}
+
+// TestFindAllComponentFiles checks that missing component directories are skipped
+func (s *FileRepositoryTestSuite) TestFindAllComponentFiles() {
+	files, err := s.repo.FindAllComponentFiles([]string{"adapter", "config"})
+	s.Require().NoError(err)
+	s.Equal([]string{
+		"internal/adapters/postgres/postgres.go",
+		"internal/adapters/postgres/schema.proto",
+		"internal/adapters/redis/redis.go",
+	}, files)
+}
+
+// TestFindSourceFiles checks the recursive listing of a directory
+func (s *FileRepositoryTestSuite) TestFindSourceFiles() {
+	files, err := s.repo.FindSourceFiles("internal/services")
+	s.Require().NoError(err)
+	s.Equal([]string{
+		"internal/services/helpers/helpers.go",
+		"internal/services/order_service.go",
+		"internal/services/user_service.go",
+	}, files)
+
+	_, err = s.repo.FindSourceFiles("internal/missing")
+	s.Error(err)
+}
+
+// TestFileRepositoryTestSuite runs the file repository test suite
+func TestFileRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(FileRepositoryTestSuite))
+}
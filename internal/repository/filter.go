@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFile lists paths to leave out of diagrams, with gitignore semantics, at the repository root
+const IgnoreFile = ".mm-genignore"
+
+// Filter selects the source files found in directories. Tests, generated files and vendored
+// code are always skipped unless enabled. Files passed by path are never filtered.
+type Filter struct {
+	// Include limits the files to those matching one of the patterns or inside a matching directory
+	Include []string
+	// Exclude skips the files and directories matching one of the patterns, like lines of .mm-genignore
+	Exclude []string
+	// IncludeTests keeps _test.go files
+	IncludeTests bool
+}
+
+// Option configures a file repository
+type Option func(*fileRepository)
+
+// WithFilter sets the filter of the source files found in directories
+func WithFilter(filter Filter) Option {
+	return func(r *fileRepository) {
+		r.filter = filter
+	}
+}
+
+// ignorePattern is a compiled line of a gitignore file
+type ignorePattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreRules matches paths against patterns in order, the last matching pattern wins
+type ignoreRules []ignorePattern
+
+// parseIgnore compiles the lines of a gitignore file, skipping blank lines and comments
+func parseIgnore(content string) (ignoreRules, error) {
+	var rules ignoreRules
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if !strings.HasSuffix(line, `\ `) {
+			line = strings.TrimRight(line, " ")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern, err := compileIgnorePattern(line)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, pattern)
+	}
+	return rules, scanner.Err()
+}
+
+// compileIgnorePattern compiles a gitignore pattern. A pattern with a slash at the beginning or
+// in the middle is relative to the root, otherwise it matches at any depth.
+func compileIgnorePattern(line string) (ignorePattern, error) {
+	var p ignorePattern
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	expr := globToRegexp(strings.TrimPrefix(line, "/"))
+	if !strings.Contains(line, "/") {
+		expr = "(?:.*/)?" + expr
+	}
+
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return p, fmt.Errorf("invalid pattern %q: %w", line, err)
+	}
+	p.re = re
+	return p, nil
+}
+
+// globToRegexp converts a glob, where ** matches any number of directories, to a regular expression
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// ignored reports whether the path is matched by the rules
+func (rules ignoreRules) ignored(name string, isDir bool) bool {
+	ignored := false
+	for _, p := range rules {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.re.MatchString(name) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+// included reports whether a file or one of its directories is matched by the rules
+func (rules ignoreRules) included(name string) bool {
+	for dir := name; dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if rules.ignored(dir, dir != name) {
+			return true
+		}
+	}
+	return false
+}
+
+// generatedRe matches the comment marking generated Go files, see https://go.dev/s/generatedcode
+var generatedRe = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// isGenerated reports whether the Go source has the generated code comment before the package clause
+func isGenerated(content string) bool {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "package ") {
+			return false
+		}
+		if generatedRe.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// compiledFilter is a filter with its patterns and the ignore file compiled
+type compiledFilter struct {
+	include      ignoreRules
+	exclude      ignoreRules
+	includeTests bool
+}
+
+// compile compiles the filter patterns after the rules of the ignore file of the file system
+func (f Filter) compile(fsys fs.FS) (*compiledFilter, error) {
+	c := &compiledFilter{includeTests: f.IncludeTests}
+
+	content, err := fs.ReadFile(fsys, IgnoreFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %s: %w", IgnoreFile, err)
+	}
+	if c.exclude, err = parseIgnore(string(content)); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", IgnoreFile, err)
+	}
+
+	for _, pattern := range f.Exclude {
+		p, err := compileIgnorePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		c.exclude = append(c.exclude, p)
+	}
+	for _, pattern := range f.Include {
+		p, err := compileIgnorePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		c.include = append(c.include, p)
+	}
+
+	return c, nil
+}
+
+// skipDir reports whether a directory found while walking is left out
+func (c *compiledFilter) skipDir(name string) bool {
+	return path.Base(name) == "vendor" || c.exclude.ignored(name, true)
+}
+
+// skipFile reports whether a source file found while walking is left out, generated files
+// are detected by the caller as it requires the content
+func (c *compiledFilter) skipFile(name string) bool {
+	if !c.includeTests && strings.HasSuffix(name, "_test.go") {
+		return true
+	}
+	if c.exclude.ignored(name, false) {
+		return true
+	}
+	return len(c.include) > 0 && !c.include.included(name)
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// FilterTestSuite is a test suite for the filtering of source files
+type FilterTestSuite struct {
+	suite.Suite
+	fsys fstest.MapFS
+}
+
+// SetupTest creates a project with files that are skipped by default
+func (s *FilterTestSuite) SetupTest() {
+	s.fsys = fstest.MapFS{
+		"app/app.go":                    {Data: []byte("package app\n")},
+		"app/app_test.go":               {Data: []byte("package app\n")},
+		"app/api.pb.go":                 {Data: []byte("// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage app\n")},
+		"app/late.go":                   {Data: []byte("package app\n\n// Code generated by hand. DO NOT EDIT.\n")},
+		"app/mocks/store.go":            {Data: []byte("package mocks\n")},
+		"app/internal/db.go":            {Data: []byte("package internal\n")},
+		"app/api/api.proto":             {Data: []byte("syntax = \"proto3\";\n")},
+		"app/vendor/lib/lib.go":         {Data: []byte("package lib\n")},
+		"internal/services/user.go":     {Data: []byte("package services\n")},
+		"internal/services/user_gen.go": {Data: []byte("package services\n")},
+	}
+}
+
+func (s *FilterTestSuite) find(dir string, filter Filter) []string {
+	files, err := NewFSFileRepository(s.fsys, WithFilter(filter)).FindSourceFiles(dir)
+	s.Require().NoError(err)
+	return files
+}
+
+// TestDefaults checks that tests, generated files and vendored code are skipped
+func (s *FilterTestSuite) TestDefaults() {
+	s.Equal([]string{"app/api/api.proto", "app/app.go", "app/internal/db.go", "app/late.go", "app/mocks/store.go"}, s.find("app", Filter{}))
+	s.Contains(s.find("app", Filter{IncludeTests: true}), "app/app_test.go")
+}
+
+// TestIgnoreFile checks the gitignore semantics of .mm-genignore
+func (s *FilterTestSuite) TestIgnoreFile() {
+	s.fsys[IgnoreFile] = &fstest.MapFile{Data: []byte(`# hand written mocks
+mocks/
+/app/internal
+*.proto
+*_gen.go
+!user_gen.go
+`)}
+
+	s.Equal([]string{"app/app.go", "app/late.go"}, s.find("app", Filter{}))
+	s.Equal([]string{"internal/services/user.go", "internal/services/user_gen.go"}, s.find("internal", Filter{}))
+
+	files, err := NewFSFileRepository(s.fsys).FindComponentFiles("service", "user")
+	s.Require().NoError(err)
+	s.Equal([]string{"internal/services/user.go", "internal/services/user_gen.go"}, files)
+}
+
+// TestIncludeExclude checks the patterns of the flags
+func (s *FilterTestSuite) TestIncludeExclude() {
+	s.Equal([]string{"app/internal/db.go", "app/mocks/store.go"}, s.find("app", Filter{Include: []string{"app/internal", "**/mocks/*.go"}}))
+	s.Equal([]string{"app/api/api.proto", "app/app.go"}, s.find("app", Filter{Exclude: []string{"**/internal/**", "mocks", "late.go"}}))
+	s.Equal([]string{"app/app.go"}, s.find("app", Filter{Include: []string{"app/*.go"}, Exclude: []string{"l?te.go"}}))
+}
+
+// TestGlobToRegexp checks the conversion of glob syntax
+func (s *FilterTestSuite) TestGlobToRegexp() {
+	tests := map[string]string{
+		"*.go":       `[^/]*\.go`,
+		"a/**/b":     `a/(?:.*/)?b`,
+		"a/**":       `a/.*`,
+		"[!a-c]?":    `[^a-c][^/]`,
+		`\*literal[`: `\*literal\[`,
+	}
+	for glob, expected := range tests {
+		s.Equal(expected, globToRegexp(glob), glob)
+	}
+}
+
+// TestFilterTestSuite runs the filter test suite
+func TestFilterTestSuite(t *testing.T) {
+	suite.Run(t, new(FilterTestSuite))
+}
@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewGitFileRepository creates a file repository that reads the files below the root directory
+// as they were at a git revision, without checking them out
+func NewGitFileRepository(root, rev string, opts ...Option) (FileRepository, error) {
+	fsys, err := NewGitFS(root, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFileRepository(fsys, root, opts), nil
+}
+
+// gitFS is a read-only fs.FS of the tree of a git commit below a directory of the repository
+type gitFS struct {
+	dir    string
+	commit string
+
+	once    sync.Once
+	entries map[string]*gitEntry
+	err     error
+}
+
+// gitEntry is a blob or tree of the commit
+type gitEntry struct {
+	name     string
+	object   string
+	size     int64
+	isDir    bool
+	children []*gitEntry
+}
+
+// NewGitFS returns the tree of a git revision below dir, which must be inside a git repository
+func NewGitFS(dir, rev string) (fs.FS, error) {
+	commit, err := runGit(dir, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("unknown revision %s: %w", rev, err)
+	}
+
+	return &gitFS{dir: dir, commit: strings.TrimSpace(string(commit))}, nil
+}
+
+// runGit runs a git command in a directory and returns its output
+func runGit(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+
+	return out, nil
+}
+
+// load lists the whole tree once, file contents are read when opened
+func (g *gitFS) load() error {
+	g.once.Do(func() {
+		// Run in the directory, ls-tree lists the entries below it with relative paths
+		out, err := runGit(g.dir, "ls-tree", "-r", "-t", "-z", "--long", g.commit)
+		if err != nil {
+			g.err = err
+			return
+		}
+
+		g.entries = map[string]*gitEntry{".": {name: ".", isDir: true}}
+		for _, record := range strings.Split(string(out), "\x00") {
+			// <mode> SP <type> SP <object> SP+ <size> TAB <path>
+			meta, name, ok := strings.Cut(record, "\t")
+			if !ok {
+				continue
+			}
+			fields := strings.Fields(meta)
+			if len(fields) != 4 || (fields[1] != "blob" && fields[1] != "tree") {
+				continue // Submodules aren't part of the tree
+			}
+			if !fs.ValidPath(name) {
+				continue // Below the repository root the directory itself is listed as "./"
+			}
+
+			entry := &gitEntry{name: name, object: fields[2], isDir: fields[1] == "tree"}
+			if !entry.isDir {
+				entry.size, _ = strconv.ParseInt(fields[3], 10, 64)
+			}
+			g.entries[name] = entry
+		}
+
+		// Trees are listed too, so every parent exists
+		for name, entry := range g.entries {
+			if name == "." {
+				continue
+			}
+			if parent, ok := g.entries[path.Dir(name)]; ok {
+				parent.children = append(parent.children, entry)
+			}
+		}
+		for _, entry := range g.entries {
+			sort.Slice(entry.children, func(i, j int) bool { return entry.children[i].name < entry.children[j].name })
+		}
+	})
+
+	return g.err
+}
+
+func (g *gitFS) lookup(op, name string) (*gitEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if err := g.load(); err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	entry, ok := g.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return entry, nil
+}
+
+// Open implements fs.FS
+func (g *gitFS) Open(name string) (fs.File, error) {
+	entry, err := g.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if entry.isDir {
+		return &gitDir{entry: entry}, nil
+	}
+
+	content, err := runGit(g.dir, "cat-file", "blob", entry.object)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &gitFile{entry: entry, Reader: bytes.NewReader(content)}, nil
+}
+
+// Stat implements fs.StatFS
+func (g *gitFS) Stat(name string) (fs.FileInfo, error) {
+	entry, err := g.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return gitFileInfo{entry}, nil
+}
+
+// ReadDir implements fs.ReadDirFS
+func (g *gitFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entry, err := g.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.isDir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return dirEntries(entry.children), nil
+}
+
+func dirEntries(children []*gitEntry) []fs.DirEntry {
+	entries := make([]fs.DirEntry, len(children))
+	for i, child := range children {
+		entries[i] = gitFileInfo{child}
+	}
+	return entries
+}
+
+// gitFileInfo implements fs.FileInfo and fs.DirEntry of an entry
+type gitFileInfo struct {
+	entry *gitEntry
+}
+
+func (i gitFileInfo) Name() string       { return path.Base(i.entry.name) }
+func (i gitFileInfo) Size() int64        { return i.entry.size }
+func (i gitFileInfo) ModTime() time.Time { return time.Time{} }
+func (i gitFileInfo) IsDir() bool        { return i.entry.isDir }
+func (i gitFileInfo) Sys() any           { return nil }
+
+func (i gitFileInfo) Mode() fs.FileMode {
+	if i.entry.isDir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+func (i gitFileInfo) Type() fs.FileMode          { return i.Mode().Type() }
+func (i gitFileInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+// gitFile is an open blob
+type gitFile struct {
+	entry *gitEntry
+	*bytes.Reader
+}
+
+func (f *gitFile) Stat() (fs.FileInfo, error) { return gitFileInfo{f.entry}, nil }
+func (f *gitFile) Close() error               { return nil }
+
+// gitDir is an open tree
+type gitDir struct {
+	entry  *gitEntry
+	offset int
+}
+
+func (d *gitDir) Stat() (fs.FileInfo, error) { return gitFileInfo{d.entry}, nil }
+func (d *gitDir) Close() error               { return nil }
+
+func (d *gitDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.entry.name, Err: fs.ErrInvalid}
+}
+
+// ReadDir implements fs.ReadDirFile
+func (d *gitDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entry.children[d.offset:]
+	if n <= 0 {
+		d.offset += len(remaining)
+		return dirEntries(remaining), nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	n = min(n, len(remaining))
+	d.offset += n
+	return dirEntries(remaining[:n]), nil
+}
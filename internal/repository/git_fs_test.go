@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// GitFSTestSuite is a test suite for reading files at git revisions
+type GitFSTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+// SetupTest creates a repository with two commits
+func (s *GitFSTestSuite) SetupTest() {
+	if _, err := exec.LookPath("git"); err != nil {
+		s.T().Skip("git is not installed")
+	}
+	s.dir = s.T().TempDir()
+
+	s.git("init", "-q")
+	s.write("pkg/store.go", "package pkg\n\ntype Store struct{}\n")
+	s.write("pkg/api/api.proto", "syntax = \"proto3\";\n")
+	s.write("notes.txt", "notes\n")
+	s.git("add", "-A")
+	s.git("commit", "-q", "-m", "first")
+
+	s.write("pkg/store.go", "package pkg\n\ntype Store struct{ name string }\n")
+	s.write("pkg/cache.go", "package pkg\n")
+	s.git("add", "-A")
+	s.git("commit", "-q", "-m", "second")
+
+	// Uncommitted changes aren't visible at any revision
+	s.write("pkg/store.go", "package pkg\n")
+}
+
+func (s *GitFSTestSuite) git(args ...string) {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = s.dir
+	out, err := cmd.CombinedOutput()
+	s.Require().NoError(err, string(out))
+}
+
+func (s *GitFSTestSuite) write(name, content string) {
+	path := filepath.Join(s.dir, name)
+	s.Require().NoError(os.MkdirAll(filepath.Dir(path), 0o755))
+	s.Require().NoError(os.WriteFile(path, []byte(content), 0o644))
+}
+
+// TestFS checks the file system against the io/fs conventions
+func (s *GitFSTestSuite) TestFS() {
+	fsys, err := NewGitFS(s.dir, "HEAD~1")
+	s.Require().NoError(err)
+	s.NoError(fstest.TestFS(fsys, "pkg/store.go", "pkg/api/api.proto", "notes.txt"))
+
+	// Below the repository root paths are relative to the directory
+	fsys, err = NewGitFS(filepath.Join(s.dir, "pkg"), "HEAD")
+	s.Require().NoError(err)
+	s.NoError(fstest.TestFS(fsys, "store.go", "cache.go", "api/api.proto"))
+}
+
+// TestRepository checks that files are read as they were at the revision
+func (s *GitFSTestSuite) TestRepository() {
+	repo, err := NewGitFileRepository(s.dir, "HEAD~1")
+	s.Require().NoError(err)
+
+	content, err := repo.ReadGoFile("pkg/store.go")
+	s.Require().NoError(err)
+	s.Equal("package pkg\n\ntype Store struct{}\n", content)
+
+	files, err := repo.FindSourceFiles("pkg")
+	s.Require().NoError(err)
+	s.Equal([]string{"pkg/api/api.proto", "pkg/store.go"}, files)
+
+	repo, err = NewGitFileRepository(s.dir, "HEAD")
+	s.Require().NoError(err)
+	content, err = repo.ReadGoFile(filepath.Join(s.dir, "pkg", "store.go"))
+	s.Require().NoError(err)
+	s.Equal("package pkg\n\ntype Store struct{ name string }\n", content)
+
+	_, err = NewGitFileRepository(s.dir, "missing")
+	s.ErrorContains(err, "unknown revision missing")
+}
+
+// TestGitFSTestSuite runs the git file system test suite
+func TestGitFSTestSuite(t *testing.T) {
+	suite.Run(t, new(GitFSTestSuite))
+}
@@ -2,28 +2,44 @@ package diagram
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 
 	"mm-go-agent/internal/adapter/renderer"
 	fileOutputRepo "mm-go-agent/internal/repository/file"
+	"mm-go-agent/pkg/mermaid"
 )
 
 // OutputService coordinates diagram processing and output
 type OutputService struct {
-	processor *Processor
-	renderer  renderer.Renderer
-	fileRepo  *fileOutputRepo.OutputRepository
+	processor  *Processor
+	renderer   renderer.Renderer
+	fileRepo   *fileOutputRepo.OutputRepository
+	formatOpts mermaid.FormatOptions
 }
 
-// NewOutputService creates a new diagram output service
-func NewOutputService(processor *Processor, renderer renderer.Renderer, fileRepo *fileOutputRepo.OutputRepository) *OutputService {
+// NewOutputService creates a new diagram output service that formats saved diagrams with formatOpts
+func NewOutputService(processor *Processor, renderer renderer.Renderer, fileRepo *fileOutputRepo.OutputRepository, formatOpts mermaid.FormatOptions) *OutputService {
 	return &OutputService{
-		processor: processor,
-		renderer:  renderer,
-		fileRepo:  fileRepo,
+		processor:  processor,
+		renderer:   renderer,
+		fileRepo:   fileRepo,
+		formatOpts: formatOpts,
 	}
 }
 
+// normalize cleans the diagram content and formats it with canonical layout
+func (s *OutputService) normalize(content string) string {
+	cleaned := s.processor.CleanDiagramOutput(content)
+
+	formatted, err := mermaid.Format(cleaned, s.formatOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to format diagram, saving it unformatted: %v\n", err)
+		return cleaned
+	}
+	return formatted
+}
+
 // GenerateAndSaveDiagram generates a diagram and saves it to the specified output directory
 func (s *OutputService) GenerateAndSaveDiagram(diagramType, filePath, target, outDir string, svgFormat bool) error {
 	// If outDir is not specified, just return
@@ -45,10 +61,10 @@ func (s *OutputService) GenerateAndSaveDiagram(diagramType, filePath, target, ou
 	return s.SaveDiagram(filename, outDir, filePath, svgFormat)
 }
 
-// SaveDiagram saves a diagram to the specified output directory
+// SaveDiagram formats a diagram and saves it to the specified output directory
 func (s *OutputService) SaveDiagram(filename, outDir, content string, svgFormat bool) error {
-	// Clean the diagram content
-	cleanedContent := s.processor.CleanDiagramOutput(content)
+	// Clean and format the diagram content
+	cleanedContent := s.normalize(content)
 
 	// If SVG format is requested, convert the diagram
 	if svgFormat {
@@ -72,7 +88,7 @@ func (s *OutputService) SaveSplitDiagram(diagram, diagramType, outDir string, sv
 
 	// If no sections were found, save the whole diagram
 	if len(componentSections) == 0 {
-		cleanedDiagram := s.processor.CleanDiagramOutput(diagram)
+		cleanedDiagram := s.normalize(diagram)
 		filename := fmt.Sprintf("project_%s", diagramType)
 
 		if svgFormat {
@@ -88,34 +104,34 @@ func (s *OutputService) SaveSplitDiagram(diagram, diagramType, outDir string, sv
 
 	// Save each component section to its own file
 	for component, content := range componentSections {
-		cleanedContent := s.processor.CleanDiagramOutput(content)
+		cleanedContent := s.normalize(content)
 		filename := fmt.Sprintf("%s_%s", component, diagramType)
 
 		if svgFormat {
 			svgContent, err := s.renderer.ConvertToSVG(cleanedContent)
 			if err != nil {
-				fmt.Printf("Warning: Error converting %s to SVG: %v\n", component, err)
+				fmt.Fprintf(os.Stderr, "Warning: Error converting %s to SVG: %v\n", component, err)
 				// Continue with other components
 				continue
 			}
 			if err := s.fileRepo.SaveDiagramFiles(outDir, filename, cleanedContent, svgContent); err != nil {
-				fmt.Printf("Warning: Error saving %s: %v\n", component, err)
+				fmt.Fprintf(os.Stderr, "Warning: Error saving %s: %v\n", component, err)
 			}
 		} else {
 			if err := s.fileRepo.SaveDiagramFile(outDir, filename, cleanedContent, "mmd"); err != nil {
-				fmt.Printf("Warning: Error saving %s: %v\n", component, err)
+				fmt.Fprintf(os.Stderr, "Warning: Error saving %s: %v\n", component, err)
 			}
 		}
 	}
 
 	// Also save a full combined diagram
-	cleanedFullDiagram := s.processor.CleanDiagramOutput(diagram)
+	cleanedFullDiagram := s.normalize(diagram)
 	fullFilename := fmt.Sprintf("project_%s_full", diagramType)
 
 	if svgFormat {
 		svgDiagram, err := s.renderer.ConvertToSVG(cleanedFullDiagram)
 		if err != nil {
-			fmt.Printf("Warning: Could not convert combined diagram to SVG: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Warning: Could not convert combined diagram to SVG: %v\n", err)
 			// Still save the MMD version
 			return s.fileRepo.SaveDiagramFile(outDir, fullFilename, cleanedFullDiagram, "mmd")
 		}
@@ -3,13 +3,13 @@ package service
 import (
 	"context"
 	"fmt"
-	"math/rand"
-	"path/filepath"
+	"io"
+	"os"
 	"strings"
-	"time"
 
 	"mm-go-agent/internal/adapter/llm"
 	"mm-go-agent/internal/repository"
+	"mm-go-agent/pkg/analysis"
 	"mm-go-agent/pkg/mermaid"
 	"mm-go-agent/pkg/prompt"
 )
@@ -18,7 +18,11 @@ import (
 type DiagramService interface {
 	GenerateDiagram(ctx context.Context, filePath string, diagramType string) (string, error)
 	GenerateComponentDiagram(ctx context.Context, componentSpec string, diagramType string) (string, error)
+	GenerateDirectoryDiagram(ctx context.Context, dir string, diagramType string) (string, error)
+	GenerateTargetDiagram(ctx context.Context, target string, diagramType string) (string, error)
+	GenerateFocusDiagram(ctx context.Context, symbol string, depth int, direction string, diagramType string) (string, error)
 	GenerateProjectDiagram(ctx context.Context, diagramType string) (string, error)
+	GenerateImplementsMatrix(ctx context.Context) (string, string, error)
 }
 
 // diagramService implements DiagramService
@@ -26,39 +30,77 @@ type diagramService struct {
 	fileRepo   repository.FileRepository
 	llmAdapter llm.LLMAdapter
 	promptMgr  *prompt.TemplateManager
+	mode       GenerationMode
+	// verify fact-checks generated diagrams, strictVerify also asks the LLM to correct them
+	verify       bool
+	strictVerify bool
+	// packageDir is the directory Go packages are loaded from
+	packageDir string
+	// notes are added to the prompts
+	notes string
+	// structured asks the LLM for JSON graphs rendered into Mermaid instead of Mermaid text
+	structured bool
+	// log receives progress and warnings, stdout only gets the diagram
+	log io.Writer
 }
 
-// NewDiagramService creates a new diagram service
-func NewDiagramService(fileRepo repository.FileRepository, llmAdapter llm.LLMAdapter) DiagramService {
-	promptMgr, err := prompt.New()
-	if err != nil {
-		// Fall back to empty manager if templates can't be loaded
-		promptMgr = &prompt.TemplateManager{}
+// WithLog writes the progress and warnings of the service to w instead of stderr
+func WithLog(w io.Writer) Option {
+	return func(s *diagramService) {
+		s.log = w
 	}
+}
 
-	return &diagramService{
+// NewDiagramService creates a new diagram service
+func NewDiagramService(fileRepo repository.FileRepository, llmAdapter llm.LLMAdapter, opts ...Option) DiagramService {
+	s := &diagramService{
 		fileRepo:   fileRepo,
 		llmAdapter: llmAdapter,
-		promptMgr:  promptMgr,
+		mode:       ModeLLM,
+		log:        os.Stderr,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.promptMgr == nil {
+		promptMgr, err := prompt.New()
+		if err != nil {
+			// Fall back to empty manager if templates can't be loaded
+			fmt.Fprintf(s.log, "Warning: %v, using the default prompts\n", err)
+			promptMgr = &prompt.TemplateManager{}
+		}
+		s.promptMgr = promptMgr
+	}
+	return s
 }
 
 // GenerateDiagram generates a Mermaid diagram from Go code in the specified file
 func (s *diagramService) GenerateDiagram(ctx context.Context, filePath string, diagramType string) (string, error) {
+	// Map the diagram type string to a DiagramType
+	dt := s.mapDiagramType(diagramType)
+
+	dt = fileDiagramType(filePath, dt)
+	if isStaticDiagramType(dt) {
+		return s.generateStaticDiagram([]string{filePath}, dt)
+	}
+	if skeleton, err := s.usesSkeleton(dt); err != nil {
+		return "", err
+	} else if skeleton {
+		return s.generateSkeletonDiagram(ctx, []string{filePath}, filePath, nil)
+	}
+
 	// Read the file content
-	codeContent, err := s.fileRepo.ReadGoFile(filePath)
+	codeContent, err := s.fileRepo.ReadSourceFile(filePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to read Go file: %w", err)
+		return "", fmt.Errorf("failed to read source file: %w", err)
 	}
 
-	// Map the diagram type string to a DiagramType
-	dt := s.mapDiagramType(diagramType)
-
 	// Generate the prompt for the diagram
 	var promptText string
 	if s.promptMgr != nil {
 		// Use the template manager if available
-		promptText, err = s.promptMgr.GetDiagramPrompt(codeContent, dt)
+		promptText, err = s.promptMgr.GetDiagramPrompt(codeContent, dt, s.notes)
 		if err != nil {
 			// Fall back to old method if template fails
 			promptText = mermaid.CreatePrompt(codeContent, dt)
@@ -68,6 +110,14 @@ func (s *diagramService) GenerateDiagram(ctx context.Context, filePath string, d
 		promptText = mermaid.CreatePrompt(codeContent, dt)
 	}
 
+	if s.structured {
+		structuredDiagram, err := s.generateStructuredDiagram(ctx, promptText, dt, filePath)
+		if err != nil {
+			return "", err
+		}
+		return s.verifyDiagram(ctx, []string{filePath}, structuredDiagram), nil
+	}
+
 	// Generate the diagram using the LLM
 	diagramText, err := s.llmAdapter.GenerateCompletion(ctx, promptText)
 	if err != nil {
@@ -81,26 +131,24 @@ func (s *diagramService) GenerateDiagram(ctx context.Context, filePath string, d
 	validationResult := mermaid.ValidateSyntax(formattedDiagram)
 
 	if !validationResult.IsValid {
-		fmt.Printf("Diagram for file %s has syntax errors, attempting to fix...\n", filePath)
+		fmt.Fprintf(s.log, "Diagram for file %s has syntax errors, attempting to fix...\n", filePath)
 
 		// Create validation service for fixing diagrams
-		validationService := NewValidationService(llm.NewClientAdapter(s.llmAdapter))
+		validationService := s.newValidationService()
 
 		// Try to fix the diagram
-		fixedDiagram, err := s.retryWithBackoff(ctx, "fix-file-diagram", func() (string, error) {
-			return validationService.FixMermaidDiagramWithLLM(ctx, formattedDiagram, validationResult)
-		})
+		fixedDiagram, err := validationService.FixMermaidDiagramWithLLM(ctx, formattedDiagram, validationResult)
 
 		if err != nil {
 			// If fixing failed, use the original but log the error
-			fmt.Printf("Warning: Failed to fix diagram for %s: %v\n", filePath, err)
+			fmt.Fprintf(s.log, "Warning: Failed to fix diagram for %s: %v\n", filePath, err)
 		} else {
-			fmt.Printf("Successfully fixed diagram for %s\n", filePath)
+			fmt.Fprintf(s.log, "Successfully fixed diagram for %s\n", filePath)
 			formattedDiagram = fixedDiagram
 		}
 	}
 
-	return formattedDiagram, nil
+	return s.verifyDiagram(ctx, []string{filePath}, formattedDiagram), nil
 }
 
 // GenerateComponentDiagram generates a Mermaid diagram for a specific component (service, repository, etc.)
@@ -124,22 +172,66 @@ func (s *diagramService) GenerateComponentDiagram(ctx context.Context, component
 		return "", fmt.Errorf("no files found for %s %s", componentType, componentName)
 	}
 
-	// Read all files content
-	var codeContents []string
-	for _, file := range files {
-		content, err := s.fileRepo.ReadGoFile(file)
-		if err != nil {
-			return "", fmt.Errorf("failed to read file %s: %w", file, err)
-		}
-		codeContents = append(codeContents, content)
+	return s.generateFilesDiagram(ctx, &analysis.Target{Scope: fmt.Sprintf("the %s '%s'", componentType, componentName), Files: files}, componentType, diagramType)
+}
+
+// GenerateDirectoryDiagram generates a Mermaid diagram for all source files in a directory
+func (s *diagramService) GenerateDirectoryDiagram(ctx context.Context, dir string, diagramType string) (string, error) {
+	files, err := s.fileRepo.FindSourceFiles(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to find files in %s: %w", dir, err)
 	}
 
-	// Join all code contents
-	allCode := strings.Join(codeContents, "\n\n")
+	if len(files) == 0 {
+		return "", fmt.Errorf("no source files found in %s", dir)
+	}
 
-	// Create prompt for component
-	promptText := fmt.Sprintf("Please create a %s Mermaid diagram for the %s '%s' from this Go code:\n\n```go\n%s\n```\n\nProvide only the Mermaid diagram syntax without any explanation or markdown formatting.",
-		diagramType, componentType, componentName, allCode)
+	return s.generateFilesDiagram(ctx, &analysis.Target{Scope: fmt.Sprintf("the directory '%s'", dir), Files: files}, "", diagramType)
+}
+
+// GenerateTargetDiagram generates a Mermaid diagram for packages or a symbol resolved with go/packages,
+// e.g. ./internal/service/... or service.DiagramService
+func (s *diagramService) GenerateTargetDiagram(ctx context.Context, target string, diagramType string) (string, error) {
+	t, err := analysis.ResolveTarget(s.dir(), target)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve target %s: %w", target, err)
+	}
+
+	return s.generateFilesDiagram(ctx, t, "", diagramType)
+}
+
+// generateFilesDiagram generates a single diagram for the files of a target, its scope describes them in prompts and messages.
+// The component type is set for the files of a component.
+func (s *diagramService) generateFilesDiagram(ctx context.Context, target *analysis.Target, componentType, diagramType string) (string, error) {
+	files, scope := target.Files, target.Scope
+
+	dt := s.mapDiagramType(diagramType)
+	if isStaticDiagramType(dt) {
+		return s.generateStaticDiagram(files, dt)
+	}
+	if skeleton, err := s.usesSkeleton(dt); err != nil {
+		return "", err
+	} else if skeleton {
+		return s.generateSkeletonDiagram(ctx, files, scope, target.Types)
+	}
+
+	// Create prompt for the files
+	data, err := s.scopePromptData(diagramType, scope, componentType, files)
+	if err != nil {
+		return "", err
+	}
+	promptText, err := s.promptMgr.GetComponentPrompt(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to create prompt: %w", err)
+	}
+
+	if s.structured {
+		structuredDiagram, err := s.generateStructuredDiagram(ctx, promptText, dt, scope)
+		if err != nil {
+			return "", err
+		}
+		return s.verifyDiagram(ctx, files, structuredDiagram), nil
+	}
 
 	// Generate diagram using LLM
 	diagramText, err := s.llmAdapter.GenerateCompletion(ctx, promptText)
@@ -153,33 +245,31 @@ func (s *diagramService) GenerateComponentDiagram(ctx context.Context, component
 	validationResult := mermaid.ValidateSyntax(formattedDiagram)
 
 	if !validationResult.IsValid {
-		fmt.Printf("Diagram for %s %s has syntax errors, attempting to fix...\n", componentType, componentName)
+		fmt.Fprintf(s.log, "Diagram for %s has syntax errors, attempting to fix...\n", scope)
 
 		// Create validation service for fixing diagrams
-		validationService := NewValidationService(llm.NewClientAdapter(s.llmAdapter))
+		validationService := s.newValidationService()
 
 		// Try to fix the diagram
-		fixedDiagram, err := s.retryWithBackoff(ctx, fmt.Sprintf("fix-%s-%s-diagram", componentType, componentName), func() (string, error) {
-			return validationService.FixMermaidDiagramWithLLM(ctx, formattedDiagram, validationResult)
-		})
+		fixedDiagram, err := validationService.FixMermaidDiagramWithLLM(ctx, formattedDiagram, validationResult)
 
 		if err != nil {
 			// If fixing failed, use the original but log the error
-			fmt.Printf("Warning: Failed to fix diagram for %s %s: %v\n", componentType, componentName, err)
+			fmt.Fprintf(s.log, "Warning: Failed to fix diagram for %s: %v\n", scope, err)
 		} else {
-			fmt.Printf("Successfully fixed diagram for %s %s\n", componentType, componentName)
+			fmt.Fprintf(s.log, "Successfully fixed diagram for %s\n", scope)
 			formattedDiagram = fixedDiagram
 		}
 	}
 
-	return formattedDiagram, nil
+	return s.verifyDiagram(ctx, files, formattedDiagram), nil
 }
 
 // GenerateProjectDiagram generates project-wide Mermaid diagrams
 func (s *diagramService) GenerateProjectDiagram(ctx context.Context, diagramType string) (string, error) {
 	// Validate diagram type
 	if !isValidProjectDiagramType(diagramType) {
-		return "", fmt.Errorf("invalid project diagram type: %s (should be 'sequence', 'class', 'config', or 'adapters')", diagramType)
+		return "", fmt.Errorf("invalid project diagram type: %s (should be 'sequence', 'class', 'config', 'adapters', 'proto', 'grpc', 'concurrency' or 'implements')", diagramType)
 	}
 
 	// Find all relevant files based on diagram type
@@ -188,8 +278,23 @@ func (s *diagramService) GenerateProjectDiagram(ctx context.Context, diagramType
 
 	// Special handling for class diagrams to process each component type separately
 	if diagramType == "class" {
+		if s.mode == ModeAST || s.mode == ModeHybrid {
+			files, err = s.fileRepo.FindAllComponentFiles([]string{"service", "repository", "adapter", "model", "config"})
+			if err != nil {
+				return "", fmt.Errorf("failed to find files: %w", err)
+			}
+			return s.generateSkeletonDiagram(ctx, files, "the whole project", nil)
+		}
 		return s.generateConcurrentClassDiagram(ctx)
 	}
+	if _, err := s.usesSkeleton(s.mapDiagramType(diagramType)); err != nil {
+		return "", err
+	}
+
+	// The implementation matrix is derived from the type-checked module
+	if diagramType == "implements" {
+		return s.generateImplementsDiagram()
+	}
 
 	switch diagramType {
 	case "sequence":
@@ -199,10 +304,13 @@ func (s *diagramService) GenerateProjectDiagram(ctx context.Context, diagramType
 		// Get only config files
 		files, err = s.fileRepo.FindAllComponentFiles([]string{"config"})
 	case "adapters":
-		// Get adapter files
+		// Get adapter files, including .proto definitions of generated gRPC stubs
 		files, err = s.fileRepo.FindAllComponentFiles([]string{"adapter"})
+	case "proto", "grpc":
+		// Get the files that may define or wire gRPC services
+		files, err = s.fileRepo.FindAllComponentFiles([]string{"service", "repository", "adapter"})
 	default:
-		// Fallback to all files
+		// Fallback to all files, e.g. for concurrency where goroutines may be started by any component
 		files, err = s.fileRepo.FindAllComponentFiles([]string{"service", "repository", "adapter", "model", "config"})
 	}
 
@@ -214,30 +322,26 @@ func (s *diagramService) GenerateProjectDiagram(ctx context.Context, diagramType
 		return "", fmt.Errorf("no relevant files found for diagram type: %s", diagramType)
 	}
 
-	// Read all files content
-	var codeContents []string
-	for _, file := range files {
-		content, err := s.fileRepo.ReadGoFile(file)
-		if err != nil {
-			return "", fmt.Errorf("failed to read file %s: %w", file, err)
-		}
-		codeContents = append(codeContents, fmt.Sprintf("// File: %s\n%s", filepath.Base(file), content))
+	if dt := s.mapDiagramType(diagramType); isStaticDiagramType(dt) {
+		return s.generateStaticDiagram(files, dt)
 	}
 
-	// Join all code contents
-	allCode := strings.Join(codeContents, "\n\n")
-
 	// Create prompt for project diagram
-	var promptText string
-	switch diagramType {
-	case "sequence":
-		promptText = fmt.Sprintf("Please create a sequence diagram showing the interactions between all components (services, repositories, adapters) in this Go project. Focus on the flow of calls between different components and how they interact:\n\n```go\n%s\n```\n\nProvide only the Mermaid diagram syntax without any explanation or markdown formatting.", allCode)
-	case "config":
-		promptText = fmt.Sprintf("Please create a diagram showing how configuration is structured and accessed throughout the application. Show config structs and how other components interact with them:\n\n```go\n%s\n```\n\nProvide only the Mermaid diagram syntax without any explanation or markdown formatting.", allCode)
-	case "adapters":
-		promptText = fmt.Sprintf("Please create a diagram showing all inbound and outbound communications in the application. Focus on adapter components and how they interact with external systems and internal components:\n\n```go\n%s\n```\n\nProvide only the Mermaid diagram syntax without any explanation or markdown formatting.", allCode)
-	default:
-		promptText = fmt.Sprintf("Please create a diagram showing the overall architecture of this Go project based on the following code:\n\n```go\n%s\n```\n\nProvide only the Mermaid diagram syntax without any explanation or markdown formatting.", allCode)
+	data, err := s.scopePromptData(diagramType, "the whole project", "", files)
+	if err != nil {
+		return "", err
+	}
+	promptText, err := s.promptMgr.GetProjectPrompt(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to create prompt: %w", err)
+	}
+
+	if s.structured {
+		structuredDiagram, err := s.generateStructuredDiagram(ctx, promptText, s.mapDiagramType(diagramType), "the whole project")
+		if err != nil {
+			return "", err
+		}
+		return s.verifyDiagram(ctx, files, structuredDiagram), nil
 	}
 
 	// Generate diagram using LLM
@@ -252,66 +356,24 @@ func (s *diagramService) GenerateProjectDiagram(ctx context.Context, diagramType
 	validationResult := mermaid.ValidateSyntax(formattedDiagram)
 
 	if !validationResult.IsValid {
-		fmt.Printf("Project diagram for type %s has syntax errors, attempting to fix...\n", diagramType)
+		fmt.Fprintf(s.log, "Project diagram for type %s has syntax errors, attempting to fix...\n", diagramType)
 
 		// Create validation service for fixing diagrams
-		validationService := NewValidationService(llm.NewClientAdapter(s.llmAdapter))
+		validationService := s.newValidationService()
 
 		// Try to fix the diagram
-		fixedDiagram, err := s.retryWithBackoff(ctx, fmt.Sprintf("fix-project-%s-diagram", diagramType), func() (string, error) {
-			return validationService.FixMermaidDiagramWithLLM(ctx, formattedDiagram, validationResult)
-		})
+		fixedDiagram, err := validationService.FixMermaidDiagramWithLLM(ctx, formattedDiagram, validationResult)
 
 		if err != nil {
 			// If fixing failed, use the original but log the error
-			fmt.Printf("Warning: Failed to fix project diagram for type %s: %v\n", diagramType, err)
+			fmt.Fprintf(s.log, "Warning: Failed to fix project diagram for type %s: %v\n", diagramType, err)
 		} else {
-			fmt.Printf("Successfully fixed project diagram for type %s\n", diagramType)
+			fmt.Fprintf(s.log, "Successfully fixed project diagram for type %s\n", diagramType)
 			formattedDiagram = fixedDiagram
 		}
 	}
 
-	return formattedDiagram, nil
-}
-
-// retryWithBackoff attempts to call the provided function with exponential backoff
-// for rate limit errors (429 status code)
-func (s *diagramService) retryWithBackoff(ctx context.Context, operation string, fn func() (string, error)) (string, error) {
-	maxRetries := 3
-	baseDelay := 2 * time.Second
-
-	var lastErr error
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		result, err := fn()
-		if err == nil {
-			return result, nil
-		}
-
-		lastErr = err
-		// Check if the error is a rate limit error (contains 429 status code)
-		if strings.Contains(err.Error(), "429") {
-			// Calculate backoff with jitter
-			delay := baseDelay * time.Duration(1<<uint(attempt))
-			jitter := time.Duration(rand.Int63n(int64(delay) / 2))
-			waitTime := delay + jitter
-
-			fmt.Printf("Rate limit hit for %s operation, retrying after %v (attempt %d/%d)\n",
-				operation, waitTime, attempt+1, maxRetries)
-
-			// Wait before next attempt
-			select {
-			case <-time.After(waitTime):
-				continue
-			case <-ctx.Done():
-				return "", fmt.Errorf("context canceled while waiting to retry: %w", ctx.Err())
-			}
-		} else {
-			// For non-rate-limit errors, don't retry
-			return "", err
-		}
-	}
-
-	return "", fmt.Errorf("failed after %d attempts: %w", maxRetries, lastErr)
+	return s.verifyDiagram(ctx, files, formattedDiagram), nil
 }
 
 // generateConcurrentClassDiagram generates class diagrams for each component type concurrently,
@@ -330,16 +392,14 @@ func (s *diagramService) generateConcurrentClassDiagram(ctx context.Context) (st
 	resultCh := make(chan diagramResult, len(componentTypes))
 
 	// Create a validation service for fixing diagrams
-	validationService := NewValidationService(llm.NewClientAdapter(s.llmAdapter))
-
-	// Create a semaphore to limit concurrent LLM API calls
-	// Using a buffered channel as a semaphore
-	const maxConcurrentLLMCalls = 2 // Limit to 2 concurrent calls to avoid rate limiting
-	sem := make(chan struct{}, maxConcurrentLLMCalls)
+	validationService := s.newValidationService()
 
-	// Process each component type concurrently
+	// Process each component type concurrently, the LLM adapter limits the concurrent calls
 	for _, compType := range componentTypes {
 		go func(componentType string) {
+			// Label the LLM calls of the component for progress reporting
+			ctx := llm.WithLabel(ctx, componentType)
+
 			// Find files for this component type
 			files, err := s.fileRepo.FindAllComponentFiles([]string{componentType})
 			if err != nil {
@@ -353,36 +413,29 @@ func (s *diagramService) generateConcurrentClassDiagram(ctx context.Context) (st
 				return
 			}
 
-			// Read all files for this component type
-			var codeContents []string
-			for _, file := range files {
-				content, err := s.fileRepo.ReadGoFile(file)
+			// Create prompt for this component type
+			data, err := s.scopePromptData("class", fmt.Sprintf("the '%s' components of the project", componentType), componentType, files)
+			if err != nil {
+				resultCh <- diagramResult{componentType: componentType, err: err}
+				return
+			}
+			promptText, err := s.promptMgr.GetComponentClassPrompt(data)
+			if err != nil {
+				resultCh <- diagramResult{componentType: componentType, err: fmt.Errorf("failed to create prompt: %w", err)}
+				return
+			}
+
+			if s.structured {
+				structuredDiagram, err := s.generateStructuredDiagram(ctx, promptText, mermaid.Class, fmt.Sprintf("the %s components", componentType))
 				if err != nil {
-					resultCh <- diagramResult{componentType: componentType, err: fmt.Errorf("failed to read file %s: %w", file, err)}
-					return
+					err = fmt.Errorf("failed to generate %s diagram: %w", componentType, err)
 				}
-				codeContents = append(codeContents, fmt.Sprintf("// File: %s\n%s", filepath.Base(file), content))
+				resultCh <- diagramResult{componentType: componentType, diagram: structuredDiagram, err: err}
+				return
 			}
 
-			// Join code contents
-			allCode := strings.Join(codeContents, "\n\n")
-
-			// Acquire semaphore before making LLM API call
-			sem <- struct{}{}
-			fmt.Printf("Starting diagram generation for %s component\n", componentType)
-
-			// Create prompt for this component type
-			promptText := fmt.Sprintf("Please create a class diagram for the '%s' components in this Go project. Show their structs, interfaces, methods, and relationships:\n\n```go\n%s\n```\n\nProvide only the Mermaid diagram syntax without any explanation or markdown formatting.", componentType, allCode)
-
-			// Use retryWithBackoff for LLM calls
-			operation := fmt.Sprintf("generate-%s-diagram", componentType)
-			diagramText, err := s.retryWithBackoff(ctx, operation, func() (string, error) {
-				return s.llmAdapter.GenerateCompletion(ctx, promptText)
-			})
-
-			// Release semaphore after LLM API call
-			<-sem
-
+			fmt.Fprintf(s.log, "Starting diagram generation for %s component\n", componentType)
+			diagramText, err := s.llmAdapter.GenerateCompletion(ctx, promptText)
 			if err != nil {
 				resultCh <- diagramResult{componentType: componentType, err: fmt.Errorf("failed to generate %s diagram: %w", componentType, err)}
 				return
@@ -393,25 +446,15 @@ func (s *diagramService) generateConcurrentClassDiagram(ctx context.Context) (st
 			validationResult := mermaid.ValidateSyntax(formattedDiagram)
 
 			if !validationResult.IsValid {
-				fmt.Printf("Diagram for %s component has syntax errors, attempting to fix...\n", componentType)
-
-				// Try to fix the diagram - acquire semaphore before LLM call
-				sem <- struct{}{}
-
-				// Use retryWithBackoff for fixing
-				fixOperation := fmt.Sprintf("fix-%s-diagram", componentType)
-				fixedDiagram, err := s.retryWithBackoff(ctx, fixOperation, func() (string, error) {
-					return validationService.FixMermaidDiagramWithLLM(ctx, formattedDiagram, validationResult)
-				})
-
-				// Release semaphore after LLM API call
-				<-sem
+				fmt.Fprintf(s.log, "Diagram for %s component has syntax errors, attempting to fix...\n", componentType)
 
+				// Try to fix the diagram
+				fixedDiagram, err := validationService.FixMermaidDiagramWithLLM(ctx, formattedDiagram, validationResult)
 				if err != nil {
 					// If fixing failed, use the original but log the error
-					fmt.Printf("Warning: Failed to fix %s diagram: %v\n", componentType, err)
+					fmt.Fprintf(s.log, "Warning: Failed to fix %s diagram: %v\n", componentType, err)
 				} else {
-					fmt.Printf("Successfully fixed %s diagram\n", componentType)
+					fmt.Fprintf(s.log, "Successfully fixed %s diagram\n", componentType)
 					formattedDiagram = fixedDiagram
 				}
 			}
@@ -459,24 +502,20 @@ func (s *diagramService) generateConcurrentClassDiagram(ctx context.Context) (st
 		}
 	}
 
-	// Generate relationships between components using LLM - acquire semaphore
-	relationshipPrompt := "Based on the following component definitions, please generate only the relationships between different component types (service, repository, adapter, model, config). Return only Mermaid class diagram relationship syntax (e.g., 'ClassA --> ClassB: uses'):\n\n"
-
-	for compType, diagram := range componentDiagrams {
-		relationshipPrompt += fmt.Sprintf("// %s components\n%s\n\n", compType, diagram)
+	// Generate relationships between components using LLM
+	relationshipData := prompt.RelationshipsPromptData{ModulePath: s.modulePath(), Notes: s.notes}
+	for _, compType := range componentTypes {
+		if diagram, ok := componentDiagrams[compType]; ok {
+			relationshipData.ComponentDiagrams = append(relationshipData.ComponentDiagrams, prompt.ComponentDiagram{ComponentType: compType, Diagram: diagram})
+		}
+	}
+	relationshipPrompt, err := s.promptMgr.GetRelationshipsPrompt(relationshipData)
+	if err != nil {
+		return "", fmt.Errorf("failed to create prompt: %w", err)
 	}
 
-	// Acquire semaphore for the final LLM call
-	sem <- struct{}{}
-	fmt.Println("Generating cross-component relationships...")
-
-	// Use retryWithBackoff for relationship generation
-	relationships, err := s.retryWithBackoff(ctx, "generate-relationships", func() (string, error) {
-		return s.llmAdapter.GenerateCompletion(ctx, relationshipPrompt)
-	})
-
-	// Release semaphore
-	<-sem
+	fmt.Fprintln(s.log, "Generating cross-component relationships...")
+	relationships, err := s.llmAdapter.GenerateCompletion(llm.WithLabel(ctx, "relationships"), relationshipPrompt)
 
 	if err == nil && relationships != "" {
 		// Add relationships to the combined diagram
@@ -494,12 +533,24 @@ func (s *diagramService) generateConcurrentClassDiagram(ctx context.Context) (st
 			}
 		}
 	} else if err != nil {
-		fmt.Printf("Warning: Failed to generate cross-component relationships: %v\n", err)
+		fmt.Fprintf(s.log, "Warning: Failed to generate cross-component relationships: %v\n", err)
+	}
+
+	if s.verify {
+		if files, err := s.fileRepo.FindAllComponentFiles(componentTypes); err == nil {
+			return s.verifyDiagram(ctx, files, combinedDiagram.String()), nil
+		}
 	}
 
 	return combinedDiagram.String(), nil
 }
 
+// newValidationService creates the service fixing diagrams with the LLM, the prompt templates
+// and the log of this service
+func (s *diagramService) newValidationService() *ValidationService {
+	return NewValidationService(llm.NewClientAdapter(s.llmAdapter), WithValidationPrompts(s.promptMgr), WithValidationLog(s.log))
+}
+
 // mapDiagramType converts a string to a DiagramType
 func (s *diagramService) mapDiagramType(dt string) mermaid.DiagramType {
 	switch dt {
@@ -517,6 +568,14 @@ func (s *diagramService) mapDiagramType(dt string) mermaid.DiagramType {
 		return mermaid.Config
 	case "adapters":
 		return mermaid.Adapters
+	case "proto":
+		return mermaid.Proto
+	case "grpc":
+		return mermaid.GRPC
+	case "concurrency":
+		return mermaid.Concurrency
+	case "implements":
+		return mermaid.Implements
 	default:
 		return mermaid.Basic
 	}
@@ -524,7 +583,7 @@ func (s *diagramService) mapDiagramType(dt string) mermaid.DiagramType {
 
 // isValidProjectDiagramType checks if a diagram type is valid for project-wide diagrams
 func isValidProjectDiagramType(dt string) bool {
-	validTypes := []string{"sequence", "class", "config", "adapters"}
+	validTypes := []string{"sequence", "class", "config", "adapters", "proto", "grpc", "concurrency", "implements"}
 	for _, t := range validTypes {
 		if dt == t {
 			return true
@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/suite"
+
+	"mm-go-agent/internal/adapter/llm"
+	"mm-go-agent/internal/repository"
+	"mm-go-agent/pkg/prompt"
+)
+
+// fakeLLM returns a canned completion and records the prompts
+type fakeLLM struct {
+	completion string
+	prompts    []string
+}
+
+func (f *fakeLLM) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	f.prompts = append(f.prompts, prompt)
+	return f.completion, nil
+}
+
+// structuredLLM returns JSON graphs in order through tool use and records the prompts and schemas
+type structuredLLM struct {
+	graphs  []string
+	prompts []string
+	schemas []string
+}
+
+func (f *structuredLLM) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	return "", fmt.Errorf("unexpected text completion")
+}
+
+func (f *structuredLLM) GenerateStructured(ctx context.Context, prompt string, schema llm.Schema) (string, error) {
+	f.prompts = append(f.prompts, prompt)
+	f.schemas = append(f.schemas, schema.Name)
+	graph := f.graphs[0]
+	f.graphs = f.graphs[1:]
+	return graph, nil
+}
+
+// DiagramServiceTestSuite is a test suite for the diagram service over an in-memory project
+type DiagramServiceTestSuite struct {
+	suite.Suite
+	repo repository.FileRepository
+	llm  *fakeLLM
+}
+
+// SetupTest creates the project and the fake LLM
+func (s *DiagramServiceTestSuite) SetupTest() {
+	s.repo = repository.NewFSFileRepository(fstest.MapFS{
+		"go.mod": {Data: []byte("module example.com/users\n\ngo 1.22\n")},
+		"internal/services/user_service.go": {Data: []byte(`package services
+
+// UserService manages users
+type UserService struct {
+	store Store
+}
+
+// Store persists users
+type Store interface {
+	Get(id string) (string, error)
+}
+
+func (s *UserService) Find(id string) (string, error) { return s.store.Get(id) }
+`)},
+		"internal/services/store/memory.go": {Data: []byte(`package store
+
+type Memory struct {
+	users map[string]string
+}
+`)},
+	})
+	s.llm = &fakeLLM{completion: "```mermaid\nclassDiagram\n  class UserService\n```"}
+}
+
+// TestGenerateDiagram checks that the file is sent to the LLM
+func (s *DiagramServiceTestSuite) TestGenerateDiagram() {
+	svc := NewDiagramService(s.repo, s.llm)
+
+	diagram, err := svc.GenerateDiagram(context.Background(), "internal/services/user_service.go", "class")
+	s.Require().NoError(err)
+	s.Contains(diagram, "class UserService")
+	s.Require().Len(s.llm.prompts, 1)
+	s.Contains(s.llm.prompts[0], "type UserService struct")
+
+	_, err = svc.GenerateDiagram(context.Background(), "internal/services/missing.go", "class")
+	s.ErrorContains(err, "failed to read source file")
+}
+
+// TestGenerateComponentDiagram checks the component lookup and the prompt data
+func (s *DiagramServiceTestSuite) TestGenerateComponentDiagram() {
+	svc := NewDiagramService(s.repo, s.llm, WithNotes("Stores are backed by Redis"))
+
+	_, err := svc.GenerateComponentDiagram(context.Background(), "service:user", "class")
+	s.Require().NoError(err)
+	s.Require().Len(s.llm.prompts, 1)
+	s.Contains(s.llm.prompts[0], "for the service 'user'")
+	s.Contains(s.llm.prompts[0], "in the Go module example.com/users. It is part of the package services.")
+	s.Contains(s.llm.prompts[0], "// File: internal/services/user_service.go")
+	s.Contains(s.llm.prompts[0], "Stores are backed by Redis")
+
+	_, err = svc.GenerateComponentDiagram(context.Background(), "repository:user", "class")
+	s.Error(err)
+}
+
+// TestWithPrompts checks that the examples of the prompt manager reach the prompts
+func (s *DiagramServiceTestSuite) TestWithPrompts() {
+	promptMgr, err := prompt.Load("")
+	s.Require().NoError(err)
+	promptMgr.UseExamples([]prompt.Example{{DiagramType: "class", Name: "house style", Code: "type A struct{}", Diagram: "classDiagram\n  namespace store {\n    class A\n  }"}}, 0)
+	svc := NewDiagramService(s.repo, s.llm, WithPrompts(promptMgr))
+
+	_, err = svc.GenerateComponentDiagram(context.Background(), "service:user", "class")
+	s.Require().NoError(err)
+	s.Require().Len(s.llm.prompts, 1)
+	s.Contains(s.llm.prompts[0], "## house style")
+}
+
+// TestFixWithPrompts checks that diagrams are fixed with the templates given to the service
+func (s *DiagramServiceTestSuite) TestFixWithPrompts() {
+	dir := s.T().TempDir()
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "fix_diagram.tmpl"), []byte("Fix the diagram the house way"), 0o644))
+	promptMgr, err := prompt.Load(dir)
+	s.Require().NoError(err)
+	s.llm.completion = "flowchart TD\n  A[Start --> B"
+	svc := NewDiagramService(s.repo, s.llm, WithPrompts(promptMgr), WithLog(io.Discard))
+
+	_, err = svc.GenerateDiagram(context.Background(), "internal/services/user_service.go", "class")
+	s.Require().NoError(err)
+	s.Require().Greater(len(s.llm.prompts), 1)
+	s.Equal("Fix the diagram the house way", s.llm.prompts[1])
+}
+
+// TestWithLog checks that the progress of fixing a diagram goes to the log and not to the diagram
+func (s *DiagramServiceTestSuite) TestWithLog() {
+	s.llm.completion = "classDiagram\n  class UserService {\n    +Find(id string)"
+	var log strings.Builder
+	svc := NewDiagramService(s.repo, s.llm, WithLog(&log))
+
+	diagram, err := svc.GenerateDiagram(context.Background(), "internal/services/user_service.go", "class")
+	s.Require().NoError(err)
+	s.Contains(log.String(), "Diagram for file internal/services/user_service.go has syntax errors, attempting to fix...")
+	s.NotContains(diagram, "attempting to fix")
+}
+
+// TestGenerateProjectDiagram checks that project prompts use the template of the diagram type
+func (s *DiagramServiceTestSuite) TestGenerateProjectDiagram() {
+	s.llm.completion = "sequenceDiagram\n  UserService->>Store: Get"
+	svc := NewDiagramService(s.repo, s.llm)
+
+	_, err := svc.GenerateProjectDiagram(context.Background(), "sequence")
+	s.Require().NoError(err)
+	s.Require().Len(s.llm.prompts, 1)
+	s.Contains(s.llm.prompts[0], "Create a sequence diagram showing the interactions between all components")
+	s.Contains(s.llm.prompts[0], "The code below is the whole project in the Go module example.com/users")
+	s.NotContains(s.llm.prompts[0], "# NOTES")
+}
+
+// TestGenerateDirectoryDiagram checks that AST mode works without the LLM
+func (s *DiagramServiceTestSuite) TestGenerateDirectoryDiagram() {
+	svc := NewDiagramService(s.repo, nil, WithMode(ModeAST))
+
+	diagram, err := svc.GenerateDirectoryDiagram(context.Background(), "internal/services", "class")
+	s.Require().NoError(err)
+	s.Contains(diagram, "class UserService")
+	s.Contains(diagram, "class Memory")
+	s.Contains(diagram, "UserService --> Store")
+	s.Empty(s.llm.prompts)
+
+	_, err = svc.GenerateDirectoryDiagram(context.Background(), "internal/services", "sequence")
+	s.ErrorContains(err, "only supports class diagrams")
+}
+
+// TestHybridMode checks that the LLM only annotates the skeleton: added classes and members are
+// rejected and dropped members are kept
+func (s *DiagramServiceTestSuite) TestHybridMode() {
+	s.llm.completion = "```mermaid\nclassDiagram\n  class UserService[\"User service\"] {\n    +Delete(id string) error\n  }\n  class Ghost\n  note for UserService \"Entry point\"\n```"
+	var log strings.Builder
+	svc := NewDiagramService(s.repo, s.llm, WithMode(ModeHybrid), WithLog(&log))
+
+	diagram, err := svc.GenerateDiagram(context.Background(), "internal/services/user_service.go", "class")
+	s.Require().NoError(err)
+	s.Require().Len(s.llm.prompts, 1)
+	s.Contains(s.llm.prompts[0], "+Find(id string) (string, error)")
+	s.Contains(diagram, `class UserService["User service"]`)
+	s.Contains(diagram, "+Find(id string) (string, error)")
+	s.Contains(diagram, `note for UserService "Entry point"`)
+	s.NotContains(diagram, "Delete")
+	s.NotContains(diagram, "Ghost")
+	s.Contains(log.String(), "Rejected 2 annotations for internal/services/user_service.go not found in the code: member UserService.+Delete(id string) error; class Ghost")
+
+	// Annotations that aren't a class diagram leave the skeleton as it is
+	s.llm.completion = "sequenceDiagram\n  A->>B: hi"
+	diagram, err = svc.GenerateDiagram(context.Background(), "internal/services/user_service.go", "class")
+	s.Require().NoError(err)
+	s.Contains(diagram, "class UserService {")
+	s.Contains(log.String(), "aren't a class diagram, using the AST skeleton")
+}
+
+// TestGenerateImplementsMatrix checks that the diagram and the table come from the same module
+func (s *DiagramServiceTestSuite) TestGenerateImplementsMatrix() {
+	dir := s.T().TempDir()
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/users\n\ngo 1.22\n"), 0o644))
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "users.go"), []byte(`package users
+
+type Store interface {
+	Get(id string) (string, error)
+}
+
+type Memory struct{}
+
+func (m *Memory) Get(id string) (string, error) { return id, nil }
+`), 0o644))
+	svc := NewDiagramService(s.repo, nil, WithPackageDir(dir))
+
+	diagram, table, err := svc.GenerateImplementsMatrix(context.Background())
+	s.Require().NoError(err)
+	s.Contains(diagram, "Memory ..|> Store")
+	s.Contains(table, "| `Store` | `example.com/users` | `*users.Memory` |")
+}
+
+// TestRequiresLLM checks that static diagram types and class diagrams of .proto files don't need an LLM
+func (s *DiagramServiceTestSuite) TestRequiresLLM() {
+	s.True(RequiresLLM("class", ""))
+	s.True(RequiresLLM("class", "internal/services/user_service.go"))
+	s.True(RequiresLLM("sequence", "api/users.proto"))
+	s.False(RequiresLLM("class", "api/users.proto"))
+	s.False(RequiresLLM("grpc", ""))
+	s.False(RequiresLLM("concurrency", ""))
+	s.False(RequiresLLM("implements", ""))
+}
+
+// TestStructuredOutput checks that JSON graphs are rendered and only schema violations retried
+func (s *DiagramServiceTestSuite) TestStructuredOutput() {
+	structured := &structuredLLM{graphs: []string{
+		`{"kind": "class", "classes": [{"name": "UserService"}], "relations": [{"from": "UserService", "to": "Store", "type": "association"}]}`,
+		`{"kind": "class", "classes": [{"name": "UserService"}, {"name": "Store", "annotation": "interface"}], "relations": [{"from": "UserService", "to": "Store", "type": "association", "label": "store"}]}`,
+	}}
+	svc := NewDiagramService(s.repo, structured, WithStructuredOutput())
+
+	diagram, err := svc.GenerateDiagram(context.Background(), "internal/services/user_service.go", "class")
+	s.Require().NoError(err)
+	s.Contains(diagram, "UserService --> Store : store")
+	s.Contains(diagram, "<<interface>>")
+	s.Require().Len(structured.prompts, 2)
+	s.Equal([]string{graphTool, graphTool}, structured.schemas)
+	s.Contains(structured.prompts[0], "type UserService struct")
+	s.Contains(structured.prompts[0], `Describe the diagram as a JSON graph of kind "class"`)
+	s.Contains(structured.prompts[1], `- relations[0].to "Store" is not a declared class`)
+
+	structured.graphs = []string{`{"kind": "flowchart", "nodes": [{"id": "A"}]}`, `{"kind": "flowchart", "nodes": [{"id": "A"}]}`, `{"kind": "flowchart", "nodes": [{"id": "A"}]}`, `{"kind": "flowchart", "nodes": [{"id": "A"}]}`}
+	_, err = svc.GenerateDiagram(context.Background(), "internal/services/user_service.go", "sequence")
+	s.ErrorContains(err, `kind must be "sequence", got "flowchart"`)
+	s.Empty(structured.graphs, "the graph should be sent back until the fix retries run out")
+}
+
+// TestHighlightFocus checks that the focal class is styled, preferring package qualified IDs
+func (s *DiagramServiceTestSuite) TestHighlightFocus() {
+	diagram := highlightFocus("```mermaid\nclassDiagram\n  class Store\n  class store_Store\n  class UserService\n```", "store.Store")
+	s.Contains(diagram, "class store_Store:::focus")
+	s.NotContains(diagram, "class Store:::focus")
+	s.Contains(diagram, "classDef focus "+focusStyle)
+
+	diagram = highlightFocus("```mermaid\nflowchart TD\n  UserService --> Store\n```", "services.UserService")
+	s.Contains(diagram, "UserService:::focus")
+}
+
+// TestDiagramServiceTestSuite runs the diagram service test suite
+func TestDiagramServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(DiagramServiceTestSuite))
+}
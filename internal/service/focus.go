@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"mm-go-agent/pkg/analysis"
+	"mm-go-agent/pkg/mermaid"
+)
+
+// focusStyle is the classDef of the focal type of a focus diagram
+const focusStyle = "fill:#fff3b0,stroke:#d4a017,stroke-width:3px"
+
+// GenerateFocusDiagram generates a Mermaid diagram of the types within depth hops of a type in
+// the type graph of the module, following relations in the given direction (out, in or both).
+// The type itself is highlighted.
+func (s *diagramService) GenerateFocusDiagram(ctx context.Context, symbol string, depth int, direction string, diagramType string) (string, error) {
+	dir, err := analysis.ParseDirection(direction)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := analysis.FocusTarget(s.dir(), symbol, depth, dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", symbol, err)
+	}
+
+	diagram, err := s.generateFilesDiagram(ctx, target, "", diagramType)
+	if err != nil {
+		return "", err
+	}
+
+	return highlightFocus(diagram, target.Focus), nil
+}
+
+// highlightFocus styles the class or flowchart node of the focal type, given as "package.Name".
+// Skeleton diagrams qualify ambiguous class IDs as package_Name.
+func highlightFocus(diagram, focus string) string {
+	d, err := mermaid.Parse(diagram)
+	if err != nil {
+		return diagram
+	}
+
+	pkg, name, _ := strings.Cut(focus, ".")
+	for _, id := range []string{pkg + "_" + name, name} {
+		if c := d.Class(id); c != nil {
+			c.CSSClass = "focus"
+			break
+		}
+		if n := d.Node(id); n != nil {
+			n.CSSClass = "focus"
+			break
+		}
+	}
+
+	d.Extra = append(d.Extra, &mermaid.Statement{Text: "classDef focus " + focusStyle})
+	return mermaid.FormatOutput(d.String())
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"mm-go-agent/internal/adapter/llm"
+	"mm-go-agent/pkg/analysis"
+	"mm-go-agent/pkg/mermaid"
+)
+
+// GenerationMode selects how class diagrams are produced
+type GenerationMode string
+
+const (
+	// ModeLLM asks the LLM to generate the whole diagram from the code
+	ModeLLM GenerationMode = "llm"
+	// ModeAST renders the types, members and edges extracted from the code without an LLM
+	ModeAST GenerationMode = "ast"
+	// ModeHybrid renders the AST skeleton annotated by the LLM with labels, namespaces and notes
+	ModeHybrid GenerationMode = "hybrid"
+)
+
+// ParseGenerationMode converts a mode name into a GenerationMode
+func ParseGenerationMode(name string) (GenerationMode, error) {
+	switch mode := GenerationMode(name); mode {
+	case ModeLLM, ModeAST, ModeHybrid:
+		return mode, nil
+	case "":
+		return ModeLLM, nil
+	}
+	return "", fmt.Errorf("invalid generation mode: %s (should be 'llm', 'ast' or 'hybrid')", name)
+}
+
+// Option configures a DiagramService
+type Option func(*diagramService)
+
+// WithMode sets the generation mode used for class diagrams
+func WithMode(mode GenerationMode) Option {
+	return func(s *diagramService) {
+		s.mode = mode
+	}
+}
+
+// usesSkeleton reports whether the diagram type is built from the AST skeleton in the current mode
+func (s *diagramService) usesSkeleton(dt mermaid.DiagramType) (bool, error) {
+	if s.mode == "" || s.mode == ModeLLM || !RequiresLLM(string(dt), "") {
+		return false, nil
+	}
+	if dt != mermaid.Class {
+		return false, fmt.Errorf("%s mode only supports class diagrams, got %s", s.mode, dt)
+	}
+	return true, nil
+}
+
+// generateSkeletonDiagram builds a class diagram from the AST of the Go files. In hybrid mode the
+// LLM annotates the skeleton and every proposed class, member or relation that isn't in the
+// skeleton is rejected before rendering. When types is set, only those types are shown.
+func (s *diagramService) generateSkeletonDiagram(ctx context.Context, files []string, scope string, types []string) (string, error) {
+	var sources []analysis.SourceFile
+	var codeContents []string
+	for _, file := range files {
+		if filepath.Ext(file) != ".go" {
+			continue
+		}
+		content, err := s.fileRepo.ReadGoFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file %s: %w", file, err)
+		}
+		sources = append(sources, analysis.SourceFile{Path: file, Content: content})
+		codeContents = append(codeContents, fmt.Sprintf("// File: %s\n%s", filepath.Base(file), content))
+	}
+
+	skeleton, err := analysis.BuildSkeleton(sources)
+	if err != nil {
+		return "", fmt.Errorf("failed to build skeleton: %w", err)
+	}
+	if len(types) > 0 {
+		skeleton = skeleton.Restrict(types)
+	}
+	if len(skeleton.Types) == 0 {
+		return "", fmt.Errorf("no types found in %s", scope)
+	}
+
+	diagram := skeleton.Diagram()
+	if s.mode == ModeAST {
+		return mermaid.FormatOutput(diagram.String()), nil
+	}
+
+	allCode := strings.Join(codeContents, "\n\n")
+	promptText, err := s.promptMgr.GetHybridPrompt(diagram.String(), allCode, scope)
+	if err != nil {
+		promptText = mermaid.CreateHybridPrompt(diagram.String(), allCode)
+	}
+
+	annotated, err := s.llmAdapter.GenerateCompletion(llm.WithLabel(ctx, "annotations"), promptText)
+	if err != nil {
+		// The skeleton is a complete diagram on its own
+		fmt.Fprintf(s.log, "Warning: Failed to annotate diagram for %s, using the AST skeleton: %v\n", scope, err)
+		return mermaid.FormatOutput(diagram.String()), nil
+	}
+
+	proposed, err := mermaid.Parse(annotated)
+	if err != nil || proposed.Kind != mermaid.KindClass {
+		fmt.Fprintf(s.log, "Warning: Annotations for %s aren't a class diagram, using the AST skeleton\n", scope)
+		return mermaid.FormatOutput(diagram.String()), nil
+	}
+
+	if rejected := mermaid.Annotate(diagram, proposed); len(rejected) > 0 {
+		fmt.Fprintf(s.log, "Rejected %d annotations for %s not found in the code: %s\n", len(rejected), scope, strings.Join(rejected, "; "))
+	}
+
+	return mermaid.FormatOutput(diagram.String()), nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+
+	"mm-go-agent/pkg/prompt"
+	"mm-go-agent/pkg/proto"
+)
+
+// WithNotes adds notes about the code, e.g. domain terms or what to leave out, to every prompt
+func WithNotes(notes string) Option {
+	return func(s *diagramService) {
+		s.notes = notes
+	}
+}
+
+// WithPrompts sets the prompt templates and examples used instead of the default templates
+func WithPrompts(promptMgr *prompt.TemplateManager) Option {
+	return func(s *diagramService) {
+		s.promptMgr = promptMgr
+	}
+}
+
+// scopePromptData reads the files of a scope into the data of a prompt
+func (s *diagramService) scopePromptData(diagramType, scope, componentType string, files []string) (prompt.ScopePromptData, error) {
+	data := prompt.ScopePromptData{
+		DiagramType:   diagramType,
+		Scope:         scope,
+		ComponentType: componentType,
+		ModulePath:    s.modulePath(),
+		Notes:         s.notes,
+	}
+
+	seen := make(map[string]bool)
+	for _, file := range files {
+		content, err := s.fileRepo.ReadSourceFile(file)
+		if err != nil {
+			return data, err
+		}
+
+		source := prompt.SourceFile{Path: filepath.ToSlash(file), Language: "go", Content: content}
+		if filepath.Ext(file) == ".proto" {
+			source.Language = "proto"
+			if f, err := proto.Parse(file, content); err == nil {
+				source.Package = f.Package
+			}
+		} else if f, err := parser.ParseFile(token.NewFileSet(), file, content, parser.PackageClauseOnly); err == nil {
+			source.Package = f.Name.Name
+		}
+
+		if source.Package != "" && !seen[source.Package] {
+			seen[source.Package] = true
+			data.Packages = append(data.Packages, source.Package)
+		}
+		data.Files = append(data.Files, source)
+	}
+	sort.Strings(data.Packages)
+	return data, nil
+}
+
+// modulePath returns the module path of the repository, or an empty string outside of a module
+func (s *diagramService) modulePath() string {
+	modulePath, err := s.fileRepo.ModulePath()
+	if err != nil {
+		return ""
+	}
+	return modulePath
+}
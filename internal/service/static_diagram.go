@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"mm-go-agent/pkg/analysis"
+	"mm-go-agent/pkg/mermaid"
+	"mm-go-agent/pkg/proto"
+)
+
+// isStaticDiagramType reports whether the diagram type is rendered from static analysis
+// of the source files instead of being generated by the LLM
+func isStaticDiagramType(dt mermaid.DiagramType) bool {
+	switch dt {
+	case mermaid.Proto, mermaid.GRPC, mermaid.Concurrency:
+		return true
+	}
+	return false
+}
+
+// fileDiagramType returns the diagram type generated for a file: class diagrams of protobuf
+// files are rendered from the parsed definitions
+func fileDiagramType(filePath string, dt mermaid.DiagramType) mermaid.DiagramType {
+	if filepath.Ext(filePath) == ".proto" && dt == mermaid.Class {
+		return mermaid.Proto
+	}
+	return dt
+}
+
+// RequiresLLM reports whether generating the diagram type for the file needs an LLM. The file
+// path is empty for components, targets and the project.
+func RequiresLLM(diagramType, filePath string) bool {
+	dt := fileDiagramType(filePath, mermaid.DiagramType(diagramType))
+	return !isStaticDiagramType(dt) && dt != mermaid.Implements
+}
+
+// generateStaticDiagram renders a static diagram type from the given source files
+func (s *diagramService) generateStaticDiagram(files []string, dt mermaid.DiagramType) (string, error) {
+	if dt == mermaid.Concurrency {
+		return s.generateConcurrencyDiagram(files)
+	}
+	return s.generateGRPCDiagram(files, dt)
+}
+
+// generateImplementsDiagram renders every interface of the module and the concrete types
+// satisfying it as a class diagram with realization arrows
+func (s *diagramService) generateImplementsDiagram() (string, error) {
+	report, err := s.findImplementations()
+	if err != nil {
+		return "", err
+	}
+	return mermaid.FormatOutput(report.ClassDiagram()), nil
+}
+
+// GenerateImplementsMatrix renders the interface implementation matrix both as the implements
+// class diagram and as a Markdown table, loading the packages once
+func (s *diagramService) GenerateImplementsMatrix(ctx context.Context) (string, string, error) {
+	report, err := s.findImplementations()
+	if err != nil {
+		return "", "", err
+	}
+	return mermaid.FormatOutput(report.ClassDiagram()), report.MarkdownTable(), nil
+}
+
+// WithPackageDir sets the directory Go packages are loaded from for the implements diagram and
+// targets, the current directory by default
+func WithPackageDir(dir string) Option {
+	return func(s *diagramService) {
+		s.packageDir = dir
+	}
+}
+
+// dir returns the directory Go packages are loaded from
+func (s *diagramService) dir() string {
+	if s.packageDir == "" {
+		return "."
+	}
+	return s.packageDir
+}
+
+func (s *diagramService) findImplementations() (*analysis.ImplementsReport, error) {
+	pkgs, err := analysis.LoadPackages(s.dir(), "./...")
+	if err != nil {
+		return nil, err
+	}
+
+	report := analysis.FindImplementations(pkgs)
+	if len(report.Interfaces) == 0 {
+		return nil, fmt.Errorf("no interfaces found")
+	}
+	return report, nil
+}
+
+// generateConcurrencyDiagram renders the goroutines, channels, wait groups and semaphores
+// found in the Go files as a flowchart
+func (s *diagramService) generateConcurrencyDiagram(files []string) (string, error) {
+	var sources []analysis.SourceFile
+	for _, file := range files {
+		if filepath.Ext(file) != ".go" {
+			continue
+		}
+		content, err := s.fileRepo.ReadGoFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file %s: %w", file, err)
+		}
+		sources = append(sources, analysis.SourceFile{Path: file, Content: content})
+	}
+
+	report, err := analysis.AnalyzeConcurrency(sources)
+	if err != nil {
+		return "", fmt.Errorf("failed to analyze concurrency: %w", err)
+	}
+	if report.Empty() {
+		return "", fmt.Errorf("no goroutines, channels or wait groups found")
+	}
+
+	return mermaid.FormatOutput(report.FlowchartDiagram()), nil
+}
+
+// generateGRPCDiagram renders proto and grpc diagrams directly from the given source files.
+// Proto diagrams show services, RPCs and messages as a class diagram, grpc diagrams show the
+// client/server wiring found in Go code as a sequence diagram.
+func (s *diagramService) generateGRPCDiagram(files []string, dt mermaid.DiagramType) (string, error) {
+	var protoFiles []*proto.File
+	var sources []proto.SourceFile
+
+	for _, file := range files {
+		content, err := s.fileRepo.ReadSourceFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file %s: %w", file, err)
+		}
+
+		switch filepath.Ext(file) {
+		case ".proto":
+			parsed, err := proto.Parse(filepath.Base(file), content)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse proto file %s: %w", file, err)
+			}
+			protoFiles = append(protoFiles, parsed)
+		case ".go":
+			if dt != mermaid.GRPC {
+				continue
+			}
+			sources = append(sources, proto.SourceFile{Path: file, Content: content})
+		}
+	}
+
+	if dt == mermaid.Proto {
+		if len(protoFiles) == 0 {
+			return "", fmt.Errorf("no .proto files found")
+		}
+		return mermaid.FormatOutput(proto.ClassDiagram(protoFiles...)), nil
+	}
+
+	wiring, err := proto.FindWiring(sources...)
+	if err != nil {
+		return "", fmt.Errorf("failed to analyze the gRPC wiring: %w", err)
+	}
+	if wiring.Empty() {
+		return "", fmt.Errorf("no gRPC client or server wiring found")
+	}
+	return mermaid.FormatOutput(proto.SequenceDiagram([]*proto.Wiring{wiring}, protoFiles)), nil
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"mm-go-agent/internal/adapter/llm"
+	"mm-go-agent/pkg/mermaid"
+	"mm-go-agent/pkg/prompt"
+)
+
+// graphTool is the name of the tool LLMs supporting tool use return JSON graphs with
+const graphTool = "render_diagram"
+
+// WithStructuredOutput asks the LLM for class, sequence and flowchart diagrams as JSON graphs
+// matching a schema, which are rendered into Mermaid without syntax errors. Only graphs violating
+// the schema are sent back to the LLM.
+func WithStructuredOutput() Option {
+	return func(s *diagramService) {
+		s.structured = true
+	}
+}
+
+// generateStructuredDiagram asks for the diagram of a generation prompt as a JSON graph and
+// renders it, fixing schema violations up to the configured number of retries
+func (s *diagramService) generateStructuredDiagram(ctx context.Context, promptText string, dt mermaid.DiagramType, scope string) (string, error) {
+	kind, ok := mermaid.GraphKindFor(dt)
+	if !ok {
+		return "", fmt.Errorf("structured output only supports class, sequence and flowchart diagrams, got %s", dt)
+	}
+
+	schema := string(mermaid.GraphSchema())
+	graphPrompt, err := s.promptMgr.GetGraphPrompt(prompt.GraphPromptData{Prompt: promptText, Kind: string(kind), Schema: schema, Tool: graphTool})
+	if err != nil {
+		return "", fmt.Errorf("failed to create prompt: %w", err)
+	}
+
+	response, err := s.generateGraph(ctx, graphPrompt)
+	if err != nil {
+		return "", fmt.Errorf("error generating diagram: %w", err)
+	}
+
+	// Fix calls are reported under the label of the diagram they fix
+	fixCtx := llm.WithLabel(ctx, strings.TrimSpace(llm.Label(ctx)+" fix"))
+	maxRetries := fixRetries()
+	for attempt := 1; ; attempt++ {
+		graph, err := mermaid.ParseGraph(response, kind)
+		if err == nil {
+			return mermaid.FormatOutput(graph.Diagram().String()), nil
+		}
+
+		var graphErr *mermaid.GraphError
+		if !errors.As(err, &graphErr) || attempt > maxRetries {
+			return "", fmt.Errorf("graph for %s: %w", scope, err)
+		}
+		fmt.Fprintf(s.log, "Graph for %s doesn't match the schema, attempting to fix (%d/%d)...\n", scope, attempt, maxRetries)
+
+		fixPrompt, err := s.promptMgr.GetGraphFixPrompt(prompt.GraphFixPromptData{
+			Kind:       string(kind),
+			Schema:     schema,
+			Tool:       graphTool,
+			Graph:      response,
+			Violations: graphErr.Violations,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to create fix prompt: %w", err)
+		}
+		if response, err = s.generateGraph(fixCtx, fixPrompt); err != nil {
+			return "", fmt.Errorf("error fixing graph for %s: %w", scope, err)
+		}
+	}
+}
+
+// generateGraph returns the JSON graph answering a prompt, constrained to the graph schema with
+// tool use where the adapter supports it
+func (s *diagramService) generateGraph(ctx context.Context, promptText string) (string, error) {
+	return llm.GenerateStructured(ctx, s.llmAdapter, promptText, llm.Schema{
+		Name:        graphTool,
+		Description: "Renders a diagram described as a JSON graph into Mermaid",
+		Parameters:  mermaid.GraphSchema(),
+	})
+}
@@ -3,10 +3,13 @@ package service
 import (
 	"context"
 	"fmt"
+	"io"
+	llmadapter "mm-go-agent/internal/adapter/llm"
 	"mm-go-agent/pkg/llm"
 	"mm-go-agent/pkg/mermaid"
 	"os"
 	"strconv"
+	"strings"
 
 	"mm-go-agent/pkg/prompt"
 )
@@ -19,30 +22,60 @@ type ValidationService struct {
 	llmClient  llm.Client
 	promptMgr  *prompt.TemplateManager
 	maxRetries int
+	// log receives the progress and warnings of fixes
+	log io.Writer
 }
 
-// NewValidationService creates a new validation service with the given LLM client
-func NewValidationService(llmClient llm.Client) *ValidationService {
-	// Get max retries from environment variable or use default
-	maxRetries := MaxFixRetries
-	if envRetries := os.Getenv("MERMAID_FIX_RETRIES"); envRetries != "" {
-		if val, err := strconv.Atoi(envRetries); err == nil && val > 0 {
-			maxRetries = val
-		}
+// ValidationOption configures a ValidationService
+type ValidationOption func(*ValidationService)
+
+// WithValidationLog writes the progress and warnings of fixes to w instead of stderr
+func WithValidationLog(w io.Writer) ValidationOption {
+	return func(s *ValidationService) {
+		s.log = w
 	}
+}
 
-	// Create a prompt template manager
-	promptMgr, err := prompt.New()
-	if err != nil {
-		// Fall back to nil if templates can't be loaded
-		promptMgr = nil
+// WithValidationPrompts sets the prompt templates used to explain and fix diagrams
+func WithValidationPrompts(promptMgr *prompt.TemplateManager) ValidationOption {
+	return func(s *ValidationService) {
+		s.promptMgr = promptMgr
 	}
+}
 
-	return &ValidationService{
+// NewValidationService creates a new validation service with the given LLM client
+func NewValidationService(llmClient llm.Client, opts ...ValidationOption) *ValidationService {
+	s := &ValidationService{
 		llmClient:  llmClient,
-		promptMgr:  promptMgr,
-		maxRetries: maxRetries,
+		maxRetries: fixRetries(),
+		log:        os.Stderr,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	// Load the prompt templates unless they were given
+	if s.promptMgr == nil {
+		promptMgr, err := prompt.New()
+		if err != nil {
+			// Fall back to nil if templates can't be loaded
+			fmt.Fprintf(s.log, "Warning: %v, using the default prompts\n", err)
+			promptMgr = nil
+		}
+		s.promptMgr = promptMgr
 	}
+	return s
+}
+
+// fixRetries returns the number of fix retries from the MERMAID_FIX_RETRIES environment variable,
+// or MaxFixRetries
+func fixRetries() int {
+	if envRetries := os.Getenv("MERMAID_FIX_RETRIES"); envRetries != "" {
+		if val, err := strconv.Atoi(envRetries); err == nil && val > 0 {
+			return val
+		}
+	}
+	return MaxFixRetries
 }
 
 // ValidateMermaidDiagram validates a Mermaid diagram and returns the validation result
@@ -68,6 +101,24 @@ func (s *ValidationService) FixMermaidDiagramWithLLM(ctx context.Context, diagra
 	currentResult := validationResult
 	fixAttempts := 0
 
+	// Apply the deterministic fixes first, the LLM only gets what they can't fix
+	if fixedDiagram, applied := mermaid.AutoFix(diagram); len(applied) > 0 {
+		fmt.Fprintf(s.log, "Applied automatic fixes: %s\n", strings.Join(applied, ", "))
+		fixedResult := mermaid.ValidateSyntax(fixedDiagram)
+		if fixedResult.IsValid {
+			return fixedDiagram, nil
+		}
+		currentDiagram = fixedDiagram
+		currentResult = fixedResult
+	}
+
+	if s.llmClient == nil {
+		return currentDiagram, fmt.Errorf("no LLM configured to fix the remaining errors: %s", mermaid.FormatLinterOutput(currentResult))
+	}
+
+	// Fix calls are reported under the label of the diagram they fix
+	ctx = llmadapter.WithLabel(ctx, strings.TrimSpace(llmadapter.Label(ctx)+" fix"))
+
 	// Try to fix the diagram up to the maximum number of retries
 	for fixAttempts < s.maxRetries {
 		fixAttempts++
@@ -122,9 +173,10 @@ Only respond with the corrected Mermaid diagram code, without any explanations o
 `, retryInfo, validationContext, currentDiagram)
 		}
 
+		// Transient errors were already retried by the adapter, the automatic fixes are kept
 		response, err := s.llmClient.GenerateText(ctx, prompt)
 		if err != nil {
-			return "", fmt.Errorf("error generating fixed diagram (attempt %d): %w", fixAttempts, err)
+			return currentDiagram, fmt.Errorf("error generating fixed diagram (attempt %d): %w", fixAttempts, err)
 		}
 
 		// Re-validate the fixed diagram
@@ -199,7 +251,7 @@ Use a friendly, educational tone as if you're teaching someone about Mermaid syn
 `, validationContext, validationResult.Diagram)
 	}
 
-	response, err := s.llmClient.GenerateText(ctx, prompt)
+	response, err := s.llmClient.GenerateText(llmadapter.WithLabel(ctx, "explanation"), prompt)
 	if err != nil {
 		return "", fmt.Errorf("error generating error explanation: %w", err)
 	}
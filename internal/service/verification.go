@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"mm-go-agent/internal/adapter/llm"
+	"mm-go-agent/pkg/analysis"
+	"mm-go-agent/pkg/mermaid"
+)
+
+// WithVerification fact-checks LLM generated diagrams against the symbols of the source files.
+// When strict, a diagram showing entities that don't exist in the code is sent back to the
+// LLM with the findings for correction.
+func WithVerification(strict bool) Option {
+	return func(s *diagramService) {
+		s.verify = true
+		s.strictVerify = strict
+	}
+}
+
+// verifyDiagram prints the verification report of a generated diagram and returns the
+// diagram, corrected by the LLM in strict mode. Verification problems never fail generation.
+func (s *diagramService) verifyDiagram(ctx context.Context, files []string, diagram string) string {
+	if !s.verify {
+		return diagram
+	}
+
+	var sources []analysis.SourceFile
+	var codeContents []string
+	for _, file := range files {
+		if filepath.Ext(file) != ".go" {
+			continue
+		}
+		content, err := s.fileRepo.ReadGoFile(file)
+		if err != nil {
+			fmt.Fprintf(s.log, "Warning: Failed to read %s for verification: %v\n", file, err)
+			return diagram
+		}
+		sources = append(sources, analysis.SourceFile{Path: file, Content: content})
+		codeContents = append(codeContents, fmt.Sprintf("// File: %s\n%s", filepath.Base(file), content))
+	}
+
+	report, err := s.factCheck(sources, diagram)
+	if err != nil {
+		fmt.Fprintf(s.log, "Warning: Failed to verify diagram: %v\n", err)
+		return diagram
+	}
+	fmt.Fprintln(s.log, report.String())
+
+	if !s.strictVerify || report.OK() || !report.Supported {
+		return diagram
+	}
+
+	fmt.Fprintf(s.log, "Diagram shows %d entities not found in the code, asking for a correction...\n", len(report.Hallucinated))
+
+	allCode := strings.Join(codeContents, "\n\n")
+	promptText, err := s.promptMgr.GetCorrectionPrompt(diagram, report.String(), allCode)
+	if err != nil {
+		promptText = fmt.Sprintf("This Mermaid diagram was generated from Go code, but a check against the code found problems:\n%s\n\nDiagram:\n\n%s\n\nSource code:\n\n```go\n%s\n```\n\nCorrect the diagram so it only shows entities that exist in the code, using their exact names. Provide only the Mermaid diagram syntax without any explanation or markdown formatting.",
+			report.String(), diagram, allCode)
+	}
+
+	corrected, err := s.llmAdapter.GenerateCompletion(llm.WithLabel(ctx, "correction"), promptText)
+	if err != nil {
+		fmt.Fprintf(s.log, "Warning: Failed to correct diagram: %v\n", err)
+		return diagram
+	}
+
+	corrected = mermaid.FormatOutput(corrected)
+	if !mermaid.ValidateSyntax(corrected).IsValid {
+		fmt.Fprintln(s.log, "Warning: Corrected diagram has syntax errors, keeping the original")
+		return diagram
+	}
+
+	if correctedReport, err := s.factCheck(sources, corrected); err == nil {
+		fmt.Fprintln(s.log, correctedReport.String())
+	}
+	return corrected
+}
+
+// factCheck verifies a diagram against the symbols declared in the source files
+func (s *diagramService) factCheck(sources []analysis.SourceFile, diagram string) (*analysis.VerificationReport, error) {
+	symbols, err := analysis.CollectSymbols(sources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect symbols: %w", err)
+	}
+
+	parsed, err := mermaid.Parse(diagram)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse diagram: %w", err)
+	}
+
+	return analysis.Verify(parsed, symbols), nil
+}
@@ -0,0 +1,39 @@
+package usage
+
+import "strings"
+
+// Price is the price of a model in USD per million tokens
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// Prices are the list prices of the Claude models, keyed by model name prefix so that they
+// apply to every snapshot of a model
+var Prices = map[string]Price{
+	"claude-opus-4":     {Input: 15, Output: 75},
+	"claude-sonnet-4":   {Input: 3, Output: 15},
+	"claude-3-7-sonnet": {Input: 3, Output: 15},
+	"claude-3-5-sonnet": {Input: 3, Output: 15},
+	"claude-3-5-haiku":  {Input: 0.8, Output: 4},
+	"claude-3-opus":     {Input: 15, Output: 75},
+	"claude-3-sonnet":   {Input: 3, Output: 15},
+	"claude-3-haiku":    {Input: 0.25, Output: 1.25},
+}
+
+// PriceOf returns the price of the model with the longest matching prefix in Prices
+func PriceOf(model string) (Price, bool) {
+	var price Price
+	longest := 0
+	for prefix, p := range Prices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > longest {
+			price, longest = p, len(prefix)
+		}
+	}
+	return price, longest > 0
+}
+
+// Cost returns the cost of a call in USD
+func (p Price) Cost(u Usage) float64 {
+	return (float64(u.InputTokens)*p.Input + float64(u.OutputTokens)*p.Output) / 1e6
+}
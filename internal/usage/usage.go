@@ -0,0 +1,298 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"mm-go-agent/internal/adapter/llm"
+)
+
+// defaultLabel is the label of LLM calls made without one
+const defaultLabel = "diagram"
+
+var (
+	// ErrBudgetExceeded is returned for calls made after the cost reached the budget
+	ErrBudgetExceeded = errors.New("budget exceeded")
+	// ErrCallLimit is returned for calls made after the maximum number of calls
+	ErrCallLimit = errors.New("call limit reached")
+)
+
+// Usage is the token usage and estimated cost of LLM calls
+type Usage struct {
+	Calls        int     `json:"calls"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+	// Failed counts the calls that returned an error without reporting any usage, they are
+	// counted as calls but have no tokens or cost
+	Failed int `json:"failed,omitempty"`
+	// Estimated is set when some token counts were estimated from the text length, because the
+	// adapter didn't report them
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+func (u *Usage) add(other Usage) {
+	u.Calls += other.Calls
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CostUSD += other.CostUSD
+	u.Failed += other.Failed
+	u.Estimated = u.Estimated || other.Estimated
+}
+
+// LabelUsage is the usage of the LLM calls with a label, e.g. a component or the relationships pass
+type LabelUsage struct {
+	Label string `json:"label"`
+	Usage
+}
+
+// Report is the usage of all LLM calls of a run
+type Report struct {
+	// Model prices the calls that don't report the model that served them
+	Model string `json:"model"`
+	// Models are the models that served the calls, in the order of their first call
+	Models []string `json:"models,omitempty"`
+	// Priced is false when the price of a model that served calls is unknown, the costs of its
+	// calls are zero
+	Priced bool         `json:"priced"`
+	Total  Usage        `json:"total"`
+	Labels []LabelUsage `json:"labels,omitempty"`
+	// Limit is the budget or call limit that stopped the run
+	Limit string `json:"limit,omitempty"`
+}
+
+// String formats the report as a summary, one line per label
+func (r Report) String() string {
+	var b strings.Builder
+	models := r.Model
+	if len(r.Models) > 0 {
+		models = strings.Join(r.Models, ", ")
+	}
+	fmt.Fprintf(&b, "LLM usage (%s): %s", models, r.Total.Summary(r.Priced))
+	for _, l := range r.Labels {
+		fmt.Fprintf(&b, "\n  %s: %s", l.Label, l.Usage.Summary(r.Priced))
+	}
+	if r.Limit != "" {
+		fmt.Fprintf(&b, "\nStopped: %s", r.Limit)
+	}
+	return b.String()
+}
+
+// Summary formats the usage on one line, with the cost if the prices of the models are known
+func (u Usage) Summary(priced bool) string {
+	calls := "calls"
+	if u.Calls == 1 {
+		calls = "call"
+	}
+	line := fmt.Sprintf("%d %s, %d input and %d output tokens", u.Calls, calls, u.InputTokens, u.OutputTokens)
+	if u.Failed > 0 {
+		line = fmt.Sprintf("%d %s (%d failed), %d input and %d output tokens", u.Calls, calls, u.Failed, u.InputTokens, u.OutputTokens)
+	}
+	if u.Estimated {
+		line += " (estimated)"
+	}
+	if priced {
+		line += fmt.Sprintf(", $%.4f", u.CostUSD)
+	} else {
+		line += ", cost unknown"
+	}
+	return line
+}
+
+// Option configures a Meter
+type Option func(*Meter)
+
+// WithBudget stops LLM calls once their estimated cost reaches the budget in USD
+func WithBudget(usd float64) Option {
+	return func(m *Meter) {
+		m.budget = usd
+	}
+}
+
+// WithMaxCalls stops LLM calls after the given number of calls
+func WithMaxCalls(n int) Option {
+	return func(m *Meter) {
+		m.maxCalls = n
+	}
+}
+
+// Meter records the token usage of LLM calls and enforces the budget and call limits. Every call
+// is priced with the model that served it.
+type Meter struct {
+	model    string
+	priced   bool
+	budget   float64
+	maxCalls int
+
+	mu     sync.Mutex
+	total  Usage
+	labels []LabelUsage
+	models []string
+	// unpriced is set once a call of a model without a known price was recorded
+	unpriced bool
+	// inFlight counts the calls allowed but not recorded yet
+	inFlight int
+	// limitErr is the error of the first call refused by a limit
+	limitErr error
+}
+
+// NewMeter creates a meter pricing calls with the price of the model that served them, or of the
+// given model when the adapter doesn't report it
+func NewMeter(model string, opts ...Option) *Meter {
+	m := &Meter{model: model}
+	_, m.priced = PriceOf(model)
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Priced reports whether the price of the meter's model is known
+func (m *Meter) Priced() bool {
+	return m.priced
+}
+
+// Err returns the budget or call limit error that stopped LLM calls, if any
+func (m *Meter) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.limitErr
+}
+
+// Report returns the usage of the calls made so far
+func (m *Meter) Report() Report {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := Report{
+		Model:  m.model,
+		Models: append([]string(nil), m.models...),
+		Priced: !m.unpriced,
+		Total:  m.total,
+		Labels: append([]LabelUsage(nil), m.labels...),
+	}
+	if m.total.Calls == 0 {
+		r.Priced = m.priced
+	}
+	if m.limitErr != nil {
+		r.Limit = m.limitErr.Error()
+	}
+	return r
+}
+
+// Adapter returns an adapter recording the usage of the calls of adapter, calls past a limit
+// fail with ErrBudgetExceeded or ErrCallLimit
+func (m *Meter) Adapter(adapter llm.LLMAdapter) llm.LLMAdapter {
+	return &meterAdapter{meter: m, adapter: adapter}
+}
+
+// meterAdapter records the usage of the calls of an adapter
+type meterAdapter struct {
+	meter   *Meter
+	adapter llm.LLMAdapter
+}
+
+// GenerateCompletion implements llm.LLMAdapter
+func (a *meterAdapter) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	return a.meter.call(ctx, prompt, func(ctx context.Context) (string, error) {
+		return a.adapter.GenerateCompletion(ctx, prompt)
+	})
+}
+
+// GenerateCompletionStream implements llm.StreamingAdapter
+func (a *meterAdapter) GenerateCompletionStream(ctx context.Context, prompt string, onChunk func(chunk string)) (string, error) {
+	return a.meter.call(ctx, prompt, func(ctx context.Context) (string, error) {
+		return llm.GenerateStream(ctx, a.adapter, prompt, onChunk)
+	})
+}
+
+// GenerateStructured implements llm.StructuredAdapter
+func (a *meterAdapter) GenerateStructured(ctx context.Context, prompt string, schema llm.Schema) (string, error) {
+	return a.meter.call(ctx, prompt, func(ctx context.Context) (string, error) {
+		return llm.GenerateStructured(ctx, a.adapter, prompt, schema)
+	})
+}
+
+// call makes an LLM call if the limits allow it and records its usage. Tokens are estimated
+// from the prompt and completion when the adapter doesn't report them, failed calls without
+// reported usage are only counted.
+func (m *Meter) call(ctx context.Context, prompt string, generate func(ctx context.Context) (string, error)) (string, error) {
+	if err := m.reserve(); err != nil {
+		return "", err
+	}
+
+	var reported *llm.Usage
+	completion, err := generate(llm.WithUsageFunc(ctx, func(u llm.Usage) {
+		reported = &u
+	}))
+
+	// A failed call that reported nothing may never have reached a provider, so it isn't charged
+	if err != nil && (reported == nil || !reported.Counted()) {
+		m.record(llm.Label(ctx), "", true, Usage{Calls: 1, Failed: 1})
+		return completion, err
+	}
+
+	model := m.model
+	u := Usage{Calls: 1}
+	if reported != nil && reported.Model != "" {
+		model = reported.Model
+	}
+	if reported != nil && reported.Counted() {
+		u.InputTokens, u.OutputTokens = reported.InputTokens, reported.OutputTokens
+	} else {
+		u.InputTokens, u.OutputTokens = llm.EstimateTokens(len(prompt)), llm.EstimateTokens(len(completion))
+		u.Estimated = true
+	}
+	price, priced := PriceOf(model)
+	u.CostUSD = price.Cost(u)
+	m.record(llm.Label(ctx), model, priced, u)
+	return completion, err
+}
+
+// reserve checks the limits before a call
+func (m *Meter) reserve() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var err error
+	switch {
+	case m.maxCalls > 0 && m.total.Calls+m.inFlight >= m.maxCalls:
+		err = fmt.Errorf("%w: %d calls allowed", ErrCallLimit, m.maxCalls)
+	case m.budget > 0 && m.total.CostUSD >= m.budget:
+		err = fmt.Errorf("%w: $%.4f spent of $%.2f", ErrBudgetExceeded, m.total.CostUSD, m.budget)
+	}
+	if err != nil {
+		if m.limitErr == nil {
+			m.limitErr = err
+		}
+		return err
+	}
+	m.inFlight++
+	return nil
+}
+
+// record adds the usage of a finished call served by the model, empty when no model served it
+func (m *Meter) record(label, model string, priced bool, u Usage) {
+	if label == "" {
+		label = defaultLabel
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight--
+	m.total.add(u)
+	m.unpriced = m.unpriced || !priced
+	if model != "" && !slices.Contains(m.models, model) {
+		m.models = append(m.models, model)
+	}
+	for i := range m.labels {
+		if m.labels[i].Label == label {
+			m.labels[i].add(u)
+			return
+		}
+	}
+	m.labels = append(m.labels, LabelUsage{Label: label, Usage: u})
+}
@@ -0,0 +1,177 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"mm-go-agent/internal/adapter/llm"
+)
+
+// reportingLLM reports a fixed token usage for every call, like the Claude adapter
+type reportingLLM struct {
+	usage llm.Usage
+	calls int
+}
+
+func (l *reportingLLM) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	l.calls++
+	llm.ReportUsage(ctx, l.usage)
+	return "classDiagram", nil
+}
+
+// silentLLM doesn't report its usage
+type silentLLM struct{}
+
+func (silentLLM) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	return "flowchart TD\n  A --> B", nil
+}
+
+// failingLLM fails every call without reporting its usage, like a provider that is down
+type failingLLM struct{}
+
+func (failingLLM) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	return "", errors.New("connection refused")
+}
+
+// UsageTestSuite is a test suite for metering LLM calls
+type UsageTestSuite struct {
+	suite.Suite
+}
+
+// TestPrices checks that prices apply to every snapshot of a model
+func (s *UsageTestSuite) TestPrices() {
+	price, ok := PriceOf(llm.DefaultModel)
+	s.True(ok)
+	s.Equal(Price{Input: 3, Output: 15}, price)
+
+	price, ok = PriceOf("claude-3-5-haiku-latest")
+	s.True(ok)
+	s.InDelta(0.0048, price.Cost(Usage{InputTokens: 1000, OutputTokens: 1000}), 1e-9)
+
+	_, ok = PriceOf("llama3")
+	s.False(ok)
+}
+
+// TestReport checks the totals and the usage per label
+func (s *UsageTestSuite) TestReport() {
+	meter := NewMeter("claude-3-7-sonnet-20250219")
+	reporting := meter.Adapter(&reportingLLM{usage: llm.Usage{InputTokens: 10000, OutputTokens: 2000}})
+	ctx := llm.WithLabel(context.Background(), "service")
+
+	_, err := reporting.GenerateCompletion(ctx, "prompt")
+	s.Require().NoError(err)
+	_, err = reporting.GenerateCompletion(llm.WithLabel(ctx, "service fix"), "prompt")
+	s.Require().NoError(err)
+	_, err = meter.Adapter(silentLLM{}).GenerateCompletion(context.Background(), "a prompt of 24 characters")
+	s.Require().NoError(err)
+
+	report := meter.Report()
+	s.True(report.Priced)
+	s.Equal(3, report.Total.Calls)
+	s.Equal(20007, report.Total.InputTokens)
+	s.Equal(4006, report.Total.OutputTokens)
+	s.InDelta(0.120111, report.Total.CostUSD, 1e-6)
+	s.True(report.Total.Estimated)
+	s.Require().Len(report.Labels, 3)
+	s.Equal(LabelUsage{Label: "service", Usage: Usage{Calls: 1, InputTokens: 10000, OutputTokens: 2000, CostUSD: 0.06}}, report.Labels[0])
+	s.Equal("diagram", report.Labels[2].Label)
+	s.Contains(report.String(), "LLM usage (claude-3-7-sonnet-20250219): 3 calls, 20007 input and 4006 output tokens (estimated), $0.1201")
+	s.Contains(report.String(), "\n  service fix: 1 call, 10000 input and 2000 output tokens, $0.0600")
+	s.NoError(meter.Err())
+}
+
+// TestLimits checks that calls past the budget or the call limit are refused
+func (s *UsageTestSuite) TestLimits() {
+	inner := &reportingLLM{usage: llm.Usage{InputTokens: 100000, OutputTokens: 10000}}
+	meter := NewMeter(llm.DefaultModel, WithMaxCalls(2))
+	adapter := meter.Adapter(inner)
+	for i := 0; i < 2; i++ {
+		_, err := adapter.GenerateCompletion(context.Background(), "prompt")
+		s.Require().NoError(err)
+	}
+	_, err := adapter.GenerateCompletion(context.Background(), "prompt")
+	s.ErrorIs(err, ErrCallLimit)
+	s.ErrorIs(meter.Err(), ErrCallLimit)
+	s.Equal(2, inner.calls)
+	s.Equal("call limit reached: 2 calls allowed", meter.Report().Limit)
+
+	// The first call costs $0.45, over the budget
+	inner.calls = 0
+	meter = NewMeter(llm.DefaultModel, WithBudget(0.25))
+	adapter = meter.Adapter(inner)
+	_, err = adapter.GenerateCompletion(context.Background(), "prompt")
+	s.Require().NoError(err)
+	_, err = adapter.GenerateCompletion(context.Background(), "prompt")
+	s.ErrorIs(err, ErrBudgetExceeded)
+	s.EqualError(err, "budget exceeded: $0.4500 spent of $0.25")
+	s.Equal(1, inner.calls)
+	s.Equal(1, meter.Report().Total.Calls)
+}
+
+// TestFailedCalls checks that failed calls without reported usage are counted but not charged
+func (s *UsageTestSuite) TestFailedCalls() {
+	meter := NewMeter(llm.DefaultModel, WithBudget(0.0001))
+	adapter := meter.Adapter(failingLLM{})
+	for i := 0; i < 3; i++ {
+		_, err := adapter.GenerateCompletion(context.Background(), "a prompt long enough to cost something if it were charged")
+		s.EqualError(err, "connection refused")
+	}
+	s.NoError(meter.Err())
+
+	report := meter.Report()
+	s.Equal(Usage{Calls: 3, Failed: 3}, report.Total)
+	s.Empty(report.Models)
+	s.True(report.Priced)
+	s.Contains(report.String(), "3 calls (3 failed), 0 input and 0 output tokens, $0.0000")
+
+	// The budget is still available to a call that succeeds
+	_, err := meter.Adapter(silentLLM{}).GenerateCompletion(context.Background(), "prompt")
+	s.Require().NoError(err)
+	s.Equal(4, meter.Report().Total.Calls)
+	s.Equal([]string{llm.DefaultModel}, meter.Report().Models)
+}
+
+// TestModelPricing checks that every call is priced with the model that served it, as after a
+// failover between providers
+func (s *UsageTestSuite) TestModelPricing() {
+	claude := &reportingLLM{usage: llm.Usage{Model: "claude-3-5-haiku-latest", InputTokens: 100000, OutputTokens: 10000}}
+	ollama := &reportingLLM{usage: llm.Usage{Model: "llama3.1", InputTokens: 100000, OutputTokens: 10000}}
+
+	// Ollama calls aren't charged at the price of the first provider
+	meter := NewMeter(llm.DefaultModel)
+	_, err := meter.Adapter(claude).GenerateCompletion(context.Background(), "prompt")
+	s.Require().NoError(err)
+	_, err = meter.Adapter(ollama).GenerateCompletion(context.Background(), "prompt")
+	s.Require().NoError(err)
+	report := meter.Report()
+	s.InDelta(0.12, report.Total.CostUSD, 1e-9)
+	s.Equal([]string{"claude-3-5-haiku-latest", "llama3.1"}, report.Models)
+	s.False(report.Priced)
+	s.Contains(report.String(), "LLM usage (claude-3-5-haiku-latest, llama3.1): 2 calls")
+
+	// The budget is enforced with Claude behind a provider without a price
+	meter = NewMeter("llama3.1", WithBudget(0.1))
+	s.False(meter.Priced())
+	_, err = meter.Adapter(ollama).GenerateCompletion(context.Background(), "prompt")
+	s.Require().NoError(err)
+	_, err = meter.Adapter(claude).GenerateCompletion(context.Background(), "prompt")
+	s.Require().NoError(err)
+	_, err = meter.Adapter(claude).GenerateCompletion(context.Background(), "prompt")
+	s.ErrorIs(err, ErrBudgetExceeded)
+
+	// A call reporting its model without token counts is estimated and priced with that model
+	meter = NewMeter(llm.DefaultModel)
+	_, err = meter.Adapter(&reportingLLM{usage: llm.Usage{Model: "llama3.1"}}).GenerateCompletion(context.Background(), "prompt")
+	s.Require().NoError(err)
+	report = meter.Report()
+	s.True(report.Total.Estimated)
+	s.Zero(report.Total.CostUSD)
+}
+
+// TestUsageTestSuite runs the usage test suite
+func TestUsageTestSuite(t *testing.T) {
+	suite.Run(t, new(UsageTestSuite))
+}
@@ -0,0 +1,750 @@
+package analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// SourceFile is a Go source file and its content
+type SourceFile struct {
+	Path    string
+	Content string
+}
+
+// OpKind is the kind of a concurrency operation
+type OpKind string
+
+const (
+	// OpSpawn starts a goroutine
+	OpSpawn OpKind = "go"
+	// OpSend sends a value on a channel
+	OpSend OpKind = "send"
+	// OpReceive receives a value from a channel
+	OpReceive OpKind = "receive"
+	// OpAcquire acquires a channel-based semaphore slot
+	OpAcquire OpKind = "acquire"
+	// OpRelease releases a channel-based semaphore slot
+	OpRelease OpKind = "release"
+	// OpAdd increments a sync.WaitGroup
+	OpAdd OpKind = "Add"
+	// OpDone decrements a sync.WaitGroup
+	OpDone OpKind = "Done"
+	// OpWait waits on a sync.WaitGroup
+	OpWait OpKind = "Wait"
+	// OpClose closes a channel
+	OpClose OpKind = "close"
+)
+
+// Actor is a function or goroutine that takes part in concurrent communication
+type Actor struct {
+	ID        string
+	Name      string
+	Goroutine bool
+	File      string
+	Line      int
+	Selects   int
+}
+
+// Channel is a channel created with make or declared as a parameter/field
+type Channel struct {
+	ID        string
+	Name      string
+	ElemType  string
+	Buffer    string
+	Semaphore bool
+	File      string
+	Line      int
+}
+
+// ConcurrencyOp is a single operation performed by an actor on a channel, wait group or goroutine
+type ConcurrencyOp struct {
+	Actor    string
+	Target   string
+	Kind     OpKind
+	InSelect bool
+	Line     int
+}
+
+// ConcurrencyReport describes the goroutines, channels and synchronization found in Go code
+type ConcurrencyReport struct {
+	Actors     []Actor
+	Channels   []Channel
+	WaitGroups []string
+	Ops        []ConcurrencyOp
+}
+
+// AnalyzeConcurrency statically finds go statements, channel creation, sends, receives,
+// sync.WaitGroup usage, channel semaphores and select blocks in the given files
+func AnalyzeConcurrency(files []SourceFile) (*ConcurrencyReport, error) {
+	report := &ConcurrencyReport{}
+	fset := token.NewFileSet()
+
+	// Parse every file first so that goroutines can be linked to functions declared anywhere
+	var parsed []*ast.File
+	funcs := make(map[string]funcDecl)
+	var names []string
+	for _, file := range files {
+		f, err := parser.ParseFile(fset, file.Path, file.Content, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file.Path, err)
+		}
+		parsed = append(parsed, f)
+		for _, decl := range f.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil {
+				name := funcName(fn)
+				if _, ok := funcs[name]; !ok {
+					names = append(names, name)
+				}
+				funcs[name] = funcDecl{file: file.Path, pos: fn.Pos(), params: paramNames(fn.Type)}
+			}
+		}
+	}
+
+	bindings := make(map[string]string)
+	for i, f := range parsed {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			w := &concurrencyWalker{
+				report:   report,
+				fset:     fset,
+				file:     files[i].Path,
+				scope:    funcName(fn),
+				funcs:    funcs,
+				names:    names,
+				bindings: bindings,
+				channels: make(map[string]string),
+				groups:   make(map[string]string),
+			}
+			w.walkFunc(fn)
+		}
+	}
+
+	report.bindParams(bindings)
+	report.markSemaphores()
+	report.dropUnusedActors()
+	return report, nil
+}
+
+// Empty reports whether no concurrency constructs were found
+func (r *ConcurrencyReport) Empty() bool {
+	return len(r.Channels) == 0 && len(r.WaitGroups) == 0 && len(r.Ops) == 0
+}
+
+// funcDecl is a function declared in the analyzed files
+type funcDecl struct {
+	file   string
+	pos    token.Pos
+	params []string
+}
+
+// concurrencyWalker walks a single top-level function and records what it finds.
+// Channels and wait groups are resolved by name within the function, fields by selector name.
+type concurrencyWalker struct {
+	report     *ConcurrencyReport
+	fset       *token.FileSet
+	file       string
+	scope      string
+	goroutines int
+	funcs      map[string]funcDecl // function ID -> declaration
+	names      []string            // function IDs in declaration order
+	bindings   map[string]string   // callee parameter ID -> channel or wait group ID passed to it
+	channels   map[string]string   // local name -> channel ID
+	groups     map[string]string   // local name -> wait group ID
+}
+
+func (w *concurrencyWalker) line(pos token.Pos) int {
+	return w.fset.Position(pos).Line
+}
+
+func (w *concurrencyWalker) walkFunc(fn *ast.FuncDecl) {
+	actor := w.addActor(w.scope, w.scope, false, w.file, fn.Pos())
+
+	// Channel and wait group parameters
+	for _, field := range fn.Type.Params.List {
+		w.declare(field.Type, field.Names, fn.Pos())
+	}
+
+	w.walk(fn.Body, actor, false)
+}
+
+// addActor records an actor once; a function that is also started with go is marked as a goroutine
+// whichever is seen first
+func (w *concurrencyWalker) addActor(id, name string, goroutine bool, file string, pos token.Pos) string {
+	for i, a := range w.report.Actors {
+		if a.ID == id {
+			if goroutine {
+				w.report.Actors[i].Goroutine = true
+			}
+			return id
+		}
+	}
+	w.report.Actors = append(w.report.Actors, Actor{
+		ID:        id,
+		Name:      name,
+		Goroutine: goroutine,
+		File:      file,
+		Line:      w.line(pos),
+	})
+	return id
+}
+
+func (w *concurrencyWalker) addOp(actor, target string, kind OpKind, inSelect bool, pos token.Pos) {
+	w.report.Ops = append(w.report.Ops, ConcurrencyOp{
+		Actor:    actor,
+		Target:   target,
+		Kind:     kind,
+		InSelect: inSelect,
+		Line:     w.line(pos),
+	})
+}
+
+// declare records channel and wait group variables declared with an explicit type
+func (w *concurrencyWalker) declare(typ ast.Expr, names []*ast.Ident, pos token.Pos) {
+	if typ == nil {
+		return
+	}
+	for _, name := range names {
+		switch t := typ.(type) {
+		case *ast.ChanType:
+			w.channels[name.Name] = w.addChannel(name.Name, exprString(t.Value), "", pos)
+		case *ast.SelectorExpr, *ast.StarExpr:
+			if isWaitGroup(t) {
+				w.groups[name.Name] = w.addWaitGroup(name.Name)
+			}
+		}
+	}
+}
+
+func (w *concurrencyWalker) addChannel(name, elem, buffer string, pos token.Pos) string {
+	id := fmt.Sprintf("%s.%s", w.scope, name)
+	for _, c := range w.report.Channels {
+		if c.ID == id {
+			return id
+		}
+	}
+	w.report.Channels = append(w.report.Channels, Channel{
+		ID:       id,
+		Name:     name,
+		ElemType: elem,
+		Buffer:   buffer,
+		File:     w.file,
+		Line:     w.line(pos),
+	})
+	return id
+}
+
+func (w *concurrencyWalker) addWaitGroup(name string) string {
+	id := fmt.Sprintf("%s.%s", w.scope, name)
+	for _, g := range w.report.WaitGroups {
+		if g == id {
+			return id
+		}
+	}
+	w.report.WaitGroups = append(w.report.WaitGroups, id)
+	return id
+}
+
+// channelID resolves a channel expression, registering struct fields on first use
+func (w *concurrencyWalker) channelID(expr ast.Expr, pos token.Pos) string {
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		if id, ok := w.channels[e.Name]; ok {
+			return id
+		}
+		// Channel captured from an outer declaration we didn't see (e.g. package level)
+		id := w.addChannel(e.Name, "", "", pos)
+		w.channels[e.Name] = id
+		return id
+	case *ast.SelectorExpr:
+		id := "field." + e.Sel.Name
+		for _, c := range w.report.Channels {
+			if c.ID == id {
+				return id
+			}
+		}
+		w.report.Channels = append(w.report.Channels, Channel{
+			ID:   id,
+			Name: exprString(e),
+			File: w.file,
+			Line: w.line(pos),
+		})
+		return id
+	}
+	return ""
+}
+
+func (w *concurrencyWalker) walk(node ast.Node, actor string, inSelect bool) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch stmt := n.(type) {
+		case *ast.GoStmt:
+			w.walkGo(stmt, actor)
+			return false
+
+		case *ast.FuncLit:
+			// Closures that are not started with go run on the current actor
+			w.walk(stmt.Body, actor, inSelect)
+			return false
+
+		case *ast.SelectStmt:
+			w.markSelect(actor)
+			for _, clause := range stmt.Body.List {
+				comm := clause.(*ast.CommClause)
+				if comm.Comm != nil {
+					w.walk(comm.Comm, actor, true)
+				}
+				for _, body := range comm.Body {
+					w.walk(body, actor, false)
+				}
+			}
+			return false
+
+		case *ast.AssignStmt:
+			for i, rhs := range stmt.Rhs {
+				if i >= len(stmt.Lhs) {
+					break
+				}
+				ident, ok := stmt.Lhs[i].(*ast.Ident)
+				if !ok {
+					continue
+				}
+				if elem, buffer, ok := makeChan(rhs); ok {
+					w.channels[ident.Name] = w.addChannel(ident.Name, elem, buffer, stmt.Pos())
+				}
+				if isWaitGroupLiteral(rhs) {
+					w.groups[ident.Name] = w.addWaitGroup(ident.Name)
+				}
+			}
+
+		case *ast.ValueSpec:
+			w.declare(stmt.Type, stmt.Names, stmt.Pos())
+			for i, value := range stmt.Values {
+				if i >= len(stmt.Names) {
+					break
+				}
+				if elem, buffer, ok := makeChan(value); ok {
+					name := stmt.Names[i].Name
+					w.channels[name] = w.addChannel(name, elem, buffer, stmt.Pos())
+				}
+			}
+
+		case *ast.SendStmt:
+			if id := w.channelID(stmt.Chan, stmt.Pos()); id != "" {
+				w.addOp(actor, id, OpSend, inSelect, stmt.Pos())
+			}
+
+		case *ast.UnaryExpr:
+			if stmt.Op == token.ARROW {
+				if id := w.channelID(stmt.X, stmt.Pos()); id != "" {
+					w.addOp(actor, id, OpReceive, inSelect, stmt.Pos())
+				}
+			}
+
+		case *ast.RangeStmt:
+			if ident, ok := stmt.X.(*ast.Ident); ok {
+				if id, ok := w.channels[ident.Name]; ok {
+					w.addOp(actor, id, OpReceive, false, stmt.Pos())
+				}
+			}
+
+		case *ast.CallExpr:
+			w.walkCall(stmt, actor)
+		}
+		return true
+	})
+}
+
+// walkGo records a goroutine spawn and the operations performed inside the goroutine
+func (w *concurrencyWalker) walkGo(stmt *ast.GoStmt, parent string) {
+	if lit, ok := stmt.Call.Fun.(*ast.FuncLit); ok {
+		w.goroutines++
+		id := fmt.Sprintf("%s.go%d", w.scope, w.goroutines)
+		name := fmt.Sprintf("goroutine #%d in %s", w.goroutines, w.scope)
+		w.addActor(id, name, true, w.file, stmt.Pos())
+		w.addOp(parent, id, OpSpawn, false, stmt.Pos())
+
+		// Channels passed as arguments are bound to the literal's parameter names
+		for i, param := range paramNames(lit.Type) {
+			if i < len(stmt.Call.Args) {
+				if arg, ok := stmt.Call.Args[i].(*ast.Ident); ok {
+					if chID, ok := w.channels[arg.Name]; ok {
+						w.channels[param] = chID
+					}
+				}
+			}
+		}
+		for _, arg := range stmt.Call.Args {
+			w.walk(arg, parent, false)
+		}
+		w.walk(lit.Body, id, false)
+		return
+	}
+
+	// go namedFunc(...) - the callee is analyzed as its own function
+	callee := w.resolveCallee(stmt.Call.Fun)
+	if callee == "" {
+		return
+	}
+	decl, declared := w.funcs[callee]
+	if declared {
+		w.addActor(callee, callee, true, decl.file, decl.pos)
+	} else {
+		w.addActor(callee, callee, true, w.file, stmt.Pos())
+	}
+	w.addOp(parent, callee, OpSpawn, false, stmt.Pos())
+
+	// Channels and wait groups passed as arguments are bound to the callee's parameters
+	for i, arg := range stmt.Call.Args {
+		if i < len(decl.params) {
+			if id := w.argTarget(arg); id != "" {
+				key := callee + "." + decl.params[i]
+				if _, ok := w.bindings[key]; !ok {
+					w.bindings[key] = id
+				}
+			}
+		}
+		w.walk(arg, parent, false)
+	}
+}
+
+// resolveCallee returns the ID of the function started by go: a declared Receiver.method for
+// s.method, else the called name
+func (w *concurrencyWalker) resolveCallee(fun ast.Expr) string {
+	name := calleeName(fun)
+	if name == "" {
+		return ""
+	}
+	if _, ok := fun.(*ast.SelectorExpr); ok {
+		for _, id := range w.names {
+			if strings.HasSuffix(id, "."+name) {
+				return id
+			}
+		}
+	}
+	return name
+}
+
+// argTarget returns the channel or wait group ID of a call argument such as ch or &wg
+func (w *concurrencyWalker) argTarget(arg ast.Expr) string {
+	if unary, ok := arg.(*ast.UnaryExpr); ok && unary.Op == token.AND {
+		arg = unary.X
+	}
+	ident, ok := ast.Unparen(arg).(*ast.Ident)
+	if !ok {
+		return ""
+	}
+	if id, ok := w.channels[ident.Name]; ok {
+		return id
+	}
+	return w.groups[ident.Name]
+}
+
+// walkCall records sync.WaitGroup operations and close calls
+func (w *concurrencyWalker) walkCall(call *ast.CallExpr, actor string) {
+	if ident, ok := call.Fun.(*ast.Ident); ok && ident.Name == "close" && len(call.Args) == 1 {
+		if id := w.channelID(call.Args[0], call.Pos()); id != "" {
+			w.addOp(actor, id, OpClose, false, call.Pos())
+		}
+		return
+	}
+
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return
+	}
+	var group string
+	switch x := sel.X.(type) {
+	case *ast.Ident:
+		group = w.groups[x.Name]
+	case *ast.SelectorExpr:
+		if strings.Contains(strings.ToLower(x.Sel.Name), "wg") || strings.Contains(strings.ToLower(x.Sel.Name), "waitgroup") {
+			group = "field." + x.Sel.Name
+			w.report.WaitGroups = appendUnique(w.report.WaitGroups, group)
+		}
+	}
+	if group == "" {
+		return
+	}
+	switch sel.Sel.Name {
+	case "Add":
+		w.addOp(actor, group, OpAdd, false, call.Pos())
+	case "Done":
+		w.addOp(actor, group, OpDone, false, call.Pos())
+	case "Wait":
+		w.addOp(actor, group, OpWait, false, call.Pos())
+	}
+}
+
+func (w *concurrencyWalker) markSelect(actor string) {
+	for i := range w.report.Actors {
+		if w.report.Actors[i].ID == actor {
+			w.report.Actors[i].Selects++
+		}
+	}
+}
+
+// markSemaphores flags buffered chan struct{} channels that the same actor both sends to and
+// receives from, and rewrites their operations as acquire/release
+func (r *ConcurrencyReport) markSemaphores() {
+	for i, ch := range r.Channels {
+		if ch.ElemType != "struct{}" || ch.Buffer == "" {
+			continue
+		}
+		sends := make(map[string]bool)
+		receives := make(map[string]bool)
+		for _, op := range r.Ops {
+			if op.Target != ch.ID {
+				continue
+			}
+			switch op.Kind {
+			case OpSend:
+				sends[op.Actor] = true
+			case OpReceive:
+				receives[op.Actor] = true
+			}
+		}
+		for actor := range sends {
+			if receives[actor] {
+				r.Channels[i].Semaphore = true
+				break
+			}
+		}
+		if !r.Channels[i].Semaphore {
+			continue
+		}
+		for j, op := range r.Ops {
+			if op.Target != ch.ID {
+				continue
+			}
+			switch op.Kind {
+			case OpSend:
+				r.Ops[j].Kind = OpAcquire
+			case OpReceive:
+				r.Ops[j].Kind = OpRelease
+			}
+		}
+	}
+}
+
+// bindParams replaces the channel and wait group parameters of spawned functions with what the
+// spawning function passed to them, so both sides of the communication share one node
+func (r *ConcurrencyReport) bindParams(bindings map[string]string) {
+	if len(bindings) == 0 {
+		return
+	}
+	// A parameter may be passed on to another goroutine, so follow the chain to its origin
+	resolve := func(id string) string {
+		for range len(bindings) {
+			next, ok := bindings[id]
+			if !ok {
+				break
+			}
+			id = next
+		}
+		return id
+	}
+	for i, op := range r.Ops {
+		r.Ops[i].Target = resolve(op.Target)
+	}
+	channels := r.Channels[:0]
+	for _, ch := range r.Channels {
+		if _, ok := bindings[ch.ID]; !ok {
+			channels = append(channels, ch)
+		}
+	}
+	r.Channels = channels
+	groups := r.WaitGroups[:0]
+	for _, g := range r.WaitGroups {
+		if _, ok := bindings[g]; !ok {
+			groups = append(groups, g)
+		}
+	}
+	r.WaitGroups = groups
+}
+
+// dropUnusedActors removes plain functions that take part in no concurrency operation
+func (r *ConcurrencyReport) dropUnusedActors() {
+	used := make(map[string]bool)
+	for _, op := range r.Ops {
+		used[op.Actor] = true
+		used[op.Target] = true
+	}
+	actors := r.Actors[:0]
+	for _, a := range r.Actors {
+		if a.Goroutine || used[a.ID] || a.Selects > 0 {
+			actors = append(actors, a)
+		}
+	}
+	r.Actors = actors
+}
+
+// FlowchartDiagram renders the report as a Mermaid flowchart showing which goroutines
+// communicate over which channels
+func (r *ConcurrencyReport) FlowchartDiagram() string {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+
+	for _, a := range r.Actors {
+		label := a.Name
+		if a.Selects > 0 {
+			label = fmt.Sprintf("%s (select x%d)", label, a.Selects)
+		}
+		if a.Goroutine {
+			b.WriteString(fmt.Sprintf("  %s([\"%s\"])\n", nodeID(a.ID), escapeLabel(label)))
+		} else {
+			b.WriteString(fmt.Sprintf("  %s[\"%s\"]\n", nodeID(a.ID), escapeLabel(label)))
+		}
+	}
+
+	for _, ch := range r.Channels {
+		label := ch.Name
+		if ch.ElemType != "" {
+			label = fmt.Sprintf("%s chan %s", label, ch.ElemType)
+		}
+		if ch.Buffer != "" {
+			label = fmt.Sprintf("%s, buffer %s", label, ch.Buffer)
+		}
+		if ch.Semaphore {
+			b.WriteString(fmt.Sprintf("  %s{{\"semaphore %s\"}}\n", nodeID(ch.ID), escapeLabel(label)))
+		} else {
+			b.WriteString(fmt.Sprintf("  %s[/\"%s\"/]\n", nodeID(ch.ID), escapeLabel(label)))
+		}
+	}
+
+	for _, wg := range r.WaitGroups {
+		name := wg[strings.LastIndex(wg, ".")+1:]
+		b.WriteString(fmt.Sprintf("  %s[(\"%s sync.WaitGroup\")]\n", nodeID(wg), escapeLabel(name)))
+	}
+
+	seen := make(map[string]bool)
+	for _, op := range r.Ops {
+		var edge string
+		label := string(op.Kind)
+		if op.InSelect {
+			label += " in select"
+		}
+		switch op.Kind {
+		case OpSpawn:
+			edge = fmt.Sprintf("  %s -.->|%s| %s\n", nodeID(op.Actor), label, nodeID(op.Target))
+		case OpReceive, OpRelease:
+			edge = fmt.Sprintf("  %s -->|%s| %s\n", nodeID(op.Target), label, nodeID(op.Actor))
+		default:
+			edge = fmt.Sprintf("  %s -->|%s| %s\n", nodeID(op.Actor), label, nodeID(op.Target))
+		}
+		if !seen[edge] {
+			seen[edge] = true
+			b.WriteString(edge)
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// makeChan reports whether expr is make(chan T[, n]) and returns the element type and buffer size
+func makeChan(expr ast.Expr) (string, string, bool) {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return "", "", false
+	}
+	if ident, ok := call.Fun.(*ast.Ident); !ok || ident.Name != "make" {
+		return "", "", false
+	}
+	chanType, ok := call.Args[0].(*ast.ChanType)
+	if !ok {
+		return "", "", false
+	}
+	buffer := ""
+	if len(call.Args) > 1 {
+		buffer = exprString(call.Args[1])
+	}
+	return exprString(chanType.Value), buffer, true
+}
+
+func isWaitGroup(expr ast.Expr) bool {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	sel, ok := expr.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == "WaitGroup"
+}
+
+func isWaitGroupLiteral(expr ast.Expr) bool {
+	if unary, ok := expr.(*ast.UnaryExpr); ok && unary.Op == token.AND {
+		expr = unary.X
+	}
+	if lit, ok := expr.(*ast.CompositeLit); ok {
+		return isWaitGroup(lit.Type)
+	}
+	if call, ok := expr.(*ast.CallExpr); ok {
+		if ident, ok := call.Fun.(*ast.Ident); ok && ident.Name == "new" && len(call.Args) == 1 {
+			return isWaitGroup(call.Args[0])
+		}
+	}
+	return false
+}
+
+func paramNames(fn *ast.FuncType) []string {
+	var names []string
+	for _, field := range fn.Params.List {
+		if len(field.Names) == 0 {
+			names = append(names, "_")
+		}
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+	}
+	return names
+}
+
+// funcName returns Receiver.Method for methods or the function name otherwise
+func funcName(fn *ast.FuncDecl) string {
+	if fn.Recv != nil && len(fn.Recv.List) > 0 {
+		recv := fn.Recv.List[0].Type
+		if star, ok := recv.(*ast.StarExpr); ok {
+			recv = star.X
+		}
+		if ident, ok := recv.(*ast.Ident); ok {
+			return ident.Name + "." + fn.Name.Name
+		}
+	}
+	return fn.Name.Name
+}
+
+// calleeName returns the name of a called function such as f, pkg.F or s.method
+func calleeName(fun ast.Expr) string {
+	switch f := fun.(type) {
+	case *ast.Ident:
+		return f.Name
+	case *ast.SelectorExpr:
+		return f.Sel.Name
+	}
+	return ""
+}
+
+// nodeID converts an actor or channel ID into a valid Mermaid node identifier
+func nodeID(id string) string {
+	return strings.NewReplacer(".", "_", "#", "", " ", "_", "*", "").Replace(id)
+}
+
+func escapeLabel(label string) string {
+	return strings.ReplaceAll(label, "\"", "#quot;")
+}
+
+func exprString(expr ast.Expr) string {
+	return types.ExprString(expr)
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
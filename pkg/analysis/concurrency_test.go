@@ -0,0 +1,107 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ConcurrencyTestSuite is a test suite for the static concurrency analysis
+type ConcurrencyTestSuite struct {
+	suite.Suite
+}
+
+// loadFixture reads files of a testdata/concurrency package in the given order
+func (s *ConcurrencyTestSuite) loadFixture(pkg string, names ...string) []SourceFile {
+	var files []SourceFile
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join("testdata", "concurrency", pkg, name))
+		s.Require().NoError(err)
+		files = append(files, SourceFile{Path: name, Content: string(content)})
+	}
+	return files
+}
+
+// TestWorkerPool checks a pool whose worker is declared before the function that starts it and
+// receives its channels and wait group as arguments
+func (s *ConcurrencyTestSuite) TestWorkerPool() {
+	report, err := AnalyzeConcurrency(s.loadFixture("workers", "worker.go", "run.go"))
+	s.Require().NoError(err)
+
+	s.Equal([]Actor{
+		{ID: "worker", Name: "worker", Goroutine: true, File: "worker.go", Line: 6},
+		{ID: "Run", Name: "Run", File: "run.go", Line: 6},
+	}, report.Actors)
+
+	var channels []string
+	for _, ch := range report.Channels {
+		channels = append(channels, ch.ID)
+	}
+	s.Equal([]string{"Run.in", "Run.results"}, channels)
+	s.Equal([]string{"Run.wg"}, report.WaitGroups)
+
+	s.Contains(report.Ops, ConcurrencyOp{Actor: "worker", Target: "Run.in", Kind: OpReceive, Line: 8})
+	s.Contains(report.Ops, ConcurrencyOp{Actor: "worker", Target: "Run.results", Kind: OpSend, Line: 9})
+	s.Contains(report.Ops, ConcurrencyOp{Actor: "worker", Target: "Run.wg", Kind: OpDone, Line: 7})
+
+	golden, err := os.ReadFile(filepath.Join("testdata", "concurrency", "workers.golden.mmd"))
+	s.Require().NoError(err)
+	s.Equal(string(golden), report.FlowchartDiagram()+"\n")
+
+	// The worker is the same goroutine when its file comes last
+	report, err = AnalyzeConcurrency(s.loadFixture("workers", "run.go", "worker.go"))
+	s.Require().NoError(err)
+	s.ElementsMatch([]Actor{
+		{ID: "Run", Name: "Run", File: "run.go", Line: 6},
+		{ID: "worker", Name: "worker", Goroutine: true, File: "worker.go", Line: 6},
+	}, report.Actors)
+}
+
+// TestSpawnChain checks that channels passed on through several goroutines and to methods end up
+// on the channel that was made
+func (s *ConcurrencyTestSuite) TestSpawnChain() {
+	report, err := AnalyzeConcurrency([]SourceFile{{Path: "chain.go", Content: `package chain
+
+func (p *Pipeline) sink(in <-chan string) {
+	for range in {
+	}
+}
+
+func relay(in <-chan string, p *Pipeline) {
+	go p.sink(in)
+}
+
+func Start(p *Pipeline) {
+	lines := make(chan string)
+	go relay(lines, p)
+	lines <- "hello"
+}
+`}})
+	s.Require().NoError(err)
+
+	s.Require().Len(report.Channels, 1)
+	s.Equal("Start.lines", report.Channels[0].ID)
+	s.Contains(report.Ops, ConcurrencyOp{Actor: "Pipeline.sink", Target: "Start.lines", Kind: OpReceive, Line: 4})
+	s.Contains(report.Ops, ConcurrencyOp{Actor: "relay", Target: "Pipeline.sink", Kind: OpSpawn, Line: 9})
+
+	var goroutines []string
+	for _, a := range report.Actors {
+		if a.Goroutine {
+			goroutines = append(goroutines, a.ID)
+		}
+	}
+	s.Equal([]string{"Pipeline.sink", "relay"}, goroutines)
+}
+
+// TestParseError checks that files that don't parse are reported
+func (s *ConcurrencyTestSuite) TestParseError() {
+	_, err := AnalyzeConcurrency([]SourceFile{{Path: "broken.go", Content: "package broken\nfunc {"}})
+	s.ErrorContains(err, "failed to parse broken.go")
+}
+
+// TestConcurrencyTestSuite runs the concurrency test suite
+func TestConcurrencyTestSuite(t *testing.T) {
+	suite.Run(t, new(ConcurrencyTestSuite))
+}
@@ -0,0 +1,173 @@
+package analysis
+
+import (
+	"fmt"
+	"go/types"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Direction selects the relations followed from the focal type of a graph
+type Direction string
+
+const (
+	// DirectionOut follows relations to the types the focal type uses
+	DirectionOut Direction = "out"
+	// DirectionIn follows relations from the types using the focal type
+	DirectionIn Direction = "in"
+	// DirectionBoth follows relations either way
+	DirectionBoth Direction = "both"
+)
+
+// ParseDirection parses the name of a direction
+func ParseDirection(name string) (Direction, error) {
+	switch d := Direction(name); d {
+	case DirectionOut, DirectionIn, DirectionBoth:
+		return d, nil
+	}
+	return "", fmt.Errorf("invalid direction: %s (should be 'out', 'in' or 'both')", name)
+}
+
+// TypeGraph is the graph of the named types declared in a set of packages. A type has an edge
+// to every type it uses in fields, embedded types and method signatures and to the interfaces
+// it implements.
+type TypeGraph struct {
+	out map[*types.TypeName][]*types.TypeName
+	in  map[*types.TypeName][]*types.TypeName
+}
+
+// BuildTypeGraph builds the type graph of the packages, without their dependencies
+func BuildTypeGraph(pkgs []*packages.Package) *TypeGraph {
+	var named []*types.Named
+	declared := make(map[*types.TypeName]bool)
+	for _, pkg := range pkgs {
+		if pkg.Types == nil {
+			continue
+		}
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			obj, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || obj.IsAlias() {
+				continue
+			}
+			if n, ok := obj.Type().(*types.Named); ok {
+				named = append(named, n)
+				declared[obj] = true
+			}
+		}
+	}
+
+	g := &TypeGraph{out: make(map[*types.TypeName][]*types.TypeName), in: make(map[*types.TypeName][]*types.TypeName)}
+	for _, n := range named {
+		for _, ref := range referencedTypes(n) {
+			if declared[ref.Obj()] {
+				g.addEdge(n.Obj(), ref.Obj())
+			}
+		}
+	}
+
+	for _, iface := range named {
+		it, ok := iface.Underlying().(*types.Interface)
+		if !ok || it.NumMethods() == 0 {
+			continue
+		}
+		for _, n := range named {
+			if types.IsInterface(n) || n.TypeParams().Len() > 0 {
+				continue
+			}
+			if types.Implements(n, it) || types.Implements(types.NewPointer(n), it) {
+				g.addEdge(n.Obj(), iface.Obj())
+			}
+		}
+	}
+	return g
+}
+
+func (g *TypeGraph) addEdge(from, to *types.TypeName) {
+	for _, existing := range g.out[from] {
+		if existing == to {
+			return
+		}
+	}
+	g.out[from] = append(g.out[from], to)
+	g.in[to] = append(g.in[to], from)
+}
+
+// Neighborhood returns the types within depth hops of a type in the given direction,
+// starting with the type itself, in breadth-first order
+func (g *TypeGraph) Neighborhood(focus *types.TypeName, depth int, direction Direction) []*types.TypeName {
+	distance := map[*types.TypeName]int{focus: 0}
+	order := []*types.TypeName{focus}
+
+	for i := 0; i < len(order); i++ {
+		current := order[i]
+		if distance[current] == depth {
+			continue
+		}
+
+		var next []*types.TypeName
+		if direction != DirectionIn {
+			next = append(next, g.out[current]...)
+		}
+		if direction != DirectionOut {
+			next = append(next, g.in[current]...)
+		}
+		sort.Slice(next, func(a, b int) bool { return typeKey(next[a]) < typeKey(next[b]) })
+
+		for _, t := range next {
+			if _, ok := distance[t]; !ok {
+				distance[t] = distance[current] + 1
+				order = append(order, t)
+			}
+		}
+	}
+	return order
+}
+
+// FocusTarget resolves the types of the module below dir within depth hops of a type symbol,
+// e.g. service.diagramService, with the symbol as focus
+func FocusTarget(dir, symbol string, depth int, direction Direction) (*Target, error) {
+	if depth < 0 {
+		return nil, fmt.Errorf("invalid depth: %d", depth)
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgPart, name, ok := splitSymbol(symbol)
+	if !ok {
+		return nil, fmt.Errorf("invalid symbol: %s (should be 'package.Type')", symbol)
+	}
+
+	pkgs, err := LoadPackages(dir, "./...")
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := lookupPackage(pkgs, pkgPart)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := pkg.Types.Scope().Lookup(name).(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("type %s not found in package %s", name, pkg.PkgPath)
+	}
+
+	var selected []*types.Named
+	for _, t := range BuildTypeGraph(pkgs).Neighborhood(obj, depth, direction) {
+		if n, ok := t.Type().(*types.Named); ok {
+			selected = append(selected, n)
+		}
+	}
+
+	t := &Target{
+		Scope: fmt.Sprintf("the type %s.%s and the types within %d hops", pkg.Name, name, depth),
+		Focus: typeKey(obj),
+	}
+	if err := t.addTypes(absDir, pkg.Fset, selected); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
@@ -0,0 +1,287 @@
+package analysis
+
+import (
+	"fmt"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// TypeRef identifies a named type declared in the analyzed module
+type TypeRef struct {
+	Package string
+	Name    string
+}
+
+// String returns the package qualified name of the type
+func (t TypeRef) String() string {
+	return fmt.Sprintf("%s.%s", t.Package, t.Name)
+}
+
+// ShortString returns the type name qualified by the last element of its package path
+func (t TypeRef) ShortString() string {
+	return fmt.Sprintf("%s.%s", t.Package[strings.LastIndex(t.Package, "/")+1:], t.Name)
+}
+
+// Implementer is a concrete type that satisfies an interface
+type Implementer struct {
+	Type TypeRef
+	// Pointer is set when only the pointer type (*T) satisfies the interface
+	Pointer bool
+}
+
+// InterfaceInfo is an interface and every concrete type that satisfies it
+type InterfaceInfo struct {
+	Type         TypeRef
+	Methods      []string
+	Implementers []Implementer
+}
+
+// Bridge is a struct field holding an interface value, e.g. an adapter wrapping another interface
+type Bridge struct {
+	From      TypeRef
+	Field     string
+	Interface TypeRef
+}
+
+// ImplementsReport lists the interfaces of a module and their implementations
+type ImplementsReport struct {
+	Interfaces []InterfaceInfo
+	Bridges    []Bridge
+	// Methods of the implementing concrete types, keyed by TypeRef.String()
+	Methods map[string][]string
+}
+
+// FindImplementations lists every non-empty interface declared in the packages and
+// every concrete type from the packages that satisfies it according to types.Implements
+func FindImplementations(pkgs []*packages.Package) *ImplementsReport {
+	var ifaces []*types.TypeName
+	var concrete []*types.TypeName
+
+	for _, pkg := range pkgs {
+		if pkg.Types == nil {
+			continue
+		}
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			obj, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || obj.IsAlias() {
+				continue
+			}
+			named, ok := obj.Type().(*types.Named)
+			if !ok || named.TypeParams().Len() > 0 {
+				continue
+			}
+			if iface, ok := named.Underlying().(*types.Interface); ok {
+				if iface.NumMethods() > 0 {
+					ifaces = append(ifaces, obj)
+				}
+				continue
+			}
+			concrete = append(concrete, obj)
+		}
+	}
+
+	report := &ImplementsReport{Methods: make(map[string][]string)}
+	ifaceRefs := make(map[*types.TypeName]TypeRef)
+
+	for _, obj := range ifaces {
+		iface := obj.Type().Underlying().(*types.Interface)
+		info := InterfaceInfo{Type: typeRef(obj)}
+		ifaceRefs[obj] = info.Type
+
+		for i := 0; i < iface.NumMethods(); i++ {
+			info.Methods = append(info.Methods, methodSignature(iface.Method(i), obj.Pkg()))
+		}
+
+		for _, impl := range concrete {
+			switch {
+			case types.Implements(impl.Type(), iface):
+				info.Implementers = append(info.Implementers, Implementer{Type: typeRef(impl)})
+			case types.Implements(types.NewPointer(impl.Type()), iface):
+				info.Implementers = append(info.Implementers, Implementer{Type: typeRef(impl), Pointer: true})
+			default:
+				continue
+			}
+			report.addMethods(impl)
+		}
+
+		report.Interfaces = append(report.Interfaces, info)
+	}
+
+	// Struct fields holding one of the interfaces
+	for _, obj := range concrete {
+		st, ok := obj.Type().Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		for i := 0; i < st.NumFields(); i++ {
+			field := st.Field(i)
+			named, ok := field.Type().(*types.Named)
+			if !ok {
+				continue
+			}
+			if ref, ok := ifaceRefs[named.Obj()]; ok {
+				report.Bridges = append(report.Bridges, Bridge{From: typeRef(obj), Field: field.Name(), Interface: ref})
+				report.addMethods(obj)
+			}
+		}
+	}
+
+	sort.Slice(report.Interfaces, func(i, j int) bool {
+		return report.Interfaces[i].Type.String() < report.Interfaces[j].Type.String()
+	})
+	return report
+}
+
+func (r *ImplementsReport) addMethods(obj *types.TypeName) {
+	key := typeRef(obj).String()
+	if _, ok := r.Methods[key]; ok {
+		return
+	}
+	methods := []string{}
+	mset := types.NewMethodSet(types.NewPointer(obj.Type()))
+	for i := 0; i < mset.Len(); i++ {
+		fn, ok := mset.At(i).Obj().(*types.Func)
+		if ok && fn.Exported() {
+			methods = append(methods, methodSignature(fn, obj.Pkg()))
+		}
+	}
+	r.Methods[key] = methods
+}
+
+// ClassDiagram renders the report as a Mermaid class diagram with realization arrows
+func (r *ImplementsReport) ClassDiagram() string {
+	ids := classIDs(r)
+
+	var b strings.Builder
+	b.WriteString("classDiagram\n")
+
+	declared := make(map[string]bool)
+	for _, info := range r.Interfaces {
+		id := ids[info.Type.String()]
+		declared[id] = true
+		b.WriteString(fmt.Sprintf("  class %s {\n", id))
+		b.WriteString("    <<interface>>\n")
+		for _, m := range info.Methods {
+			b.WriteString(fmt.Sprintf("    +%s\n", m))
+		}
+		b.WriteString("  }\n")
+	}
+
+	keys := make([]string, 0, len(r.Methods))
+	for key := range r.Methods {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		id := ids[key]
+		if declared[id] {
+			continue
+		}
+		declared[id] = true
+		b.WriteString(fmt.Sprintf("  class %s {\n", id))
+		for _, m := range r.Methods[key] {
+			b.WriteString(fmt.Sprintf("    +%s\n", m))
+		}
+		b.WriteString("  }\n")
+	}
+
+	for _, info := range r.Interfaces {
+		for _, impl := range info.Implementers {
+			b.WriteString(fmt.Sprintf("  %s ..|> %s\n", ids[impl.Type.String()], ids[info.Type.String()]))
+		}
+	}
+	for _, bridge := range r.Bridges {
+		b.WriteString(fmt.Sprintf("  %s --> %s : %s\n", ids[bridge.From.String()], ids[bridge.Interface.String()], bridge.Field))
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// MarkdownTable renders the report as a Markdown table of interfaces and their implementations
+func (r *ImplementsReport) MarkdownTable() string {
+	var b strings.Builder
+	b.WriteString("| Interface | Package | Implementations |\n")
+	b.WriteString("|-----------|---------|-----------------|\n")
+
+	for _, info := range r.Interfaces {
+		var impls []string
+		for _, impl := range info.Implementers {
+			name := fmt.Sprintf("`%s`", impl.Type.ShortString())
+			if impl.Pointer {
+				name = fmt.Sprintf("`*%s`", impl.Type.ShortString())
+			}
+			impls = append(impls, name)
+		}
+		if len(impls) == 0 {
+			impls = []string{"_none_"}
+		}
+		b.WriteString(fmt.Sprintf("| `%s` | `%s` | %s |\n", info.Type.Name, info.Type.Package, strings.Join(impls, ", ")))
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// classIDs assigns each type a Mermaid class ID, qualifying names that are declared in several packages
+func classIDs(r *ImplementsReport) map[string]string {
+	refs := make(map[string]TypeRef)
+	for _, info := range r.Interfaces {
+		refs[info.Type.String()] = info.Type
+		for _, impl := range info.Implementers {
+			refs[impl.Type.String()] = impl.Type
+		}
+	}
+	for _, bridge := range r.Bridges {
+		refs[bridge.From.String()] = bridge.From
+	}
+
+	counts := make(map[string]int)
+	for _, ref := range refs {
+		counts[ref.Name]++
+	}
+
+	ids := make(map[string]string)
+	for key, ref := range refs {
+		if counts[ref.Name] > 1 {
+			ids[key] = strings.NewReplacer("/", "_", ".", "_", "-", "_").Replace(ref.String())
+		} else {
+			ids[key] = ref.Name
+		}
+	}
+	return ids
+}
+
+func typeRef(obj *types.TypeName) TypeRef {
+	return TypeRef{Package: obj.Pkg().Path(), Name: obj.Name()}
+}
+
+// methodSignature formats a method as Name(params) results, qualifying types from
+// other packages by package name
+func methodSignature(fn *types.Func, pkg *types.Package) string {
+	sig := fn.Type().(*types.Signature)
+	qualifier := func(other *types.Package) string {
+		if other == pkg {
+			return ""
+		}
+		return other.Name()
+	}
+
+	var params []string
+	for i := 0; i < sig.Params().Len(); i++ {
+		params = append(params, types.TypeString(sig.Params().At(i).Type(), qualifier))
+	}
+
+	var results []string
+	for i := 0; i < sig.Results().Len(); i++ {
+		results = append(results, types.TypeString(sig.Results().At(i).Type(), qualifier))
+	}
+
+	signature := fmt.Sprintf("%s(%s)", fn.Name(), strings.Join(params, ", "))
+	if len(results) > 0 {
+		signature += " " + strings.Join(results, ", ")
+	}
+	return signature
+}
@@ -0,0 +1,102 @@
+package analysis
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ImplementsTestSuite is a test suite for the interface implementation matrix of a fixture module
+type ImplementsTestSuite struct {
+	suite.Suite
+	report *ImplementsReport
+}
+
+// SetupSuite loads the packages of the fixture module once
+func (s *ImplementsTestSuite) SetupSuite() {
+	pkgs, err := LoadPackages(filepath.Join("testdata", "implements"))
+	s.Require().NoError(err)
+	s.Len(pkgs, 3)
+	s.report = FindImplementations(pkgs)
+}
+
+// TestFindImplementations checks the interfaces, value and pointer implementers and bridges
+func (s *ImplementsTestSuite) TestFindImplementations() {
+	store := TypeRef{Package: "example.com/shop/store", Name: "Store"}
+	cached := TypeRef{Package: "example.com/shop/cache", Name: "Cached"}
+
+	// Empty and generic interfaces are left out, the others are sorted by package
+	s.Equal([]InterfaceInfo{
+		{Type: TypeRef{Package: "example.com/shop/notify", Name: "Notifier"}, Methods: []string{"Notify(string) error"}},
+		{Type: TypeRef{Package: "example.com/shop/notify", Name: "Store"}, Methods: []string{"Close() error"}},
+		{Type: store, Methods: []string{"Find(string) Order, error", "Save(Order) error"}, Implementers: []Implementer{
+			{Type: TypeRef{Package: "example.com/shop/store", Name: "Memory"}},
+			{Type: cached, Pointer: true},
+		}},
+	}, s.report.Interfaces)
+
+	s.Equal([]Bridge{{From: cached, Field: "next", Interface: store}}, s.report.Bridges)
+	s.Equal(map[string][]string{
+		"example.com/shop/store.Memory": {"Find(string) Order, error", "Save(Order) error"},
+		"example.com/shop/cache.Cached": {"Find(string) store.Order, error", "Hits() int", "Save(store.Order) error"},
+	}, s.report.Methods)
+
+	s.Equal("example.com/shop/cache.Cached", cached.String())
+	s.Equal("cache.Cached", cached.ShortString())
+}
+
+// TestClassDiagram checks that names declared in several packages get qualified class IDs
+func (s *ImplementsTestSuite) TestClassDiagram() {
+	s.Equal(`classDiagram
+  class Notifier {
+    <<interface>>
+    +Notify(string) error
+  }
+  class example_com_shop_notify_Store {
+    <<interface>>
+    +Close() error
+  }
+  class example_com_shop_store_Store {
+    <<interface>>
+    +Find(string) Order, error
+    +Save(Order) error
+  }
+  class Cached {
+    +Find(string) store.Order, error
+    +Hits() int
+    +Save(store.Order) error
+  }
+  class Memory {
+    +Find(string) Order, error
+    +Save(Order) error
+  }
+  Memory ..|> example_com_shop_store_Store
+  Cached ..|> example_com_shop_store_Store
+  Cached --> example_com_shop_store_Store : next`, s.report.ClassDiagram())
+}
+
+// TestMarkdownTable checks the matrix with pointer implementers and interfaces without any
+func (s *ImplementsTestSuite) TestMarkdownTable() {
+	s.Equal("| Interface | Package | Implementations |\n"+
+		"|-----------|---------|-----------------|\n"+
+		"| `Notifier` | `example.com/shop/notify` | _none_ |\n"+
+		"| `Store` | `example.com/shop/notify` | _none_ |\n"+
+		"| `Store` | `example.com/shop/store` | `store.Memory`, `*cache.Cached` |", s.report.MarkdownTable())
+}
+
+// TestLoadPackages checks patterns and packages that can't be loaded
+func (s *ImplementsTestSuite) TestLoadPackages() {
+	pkgs, err := LoadPackages(filepath.Join("testdata", "implements"), "./cache")
+	s.Require().NoError(err)
+	s.Require().Len(pkgs, 1)
+	s.Equal("example.com/shop/cache", pkgs[0].PkgPath)
+
+	_, err = LoadPackages(filepath.Join("testdata", "implements"), "./missing")
+	s.Error(err)
+}
+
+// TestImplementsTestSuite runs the implements test suite
+func TestImplementsTestSuite(t *testing.T) {
+	suite.Run(t, new(ImplementsTestSuite))
+}
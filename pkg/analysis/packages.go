@@ -0,0 +1,52 @@
+package analysis
+
+import (
+	"fmt"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// loadMode is the information needed by the type based analyses
+const loadMode = packages.NeedName | packages.NeedFiles | packages.NeedSyntax |
+	packages.NeedTypes | packages.NeedTypesInfo | packages.NeedImports | packages.NeedDeps | packages.NeedModule
+
+// LoadPackages loads and type-checks the packages matching the patterns relative to dir
+func LoadPackages(dir string, patterns ...string) ([]*packages.Package, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	cfg := &packages.Config{
+		Mode: loadMode,
+		Dir:  dir,
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load packages: %w", err)
+	}
+
+	var errs []string
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, e := range pkg.Errors {
+			errs = append(errs, e.Error())
+		}
+	})
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no packages found for %v", patterns)
+	}
+	if len(errs) > 0 && allFailed(pkgs) {
+		return nil, fmt.Errorf("failed to type-check packages: %s", errs[0])
+	}
+
+	return pkgs, nil
+}
+
+// allFailed reports whether none of the packages could be type-checked
+func allFailed(pkgs []*packages.Package) bool {
+	for _, pkg := range pkgs {
+		if pkg.Types != nil && len(pkg.Errors) == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,505 @@
+package analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+
+	"mm-go-agent/pkg/mermaid"
+)
+
+// EdgeKind is the kind of a relationship between two types
+type EdgeKind string
+
+const (
+	// EdgeEmbeds is a struct or interface embedding another type
+	EdgeEmbeds EdgeKind = "embeds"
+	// EdgeField is a struct field holding another type
+	EdgeField EdgeKind = "field"
+	// EdgeImplements is a concrete type whose method set satisfies an interface
+	EdgeImplements EdgeKind = "implements"
+	// EdgeDepends is a method signature referring to another type
+	EdgeDepends EdgeKind = "depends"
+)
+
+// edgeArrows maps edge kinds to Mermaid class diagram arrows
+var edgeArrows = map[EdgeKind]string{
+	EdgeEmbeds:     "*--",
+	EdgeField:      "-->",
+	EdgeImplements: "..|>",
+	EdgeDepends:    "..>",
+}
+
+// SkeletonType is a named type declared in the analyzed files
+type SkeletonType struct {
+	ID        string
+	Name      string
+	Package   string
+	File      string
+	Line      int
+	Interface bool
+	TypeArgs  string
+	Fields    []SkeletonMember
+	Methods   []SkeletonMember
+}
+
+// SkeletonMember is a field or method of a type
+type SkeletonMember struct {
+	Name     string
+	Exported bool
+	// Signature is the field type or the method parameters and results
+	Signature string
+	// shape is the method signature without parameter names, used to match method sets
+	shape string
+}
+
+// SkeletonEdge is a relationship between two types of the skeleton
+type SkeletonEdge struct {
+	From string
+	To   string
+	Kind EdgeKind
+	// Via is the field or method the edge was derived from
+	Via string
+}
+
+// Skeleton is the authoritative set of types, members and relationships found in Go source
+type Skeleton struct {
+	Types []*SkeletonType
+	Edges []SkeletonEdge
+}
+
+// BuildSkeleton extracts the types, their fields and methods and the relationships between
+// them from the syntax of the given Go files. Implementations are found by comparing method sets.
+func BuildSkeleton(files []SourceFile) (*Skeleton, error) {
+	b := &skeletonBuilder{
+		fset:    token.NewFileSet(),
+		types:   make(map[string]*SkeletonType),
+		specs:   make(map[string]*ast.TypeSpec),
+		seen:    make(map[SkeletonEdge]bool),
+		partial: make(map[*SkeletonType]bool),
+	}
+
+	var parsed []*ast.File
+	for _, file := range files {
+		f, err := parser.ParseFile(b.fset, file.Path, file.Content, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file.Path, err)
+		}
+		parsed = append(parsed, f)
+		b.collectTypes(f, file.Path)
+	}
+
+	b.assignIDs()
+	for _, f := range parsed {
+		b.collectMethods(f)
+	}
+	keys := make([]string, 0, len(b.specs))
+	for key := range b.specs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		b.collectMembers(b.types[key], b.specs[key])
+	}
+	b.collectImplements()
+
+	skeleton := &Skeleton{Edges: b.edges}
+	for _, t := range b.types {
+		skeleton.Types = append(skeleton.Types, t)
+	}
+	sort.Slice(skeleton.Types, func(i, j int) bool {
+		if skeleton.Types[i].File != skeleton.Types[j].File {
+			return skeleton.Types[i].File < skeleton.Types[j].File
+		}
+		return skeleton.Types[i].Line < skeleton.Types[j].Line
+	})
+	return skeleton, nil
+}
+
+// Type returns the type with the given class ID or nil
+func (s *Skeleton) Type(id string) *SkeletonType {
+	for _, t := range s.Types {
+		if t.ID == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// Restrict returns the skeleton of the given types, as "package.Name" with the package name,
+// and the relationships between them
+func (s *Skeleton) Restrict(types []string) *Skeleton {
+	keep := make(map[string]bool)
+	for _, key := range types {
+		keep[key] = true
+	}
+
+	restricted := &Skeleton{}
+	ids := make(map[string]bool)
+	for _, t := range s.Types {
+		if keep[t.Package+"."+t.Name] {
+			restricted.Types = append(restricted.Types, t)
+			ids[t.ID] = true
+		}
+	}
+	for _, e := range s.Edges {
+		if ids[e.From] && ids[e.To] {
+			restricted.Edges = append(restricted.Edges, e)
+		}
+	}
+	return restricted
+}
+
+// Diagram renders the skeleton as a class diagram
+func (s *Skeleton) Diagram() *mermaid.Diagram {
+	d := &mermaid.Diagram{Kind: mermaid.KindClass, Header: "classDiagram"}
+
+	for _, t := range s.Types {
+		class := &mermaid.ClassEntity{ID: t.ID, Generic: t.TypeArgs, Explicit: true}
+		if t.Interface {
+			class.Annotations = []string{"interface"}
+		}
+		for _, f := range t.Fields {
+			class.Members = append(class.Members, &mermaid.ClassMember{
+				Text:       fmt.Sprintf("%s%s %s", visibility(f.Exported), f.Name, f.Signature),
+				Visibility: visibility(f.Exported),
+				Name:       f.Name,
+				Type:       f.Signature,
+			})
+		}
+		for _, m := range t.Methods {
+			class.Members = append(class.Members, &mermaid.ClassMember{
+				Text:       fmt.Sprintf("%s%s%s", visibility(m.Exported), m.Name, m.Signature),
+				Visibility: visibility(m.Exported),
+				Name:       m.Name,
+				IsMethod:   true,
+			})
+		}
+		d.Classes = append(d.Classes, class)
+	}
+
+	for _, e := range s.Edges {
+		d.Relations = append(d.Relations, &mermaid.Relation{From: e.From, To: e.To, Arrow: edgeArrows[e.Kind]})
+	}
+	return d
+}
+
+func visibility(exported bool) string {
+	if exported {
+		return "+"
+	}
+	return "-"
+}
+
+// skeletonBuilder accumulates types keyed by "package.Name"
+type skeletonBuilder struct {
+	fset  *token.FileSet
+	types map[string]*SkeletonType
+	specs map[string]*ast.TypeSpec
+	edges []SkeletonEdge
+	seen  map[SkeletonEdge]bool
+	// partial marks interfaces embedding interfaces that aren't declared in the files
+	partial map[*SkeletonType]bool
+}
+
+func (b *skeletonBuilder) collectTypes(f *ast.File, path string) {
+	pkg := f.Name.Name
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			key := pkg + "." + ts.Name.Name
+			t := &SkeletonType{
+				Name:    ts.Name.Name,
+				Package: pkg,
+				File:    path,
+				Line:    b.fset.Position(ts.Pos()).Line,
+			}
+			if _, ok := ts.Type.(*ast.InterfaceType); ok {
+				t.Interface = true
+			}
+			if ts.TypeParams != nil {
+				var names []string
+				for _, field := range ts.TypeParams.List {
+					for _, name := range field.Names {
+						names = append(names, name.Name)
+					}
+				}
+				t.TypeArgs = strings.Join(names, ", ")
+			}
+			b.types[key] = t
+			b.specs[key] = ts
+		}
+	}
+}
+
+// assignIDs uses the type name as class ID, qualified by package when the name is ambiguous
+func (b *skeletonBuilder) assignIDs() {
+	counts := make(map[string]int)
+	for _, t := range b.types {
+		counts[t.Name]++
+	}
+	for _, t := range b.types {
+		t.ID = t.Name
+		if counts[t.Name] > 1 {
+			t.ID = t.Package + "_" + t.Name
+		}
+	}
+}
+
+func (b *skeletonBuilder) collectMethods(f *ast.File) {
+	pkg := f.Name.Name
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || len(fn.Recv.List) == 0 {
+			continue
+		}
+		recv := receiverName(fn.Recv.List[0].Type)
+		t, ok := b.types[pkg+"."+recv]
+		if !ok {
+			continue
+		}
+		t.Methods = append(t.Methods, SkeletonMember{
+			Name:      fn.Name.Name,
+			Exported:  fn.Name.IsExported(),
+			Signature: signature(fn.Type),
+			shape:     shape(fn.Type),
+		})
+		b.addSignatureEdges(t, pkg, fn.Name.Name, fn.Type)
+	}
+}
+
+func (b *skeletonBuilder) collectMembers(t *SkeletonType, spec *ast.TypeSpec) {
+	switch typ := spec.Type.(type) {
+	case *ast.StructType:
+		for _, field := range typ.Fields.List {
+			if len(field.Names) == 0 {
+				if target := b.resolve(t.Package, field.Type); target != nil {
+					b.addEdge(t.ID, target.ID, EdgeEmbeds, target.Name)
+				}
+				continue
+			}
+			for _, name := range field.Names {
+				t.Fields = append(t.Fields, SkeletonMember{
+					Name:      name.Name,
+					Exported:  name.IsExported(),
+					Signature: exprString(field.Type),
+				})
+				if target := b.resolve(t.Package, field.Type); target != nil && target != t {
+					b.addEdge(t.ID, target.ID, EdgeField, name.Name)
+				}
+			}
+		}
+	case *ast.InterfaceType:
+		for _, field := range typ.Methods.List {
+			fnType, ok := field.Type.(*ast.FuncType)
+			if !ok {
+				if target := b.resolve(t.Package, field.Type); target != nil {
+					b.addEdge(t.ID, target.ID, EdgeEmbeds, target.Name)
+				} else {
+					// Methods of interfaces from outside the files are unknown
+					b.partial[t] = true
+				}
+				continue
+			}
+			for _, name := range field.Names {
+				t.Methods = append(t.Methods, SkeletonMember{
+					Name:      name.Name,
+					Exported:  name.IsExported(),
+					Signature: signature(fnType),
+					shape:     shape(fnType),
+				})
+				b.addSignatureEdges(t, t.Package, name.Name, fnType)
+			}
+		}
+	}
+}
+
+// addSignatureEdges adds dependency edges to the types referred to by a method signature
+func (b *skeletonBuilder) addSignatureEdges(t *SkeletonType, pkg, method string, fn *ast.FuncType) {
+	var lists []*ast.FieldList
+	lists = append(lists, fn.Params, fn.Results)
+	for _, list := range lists {
+		if list == nil {
+			continue
+		}
+		for _, field := range list.List {
+			if target := b.resolve(pkg, field.Type); target != nil && target != t {
+				b.addEdge(t.ID, target.ID, EdgeDepends, method)
+			}
+		}
+	}
+}
+
+// collectImplements adds an edge from every concrete type to each interface whose methods,
+// including those of embedded interfaces, it declares with identical signatures
+func (b *skeletonBuilder) collectImplements() {
+	var ifaces, concrete []*SkeletonType
+	for _, t := range b.types {
+		if t.Interface {
+			if !b.partial[t] {
+				ifaces = append(ifaces, t)
+			}
+		} else {
+			// Types without methods of their own may still get some by embedding
+			concrete = append(concrete, t)
+		}
+	}
+	sort.Slice(ifaces, func(i, j int) bool { return ifaces[i].ID < ifaces[j].ID })
+	sort.Slice(concrete, func(i, j int) bool { return concrete[i].ID < concrete[j].ID })
+
+	for _, iface := range ifaces {
+		required := b.methodSet(iface, make(map[*SkeletonType]bool))
+		if len(required) == 0 {
+			continue
+		}
+		for _, t := range concrete {
+			methods := b.methodSet(t, make(map[*SkeletonType]bool))
+			if len(methods) > 0 && satisfies(methods, required) {
+				b.addEdge(t.ID, iface.ID, EdgeImplements, "")
+			}
+		}
+	}
+}
+
+// methodSet returns the method signatures of a type including those promoted by embedding
+func (b *skeletonBuilder) methodSet(t *SkeletonType, visited map[*SkeletonType]bool) map[string]string {
+	methods := make(map[string]string)
+	if visited[t] {
+		return methods
+	}
+	visited[t] = true
+
+	for _, e := range b.edges {
+		if e.From != t.ID || e.Kind != EdgeEmbeds {
+			continue
+		}
+		for _, embedded := range b.types {
+			if embedded.ID == e.To {
+				for name, sig := range b.methodSet(embedded, visited) {
+					methods[name] = sig
+				}
+			}
+		}
+	}
+	for _, m := range t.Methods {
+		methods[m.Name] = m.shape
+	}
+	return methods
+}
+
+func satisfies(methods, required map[string]string) bool {
+	for name, sig := range required {
+		if methods[name] != sig {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *skeletonBuilder) addEdge(from, to string, kind EdgeKind, via string) {
+	key := SkeletonEdge{From: from, To: to, Kind: kind}
+	if b.seen[key] {
+		return
+	}
+	b.seen[key] = true
+	b.edges = append(b.edges, SkeletonEdge{From: from, To: to, Kind: kind, Via: via})
+}
+
+// resolve finds the declared type an expression refers to, looking through pointers,
+// slices, arrays, maps, channels and generic instantiations
+func (b *skeletonBuilder) resolve(pkg string, expr ast.Expr) *SkeletonType {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return b.types[pkg+"."+e.Name]
+	case *ast.SelectorExpr:
+		if x, ok := e.X.(*ast.Ident); ok {
+			return b.types[x.Name+"."+e.Sel.Name]
+		}
+	case *ast.StarExpr:
+		return b.resolve(pkg, e.X)
+	case *ast.ArrayType:
+		return b.resolve(pkg, e.Elt)
+	case *ast.MapType:
+		return b.resolve(pkg, e.Value)
+	case *ast.ChanType:
+		return b.resolve(pkg, e.Value)
+	case *ast.Ellipsis:
+		return b.resolve(pkg, e.Elt)
+	case *ast.IndexExpr:
+		return b.resolve(pkg, e.X)
+	case *ast.IndexListExpr:
+		return b.resolve(pkg, e.X)
+	}
+	return nil
+}
+
+// receiverName returns the type name of a method receiver
+func receiverName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(e.X)
+	case *ast.IndexExpr:
+		return receiverName(e.X)
+	case *ast.IndexListExpr:
+		return receiverName(e.X)
+	case *ast.Ident:
+		return e.Name
+	}
+	return ""
+}
+
+// signature formats a function type as (params) results
+func signature(fn *ast.FuncType) string {
+	return formatFunc(fn, true)
+}
+
+// shape formats a function type without parameter names
+func shape(fn *ast.FuncType) string {
+	return formatFunc(fn, false)
+}
+
+func formatFunc(fn *ast.FuncType, names bool) string {
+	var params []string
+	for _, field := range fn.Params.List {
+		typ := exprString(field.Type)
+		if len(field.Names) == 0 {
+			params = append(params, typ)
+			continue
+		}
+		for _, name := range field.Names {
+			if names {
+				params = append(params, name.Name+" "+typ)
+			} else {
+				params = append(params, typ)
+			}
+		}
+	}
+
+	sig := "(" + strings.Join(params, ", ") + ")"
+	if fn.Results == nil {
+		return sig
+	}
+
+	var results []string
+	for _, field := range fn.Results.List {
+		typ := exprString(field.Type)
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			results = append(results, typ)
+		}
+	}
+	if len(results) == 1 {
+		return sig + " " + results[0]
+	}
+	return sig + " (" + strings.Join(results, ", ") + ")"
+}
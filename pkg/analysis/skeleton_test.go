@@ -0,0 +1,109 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SkeletonTestSuite is a test suite for building class diagram skeletons from Go source
+type SkeletonTestSuite struct {
+	suite.Suite
+	skeleton *Skeleton
+}
+
+// SetupTest builds the skeleton of the shop fixture package
+func (s *SkeletonTestSuite) SetupTest() {
+	var files []SourceFile
+	for _, name := range []string{"order.go", "store.go"} {
+		content, err := os.ReadFile(filepath.Join("testdata", "skeleton", "shop", name))
+		s.Require().NoError(err)
+		files = append(files, SourceFile{Path: name, Content: string(content)})
+	}
+
+	skeleton, err := BuildSkeleton(files)
+	s.Require().NoError(err)
+	s.skeleton = skeleton
+}
+
+// TestTypes checks the types in declaration order with their fields and methods
+func (s *SkeletonTestSuite) TestTypes() {
+	var ids []string
+	for _, t := range s.skeleton.Types {
+		ids = append(ids, t.ID)
+	}
+	s.Equal([]string{"Entity", "Repository", "Base", "Order", "Item", "OrderStore", "Reader", "Writer"}, ids)
+
+	repo := s.skeleton.Type("Repository")
+	s.True(repo.Interface)
+	s.Equal("T", repo.TypeArgs)
+
+	base := s.skeleton.Type("Base")
+	s.Equal("order.go", base.File)
+	s.Equal(17, base.Line)
+	s.Equal([]SkeletonMember{
+		{Name: "ID", Exported: true, Signature: "string"},
+		{Name: "created", Signature: "time.Time"},
+	}, base.Fields)
+
+	var methods []string
+	for _, m := range s.skeleton.Type("OrderStore").Methods {
+		methods = append(methods, visibility(m.Exported)+m.Name+m.Signature)
+	}
+	s.Equal([]string{"+Save(item *Order) error", "+Find(key string) (*Order, error)", "-evict(key string)"}, methods)
+
+	s.Nil(s.skeleton.Type("Missing"))
+}
+
+// TestEdges checks embedding, fields, signatures and implementations found by method sets,
+// including methods promoted by embedding
+func (s *SkeletonTestSuite) TestEdges() {
+	s.ElementsMatch([]SkeletonEdge{
+		{From: "OrderStore", To: "Order", Kind: EdgeDepends, Via: "Save"},
+		{From: "Order", To: "Base", Kind: EdgeEmbeds, Via: "Base"},
+		{From: "Order", To: "Item", Kind: EdgeField, Via: "Items"},
+		{From: "OrderStore", To: "Order", Kind: EdgeField, Via: "orders"},
+		{From: "Reader", To: "Order", Kind: EdgeDepends, Via: "Find"},
+		{From: "Writer", To: "Order", Kind: EdgeDepends, Via: "Put"},
+		{From: "Base", To: "Entity", Kind: EdgeImplements},
+		{From: "Order", To: "Entity", Kind: EdgeImplements},
+		{From: "OrderStore", To: "Reader", Kind: EdgeImplements},
+	}, s.skeleton.Edges)
+}
+
+// TestRestrict checks that only the given types and the edges between them are kept
+func (s *SkeletonTestSuite) TestRestrict() {
+	restricted := s.skeleton.Restrict([]string{"shop.Order", "shop.Item", "other.Order"})
+
+	s.Len(restricted.Types, 2)
+	s.Equal([]SkeletonEdge{{From: "Order", To: "Item", Kind: EdgeField, Via: "Items"}}, restricted.Edges)
+}
+
+// TestDiagram checks the rendered class diagram
+func (s *SkeletonTestSuite) TestDiagram() {
+	golden, err := os.ReadFile(filepath.Join("testdata", "skeleton", "shop.golden.mmd"))
+	s.Require().NoError(err)
+	s.Equal(string(golden), s.skeleton.Diagram().String()+"\n")
+}
+
+// TestAmbiguousNames checks that types of the same name in different packages get qualified IDs
+func (s *SkeletonTestSuite) TestAmbiguousNames() {
+	skeleton, err := BuildSkeleton([]SourceFile{
+		{Path: "a/user.go", Content: "package a\n\ntype User struct{}\n"},
+		{Path: "b/user.go", Content: "package b\n\ntype User struct {\n\tPrevious *a.User\n}\n"},
+	})
+	s.Require().NoError(err)
+	s.NotNil(skeleton.Type("a_User"))
+	s.NotNil(skeleton.Type("b_User"))
+	s.Equal([]SkeletonEdge{{From: "b_User", To: "a_User", Kind: EdgeField, Via: "Previous"}}, skeleton.Edges)
+
+	_, err = BuildSkeleton([]SourceFile{{Path: "broken.go", Content: "package broken\ntype {"}})
+	s.ErrorContains(err, "failed to parse broken.go")
+}
+
+// TestSkeletonTestSuite runs the skeleton test suite
+func TestSkeletonTestSuite(t *testing.T) {
+	suite.Run(t, new(SkeletonTestSuite))
+}
@@ -0,0 +1,147 @@
+package analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"sort"
+)
+
+// TypeSymbol is a named type and the names of its fields and methods
+type TypeSymbol struct {
+	Name      string
+	Package   string
+	Exported  bool
+	Interface bool
+	Fields    map[string]bool
+	// Methods include those promoted from embedded types declared in the files
+	Methods map[string]bool
+}
+
+// SymbolTable holds the symbols declared in a set of Go files
+type SymbolTable struct {
+	Types    map[string]*TypeSymbol
+	Funcs    map[string]bool
+	Packages map[string]bool
+}
+
+// HasMember reports whether the type has a field or method with the given name
+func (t *TypeSymbol) HasMember(name string) bool {
+	return t.Fields[name] || t.Methods[name]
+}
+
+// ExportedTypes returns the names of the exported types, sorted
+func (s *SymbolTable) ExportedTypes() []string {
+	var names []string
+	for name, t := range s.Types {
+		if t.Exported {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasMethod reports whether any type declares a method with the given name
+func (s *SymbolTable) HasMethod(name string) bool {
+	for _, t := range s.Types {
+		if t.Methods[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// CollectSymbols type-checks the files package by package and collects their declared types,
+// fields, methods and functions. Imports aren't resolved: types from other packages are left
+// invalid and the resulting type errors are ignored, so only the files themselves are needed.
+func CollectSymbols(files []SourceFile) (*SymbolTable, error) {
+	fset := token.NewFileSet()
+	byPackage := make(map[string][]*ast.File)
+	var order []string
+
+	for _, file := range files {
+		f, err := parser.ParseFile(fset, file.Path, file.Content, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file.Path, err)
+		}
+		pkg := f.Name.Name
+		if _, ok := byPackage[pkg]; !ok {
+			order = append(order, pkg)
+		}
+		byPackage[pkg] = append(byPackage[pkg], f)
+	}
+
+	table := &SymbolTable{
+		Types:    make(map[string]*TypeSymbol),
+		Funcs:    make(map[string]bool),
+		Packages: make(map[string]bool),
+	}
+
+	for _, name := range order {
+		conf := types.Config{
+			Importer: stubImporter{},
+			Error:    func(error) {},
+		}
+		pkg, _ := conf.Check(name, fset, byPackage[name], nil)
+		if pkg == nil {
+			continue
+		}
+		table.Packages[name] = true
+		table.add(pkg)
+	}
+
+	return table, nil
+}
+
+func (s *SymbolTable) add(pkg *types.Package) {
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		switch obj := scope.Lookup(name).(type) {
+		case *types.Func:
+			s.Funcs[name] = true
+		case *types.TypeName:
+			t := &TypeSymbol{
+				Name:     name,
+				Package:  pkg.Name(),
+				Exported: obj.Exported(),
+				Fields:   make(map[string]bool),
+				Methods:  make(map[string]bool),
+			}
+
+			switch u := obj.Type().Underlying().(type) {
+			case *types.Struct:
+				for i := 0; i < u.NumFields(); i++ {
+					t.Fields[u.Field(i).Name()] = true
+				}
+			case *types.Interface:
+				t.Interface = true
+				for i := 0; i < u.NumMethods(); i++ {
+					t.Methods[u.Method(i).Name()] = true
+				}
+			}
+
+			mset := types.NewMethodSet(types.NewPointer(obj.Type()))
+			for i := 0; i < mset.Len(); i++ {
+				t.Methods[mset.At(i).Obj().Name()] = true
+			}
+
+			// Keep the first declaration when several packages declare the same name
+			if _, ok := s.Types[name]; !ok {
+				s.Types[name] = t
+			}
+		}
+	}
+}
+
+// stubImporter returns an empty package for every import
+type stubImporter struct{}
+
+func (stubImporter) Import(importPath string) (*types.Package, error) {
+	pkg := types.NewPackage(importPath, path.Base(importPath))
+	pkg.MarkComplete()
+	return pkg, nil
+}
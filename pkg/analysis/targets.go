@@ -0,0 +1,292 @@
+package analysis
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Target is the set of Go files a diagram is generated from, selected by a package pattern or a symbol
+type Target struct {
+	// Scope describes the target in prompts and messages
+	Scope string
+	// Files are the Go files of the target, relative to the directory it was resolved in
+	Files []string
+	// Types limits class diagrams to these types, as "package.Name" with the package name.
+	// It is empty when the target is one or more whole packages.
+	Types []string
+	// Focus is the type highlighted in diagrams, as "package.Name", if any
+	Focus string
+}
+
+// ResolveTarget resolves a target relative to dir. A target is a package pattern understood by
+// go/packages (./internal/service/..., an import path) or a symbol of a package, written as the
+// package pattern, import path or package name followed by the symbol, e.g. service.DiagramService.
+// A type target covers the type and the types of the module it refers to in its fields and methods.
+func ResolveTarget(dir, target string) (*Target, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if pkgs, err := LoadPackages(dir, target); err == nil {
+		return packagesTarget(absDir, target, pkgs)
+	}
+
+	pkgPart, symbol, ok := splitSymbol(target)
+	if !ok {
+		return nil, fmt.Errorf("no packages found for %s", target)
+	}
+
+	pkg, err := findPackage(dir, pkgPart)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := pkg.Types.Scope().Lookup(symbol)
+	if obj == nil {
+		return nil, fmt.Errorf("symbol %s not found in package %s", symbol, pkg.PkgPath)
+	}
+
+	switch obj := obj.(type) {
+	case *types.TypeName:
+		return typeTarget(absDir, pkg, obj)
+	case *types.Func:
+		file, err := relativeFile(absDir, pkg.Fset.Position(obj.Pos()).Filename)
+		if err != nil {
+			return nil, err
+		}
+		return &Target{Scope: fmt.Sprintf("the function %s.%s", pkg.Name, obj.Name()), Files: []string{file}}, nil
+	default:
+		return nil, fmt.Errorf("%s.%s is not a type or a function", pkg.Name, symbol)
+	}
+}
+
+// splitSymbol splits a symbol at the last dot after the last slash, e.g. ./internal/service.DiagramService
+func splitSymbol(target string) (pkg, symbol string, ok bool) {
+	dot := strings.LastIndex(target, ".")
+	if dot <= strings.LastIndex(target, "/")+1 || dot == len(target)-1 {
+		return "", "", false
+	}
+	return target[:dot], target[dot+1:], true
+}
+
+// packagesTarget covers all Go files of the packages matching a pattern
+func packagesTarget(dir, pattern string, pkgs []*packages.Package) (*Target, error) {
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].PkgPath < pkgs[j].PkgPath })
+
+	t := &Target{}
+	var paths []string
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			continue
+		}
+		for _, file := range pkg.GoFiles {
+			rel, err := relativeFile(dir, file)
+			if err != nil {
+				return nil, err
+			}
+			t.Files = append(t.Files, rel)
+		}
+		paths = append(paths, pkg.PkgPath)
+	}
+	if len(t.Files) == 0 {
+		return nil, fmt.Errorf("no Go files found for %s", pattern)
+	}
+
+	if len(paths) == 1 {
+		t.Scope = fmt.Sprintf("the package %s", paths[0])
+	} else {
+		t.Scope = fmt.Sprintf("the packages %s", strings.Join(paths, ", "))
+	}
+	return t, nil
+}
+
+// findPackage loads a package by pattern or import path, falling back to the packages of the
+// module below dir with that name or import path suffix
+func findPackage(dir, name string) (*packages.Package, error) {
+	if pkgs, err := LoadPackages(dir, name); err == nil && len(pkgs) == 1 && len(pkgs[0].Errors) == 0 {
+		return pkgs[0], nil
+	}
+
+	pkgs, err := LoadPackages(dir, "./...")
+	if err != nil {
+		return nil, err
+	}
+	return lookupPackage(pkgs, name)
+}
+
+// lookupPackage finds a type-checked package by import path, package name or import path suffix
+func lookupPackage(pkgs []*packages.Package, name string) (*packages.Package, error) {
+	var matches []*packages.Package
+	for _, pkg := range pkgs {
+		if pkg.Types == nil {
+			continue
+		}
+		if pkg.PkgPath == name {
+			return pkg, nil
+		}
+		if pkg.Name == name || strings.HasSuffix(pkg.PkgPath, "/"+strings.TrimPrefix(name, "./")) {
+			matches = append(matches, pkg)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("package %s not found", name)
+	case 1:
+		return matches[0], nil
+	}
+
+	var paths []string
+	for _, pkg := range matches {
+		paths = append(paths, pkg.PkgPath)
+	}
+	return nil, fmt.Errorf("package %s is ambiguous: %s", name, strings.Join(paths, ", "))
+}
+
+// typeTarget covers a type and the named types of its module it refers to, with the files
+// declaring them and their methods
+func typeTarget(dir string, pkg *packages.Package, obj *types.TypeName) (*Target, error) {
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		return nil, fmt.Errorf("%s.%s is not a named type", pkg.Name, obj.Name())
+	}
+
+	module := ""
+	if pkg.Module != nil {
+		module = pkg.Module.Path
+	}
+	inModule := func(n *types.Named) bool {
+		p := n.Obj().Pkg()
+		return p != nil && (p == pkg.Types || (module != "" && (p.Path() == module || strings.HasPrefix(p.Path(), module+"/"))))
+	}
+
+	selected := []*types.Named{named}
+	for _, dep := range referencedTypes(named) {
+		if dep != named && inModule(dep) {
+			selected = append(selected, dep)
+		}
+	}
+
+	t := &Target{Scope: fmt.Sprintf("the type %s.%s and the types it uses", pkg.Name, obj.Name())}
+	if err := t.addTypes(dir, pkg.Fset, selected); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// addTypes adds types to the target with the files declaring them and their methods
+func (t *Target) addTypes(dir string, fset *token.FileSet, named []*types.Named) error {
+	seen := make(map[string]bool)
+	for _, file := range t.Files {
+		seen[file] = true
+	}
+	addFile := func(obj types.Object) error {
+		if !obj.Pos().IsValid() {
+			return nil
+		}
+		file, err := relativeFile(dir, fset.Position(obj.Pos()).Filename)
+		if err != nil {
+			return err
+		}
+		if !seen[file] {
+			seen[file] = true
+			t.Files = append(t.Files, file)
+		}
+		return nil
+	}
+
+	for _, n := range named {
+		t.Types = append(t.Types, typeKey(n.Obj()))
+		if err := addFile(n.Obj()); err != nil {
+			return err
+		}
+		for i := 0; i < n.NumMethods(); i++ {
+			if err := addFile(n.Method(i)); err != nil {
+				return err
+			}
+		}
+	}
+	sort.Strings(t.Files)
+	return nil
+}
+
+// typeKey returns the "package.Name" key of a type, with the package name
+func typeKey(obj *types.TypeName) string {
+	return obj.Pkg().Name() + "." + obj.Name()
+}
+
+// referencedTypes returns the named types used by the fields, embedded types and method
+// signatures of a type, in order of appearance
+func referencedTypes(named *types.Named) []*types.Named {
+	var refs []*types.Named
+	seen := make(map[*types.Named]bool)
+
+	var visit func(typ types.Type)
+	visit = func(typ types.Type) {
+		switch t := typ.(type) {
+		case *types.Alias:
+			visit(types.Unalias(t))
+		case *types.Named:
+			if origin := t.Origin(); !seen[origin] {
+				seen[origin] = true
+				refs = append(refs, origin)
+			}
+			for i := 0; i < t.TypeArgs().Len(); i++ {
+				visit(t.TypeArgs().At(i))
+			}
+		case *types.Pointer:
+			visit(t.Elem())
+		case *types.Slice:
+			visit(t.Elem())
+		case *types.Array:
+			visit(t.Elem())
+		case *types.Map:
+			visit(t.Key())
+			visit(t.Elem())
+		case *types.Chan:
+			visit(t.Elem())
+		case *types.Signature:
+			for i := 0; i < t.Params().Len(); i++ {
+				visit(t.Params().At(i).Type())
+			}
+			for i := 0; i < t.Results().Len(); i++ {
+				visit(t.Results().At(i).Type())
+			}
+		case *types.Struct:
+			for i := 0; i < t.NumFields(); i++ {
+				visit(t.Field(i).Type())
+			}
+		case *types.Interface:
+			for i := 0; i < t.NumEmbeddeds(); i++ {
+				visit(t.EmbeddedType(i))
+			}
+			for i := 0; i < t.NumExplicitMethods(); i++ {
+				visit(t.ExplicitMethod(i).Type())
+			}
+		}
+	}
+
+	// The type itself is visited through its underlying type, not as a reference
+	seen[named] = true
+	visit(named.Underlying())
+	for i := 0; i < named.NumMethods(); i++ {
+		visit(named.Method(i).Type())
+	}
+	return refs
+}
+
+// relativeFile returns the path of a file relative to dir, which must contain it
+func relativeFile(dir, file string) (string, error) {
+	rel, err := filepath.Rel(dir, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file %s is outside of %s", file, dir)
+	}
+	return rel, nil
+}
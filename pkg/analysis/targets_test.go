@@ -0,0 +1,145 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// TargetsTestSuite is a test suite for resolving targets in a small module
+type TargetsTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+// SetupSuite writes the module
+func (s *TargetsTestSuite) SetupSuite() {
+	s.dir = s.T().TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/shop\n\ngo 1.22\n",
+		"store/store.go": `package store
+
+// Store persists orders
+type Store interface {
+	Save(o Order) error
+}
+
+// Order is a placed order
+type Order struct {
+	ID    string
+	Items []Item
+}
+
+// Item is a line of an order
+type Item struct {
+	SKU string
+}
+`,
+		"store/unrelated.go": "package store\n\ntype Unrelated struct{}\n",
+		"checkout/service.go": `package checkout
+
+import (
+	"time"
+
+	"example.com/shop/store"
+)
+
+// Service places orders
+type Service struct {
+	store store.Store
+	clock func() time.Time
+}
+
+// New creates a service
+func New(s store.Store) *Service { return &Service{store: s} }
+`,
+		"checkout/methods.go": `package checkout
+
+import "example.com/shop/store"
+
+func (s *Service) Place(o *store.Order) error { return s.store.Save(*o) }
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(s.dir, name)
+		s.Require().NoError(os.MkdirAll(filepath.Dir(path), 0o755))
+		s.Require().NoError(os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+// TestPackages checks package patterns and import paths
+func (s *TargetsTestSuite) TestPackages() {
+	t, err := ResolveTarget(s.dir, "./...")
+	s.Require().NoError(err)
+	s.Equal("the packages example.com/shop/checkout, example.com/shop/store", t.Scope)
+	s.ElementsMatch([]string{"checkout/methods.go", "checkout/service.go", "store/store.go", "store/unrelated.go"}, t.Files)
+	s.Empty(t.Types)
+
+	t, err = ResolveTarget(s.dir, "example.com/shop/store")
+	s.Require().NoError(err)
+	s.Equal("the package example.com/shop/store", t.Scope)
+}
+
+// TestType checks that a type covers the types it uses and the files of its methods
+func (s *TargetsTestSuite) TestType() {
+	t, err := ResolveTarget(s.dir, "checkout.Service")
+	s.Require().NoError(err)
+	s.Equal("the type checkout.Service and the types it uses", t.Scope)
+	s.Equal([]string{"checkout/methods.go", "checkout/service.go", "store/store.go"}, t.Files)
+	s.Equal([]string{"checkout.Service", "store.Store", "store.Order"}, t.Types)
+
+	t, err = ResolveTarget(s.dir, "./store.Order")
+	s.Require().NoError(err)
+	s.Equal([]string{"store.Order", "store.Item"}, t.Types)
+}
+
+// TestFunction checks that a function covers its file
+func (s *TargetsTestSuite) TestFunction() {
+	t, err := ResolveTarget(s.dir, "example.com/shop/checkout.New")
+	s.Require().NoError(err)
+	s.Equal("the function checkout.New", t.Scope)
+	s.Equal([]string{"checkout/service.go"}, t.Files)
+}
+
+// TestFocus checks the neighborhood of a type in each direction
+func (s *TargetsTestSuite) TestFocus() {
+	t, err := FocusTarget(s.dir, "checkout.Service", 1, DirectionOut)
+	s.Require().NoError(err)
+	s.Equal("checkout.Service", t.Focus)
+	s.Equal([]string{"checkout.Service", "store.Order", "store.Store"}, t.Types)
+	s.Equal([]string{"checkout/methods.go", "checkout/service.go", "store/store.go"}, t.Files)
+
+	t, err = FocusTarget(s.dir, "checkout.Service", 2, DirectionOut)
+	s.Require().NoError(err)
+	s.Equal([]string{"checkout.Service", "store.Order", "store.Store", "store.Item"}, t.Types)
+
+	t, err = FocusTarget(s.dir, "example.com/shop/store.Order", 1, DirectionIn)
+	s.Require().NoError(err)
+	s.Equal([]string{"store.Order", "checkout.Service", "store.Store"}, t.Types)
+
+	t, err = FocusTarget(s.dir, "store.Item", 0, DirectionBoth)
+	s.Require().NoError(err)
+	s.Equal([]string{"store.Item"}, t.Types)
+
+	_, err = FocusTarget(s.dir, "checkout.New", 1, DirectionBoth)
+	s.ErrorContains(err, "type New not found")
+}
+
+// TestErrors checks unknown packages and symbols
+func (s *TargetsTestSuite) TestErrors() {
+	_, err := ResolveTarget(s.dir, "billing.Invoice")
+	s.ErrorContains(err, "package billing not found")
+
+	_, err = ResolveTarget(s.dir, "store.Missing")
+	s.ErrorContains(err, "symbol Missing not found")
+
+	_, err = ResolveTarget(s.dir, "./missing")
+	s.ErrorContains(err, "no packages found")
+}
+
+// TestTargetsTestSuite runs the targets test suite
+func TestTargetsTestSuite(t *testing.T) {
+	suite.Run(t, new(TargetsTestSuite))
+}
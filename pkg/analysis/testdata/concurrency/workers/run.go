@@ -0,0 +1,27 @@
+package workers
+
+import "sync"
+
+// Run doubles the jobs with a pool of workers
+func Run(jobs []int) []int {
+	in := make(chan int, len(jobs))
+	results := make(chan int, len(jobs))
+	var wg sync.WaitGroup
+
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go worker(in, results, &wg)
+	}
+	for _, job := range jobs {
+		in <- job
+	}
+	close(in)
+	wg.Wait()
+	close(results)
+
+	var doubled []int
+	for r := range results {
+		doubled = append(doubled, r)
+	}
+	return doubled
+}
@@ -0,0 +1,11 @@
+package workers
+
+import "sync"
+
+// worker is declared before the function that starts it
+func worker(in <-chan int, out chan<- int, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for job := range in {
+		out <- job * 2
+	}
+}
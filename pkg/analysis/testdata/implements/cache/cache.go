@@ -0,0 +1,19 @@
+package cache
+
+import "example.com/shop/store"
+
+// Cached wraps another store, only *Cached satisfies store.Store
+type Cached struct {
+	next store.Store
+	hits int
+}
+
+func (c *Cached) Save(o store.Order) error { return c.next.Save(o) }
+
+func (c *Cached) Find(id string) (store.Order, error) {
+	c.hits++
+	return c.next.Find(id)
+}
+
+// Hits returns the number of lookups
+func (c *Cached) Hits() int { return c.hits }
@@ -0,0 +1,11 @@
+package notify
+
+// Notifier sends notifications, nothing implements it
+type Notifier interface {
+	Notify(message string) error
+}
+
+// Store has the name of another package's interface
+type Store interface {
+	Close() error
+}
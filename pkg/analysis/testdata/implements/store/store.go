@@ -0,0 +1,29 @@
+package store
+
+// Store persists orders
+type Store interface {
+	Save(o Order) error
+	Find(id string) (Order, error)
+}
+
+// Order is a placed order
+type Order struct {
+	ID string
+}
+
+// Memory keeps orders in a map, its value satisfies Store
+type Memory struct {
+	orders map[string]Order
+}
+
+func (m Memory) Save(o Order) error { m.orders[o.ID] = o; return nil }
+
+func (m Memory) Find(id string) (Order, error) { return m.orders[id], nil }
+
+// Any is empty and left out of the matrix
+type Any interface{}
+
+// Keyed is generic and left out of the matrix
+type Keyed[K comparable] interface {
+	Key() K
+}
@@ -0,0 +1,36 @@
+package shop
+
+import "time"
+
+// Entity is stored by a Repository
+type Entity interface {
+	Key() string
+}
+
+// Repository persists entities of one type
+type Repository[T Entity] interface {
+	Save(item T) error
+	Find(key string) (T, error)
+}
+
+// Base holds the fields shared by all entities
+type Base struct {
+	ID      string
+	created time.Time
+}
+
+// Key returns the identifier of the entity
+func (b *Base) Key() string { return b.ID }
+
+// Order is a placed order
+type Order struct {
+	Base
+	Items []*Item
+	total int
+}
+
+// Item is a line of an order
+type Item struct {
+	SKU      string
+	Quantity int
+}
@@ -0,0 +1,31 @@
+package shop
+
+// OrderStore keeps orders in memory
+type OrderStore struct {
+	orders map[string]*Order
+}
+
+// Save stores the order
+func (s *OrderStore) Save(item *Order) error {
+	s.orders[item.Key()] = item
+	return nil
+}
+
+// Find returns the order with the key
+func (s *OrderStore) Find(key string) (*Order, error) {
+	return s.orders[key], nil
+}
+
+// Reader reads orders
+type Reader interface {
+	Find(key string) (*Order, error)
+}
+
+// Writer writes orders by key, which OrderStore doesn't do
+type Writer interface {
+	Put(key string, order *Order) error
+}
+
+func (s *OrderStore) evict(key string) {
+	delete(s.orders, key)
+}
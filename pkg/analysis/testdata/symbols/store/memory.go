@@ -0,0 +1,9 @@
+package store
+
+// Memory keeps records in memory
+type Memory struct {
+	records map[string]string
+}
+
+// Get returns the record with the id
+func (m *Memory) Get(id string) (string, error) { return m.records[id], nil }
@@ -0,0 +1,29 @@
+package users
+
+import "example.com/shop/store"
+
+// Repository loads users
+type Repository interface {
+	Get(id string) (*User, error)
+}
+
+// UserService manages users
+type UserService struct {
+	repo  Repository
+	cache *cache
+	audit store.Log
+}
+
+// NewUserService creates a UserService
+func NewUserService(repo Repository) *UserService {
+	return &UserService{repo: repo, cache: &cache{}}
+}
+
+// Find returns the user with the id
+func (s *UserService) Find(id string) (*User, error) {
+	return s.repo.Get(id)
+}
+
+type cache struct {
+	entries map[string]*User
+}
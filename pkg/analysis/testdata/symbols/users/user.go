@@ -0,0 +1,24 @@
+package users
+
+import "time"
+
+// Base holds the fields shared by all records
+type Base struct {
+	ID      string
+	Created time.Time
+}
+
+// Age returns how long ago the record was created
+func (b Base) Age() time.Duration { return time.Since(b.Created) }
+
+// User is a registered user
+type User struct {
+	Base
+	Name  string
+	email string
+}
+
+// Rename changes the name of the user
+func (u *User) Rename(name string) { u.Name = name }
+
+func (u *User) normalize() {}
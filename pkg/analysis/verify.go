@@ -0,0 +1,216 @@
+package analysis
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"mm-go-agent/pkg/mermaid"
+)
+
+// callRe matches the called name at the start of a sequence message, e.g. "GetUser(id)"
+var callRe = regexp.MustCompile(`^\s*(?:[A-Za-z_]\w*\.)?([A-Za-z_]\w*)\s*\(`)
+
+// Finding is a diagram entity that doesn't exist in the source code
+type Finding struct {
+	// Kind is "class", "member", "participant" or "message"
+	Kind   string
+	Entity string
+	Line   int
+}
+
+// VerificationReport is the result of checking a diagram against the source code symbols
+type VerificationReport struct {
+	Hallucinated []Finding
+	// MissingTypes are the exported types of the source that the diagram doesn't show
+	MissingTypes []string
+	// Checked and Matched count the diagram entities that were looked up
+	Checked int
+	Matched int
+	// Coverage is the percentage of exported types shown in the diagram
+	Coverage float64
+	// Supported is false for diagram kinds that can't be verified
+	Supported bool
+}
+
+// OK reports whether the diagram has no hallucinated entities
+func (r *VerificationReport) OK() bool {
+	return len(r.Hallucinated) == 0
+}
+
+// String formats the report for display
+func (r *VerificationReport) String() string {
+	if !r.Supported {
+		return "Verification: only class and sequence diagrams can be verified"
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Verification: %d/%d entities found in the code, %.0f%% of exported types covered\n", r.Matched, r.Checked, r.Coverage))
+	if len(r.Hallucinated) > 0 {
+		b.WriteString("Not found in the code:\n")
+		for _, f := range r.Hallucinated {
+			b.WriteString(fmt.Sprintf("  - line %d: %s %s\n", f.Line, f.Kind, f.Entity))
+		}
+	}
+	if len(r.MissingTypes) > 0 {
+		b.WriteString(fmt.Sprintf("Exported types missing from the diagram: %s\n", strings.Join(r.MissingTypes, ", ")))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// Verify cross-checks the classes and members of a class diagram or the participants
+// and called methods of a sequence diagram against the symbols of the source code.
+// Names qualified by a package that isn't part of the source are external and aren't checked.
+func Verify(d *mermaid.Diagram, symbols *SymbolTable) *VerificationReport {
+	v := &verifier{symbols: symbols, report: &VerificationReport{}, shown: make(map[string]bool)}
+
+	switch d.Kind {
+	case mermaid.KindClass:
+		v.report.Supported = true
+		v.verifyClasses(d)
+	case mermaid.KindSequence:
+		v.report.Supported = true
+		v.verifySequence(d)
+	default:
+		return v.report
+	}
+
+	exported := symbols.ExportedTypes()
+	for _, name := range exported {
+		if !v.shown[name] {
+			v.report.MissingTypes = append(v.report.MissingTypes, name)
+		}
+	}
+	if len(exported) > 0 {
+		v.report.Coverage = float64(len(exported)-len(v.report.MissingTypes)) * 100 / float64(len(exported))
+	} else {
+		v.report.Coverage = 100
+	}
+	return v.report
+}
+
+type verifier struct {
+	symbols *SymbolTable
+	report  *VerificationReport
+	// shown are the source types present in the diagram
+	shown map[string]bool
+}
+
+func (v *verifier) check(found bool, kind, entity string, line int) bool {
+	v.report.Checked++
+	if found {
+		v.report.Matched++
+	} else {
+		v.report.Hallucinated = append(v.report.Hallucinated, Finding{Kind: kind, Entity: entity, Line: line})
+	}
+	return found
+}
+
+// lookupType resolves a diagram class name to a source type. External reports names
+// qualified by a package outside of the source.
+func (v *verifier) lookupType(name string) (t *TypeSymbol, external bool) {
+	for _, sep := range []string{".", "_"} {
+		if idx := strings.LastIndex(name, sep); idx > 0 {
+			pkg, short := name[:idx], name[idx+1:]
+			if v.symbols.Packages[pkg] {
+				return v.symbols.Types[short], false
+			}
+			if sep == "." {
+				return nil, true
+			}
+		}
+	}
+	return v.symbols.Types[name], false
+}
+
+func (v *verifier) verifyClasses(d *mermaid.Diagram) {
+	for _, c := range d.Classes {
+		t, external := v.lookupType(c.ID)
+		if external {
+			continue
+		}
+		if !v.check(t != nil, "class", c.ID, c.Line) {
+			continue
+		}
+		v.shown[t.Name] = true
+
+		for _, m := range c.Members {
+			v.check(v.hasMember(t, m), "member", fmt.Sprintf("%s.%s", c.ID, strings.TrimSpace(m.Text)), m.Line)
+		}
+	}
+}
+
+// hasMember accepts fields and methods of the type and package level functions,
+// which diagrams commonly list as constructors of the type
+func (v *verifier) hasMember(t *TypeSymbol, m *mermaid.ClassMember) bool {
+	for _, name := range m.Names() {
+		if t.HasMember(name) || v.symbols.Funcs[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *verifier) verifySequence(d *mermaid.Diagram) {
+	for _, p := range d.Participants {
+		// Actors are people or external systems
+		if p.Type == "actor" {
+			continue
+		}
+		if name, ok := v.matchParticipant(p); ok {
+			v.check(true, "participant", p.ID, p.Line)
+			if name != "" {
+				v.shown[name] = true
+			}
+			continue
+		}
+		v.check(false, "participant", p.ID, p.Line)
+	}
+
+	for _, m := range d.Messages() {
+		match := callRe.FindStringSubmatch(m.Text)
+		if match == nil {
+			continue
+		}
+		name := match[1]
+		v.check(v.symbols.HasMethod(name) || v.symbols.Funcs[name], "message", fmt.Sprintf("%s->%s: %s", m.From, m.To, strings.TrimSpace(m.Text)), m.Line)
+	}
+}
+
+// matchParticipant matches a participant by ID or alias against the types, functions and
+// packages of the source, ignoring case. It returns the matched type name, if any.
+func (v *verifier) matchParticipant(p *mermaid.Participant) (string, bool) {
+	var candidates []string
+	for _, candidate := range []string{p.ID, p.Alias} {
+		if candidate = strings.ReplaceAll(strings.TrimSpace(candidate), " ", ""); candidate != "" {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	// Types take precedence over functions and packages of the same name
+	for _, candidate := range candidates {
+		if t, external := v.lookupType(candidate); t != nil {
+			return t.Name, true
+		} else if external {
+			return "", true
+		}
+		for name := range v.symbols.Types {
+			if strings.EqualFold(name, candidate) {
+				return name, true
+			}
+		}
+	}
+	for _, candidate := range candidates {
+		for name := range v.symbols.Funcs {
+			if strings.EqualFold(name, candidate) {
+				return "", true
+			}
+		}
+		for name := range v.symbols.Packages {
+			if strings.EqualFold(name, candidate) {
+				return "", true
+			}
+		}
+	}
+	return "", false
+}
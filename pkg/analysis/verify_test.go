@@ -0,0 +1,157 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"mm-go-agent/pkg/mermaid"
+)
+
+// VerifyTestSuite is a test suite for collecting symbols and verifying diagrams against them
+type VerifyTestSuite struct {
+	suite.Suite
+	symbols *SymbolTable
+}
+
+// SetupTest collects the symbols of the users and store fixture packages
+func (s *VerifyTestSuite) SetupTest() {
+	var files []SourceFile
+	for _, name := range []string{"users/user.go", "users/service.go", "store/memory.go"} {
+		content, err := os.ReadFile(filepath.Join("testdata", "symbols", name))
+		s.Require().NoError(err)
+		files = append(files, SourceFile{Path: name, Content: string(content)})
+	}
+
+	symbols, err := CollectSymbols(files)
+	s.Require().NoError(err)
+	s.symbols = symbols
+}
+
+// verify parses the diagram and verifies it against the fixture symbols
+func (s *VerifyTestSuite) verify(src string) *VerificationReport {
+	d, err := mermaid.Parse(src)
+	s.Require().NoError(err)
+	return Verify(d, s.symbols)
+}
+
+// TestCollectSymbols checks the types, their fields and method sets, functions and packages
+func (s *VerifyTestSuite) TestCollectSymbols() {
+	s.Equal(map[string]bool{"users": true, "store": true}, s.symbols.Packages)
+	s.Equal(map[string]bool{"NewUserService": true}, s.symbols.Funcs)
+	s.Equal([]string{"Base", "Memory", "Repository", "User", "UserService"}, s.symbols.ExportedTypes())
+
+	cache := s.symbols.Types["cache"]
+	s.Require().NotNil(cache)
+	s.False(cache.Exported)
+	s.Equal("users", cache.Package)
+
+	// Pointer receiver methods and methods promoted from embedded types are in the method set
+	user := s.symbols.Types["User"]
+	s.Equal(map[string]bool{"Base": true, "Name": true, "email": true}, user.Fields)
+	s.Equal(map[string]bool{"Age": true, "Rename": true, "normalize": true}, user.Methods)
+	s.True(user.HasMember("email"))
+	s.True(user.HasMember("Age"))
+	s.False(user.HasMember("Delete"))
+
+	repo := s.symbols.Types["Repository"]
+	s.True(repo.Interface)
+	s.Equal(map[string]bool{"Get": true}, repo.Methods)
+
+	// Fields of types from unresolved imports are still collected
+	s.True(s.symbols.Types["UserService"].HasMember("audit"))
+
+	s.True(s.symbols.HasMethod("Get"))
+	s.False(s.symbols.HasMethod("NewUserService"))
+
+	_, err := CollectSymbols([]SourceFile{{Path: "broken.go", Content: "package broken\nfunc {"}})
+	s.ErrorContains(err, "failed to parse broken.go")
+}
+
+// TestClassDiagram checks unknown classes and members, constructors, package qualified and
+// external classes and the coverage of exported types
+func (s *VerifyTestSuite) TestClassDiagram() {
+	report := s.verify(`classDiagram
+  class UserService {
+    -repo Repository
+    +NewUserService(repo Repository) *UserService
+    +Find(id string) (*User, error)
+    +Delete(id string) error
+  }
+  class User {
+    +string Name
+    -email string
+    +Age() time.Duration
+  }
+  class store_Memory
+  class Ghost
+  class http.Client
+  UserService --> User`)
+
+	s.True(report.Supported)
+	s.False(report.OK())
+	s.Equal([]Finding{
+		{Kind: "member", Entity: "UserService.+Delete(id string) error", Line: 6},
+		{Kind: "class", Entity: "Ghost", Line: 14},
+	}, report.Hallucinated)
+	s.Equal(11, report.Checked)
+	s.Equal(9, report.Matched)
+	s.Equal([]string{"Base", "Repository"}, report.MissingTypes)
+	s.InDelta(60.0, report.Coverage, 0.01)
+	s.Equal(`Verification: 9/11 entities found in the code, 60% of exported types covered
+Not found in the code:
+  - line 6: member UserService.+Delete(id string) error
+  - line 14: class Ghost
+Exported types missing from the diagram: Base, Repository`, report.String())
+}
+
+// TestUnexportedMembers checks that unexported fields and methods are found, including those
+// of unexported types
+func (s *VerifyTestSuite) TestUnexportedMembers() {
+	report := s.verify(`classDiagram
+  class User {
+    -normalize()
+  }
+  class cache {
+    -entries map[string]*User
+    -size int
+  }`)
+
+	s.Equal([]Finding{{Kind: "member", Entity: "cache.-size int", Line: 7}}, report.Hallucinated)
+}
+
+// TestSequenceDiagram checks participants by ID or alias and the called methods
+func (s *VerifyTestSuite) TestSequenceDiagram() {
+	report := s.verify(`sequenceDiagram
+  actor Admin
+  participant S as User Service
+  participant repo as Repository
+  participant Mailer
+  Admin->>S: Find(id)
+  S->>repo: Get(id)
+  repo-->>S: user
+  S->>Mailer: users.Notify(user)`)
+
+	s.True(report.Supported)
+	s.Equal([]Finding{
+		{Kind: "participant", Entity: "Mailer", Line: 5},
+		{Kind: "message", Entity: "S->Mailer: users.Notify(user)", Line: 9},
+	}, report.Hallucinated)
+	s.Equal(6, report.Checked)
+}
+
+// TestUnsupported checks that other diagram kinds aren't verified
+func (s *VerifyTestSuite) TestUnsupported() {
+	report := s.verify("flowchart TD\n  A --> B")
+
+	s.False(report.Supported)
+	s.True(report.OK())
+	s.Equal("Verification: only class and sequence diagrams can be verified", report.String())
+}
+
+// TestVerifyTestSuite runs the verification test suite
+func TestVerifyTestSuite(t *testing.T) {
+	suite.Run(t, new(VerifyTestSuite))
+}
@@ -0,0 +1,97 @@
+package mermaid
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Annotate copies the presentation proposed for a class diagram onto an authoritative
+// skeleton: class labels, namespaces, notes and relation labels and cardinalities.
+// Classes, members and relations proposed that don't exist in the skeleton are rejected
+// and returned as descriptions; the skeleton's own classes, members and edges are never
+// changed. The skeleton is modified in place.
+func Annotate(skeleton, proposed *Diagram) []string {
+	var rejected []string
+
+	for _, c := range proposed.Classes {
+		target := skeleton.Class(c.ID)
+		if target == nil {
+			// Classes only named by a relation are reported with the relation
+			if c.Explicit || len(c.Members) > 0 {
+				rejected = append(rejected, fmt.Sprintf("class %s", c.ID))
+			}
+			continue
+		}
+		if c.Label != "" {
+			target.Label = c.Label
+		}
+		for _, m := range c.Members {
+			if !hasMember(target, m) {
+				rejected = append(rejected, fmt.Sprintf("member %s.%s", c.ID, strings.TrimSpace(m.Text)))
+			}
+		}
+	}
+
+	for _, ns := range proposed.Namespaces {
+		var classes []string
+		for _, id := range ns.Classes {
+			target := skeleton.Class(id)
+			if target == nil || target.Namespace != "" {
+				continue
+			}
+			target.Namespace = ns.Name
+			classes = append(classes, id)
+		}
+		if len(classes) > 0 && skeleton.Namespace(ns.Name) == nil {
+			skeleton.Namespaces = append(skeleton.Namespaces, &Namespace{Name: ns.Name, Classes: classes})
+		}
+	}
+
+	for _, r := range proposed.Relations {
+		target := findRelation(skeleton, r.From, r.To)
+		if target == nil {
+			rejected = append(rejected, fmt.Sprintf("relation %s", formatRelation(r)))
+			continue
+		}
+		if r.Label != "" {
+			target.Label = r.Label
+		}
+		// Cardinalities only make sense in the skeleton's direction
+		if target.From == r.From {
+			target.FromCardinality = r.FromCardinality
+			target.ToCardinality = r.ToCardinality
+		}
+	}
+
+	for _, n := range proposed.Notes {
+		if n.For != "" && skeleton.Class(n.For) == nil {
+			rejected = append(rejected, fmt.Sprintf("note for %s", n.For))
+			continue
+		}
+		skeleton.Notes = append(skeleton.Notes, &Note{For: n.For, Text: n.Text})
+	}
+
+	return rejected
+}
+
+// hasMember reports whether the class has a member with one of the member's candidate names
+func hasMember(c *ClassEntity, member *ClassMember) bool {
+	for _, m := range c.Members {
+		for _, name := range member.Names() {
+			if m.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findRelation returns the relation between two classes in either direction or nil
+func findRelation(d *Diagram, a, b string) *Relation {
+	for _, r := range d.Relations {
+		if (r.From == a && r.To == b) || (r.From == b && r.To == a) {
+			return r
+		}
+	}
+	return nil
+}
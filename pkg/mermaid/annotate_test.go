@@ -0,0 +1,134 @@
+package mermaid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// AnnotateTestSuite is a test suite for annotating a skeleton with a proposed diagram
+type AnnotateTestSuite struct {
+	suite.Suite
+}
+
+const annotateSkeleton = `classDiagram
+  class OrderStore {
+    -orders map[string]*Order
+    +Save(item *Order) error
+  }
+  class Order {
+    +ID string
+  }
+  class Reader {
+    <<interface>>
+  }
+  OrderStore --> Order
+  OrderStore ..|> Reader`
+
+// annotate parses both diagrams, annotates the skeleton and returns it with the rejections
+func (s *AnnotateTestSuite) annotate(proposed string) (*Diagram, []string) {
+	skeleton, err := Parse(annotateSkeleton)
+	s.Require().NoError(err)
+	annotations, err := Parse(proposed)
+	s.Require().NoError(err)
+	return skeleton, Annotate(skeleton, annotations)
+}
+
+// TestCopiesPresentation checks that labels, namespaces, notes and cardinalities are copied
+func (s *AnnotateTestSuite) TestCopiesPresentation() {
+	skeleton, rejected := s.annotate(`classDiagram
+  namespace store {
+    class OrderStore["Order store"]
+  }
+  class Order {
+    +ID string
+  }
+  Order "*" <-- "1" OrderStore : keeps
+  OrderStore ..|> Reader : reads
+  note for Order "Placed by customers"
+  note "Orders are kept in memory"`)
+
+	s.Empty(rejected)
+	s.Equal(`classDiagram
+  namespace store {
+    class OrderStore["Order store"] {
+      -orders map[string]*Order
+      +Save(item *Order) error
+    }
+  }
+  class Order {
+    +ID string
+  }
+  class Reader {
+    <<interface>>
+  }
+  OrderStore --> Order : keeps
+  OrderStore ..|> Reader : reads
+  note for Order "Placed by customers"
+  note "Orders are kept in memory"`, skeleton.String())
+}
+
+// TestCardinalities checks that cardinalities are only copied in the skeleton's direction
+func (s *AnnotateTestSuite) TestCardinalities() {
+	skeleton, rejected := s.annotate(`classDiagram
+  OrderStore "1" --> "*" Order
+  Reader "1" <|.. "1" OrderStore`)
+
+	s.Empty(rejected)
+	s.Equal("1", skeleton.Relations[0].FromCardinality)
+	s.Equal("*", skeleton.Relations[0].ToCardinality)
+	s.Empty(skeleton.Relations[1].FromCardinality)
+	s.Empty(skeleton.Relations[1].ToCardinality)
+}
+
+// TestRejectsAdditions checks that classes, members, relations and notes that aren't in the
+// skeleton are rejected and leave the skeleton unchanged
+func (s *AnnotateTestSuite) TestRejectsAdditions() {
+	skeleton, rejected := s.annotate(`classDiagram
+  class Order {
+    +ID string
+    +Total() int
+  }
+  class Customer {
+    +Name string
+  }
+  class Ghost
+  Order --> Item
+  note for Customer "Buys orders"`)
+
+	s.Equal([]string{
+		"member Order.+Total() int",
+		"class Customer",
+		"class Ghost",
+		"relation Order --> Item",
+		"note for Customer",
+	}, rejected)
+	s.Equal(annotateSkeleton, skeleton.String())
+}
+
+// TestKeepsDroppedElements checks that classes, members and relations missing from the proposal
+// stay in the skeleton
+func (s *AnnotateTestSuite) TestKeepsDroppedElements() {
+	skeleton, rejected := s.annotate(`classDiagram
+  class Order["Placed order"]`)
+
+	s.Empty(rejected)
+	s.Len(skeleton.Classes, 3)
+	s.Len(skeleton.Class("OrderStore").Members, 2)
+	s.Len(skeleton.Relations, 2)
+	s.Equal("Placed order", skeleton.Class("Order").Label)
+}
+
+// TestMembersWrittenTypeFirst checks that fields written as "Type Name" match the skeleton
+func (s *AnnotateTestSuite) TestMembersWrittenTypeFirst() {
+	_, rejected := s.annotate(`classDiagram
+  class Order {
+    +string ID
+  }`)
+	s.Empty(rejected)
+}
+
+// TestAnnotateTestSuite runs the annotate test suite
+func TestAnnotateTestSuite(t *testing.T) {
+	suite.Run(t, new(AnnotateTestSuite))
+}
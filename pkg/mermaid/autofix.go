@@ -0,0 +1,408 @@
+package mermaid
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// FixRule is a deterministic fix for a common Mermaid mistake
+type FixRule struct {
+	Name        string
+	Description string
+	// Kinds limits the rule to diagram kinds, all kinds when empty
+	Kinds []DiagramKind
+	Apply func(lines []string) []string
+}
+
+// FixRules are the rules applied by AutoFix, in order
+var FixRules = []FixRule{
+	{
+		Name:        "strip-markdown",
+		Description: "Remove stray markdown fences and prose before the diagram header",
+		Apply:       stripMarkdown,
+	},
+	{
+		Name:        "percent-comments",
+		Description: "Convert % comments to %%",
+		Apply:       percentComments,
+	},
+	{
+		Name:        "quote-labels",
+		Description: "Quote flowchart node and edge labels containing parentheses or brackets",
+		Kinds:       []DiagramKind{KindFlowchart},
+		Apply:       quoteLabels,
+	},
+	{
+		Name:        "reserved-words",
+		Description: "Capitalize flowchart nodes named end, which would close a subgraph",
+		Kinds:       []DiagramKind{KindFlowchart},
+		Apply:       reservedWords,
+	},
+	{
+		Name:        "sequence-arrows",
+		Description: "Replace flowchart style and malformed arrows in sequence diagram messages",
+		Kinds:       []DiagramKind{KindSequence},
+		Apply:       sequenceArrows,
+	},
+	{
+		Name:        "close-class-blocks",
+		Description: "Close class blocks that are missing their closing brace",
+		Kinds:       []DiagramKind{KindClass},
+		Apply:       closeClassBlocks,
+	},
+}
+
+// AutoFix applies the FixRules to a diagram and returns the fixed diagram and the names
+// of the rules that changed it. Surrounding ```mermaid fences are kept.
+func AutoFix(diagram string) (string, []string) {
+	body := diagram
+	wrapped := strings.HasPrefix(body, "```mermaid\n") && strings.HasSuffix(body, "\n```")
+	if wrapped {
+		body = strings.TrimSuffix(strings.TrimPrefix(body, "```mermaid\n"), "\n```")
+	}
+
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	var applied []string
+	for _, rule := range FixRules {
+		if len(rule.Kinds) > 0 && !containsKind(rule.Kinds, detectKind(lines)) {
+			continue
+		}
+		fixed := rule.Apply(append([]string(nil), lines...))
+		if strings.Join(fixed, "\n") != strings.Join(lines, "\n") {
+			applied = append(applied, rule.Name)
+			lines = fixed
+		}
+	}
+
+	body = strings.Join(lines, "\n")
+	if wrapped {
+		body = FormatOutput(body)
+	}
+	return body, applied
+}
+
+func containsKind(kinds []DiagramKind, kind DiagramKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// detectKind returns the kind declared by the first header line
+func detectKind(lines []string) DiagramKind {
+	for _, line := range lines {
+		if kind, ok := headerKind(strings.TrimSpace(line)); ok {
+			return kind
+		}
+	}
+	return KindOther
+}
+
+// headerKind reports whether the line is a diagram header and its kind
+func headerKind(line string) (DiagramKind, bool) {
+	word := line
+	if idx := strings.IndexFunc(line, unicode.IsSpace); idx >= 0 {
+		word = line[:idx]
+	}
+	switch word {
+	case "classDiagram", "classDiagram-v2":
+		return KindClass, true
+	case "flowchart", "graph":
+		return KindFlowchart, true
+	case "sequenceDiagram":
+		return KindSequence, true
+	case "stateDiagram", "stateDiagram-v2", "erDiagram", "journey", "gantt", "pie",
+		"requirementDiagram", "gitGraph", "mindmap", "timeline", "quadrantChart":
+		return KindOther, true
+	}
+	return KindOther, false
+}
+
+func stripMarkdown(lines []string) []string {
+	header := -1
+	for i, line := range lines {
+		if _, ok := headerKind(strings.TrimSpace(line)); ok {
+			header = i
+			break
+		}
+	}
+
+	var fixed []string
+	inFrontMatter := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			continue
+		}
+		if i < header {
+			// Keep front matter, directives and comments, drop prose
+			if trimmed == "---" {
+				inFrontMatter = !inFrontMatter
+			} else if trimmed != "" && !inFrontMatter && !strings.HasPrefix(trimmed, "%%") {
+				continue
+			}
+		}
+		fixed = append(fixed, line)
+	}
+	return fixed
+}
+
+func percentComments(lines []string) []string {
+	for i, line := range lines {
+		trimmed := strings.TrimLeftFunc(line, unicode.IsSpace)
+		if strings.HasPrefix(trimmed, "%") && !strings.HasPrefix(trimmed, "%%") {
+			indent := line[:len(line)-len(trimmed)]
+			lines[i] = indent + "%" + trimmed
+		}
+	}
+	return lines
+}
+
+// labelChars are the characters that must be quoted inside a label
+const labelChars = "()[]{}"
+
+// compoundShapes are the openings of node shapes made of two delimiters
+var compoundShapes = []string{"[[", "[(", "[/", "[\\", "((", "([", "{{"}
+
+func quoteLabels(lines []string) []string {
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "%%") || strings.HasPrefix(trimmed, "subgraph") ||
+			strings.HasPrefix(trimmed, "style") || strings.HasPrefix(trimmed, "classDef") || strings.HasPrefix(trimmed, "click") {
+			continue
+		}
+		lines[i] = quoteLine(line)
+	}
+	return lines
+}
+
+// quoteLine quotes the node labels and |edge labels| of a flowchart line
+func quoteLine(line string) string {
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '"':
+			end := strings.IndexByte(line[i+1:], '"')
+			if end < 0 {
+				b.WriteString(line[i:])
+				return b.String()
+			}
+			b.WriteString(line[i : i+end+2])
+			i += end + 1
+		case c == '|':
+			end := strings.IndexByte(line[i+1:], '|')
+			if end < 0 {
+				b.WriteString(line[i:])
+				return b.String()
+			}
+			b.WriteString("|" + quoteLabel(line[i+1:i+1+end]) + "|")
+			i += end + 1
+		case strings.IndexByte("[({", c) >= 0 && i > 0 && isIDChar(line[i-1]):
+			if isCompoundShape(line[i:]) {
+				b.WriteByte(c)
+				continue
+			}
+			end := matchingBracket(line, i)
+			if end < 0 {
+				b.WriteString(line[i:])
+				return b.String()
+			}
+			b.WriteByte(c)
+			b.WriteString(quoteLabel(line[i+1 : end]))
+			b.WriteByte(line[end])
+			i = end
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func isIDChar(c byte) bool {
+	return c == '_' || c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isCompoundShape(s string) bool {
+	for _, shape := range compoundShapes {
+		if strings.HasPrefix(s, shape) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchingBracket returns the index of the bracket closing the one at start, counting all
+// bracket kinds and skipping quoted text, or -1
+func matchingBracket(line string, start int) int {
+	depth := 0
+	inQuote := false
+	for i := start; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case strings.IndexByte("[({", c) >= 0:
+			depth++
+		case strings.IndexByte("])}", c) >= 0:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func quoteLabel(label string) string {
+	trimmed := strings.TrimSpace(label)
+	if strings.HasPrefix(trimmed, "\"") || !strings.ContainsAny(trimmed, labelChars) {
+		return label
+	}
+	return "\"" + strings.ReplaceAll(trimmed, "\"", "#quot;") + "\""
+}
+
+func reservedWords(lines []string) []string {
+	endRe := regexp.MustCompile(`\bend\b`)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "end" || strings.HasPrefix(trimmed, "%%") {
+			continue
+		}
+		lines[i] = mapOutsideLabels(line, func(segment string) string {
+			return endRe.ReplaceAllString(segment, "End")
+		})
+	}
+	return lines
+}
+
+// mapOutsideLabels applies fn to the parts of a flowchart line that aren't quoted,
+// bracketed or |edge labels|
+func mapOutsideLabels(line string, fn func(string) string) string {
+	var b strings.Builder
+	start := 0
+	for i := 0; i < len(line); i++ {
+		end := -1
+		switch line[i] {
+		case '"':
+			if idx := strings.IndexByte(line[i+1:], '"'); idx >= 0 {
+				end = i + 1 + idx
+			}
+		case '|':
+			if idx := strings.IndexByte(line[i+1:], '|'); idx >= 0 {
+				end = i + 1 + idx
+			}
+		case '[', '(', '{':
+			end = matchingBracket(line, i)
+		default:
+			continue
+		}
+		if end < 0 {
+			break
+		}
+		b.WriteString(fn(line[start:i]))
+		b.WriteString(line[i : end+1])
+		start = end + 1
+		i = end
+	}
+	b.WriteString(fn(line[start:]))
+	return b.String()
+}
+
+var (
+	flowLabelArrowRe = regexp.MustCompile(`\s*-{2,3}>\|([^|]*)\|\s*`)
+	thickArrowRe     = regexp.MustCompile(`\s*={1,2}>\s*`)
+	longDottedRe     = regexp.MustCompile(`-{3,}>+`)
+	extraHeadRe      = regexp.MustCompile(`(-{1,2})>{3,}`)
+	bareDottedRe     = regexp.MustCompile(`-->([^>]|$)`)
+)
+
+// sequenceArrows fixes the arrows of sequence diagram messages. Only the part before the
+// message text is changed.
+func sequenceArrows(lines []string) []string {
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "%%") || strings.HasPrefix(strings.ToLower(trimmed), "note ") {
+			continue
+		}
+
+		head, text := line, ""
+		if idx := strings.Index(line, ":"); idx >= 0 {
+			head, text = line[:idx], line[idx:]
+		}
+
+		if m := flowLabelArrowRe.FindStringSubmatch(head); m != nil {
+			head = flowLabelArrowRe.ReplaceAllString(head, "->>")
+			if text == "" {
+				text = ": " + strings.TrimSpace(m[1])
+			}
+		}
+		head = thickArrowRe.ReplaceAllString(head, "->>")
+		head = longDottedRe.ReplaceAllString(head, "-->>")
+		head = extraHeadRe.ReplaceAllString(head, "$1>>")
+		// --> draws a dotted line without an arrowhead, generated diagrams mean a reply
+		head = bareDottedRe.ReplaceAllString(head, "-->>$1")
+
+		lines[i] = head + text
+	}
+	return lines
+}
+
+var (
+	classBlockOpenRe     = regexp.MustCompile(`^\s*(class|namespace)\s+.*\{\s*$`)
+	classBlockRelationRe = regexp.MustCompile(`^[A-Za-z_][\w.~]*\s*("[^"]*"\s*)?(<\|--|--\|>|\*--|--\*|o--|--o|-->|<--|\.\.\|>|<\|\.\.|\.\.>|<\.\.|--|\.\.)\s*("[^"]*"\s*)?[A-Za-z_]`)
+)
+
+// closeClassBlocks closes a class block before the next class, namespace, relation or note
+// statement when its closing brace is missing, and closes blocks still open at the end
+func closeClassBlocks(lines []string) []string {
+	type block struct {
+		indent    string
+		namespace bool
+	}
+	var stack []block
+	var fixed []string
+
+	closeClass := func() {
+		if len(stack) > 0 && !stack[len(stack)-1].namespace {
+			fixed = append(fixed, stack[len(stack)-1].indent+"}")
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		indent := line[:len(line)-len(strings.TrimLeftFunc(line, unicode.IsSpace))]
+		inClass := len(stack) > 0 && !stack[len(stack)-1].namespace
+
+		switch {
+		case trimmed == "}":
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case classBlockOpenRe.MatchString(line):
+			closeClass()
+			stack = append(stack, block{indent: indent, namespace: strings.HasPrefix(trimmed, "namespace")})
+		case inClass && (strings.HasPrefix(trimmed, "class ") || strings.HasPrefix(trimmed, "note ") ||
+			classBlockRelationRe.MatchString(trimmed)):
+			closeClass()
+		}
+		fixed = append(fixed, line)
+	}
+
+	// Close the remaining blocks before trailing blank lines
+	end := len(fixed)
+	for end > 0 && strings.TrimSpace(fixed[end-1]) == "" {
+		end--
+	}
+	trailing := append([]string(nil), fixed[end:]...)
+	fixed = fixed[:end]
+	for len(stack) > 0 {
+		fixed = append(fixed, stack[len(stack)-1].indent+"}")
+		stack = stack[:len(stack)-1]
+	}
+	return append(fixed, trailing...)
+}
@@ -0,0 +1,63 @@
+package mermaid
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// AutoFixTestSuite is a test suite for the deterministic fix rules
+type AutoFixTestSuite struct {
+	suite.Suite
+}
+
+// readFixture reads a file from testdata/autofix
+func (s *AutoFixTestSuite) readFixture(name string) string {
+	content, err := os.ReadFile(filepath.Join("testdata", "autofix", name))
+	s.Require().NoError(err)
+	return string(content)
+}
+
+// TestRuleFixtures checks every rule against its input and golden fixture
+func (s *AutoFixTestSuite) TestRuleFixtures() {
+	for _, rule := range FixRules {
+		s.Run(rule.Name, func() {
+			input := s.readFixture(rule.Name + ".input.mmd")
+			golden := s.readFixture(rule.Name + ".golden.mmd")
+
+			fixed, applied := AutoFix(input)
+			s.Equal(golden, fixed)
+			s.Contains(applied, rule.Name)
+
+			// Fixing is idempotent
+			again, applied := AutoFix(fixed)
+			s.Equal(fixed, again)
+			s.Empty(applied)
+		})
+	}
+}
+
+// TestValidDiagramUnchanged checks that a valid diagram is left alone
+func (s *AutoFixTestSuite) TestValidDiagramUnchanged() {
+	diagram := s.readFixture("valid.mmd")
+
+	fixed, applied := AutoFix(diagram)
+	s.Equal(diagram, fixed)
+	s.Empty(applied)
+}
+
+// TestKeepsMermaidFences checks that the fences around a formatted diagram are kept
+func (s *AutoFixTestSuite) TestKeepsMermaidFences() {
+	diagram := FormatOutput("classDiagram\n  % Services\n  class Service")
+
+	fixed, applied := AutoFix(diagram)
+	s.Equal(FormatOutput("classDiagram\n  %% Services\n  class Service"), fixed)
+	s.Equal([]string{"percent-comments"}, applied)
+}
+
+// TestAutoFixTestSuite runs the test suite
+func TestAutoFixTestSuite(t *testing.T) {
+	suite.Run(t, new(AutoFixTestSuite))
+}
@@ -0,0 +1,580 @@
+package mermaid
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChangeType is the type of a change between two diagrams
+type ChangeType string
+
+const (
+	// ChangeAdded is an element only found in the new diagram
+	ChangeAdded ChangeType = "added"
+	// ChangeRemoved is an element only found in the old diagram
+	ChangeRemoved ChangeType = "removed"
+	// ChangeModified is an element found in both diagrams with a different definition
+	ChangeModified ChangeType = "changed"
+)
+
+// Change is an element added, removed or changed between two diagrams
+type Change struct {
+	Type ChangeType `json:"type"`
+	// Element is "node", "edge", "subgraph", "class", "member", "relation", "namespace",
+	// "note", "participant" or "message"
+	Element string `json:"element"`
+	ID      string `json:"id"`
+	// Old and New are the definitions of the element in each diagram
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+
+	// oldIndex and newIndex locate edges, relations and events in their diagram, -1 if absent
+	oldIndex, newIndex int
+}
+
+// DiagramDiff is the semantic difference between two diagrams of the same kind.
+// Elements are matched by ID, so reordered statements aren't changes.
+type DiagramDiff struct {
+	Kind    DiagramKind `json:"kind"`
+	Changes []Change    `json:"changes"`
+
+	old, new *Diagram
+}
+
+// Diff compares two parsed diagrams
+func Diff(old, new *Diagram) (*DiagramDiff, error) {
+	if old.Kind != new.Kind {
+		return nil, fmt.Errorf("cannot compare a %s with a %s", old.Kind, new.Kind)
+	}
+
+	dd := &DiagramDiff{Kind: old.Kind, Changes: []Change{}, old: old, new: new}
+	switch old.Kind {
+	case KindFlowchart:
+		dd.diffFlowchart()
+	case KindClass:
+		dd.diffClasses()
+	case KindSequence:
+		dd.diffSequence()
+	default:
+		return nil, fmt.Errorf("only class, flowchart and sequence diagrams can be compared")
+	}
+	return dd, nil
+}
+
+// Empty reports whether the diagrams are equivalent
+func (dd *DiagramDiff) Empty() bool {
+	return len(dd.Changes) == 0
+}
+
+// Count returns the number of changes of a type
+func (dd *DiagramDiff) Count(t ChangeType) int {
+	count := 0
+	for _, c := range dd.Changes {
+		if c.Type == t {
+			count++
+		}
+	}
+	return count
+}
+
+// String formats the changes one per line, prefixed with +, - or ~
+func (dd *DiagramDiff) String() string {
+	if dd.Empty() {
+		return "No changes"
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%d added, %d removed, %d changed\n", dd.Count(ChangeAdded), dd.Count(ChangeRemoved), dd.Count(ChangeModified)))
+	for _, c := range dd.Changes {
+		name := c.Element + " " + c.ID
+		switch c.Type {
+		case ChangeAdded:
+			b.WriteString("+ " + describe(name, c.New) + "\n")
+		case ChangeRemoved:
+			b.WriteString("- " + describe(name, c.Old) + "\n")
+		case ChangeModified:
+			b.WriteString(fmt.Sprintf("~ %s: %s => %s\n", name, c.Old, c.New))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// describe adds the definition to the name of an element unless it's the same
+func describe(name, def string) string {
+	if def == name {
+		return name
+	}
+	return name + ": " + def
+}
+
+func (dd *DiagramDiff) add(t ChangeType, element, id, old, new string, oldIndex, newIndex int) {
+	dd.Changes = append(dd.Changes, Change{
+		Type: t, Element: element, ID: id, Old: old, New: new,
+		oldIndex: oldIndex, newIndex: newIndex,
+	})
+}
+
+// compare records the change of an element matched by ID from its old and new definition,
+// an empty definition means the element is absent
+func (dd *DiagramDiff) compare(element, id, old, new string) {
+	switch {
+	case old == "":
+		dd.add(ChangeAdded, element, id, "", new, -1, -1)
+	case new == "":
+		dd.add(ChangeRemoved, element, id, old, "", -1, -1)
+	case old != new:
+		dd.add(ChangeModified, element, id, old, new, -1, -1)
+	}
+}
+
+// compareKeyed matches elements that can repeat, such as edges between the same nodes,
+// by key and occurrence. Matched elements with a different definition are changed.
+func (dd *DiagramDiff) compareKeyed(element string, oldKeys, newKeys, oldDefs, newDefs []string, id func(key string) string) {
+	pending := make(map[string][]int)
+	for i, key := range newKeys {
+		pending[key] = append(pending[key], i)
+	}
+
+	matched := make([]bool, len(newKeys))
+	for i, key := range oldKeys {
+		if len(pending[key]) == 0 {
+			dd.add(ChangeRemoved, element, id(key), oldDefs[i], "", i, -1)
+			continue
+		}
+		j := pending[key][0]
+		pending[key] = pending[key][1:]
+		matched[j] = true
+		if oldDefs[i] != newDefs[j] {
+			dd.add(ChangeModified, element, id(key), oldDefs[i], newDefs[j], i, j)
+		}
+	}
+	for j, key := range newKeys {
+		if !matched[j] {
+			dd.add(ChangeAdded, element, id(key), "", newDefs[j], -1, j)
+		}
+	}
+}
+
+func (dd *DiagramDiff) diffFlowchart() {
+	for _, sg := range dd.old.Subgraphs {
+		dd.compare("subgraph", sg.ID, subgraphDef(sg), subgraphDef(dd.new.Subgraph(sg.ID)))
+	}
+	for _, sg := range dd.new.Subgraphs {
+		if dd.old.Subgraph(sg.ID) == nil {
+			dd.compare("subgraph", sg.ID, "", subgraphDef(sg))
+		}
+	}
+
+	for _, n := range dd.old.Nodes {
+		if dd.old.Subgraph(n.ID) == nil {
+			dd.compare("node", n.ID, nodeDef(n), nodeDef(dd.new.Node(n.ID)))
+		}
+	}
+	for _, n := range dd.new.Nodes {
+		if dd.old.Node(n.ID) == nil && dd.new.Subgraph(n.ID) == nil {
+			dd.compare("node", n.ID, "", nodeDef(n))
+		}
+	}
+
+	edgeKeys := func(edges []*Edge) (keys, defs []string) {
+		for _, e := range edges {
+			keys = append(keys, e.From+" -> "+e.To)
+			defs = append(defs, formatEdge(e))
+		}
+		return keys, defs
+	}
+	oldKeys, oldDefs := edgeKeys(dd.old.Edges)
+	newKeys, newDefs := edgeKeys(dd.new.Edges)
+	dd.compareKeyed("edge", oldKeys, newKeys, oldDefs, newDefs, func(key string) string { return key })
+}
+
+func subgraphDef(sg *Subgraph) string {
+	if sg == nil {
+		return ""
+	}
+	def := "subgraph " + sg.ID
+	if sg.Label != "" {
+		def += fmt.Sprintf(" [%s]", sg.Label)
+	}
+	if sg.Direction != "" {
+		def += " direction " + sg.Direction
+	}
+	if sg.Parent != "" {
+		def += " in " + sg.Parent
+	}
+	return def
+}
+
+func nodeDef(n *Node) string {
+	if n == nil {
+		return ""
+	}
+	def := formatNode(n)
+	if n.Subgraph != "" {
+		def += " in " + n.Subgraph
+	}
+	return def
+}
+
+func (dd *DiagramDiff) diffClasses() {
+	for _, ns := range dd.old.Namespaces {
+		if dd.new.Namespace(ns.Name) == nil {
+			dd.compare("namespace", ns.Name, "namespace "+ns.Name, "")
+		}
+	}
+	for _, ns := range dd.new.Namespaces {
+		if dd.old.Namespace(ns.Name) == nil {
+			dd.compare("namespace", ns.Name, "", "namespace "+ns.Name)
+		}
+	}
+
+	for _, c := range dd.old.Classes {
+		newClass := dd.new.Class(c.ID)
+		dd.compare("class", c.ID, classDef(c), classDef(newClass))
+		if newClass != nil {
+			dd.diffMembers(c, newClass)
+		}
+	}
+	for _, c := range dd.new.Classes {
+		if dd.old.Class(c.ID) == nil {
+			dd.compare("class", c.ID, "", classDef(c))
+		}
+	}
+
+	relationKeys := func(relations []*Relation) (keys, defs []string) {
+		for _, r := range relations {
+			keys = append(keys, r.From+" -> "+r.To)
+			defs = append(defs, formatRelation(r))
+		}
+		return keys, defs
+	}
+	oldKeys, oldDefs := relationKeys(dd.old.Relations)
+	newKeys, newDefs := relationKeys(dd.new.Relations)
+	dd.compareKeyed("relation", oldKeys, newKeys, oldDefs, newDefs, func(key string) string { return key })
+
+	noteKeys := func(notes []*Note) (keys []string) {
+		for _, n := range notes {
+			if n.For != "" {
+				keys = append(keys, fmt.Sprintf("note for %s %q", n.For, n.Text))
+			} else {
+				keys = append(keys, fmt.Sprintf("note %q", n.Text))
+			}
+		}
+		return keys
+	}
+	oldNotes, newNotes := noteKeys(dd.old.Notes), noteKeys(dd.new.Notes)
+	dd.compareKeyed("note", oldNotes, newNotes, oldNotes, newNotes, func(key string) string { return key })
+}
+
+// classDef summarizes the declaration of a class without its members
+func classDef(c *ClassEntity) string {
+	if c == nil {
+		return ""
+	}
+	def := "class " + c.ID
+	if c.Generic != "" {
+		def += "~" + c.Generic + "~"
+	}
+	if c.Label != "" {
+		def += fmt.Sprintf("[\"%s\"]", c.Label)
+	}
+	for _, a := range c.Annotations {
+		def += fmt.Sprintf(" <<%s>>", a)
+	}
+	if c.Namespace != "" {
+		def += " in " + c.Namespace
+	}
+	return def
+}
+
+// diffMembers matches the methods of a class by name. Fields are written either as
+// "Name Type" or "Type Name", so fields that aren't equal match when they share a word.
+func (dd *DiagramDiff) diffMembers(old, new *ClassEntity) {
+	split := func(members []*ClassMember) (methodKeys, methodDefs []string, fields []*ClassMember) {
+		for _, m := range members {
+			if m.IsMethod {
+				methodKeys = append(methodKeys, m.Name+"()")
+				methodDefs = append(methodDefs, memberDef(m))
+			} else {
+				fields = append(fields, m)
+			}
+		}
+		return methodKeys, methodDefs, fields
+	}
+	oldKeys, oldDefs, oldFields := split(old.Members)
+	newKeys, newDefs, newFields := split(new.Members)
+	dd.compareKeyed("member", oldKeys, newKeys, oldDefs, newDefs, func(key string) string { return old.ID + "." + key })
+
+	matched := make([]bool, len(newFields))
+	match := func(f *ClassMember, equal bool) (int, bool) {
+		for j, candidate := range newFields {
+			if matched[j] {
+				continue
+			}
+			if equal && memberDef(f) == memberDef(candidate) {
+				return j, true
+			}
+			if !equal && sharedName(f, candidate) != "" {
+				return j, true
+			}
+		}
+		return 0, false
+	}
+
+	var unmatched []*ClassMember
+	for _, f := range oldFields {
+		if j, ok := match(f, true); ok {
+			matched[j] = true
+		} else {
+			unmatched = append(unmatched, f)
+		}
+	}
+	for _, f := range unmatched {
+		if j, ok := match(f, false); ok {
+			matched[j] = true
+			dd.add(ChangeModified, "member", old.ID+"."+sharedName(f, newFields[j]), memberDef(f), memberDef(newFields[j]), -1, -1)
+		} else {
+			dd.add(ChangeRemoved, "member", old.ID+"."+fieldName(f), memberDef(f), "", -1, -1)
+		}
+	}
+	for j, f := range newFields {
+		if !matched[j] {
+			dd.add(ChangeAdded, "member", old.ID+"."+fieldName(f), "", memberDef(f), -1, -1)
+		}
+	}
+}
+
+// memberDef is the member text with normalized spacing
+func memberDef(m *ClassMember) string {
+	return strings.Join(strings.Fields(m.Text), " ")
+}
+
+// fieldName is the field text without visibility
+func fieldName(m *ClassMember) string {
+	return strings.TrimLeft(memberDef(m), "+-#~ ")
+}
+
+// sharedName returns a name two fields have in common
+func sharedName(a, b *ClassMember) string {
+	for _, x := range a.Names() {
+		for _, y := range b.Names() {
+			if x != "" && x == y {
+				return x
+			}
+		}
+	}
+	return ""
+}
+
+func (dd *DiagramDiff) diffSequence() {
+	for _, p := range dd.old.Participants {
+		dd.compare("participant", p.ID, participantDef(p), participantDef(dd.new.Participant(p.ID)))
+	}
+	for _, p := range dd.new.Participants {
+		if dd.old.Participant(p.ID) == nil {
+			dd.compare("participant", p.ID, "", participantDef(p))
+		}
+	}
+
+	// Messages and notes are matched by their participants and text, the index is the
+	// position in Events
+	eventKeys := func(events []*SeqEvent, eventType SeqEventType) (keys, defs []string, indexes []int) {
+		for i, e := range events {
+			if e.Type != eventType {
+				continue
+			}
+			if eventType == EventMessage {
+				keys = append(keys, fmt.Sprintf("%s -> %s: %s", e.From, e.To, strings.TrimSpace(e.Text)))
+			} else {
+				keys = append(keys, FormatEvent(e))
+			}
+			defs = append(defs, FormatEvent(e))
+			indexes = append(indexes, i)
+		}
+		return keys, defs, indexes
+	}
+
+	for _, eventType := range []SeqEventType{EventMessage, EventNote} {
+		oldKeys, oldDefs, oldIndexes := eventKeys(dd.old.Events, eventType)
+		newKeys, newDefs, newIndexes := eventKeys(dd.new.Events, eventType)
+
+		start := len(dd.Changes)
+		dd.compareKeyed(string(eventType), oldKeys, newKeys, oldDefs, newDefs, func(key string) string { return key })
+		for i := start; i < len(dd.Changes); i++ {
+			c := &dd.Changes[i]
+			if c.oldIndex >= 0 {
+				c.oldIndex = oldIndexes[c.oldIndex]
+			}
+			if c.newIndex >= 0 {
+				c.newIndex = newIndexes[c.newIndex]
+			}
+		}
+	}
+}
+
+func participantDef(p *Participant) string {
+	if p == nil {
+		return ""
+	}
+	kind := p.Type
+	if kind == "" {
+		kind = "participant"
+	}
+	if p.Alias != "" {
+		return fmt.Sprintf("%s %s as %s", kind, p.ID, p.Alias)
+	}
+	return kind + " " + p.ID
+}
+
+// Colors of the diff diagram
+var diffStyles = map[ChangeType]struct{ classDef, link, rect string }{
+	ChangeAdded:    {"fill:#d4f8d4,stroke:#2da44e", "stroke:#2da44e,stroke-width:2px", "rgb(212, 248, 212)"},
+	ChangeRemoved:  {"fill:#ffd7d5,stroke:#cf222e,stroke-dasharray:5 5", "stroke:#cf222e,stroke-width:2px,stroke-dasharray:5 5", "rgb(255, 215, 213)"},
+	ChangeModified: {"fill:#fff8c5,stroke:#bf8700", "stroke:#bf8700,stroke-width:2px", "rgb(255, 248, 197)"},
+}
+
+var changeTypes = []ChangeType{ChangeAdded, ChangeRemoved, ChangeModified}
+
+// Diagram renders the new diagram with the removed elements of the old one, styled green
+// when added, red when removed and yellow when changed. Flowchart and class diagrams use
+// classDef styles, sequence diagrams highlight messages with rect blocks.
+func (dd *DiagramDiff) Diagram() (*Diagram, error) {
+	// Printing and parsing copies the new diagram
+	d, err := Parse(dd.new.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy diagram: %w", err)
+	}
+
+	switch dd.Kind {
+	case KindFlowchart:
+		dd.styleFlowchart(d)
+	case KindClass:
+		dd.styleClasses(d)
+	case KindSequence:
+		dd.styleSequence(d)
+	}
+	return d, nil
+}
+
+func addClassDefs(d *Diagram) {
+	for _, t := range changeTypes {
+		d.Extra = append(d.Extra, &Statement{Text: fmt.Sprintf("classDef %s %s", t, diffStyles[t].classDef)})
+	}
+}
+
+func (dd *DiagramDiff) styleFlowchart(d *Diagram) {
+	for _, c := range dd.Changes {
+		switch {
+		case c.Element == "node" && c.Type == ChangeRemoved:
+			n := *dd.old.Node(c.ID)
+			n.Comments = nil
+			if d.Subgraph(n.Subgraph) == nil {
+				n.Subgraph = ""
+			}
+			n.CSSClass = string(ChangeRemoved)
+			d.Nodes = append(d.Nodes, &n)
+		case c.Element == "node":
+			d.Node(c.ID).CSSClass = string(c.Type)
+		case c.Element == "edge" && c.Type == ChangeRemoved:
+			e := *dd.old.Edges[c.oldIndex]
+			e.Comments = nil
+			d.Edges = append(d.Edges, &e)
+			d.Extra = append(d.Extra, linkStyle(len(d.Edges)-1, c.Type))
+		case c.Element == "edge":
+			d.Extra = append(d.Extra, linkStyle(c.newIndex, c.Type))
+		}
+	}
+	addClassDefs(d)
+}
+
+func linkStyle(index int, t ChangeType) *Statement {
+	return &Statement{Text: fmt.Sprintf("linkStyle %d %s", index, diffStyles[t].link)}
+}
+
+func (dd *DiagramDiff) styleClasses(d *Diagram) {
+	for _, c := range dd.Changes {
+		switch {
+		case c.Element == "class" && c.Type == ChangeRemoved:
+			class := *dd.old.Class(c.ID)
+			class.Comments = nil
+			if d.Namespace(class.Namespace) == nil {
+				class.Namespace = ""
+			}
+			class.Explicit = true
+			class.CSSClass = string(ChangeRemoved)
+			d.Classes = append(d.Classes, &class)
+		case c.Element == "class":
+			markClass(d, c.ID, c.Type)
+		case c.Element == "member":
+			// Members can't be styled, their class is marked as changed
+			markClass(d, strings.SplitN(c.ID, ".", 2)[0], ChangeModified)
+		case c.Element == "relation" && c.Type == ChangeRemoved:
+			r := *dd.old.Relations[c.oldIndex]
+			r.Comments = nil
+			r.Label = diffLabel(r.Label, c.Type)
+			d.Relations = append(d.Relations, &r)
+		case c.Element == "relation":
+			r := d.Relations[c.newIndex]
+			r.Label = diffLabel(r.Label, c.Type)
+		}
+	}
+	addClassDefs(d)
+}
+
+// markClass styles a class unless it already has a stronger change style
+func markClass(d *Diagram, id string, t ChangeType) {
+	c := d.Class(id)
+	if c == nil || (t == ChangeModified && c.CSSClass == string(ChangeAdded)) {
+		return
+	}
+	c.Explicit = true
+	c.CSSClass = string(t)
+}
+
+// diffLabel marks the label of a relation, which class diagrams can't style
+func diffLabel(label string, t ChangeType) string {
+	if label == "" {
+		return string(t)
+	}
+	return fmt.Sprintf("%s (%s)", label, t)
+}
+
+func (dd *DiagramDiff) styleSequence(d *Diagram) {
+	changed := make(map[int]ChangeType)
+	var removed []*SeqEvent
+	for _, c := range dd.Changes {
+		switch {
+		case c.Element == "participant" && c.Type == ChangeRemoved:
+			p := *dd.old.Participant(c.ID)
+			p.Comments = nil
+			d.Participants = append(d.Participants, &p)
+		case c.Element == "participant":
+		case c.Type == ChangeRemoved:
+			e := *dd.old.Events[c.oldIndex]
+			e.Comments = nil
+			// Activations of removed messages would unbalance the diagram
+			e.Activation = ""
+			removed = append(removed, &e)
+		default:
+			changed[c.newIndex] = c.Type
+		}
+	}
+
+	var events []*SeqEvent
+	highlight := func(t ChangeType, inner ...*SeqEvent) {
+		events = append(events, &SeqEvent{Type: EventBlockStart, Keyword: "rect", Text: diffStyles[t].rect})
+		events = append(events, inner...)
+		events = append(events, &SeqEvent{Type: EventBlockEnd})
+	}
+	for i, e := range d.Events {
+		if t, ok := changed[i]; ok {
+			highlight(t, e)
+		} else {
+			events = append(events, e)
+		}
+	}
+	if len(removed) > 0 {
+		highlight(ChangeRemoved, removed...)
+	}
+	d.Events = events
+}
@@ -0,0 +1,111 @@
+package mermaid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// DiffTestSuite is a test suite for the semantic diff
+type DiffTestSuite struct {
+	suite.Suite
+}
+
+func (s *DiffTestSuite) diff(old, new string) *DiagramDiff {
+	oldDiagram, err := Parse(old)
+	s.Require().NoError(err)
+	newDiagram, err := Parse(new)
+	s.Require().NoError(err)
+
+	dd, err := Diff(oldDiagram, newDiagram)
+	s.Require().NoError(err)
+	return dd
+}
+
+// TestReorderedIsEmpty checks that reordering statements isn't a change
+func (s *DiffTestSuite) TestReorderedIsEmpty() {
+	dd := s.diff(
+		"flowchart TD\n  A[Start] --> B\n  B --> C[End]\n",
+		"flowchart TD\n  C[End]\n  B --> C\n  A[Start] --> B\n",
+	)
+	s.True(dd.Empty())
+	s.Equal("No changes", dd.String())
+}
+
+// TestFlowchart checks node and edge changes and the diff diagram styles
+func (s *DiffTestSuite) TestFlowchart() {
+	dd := s.diff(
+		"flowchart TD\n  A[Start] --> B{Valid?}\n  B --> D[Fix]\n",
+		"flowchart TD\n  A[Start] --> B{Is it valid?}\n  B -.-> A\n",
+	)
+	s.Equal(`1 added, 2 removed, 1 changed
+~ node B: B{Valid?} => B{Is it valid?}
+- node D: D[Fix]
+- edge B -> D: B --> D
++ edge B -> A: B -.-> A`, dd.String())
+
+	d, err := dd.Diagram()
+	s.Require().NoError(err)
+	s.Equal(`flowchart TD
+  A[Start]
+  B{Is it valid?}:::changed
+  D[Fix]:::removed
+  A --> B
+  B -.-> A
+  B --> D
+  linkStyle 2 stroke:#cf222e,stroke-width:2px,stroke-dasharray:5 5
+  linkStyle 1 stroke:#2da44e,stroke-width:2px
+  classDef added fill:#d4f8d4,stroke:#2da44e
+  classDef removed fill:#ffd7d5,stroke:#cf222e,stroke-dasharray:5 5
+  classDef changed fill:#fff8c5,stroke:#bf8700`, d.String())
+}
+
+// TestClassMembers checks that fields match by a shared name and methods by name
+func (s *DiffTestSuite) TestClassMembers() {
+	dd := s.diff(
+		"classDiagram\n  class Service {\n    +Name string\n    +Run() error\n    -debug bool\n  }\n",
+		"classDiagram\n  class Service {\n    +Run(ctx Context) error\n    +Name int\n  }\n",
+	)
+	s.Equal(`0 added, 1 removed, 2 changed
+~ member Service.Run(): +Run() error => +Run(ctx Context) error
+~ member Service.Name: +Name string => +Name int
+- member Service.debug bool: -debug bool`, dd.String())
+}
+
+// TestSequence checks message changes and their highlighting
+func (s *DiffTestSuite) TestSequence() {
+	dd := s.diff(
+		"sequenceDiagram\n  A->>B: hello\n  A->>B: bye\n",
+		"sequenceDiagram\n  A-)B: hello\n  A->>C: new\n",
+	)
+	s.Equal(ChangeModified, dd.Changes[1].Type)
+
+	d, err := dd.Diagram()
+	s.Require().NoError(err)
+	s.Equal(`sequenceDiagram
+  rect rgb(255, 248, 197)
+    A-)B: hello
+  end
+  rect rgb(212, 248, 212)
+    A->>C: new
+  end
+  rect rgb(255, 215, 213)
+    A->>B: bye
+  end`, d.String())
+}
+
+// TestKindMismatch checks that diagrams of different kinds can't be compared
+func (s *DiffTestSuite) TestKindMismatch() {
+	oldDiagram, err := Parse("flowchart TD\n  A --> B\n")
+	s.Require().NoError(err)
+	newDiagram, err := Parse("sequenceDiagram\n  A->>B: hi\n")
+	s.Require().NoError(err)
+
+	_, err = Diff(oldDiagram, newDiagram)
+	s.Error(err)
+}
+
+// TestDiffTestSuite runs the diff test suite
+func TestDiffTestSuite(t *testing.T) {
+	suite.Run(t, new(DiffTestSuite))
+}
@@ -0,0 +1,41 @@
+package mermaid
+
+import (
+	"strings"
+)
+
+// FormatOptions configures the canonical layout of Format
+type FormatOptions struct {
+	// SortMembers orders class members, fields before methods, each alphabetically
+	SortMembers bool
+	// SortNodes orders classes and flowchart nodes by ID
+	SortNodes bool
+}
+
+// Format parses a diagram and prints it with two space indentation, one statement per
+// line and single spaces around arrows. The result ends with a newline, a diagram wrapped
+// in a ```mermaid fence stays wrapped.
+func Format(src string, opts FormatOptions) (string, error) {
+	body := strings.TrimSpace(strings.ReplaceAll(src, "\r\n", "\n"))
+	wrapped := strings.HasPrefix(body, "```mermaid\n") && strings.HasSuffix(body, "\n```")
+	if wrapped {
+		body = strings.TrimSuffix(strings.TrimPrefix(body, "```mermaid\n"), "\n```")
+	}
+
+	d, err := Parse(body)
+	if err != nil {
+		return "", err
+	}
+
+	formatted := d.Format(opts)
+	if wrapped {
+		formatted = FormatOutput(formatted)
+	}
+	return formatted + "\n", nil
+}
+
+// Format renders the diagram as Mermaid syntax with canonical layout
+func (d *Diagram) Format(opts FormatOptions) string {
+	p := &printer{indent: "  ", sortMembers: opts.SortMembers, sortNodes: opts.SortNodes}
+	return p.print(d)
+}
@@ -0,0 +1,62 @@
+package mermaid
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// FormatTestSuite is a test suite for the formatter
+type FormatTestSuite struct {
+	suite.Suite
+}
+
+// TestFixtures formats every input fixture and compares it with its golden file
+func (s *FormatTestSuite) TestFixtures() {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "format", "*.input.mmd"))
+	s.Require().NoError(err)
+	s.Require().NotEmpty(inputs)
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".input.mmd")
+		s.Run(name, func() {
+			src, err := os.ReadFile(input)
+			s.Require().NoError(err)
+			golden, err := os.ReadFile(filepath.Join("testdata", "format", name+".golden.mmd"))
+			s.Require().NoError(err)
+
+			formatted, err := Format(string(src), FormatOptions{})
+			s.Require().NoError(err)
+			s.Equal(string(golden), formatted)
+
+			// Formatting is idempotent
+			again, err := Format(formatted, FormatOptions{})
+			s.Require().NoError(err)
+			s.Equal(formatted, again)
+		})
+	}
+}
+
+// TestSorting checks the optional member and node ordering
+func (s *FormatTestSuite) TestSorting() {
+	src := "classDiagram\n  class B {\n    +Run()\n    +Name string\n  }\n  class A\n"
+
+	formatted, err := Format(src, FormatOptions{SortMembers: true, SortNodes: true})
+	s.Require().NoError(err)
+	s.Equal("classDiagram\n  class A\n  class B {\n    +Name string\n    +Run()\n  }\n", formatted)
+}
+
+// TestKeepsMermaidFences checks that a fenced diagram stays fenced
+func (s *FormatTestSuite) TestKeepsMermaidFences() {
+	formatted, err := Format("```mermaid\nflowchart TD\nA-->B\n```", FormatOptions{})
+	s.Require().NoError(err)
+	s.Equal("```mermaid\nflowchart TD\n  A --> B\n```\n", formatted)
+}
+
+// TestFormatTestSuite runs the formatter test suite
+func TestFormatTestSuite(t *testing.T) {
+	suite.Run(t, new(FormatTestSuite))
+}
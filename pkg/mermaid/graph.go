@@ -0,0 +1,460 @@
+package mermaid
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// GraphKind is the kind of diagram a Graph describes
+type GraphKind string
+
+const (
+	// FlowchartGraph is a flowchart of nodes and edges
+	FlowchartGraph GraphKind = "flowchart"
+	// ClassGraph is a class diagram of classes, members and relations
+	ClassGraph GraphKind = "class"
+	// SequenceGraph is a sequence diagram of participants and messages
+	SequenceGraph GraphKind = "sequence"
+)
+
+//go:embed graph.schema.json
+var graphSchema []byte
+
+// GraphSchema returns the JSON schema of a Graph
+func GraphSchema() json.RawMessage {
+	return json.RawMessage(graphSchema)
+}
+
+// GraphKindFor returns the kind of graph the LLM describes a diagram type with, the diagram
+// types without one are generated as Mermaid text only
+func GraphKindFor(dt DiagramType) (GraphKind, bool) {
+	switch dt {
+	case Basic, Class, Project:
+		return ClassGraph, true
+	case Sequence:
+		return SequenceGraph, true
+	case Flowchart, Config, Adapters:
+		return FlowchartGraph, true
+	}
+	return "", false
+}
+
+// Graph is a diagram described as JSON, so that it can be rendered into Mermaid without
+// syntax errors. It matches the schema returned by GraphSchema.
+type Graph struct {
+	Kind      GraphKind `json:"kind"`
+	Direction string    `json:"direction,omitempty"`
+
+	// Flowcharts
+	Nodes []GraphNode `json:"nodes,omitempty"`
+	Edges []GraphEdge `json:"edges,omitempty"`
+
+	// Class diagrams
+	Classes   []GraphClass    `json:"classes,omitempty"`
+	Relations []GraphRelation `json:"relations,omitempty"`
+
+	// Sequence diagrams
+	Participants []GraphParticipant `json:"participants,omitempty"`
+	Messages     []GraphMessage     `json:"messages,omitempty"`
+}
+
+// GraphNode is a flowchart node, Group is the ID of the subgraph it's drawn in
+type GraphNode struct {
+	ID    string `json:"id"`
+	Label string `json:"label,omitempty"`
+	Shape string `json:"shape,omitempty"`
+	Group string `json:"group,omitempty"`
+}
+
+// GraphEdge is a flowchart link between two nodes
+type GraphEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Label string `json:"label,omitempty"`
+	Style string `json:"style,omitempty"`
+}
+
+// GraphClass is a class diagram type
+type GraphClass struct {
+	Name       string        `json:"name"`
+	Generic    string        `json:"generic,omitempty"`
+	Annotation string        `json:"annotation,omitempty"`
+	Members    []GraphMember `json:"members,omitempty"`
+}
+
+// GraphMember is a field or method of a class, Type is the field type or the method results
+type GraphMember struct {
+	Visibility string `json:"visibility,omitempty"`
+	Name       string `json:"name"`
+	Type       string `json:"type,omitempty"`
+	Method     bool   `json:"method,omitempty"`
+	Parameters string `json:"parameters,omitempty"`
+}
+
+// GraphRelation is a relationship between two classes, read as "From Type To"
+type GraphRelation struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Type  string `json:"type"`
+	Label string `json:"label,omitempty"`
+}
+
+// GraphParticipant is a sequence diagram participant
+type GraphParticipant struct {
+	ID    string `json:"id"`
+	Label string `json:"label,omitempty"`
+	Actor bool   `json:"actor,omitempty"`
+}
+
+// GraphMessage is a sequence diagram message
+type GraphMessage struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Text string `json:"text"`
+	Type string `json:"type,omitempty"`
+}
+
+// GraphError lists the ways a graph violates the schema
+type GraphError struct {
+	Violations []string
+}
+
+// Error implements error
+func (e *GraphError) Error() string {
+	return fmt.Sprintf("graph doesn't match the schema: %s", strings.Join(e.Violations, "; "))
+}
+
+var (
+	identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	annotationPattern = regexp.MustCompile(`^[A-Za-z]+$`)
+	genericPattern    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(, ?[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+	directions    = []string{"TD", "TB", "BT", "LR", "RL"}
+	shapes        = []string{"box", "round", "stadium", "diamond", "circle", "database", "hexagon"}
+	edgeStyles    = []string{"solid", "dotted", "thick"}
+	relationTypes = []string{"inheritance", "realization", "composition", "aggregation", "association", "dependency"}
+	messageTypes  = []string{"sync", "async", "reply"}
+	visibilities  = []string{"+", "-", "#", "~"}
+
+	// graphShapes maps node shapes to the delimiters around the label
+	graphShapes = map[string]string{
+		"box":      "[]",
+		"round":    "()",
+		"stadium":  "([])",
+		"diamond":  "{}",
+		"circle":   "(())",
+		"database": "[()]",
+		"hexagon":  "{{}}",
+	}
+	edgeArrows = map[string]string{
+		"solid":  "-->",
+		"dotted": "-.->",
+		"thick":  "==>",
+	}
+	// relationArrows maps relation types to arrows pointing from the From class to the To class
+	relationArrows = map[string]string{
+		"inheritance": "--|>",
+		"realization": "..|>",
+		"composition": "*--",
+		"aggregation": "o--",
+		"association": "-->",
+		"dependency":  "..>",
+	}
+	messageArrows = map[string]string{
+		"sync":  "->>",
+		"async": "-)",
+		"reply": "-->>",
+	}
+)
+
+// ParseGraph decodes the JSON graph in an LLM response and checks that it's a graph of the
+// expected kind matching the schema. Violations are reported as a *GraphError.
+func ParseGraph(response string, kind GraphKind) (*Graph, error) {
+	start, end := strings.Index(response, "{"), strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return nil, &GraphError{Violations: []string{"the response contains no JSON object"}}
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(response[start : end+1]))
+	decoder.DisallowUnknownFields()
+	var g Graph
+	if err := decoder.Decode(&g); err != nil {
+		return nil, &GraphError{Violations: []string{fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+
+	if violations := g.Validate(kind); len(violations) > 0 {
+		return nil, &GraphError{Violations: violations}
+	}
+	return &g, nil
+}
+
+// Validate returns the schema violations of the graph, and the references to nodes, classes or
+// participants it doesn't declare
+func (g *Graph) Validate(kind GraphKind) []string {
+	v := &graphValidator{}
+	if g.Kind != kind {
+		v.addf("kind must be %q, got %q", kind, g.Kind)
+	}
+
+	switch kind {
+	case FlowchartGraph:
+		v.validateFlowchart(g)
+		v.unexpected(len(g.Classes) > 0 || len(g.Relations) > 0, "classes and relations")
+		v.unexpected(len(g.Participants) > 0 || len(g.Messages) > 0, "participants and messages")
+	case ClassGraph:
+		v.validateClasses(g)
+		v.unexpected(len(g.Nodes) > 0 || len(g.Edges) > 0, "nodes and edges")
+		v.unexpected(len(g.Participants) > 0 || len(g.Messages) > 0, "participants and messages")
+	case SequenceGraph:
+		v.validateSequence(g)
+		v.unexpected(len(g.Nodes) > 0 || len(g.Edges) > 0, "nodes and edges")
+		v.unexpected(len(g.Classes) > 0 || len(g.Relations) > 0, "classes and relations")
+	default:
+		v.addf("unknown graph kind %q", kind)
+	}
+	if kind != FlowchartGraph && g.Direction != "" {
+		v.addf("direction is only allowed in flowcharts")
+	}
+	return v.violations
+}
+
+// graphValidator collects the violations of a graph
+type graphValidator struct {
+	violations []string
+}
+
+func (v *graphValidator) addf(format string, args ...any) {
+	v.violations = append(v.violations, fmt.Sprintf(format, args...))
+}
+
+func (v *graphValidator) unexpected(present bool, what string) {
+	if present {
+		v.addf("%s aren't allowed in this diagram", what)
+	}
+}
+
+// identifier checks an ID, reserved words break flowcharts
+func (v *graphValidator) identifier(field, id string) {
+	if !identifierPattern.MatchString(id) {
+		v.addf("%s %q is not an identifier", field, id)
+	} else if strings.EqualFold(id, "end") {
+		v.addf("%s %q is a reserved word", field, id)
+	}
+}
+
+func (v *graphValidator) enum(field, value string, allowed []string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.addf("%s %q must be one of %s", field, value, strings.Join(allowed, ", "))
+}
+
+// declare checks an ID and that it's declared once
+func (v *graphValidator) declare(field, id string, declared map[string]bool) {
+	v.identifier(field, id)
+	if declared[id] {
+		v.addf("%s %q is declared twice", field, id)
+	}
+	declared[id] = true
+}
+
+func (v *graphValidator) reference(field, id string, declared map[string]bool, what string) {
+	if !declared[id] {
+		v.addf("%s %q is not a declared %s", field, id, what)
+	}
+}
+
+func (v *graphValidator) validateFlowchart(g *Graph) {
+	v.enum("direction", g.Direction, directions)
+	if len(g.Nodes) == 0 {
+		v.addf("a flowchart needs at least one node")
+	}
+
+	nodes := make(map[string]bool)
+	for i, n := range g.Nodes {
+		v.declare(fmt.Sprintf("nodes[%d].id", i), n.ID, nodes)
+		v.enum(fmt.Sprintf("nodes[%d].shape", i), n.Shape, shapes)
+	}
+	for i, n := range g.Nodes {
+		if n.Group == "" {
+			continue
+		}
+		v.identifier(fmt.Sprintf("nodes[%d].group", i), n.Group)
+		if nodes[n.Group] {
+			v.addf("nodes[%d].group %q is also a node id", i, n.Group)
+		}
+	}
+	for i, e := range g.Edges {
+		v.reference(fmt.Sprintf("edges[%d].from", i), e.From, nodes, "node")
+		v.reference(fmt.Sprintf("edges[%d].to", i), e.To, nodes, "node")
+		v.enum(fmt.Sprintf("edges[%d].style", i), e.Style, edgeStyles)
+	}
+}
+
+func (v *graphValidator) validateClasses(g *Graph) {
+	if len(g.Classes) == 0 {
+		v.addf("a class diagram needs at least one class")
+	}
+
+	classes := make(map[string]bool)
+	for i, c := range g.Classes {
+		v.declare(fmt.Sprintf("classes[%d].name", i), c.Name, classes)
+		if c.Annotation != "" && !annotationPattern.MatchString(c.Annotation) {
+			v.addf("classes[%d].annotation %q must be a single word", i, c.Annotation)
+		}
+		if c.Generic != "" && !genericPattern.MatchString(c.Generic) {
+			v.addf("classes[%d].generic %q must be type parameter names", i, c.Generic)
+		}
+		for j, m := range c.Members {
+			field := fmt.Sprintf("classes[%d].members[%d]", i, j)
+			v.identifier(field+".name", m.Name)
+			v.enum(field+".visibility", m.Visibility, visibilities)
+			if m.Parameters != "" && !m.Method {
+				v.addf("%s.parameters are only allowed for methods", field)
+			}
+		}
+	}
+	for i, r := range g.Relations {
+		v.reference(fmt.Sprintf("relations[%d].from", i), r.From, classes, "class")
+		v.reference(fmt.Sprintf("relations[%d].to", i), r.To, classes, "class")
+		if r.Type == "" {
+			v.addf("relations[%d].type is required", i)
+		}
+		v.enum(fmt.Sprintf("relations[%d].type", i), r.Type, relationTypes)
+	}
+}
+
+func (v *graphValidator) validateSequence(g *Graph) {
+	if len(g.Participants) == 0 {
+		v.addf("a sequence diagram needs at least one participant")
+	}
+
+	participants := make(map[string]bool)
+	for i, p := range g.Participants {
+		v.declare(fmt.Sprintf("participants[%d].id", i), p.ID, participants)
+	}
+	for i, m := range g.Messages {
+		v.reference(fmt.Sprintf("messages[%d].from", i), m.From, participants, "participant")
+		v.reference(fmt.Sprintf("messages[%d].to", i), m.To, participants, "participant")
+		v.enum(fmt.Sprintf("messages[%d].type", i), m.Type, messageTypes)
+	}
+}
+
+// Diagram renders a valid graph as a Mermaid diagram
+func (g *Graph) Diagram() *Diagram {
+	switch g.Kind {
+	case ClassGraph:
+		return g.classDiagram()
+	case SequenceGraph:
+		return g.sequenceDiagram()
+	}
+	return g.flowchart()
+}
+
+var (
+	// labelReplacer escapes quoted flowchart labels, pipes would end edge labels
+	labelReplacer = strings.NewReplacer(`"`, "#quot;", "|", "#124;", "\r\n", "<br/>", "\n", "<br/>")
+	// textReplacer escapes the text of messages, relations and aliases, where semicolons end
+	// the statement
+	textReplacer = strings.NewReplacer(";", "#59;", "\r\n", " ", "\n", " ")
+	// typeReplacer keeps braces of Go types from closing the class body
+	typeReplacer = strings.NewReplacer("interface{}", "any", "struct{}", "struct", "{", "", "}", "", "\n", " ")
+)
+
+func (g *Graph) flowchart() *Diagram {
+	direction := g.Direction
+	if direction == "" {
+		direction = "TD"
+	}
+	d := &Diagram{Kind: KindFlowchart, Header: "flowchart " + direction}
+
+	for _, n := range g.Nodes {
+		if n.Group != "" && d.Subgraph(n.Group) == nil {
+			d.Subgraphs = append(d.Subgraphs, &Subgraph{ID: n.Group})
+		}
+
+		shape := graphShapes[n.Shape]
+		if shape == "" {
+			shape = graphShapes["box"]
+		}
+		label := n.Label
+		if label == "" {
+			label = n.ID
+		}
+		d.Nodes = append(d.Nodes, &Node{ID: n.ID, Shape: shape, Label: `"` + labelReplacer.Replace(label) + `"`, Subgraph: n.Group, Explicit: true})
+	}
+
+	for _, e := range g.Edges {
+		arrow := edgeArrows[e.Style]
+		if arrow == "" {
+			arrow = edgeArrows["solid"]
+		}
+		edge := &Edge{From: e.From, To: e.To, Arrow: arrow}
+		if e.Label != "" {
+			edge.Label = `"` + labelReplacer.Replace(e.Label) + `"`
+		}
+		d.Edges = append(d.Edges, edge)
+	}
+	return d
+}
+
+func (g *Graph) classDiagram() *Diagram {
+	d := &Diagram{Kind: KindClass, Header: "classDiagram"}
+
+	for _, c := range g.Classes {
+		class := &ClassEntity{ID: c.Name, Generic: c.Generic, Explicit: true}
+		if c.Annotation != "" {
+			class.Annotations = []string{c.Annotation}
+		}
+		for _, m := range c.Members {
+			member := &ClassMember{Visibility: m.Visibility, Name: m.Name, Type: typeReplacer.Replace(m.Type), IsMethod: m.Method}
+			if m.Method {
+				member.Text = fmt.Sprintf("%s%s(%s)", m.Visibility, m.Name, typeReplacer.Replace(m.Parameters))
+				if member.Type != "" {
+					member.Text += " " + member.Type
+				}
+			} else {
+				member.Text = strings.TrimSpace(fmt.Sprintf("%s%s %s", m.Visibility, m.Name, member.Type))
+			}
+			class.Members = append(class.Members, member)
+		}
+		d.Classes = append(d.Classes, class)
+	}
+
+	for _, r := range g.Relations {
+		d.Relations = append(d.Relations, &Relation{From: r.From, To: r.To, Arrow: relationArrows[r.Type], Label: textReplacer.Replace(r.Label)})
+	}
+	return d
+}
+
+func (g *Graph) sequenceDiagram() *Diagram {
+	d := &Diagram{Kind: KindSequence, Header: "sequenceDiagram"}
+
+	for _, p := range g.Participants {
+		participant := &Participant{ID: p.ID, Type: "participant", Declared: true}
+		if p.Actor {
+			participant.Type = "actor"
+		}
+		if p.Label != "" && p.Label != p.ID {
+			participant.Alias = textReplacer.Replace(p.Label)
+		}
+		d.Participants = append(d.Participants, participant)
+	}
+
+	for _, m := range g.Messages {
+		arrow := messageArrows[m.Type]
+		if arrow == "" {
+			arrow = messageArrows["sync"]
+		}
+		d.Events = append(d.Events, &SeqEvent{Type: EventMessage, From: m.From, To: m.To, Arrow: arrow, Text: textReplacer.Replace(m.Text)})
+	}
+	return d
+}
@@ -0,0 +1,166 @@
+package mermaid
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// GraphTestSuite is a test suite for JSON graphs
+type GraphTestSuite struct {
+	suite.Suite
+}
+
+// TestFlowchart renders a flowchart whose labels would break Mermaid text
+func (s *GraphTestSuite) TestFlowchart() {
+	response := "```json\n" + `{
+  "kind": "flowchart",
+  "direction": "LR",
+  "nodes": [
+    {"id": "Start", "label": "Read \"config\"", "shape": "round"},
+    {"id": "Valid", "label": "Valid?", "shape": "diamond", "group": "Checks"},
+    {"id": "Store", "label": "orders (db)", "shape": "database"}
+  ],
+  "edges": [
+    {"from": "Start", "to": "Valid"},
+    {"from": "Valid", "to": "Store", "label": "yes | save", "style": "dotted"}
+  ]
+}` + "\n```"
+
+	graph, err := ParseGraph(response, FlowchartGraph)
+	s.Require().NoError(err)
+
+	diagram := graph.Diagram().String()
+	s.Equal(`flowchart LR
+  subgraph Checks
+    Valid{"Valid?"}
+  end
+  Start("Read #quot;config#quot;")
+  Store[("orders (db)")]
+  Start --> Valid
+  Valid -.->|"yes #124; save"| Store`, diagram)
+	s.True(ValidateSyntax(diagram).IsValid, diagram)
+}
+
+// TestClassDiagram renders classes, members and relations
+func (s *GraphTestSuite) TestClassDiagram() {
+	response := `{
+  "kind": "class",
+  "classes": [
+    {"name": "Repository", "annotation": "interface", "members": [
+      {"visibility": "+", "name": "Find", "method": true, "parameters": "ctx context.Context, id string", "type": "(*User, error)"}
+    ]},
+    {"name": "userRepository", "members": [
+      {"visibility": "-", "name": "cache", "type": "map[string]interface{}"}
+    ]}
+  ],
+  "relations": [
+    {"from": "userRepository", "to": "Repository", "type": "realization", "label": "implements; tested"}
+  ]
+}`
+
+	graph, err := ParseGraph(response, ClassGraph)
+	s.Require().NoError(err)
+
+	diagram := graph.Diagram().String()
+	s.Equal(`classDiagram
+  class Repository {
+    <<interface>>
+    +Find(ctx context.Context, id string) (*User, error)
+  }
+  class userRepository {
+    -cache map[string]any
+  }
+  userRepository ..|> Repository : implements#59; tested`, diagram)
+	s.True(ValidateSyntax(diagram).IsValid, diagram)
+}
+
+// TestSequenceDiagram renders participants and messages
+func (s *GraphTestSuite) TestSequenceDiagram() {
+	graph := &Graph{
+		Kind:         SequenceGraph,
+		Participants: []GraphParticipant{{ID: "User", Actor: true}, {ID: "API", Label: "Orders API"}},
+		Messages: []GraphMessage{
+			{From: "User", To: "API", Text: "POST /orders"},
+			{From: "API", To: "User", Text: "201 Created", Type: "reply"},
+		},
+	}
+	s.Empty(graph.Validate(SequenceGraph))
+
+	diagram := graph.Diagram().String()
+	s.Equal(`sequenceDiagram
+  actor User
+  participant API as Orders API
+  User->>API: POST /orders
+  API-->>User: 201 Created`, diagram)
+	s.True(ValidateSyntax(diagram).IsValid, diagram)
+}
+
+// TestViolations checks the violations reported for invalid graphs
+func (s *GraphTestSuite) TestViolations() {
+	_, err := ParseGraph("I can't draw this", FlowchartGraph)
+	s.EqualError(err, "graph doesn't match the schema: the response contains no JSON object")
+
+	_, err = ParseGraph(`{"kind": "flowchart", "nodes": [{"id": "A", "colour": "red"}]}`, FlowchartGraph)
+	s.ErrorContains(err, `invalid JSON: json: unknown field "colour"`)
+
+	_, err = ParseGraph(`{
+  "kind": "class",
+  "nodes": [{"id": "A"}],
+  "classes": [{"name": "Order"}, {"name": "Order"}, {"name": "my class", "members": [{"name": "id", "visibility": "*"}]}],
+  "relations": [{"from": "Order", "to": "Invoice", "type": "owns"}]
+}`, FlowchartGraph)
+	var graphErr *GraphError
+	s.Require().ErrorAs(err, &graphErr)
+	s.Equal([]string{
+		`kind must be "flowchart", got "class"`,
+		`classes and relations aren't allowed in this diagram`,
+	}, graphErr.Violations)
+
+	graph := &Graph{
+		Kind:      ClassGraph,
+		Classes:   []GraphClass{{Name: "Order"}, {Name: "Order"}, {Name: "my class", Members: []GraphMember{{Name: "id", Visibility: "*"}}}},
+		Relations: []GraphRelation{{From: "Order", To: "Invoice", Type: "owns"}},
+	}
+	s.Equal([]string{
+		`classes[1].name "Order" is declared twice`,
+		`classes[2].name "my class" is not an identifier`,
+		`classes[2].members[0].visibility "*" must be one of +, -, #, ~`,
+		`relations[0].to "Invoice" is not a declared class`,
+		`relations[0].type "owns" must be one of inheritance, realization, composition, aggregation, association, dependency`,
+	}, graph.Validate(ClassGraph))
+
+	flowchart := &Graph{Kind: FlowchartGraph, Nodes: []GraphNode{{ID: "end"}, {ID: "A", Group: "A"}}}
+	s.Equal([]string{
+		`nodes[0].id "end" is a reserved word`,
+		`nodes[1].group "A" is also a node id`,
+	}, flowchart.Validate(FlowchartGraph))
+}
+
+// TestSchema checks that the schema enums match the rendered values
+func (s *GraphTestSuite) TestSchema() {
+	var schema struct {
+		Properties map[string]struct {
+			Enum  []string `json:"enum"`
+			Items struct {
+				Properties map[string]struct {
+					Enum []string `json:"enum"`
+				} `json:"properties"`
+			} `json:"items"`
+		} `json:"properties"`
+	}
+	s.Require().NoError(json.Unmarshal(GraphSchema(), &schema))
+
+	s.Equal([]string{"flowchart", "class", "sequence"}, schema.Properties["kind"].Enum)
+	s.Equal(directions, schema.Properties["direction"].Enum)
+	s.Equal(shapes, schema.Properties["nodes"].Items.Properties["shape"].Enum)
+	s.Equal(edgeStyles, schema.Properties["edges"].Items.Properties["style"].Enum)
+	s.Equal(relationTypes, schema.Properties["relations"].Items.Properties["type"].Enum)
+	s.Equal(messageTypes, schema.Properties["messages"].Items.Properties["type"].Enum)
+}
+
+// TestGraphTestSuite runs the graph test suite
+func TestGraphTestSuite(t *testing.T) {
+	suite.Run(t, new(GraphTestSuite))
+}
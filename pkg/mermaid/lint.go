@@ -0,0 +1,465 @@
+package mermaid
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Severity is the severity of a lint issue
+type Severity string
+
+const (
+	// SeverityError marks issues that should fail the lint
+	SeverityError Severity = "error"
+	// SeverityWarning marks issues that make a diagram hard to read
+	SeverityWarning Severity = "warning"
+	// SeverityInfo marks style suggestions
+	SeverityInfo Severity = "info"
+	// SeverityOff disables a rule
+	SeverityOff Severity = "off"
+)
+
+// ParseSeverity converts a severity name into a Severity
+func ParseSeverity(name string) (Severity, error) {
+	switch s := Severity(strings.ToLower(name)); s {
+	case SeverityError, SeverityWarning, SeverityInfo, SeverityOff:
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid severity: %s (should be 'error', 'warning', 'info' or 'off')", name)
+}
+
+// LintIssue is a problem found by a lint rule. Line is 0 for issues about the whole diagram.
+type LintIssue struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Line     int      `json:"line"`
+	Message  string   `json:"message"`
+}
+
+// LintConfig configures the lint rules
+type LintConfig struct {
+	// Severities overrides the default severity of rules by name
+	Severities map[string]Severity
+	// MaxLabelLength is the longest label accepted by long-labels
+	MaxLabelLength int
+	// MaxNodes is the largest number of nodes accepted by too-many-nodes
+	MaxNodes int
+}
+
+// DefaultLintConfig returns the default lint configuration
+func DefaultLintConfig() LintConfig {
+	return LintConfig{
+		Severities:     map[string]Severity{},
+		MaxLabelLength: 50,
+		MaxNodes:       50,
+	}
+}
+
+// LintRule checks a parsed diagram for a kind of problem
+type LintRule struct {
+	Name        string
+	Description string
+	Severity    Severity
+	Check       func(d *Diagram, cfg LintConfig) []LintIssue
+}
+
+// LintRules are the available lint rules with their default severity
+var LintRules = []LintRule{
+	{
+		Name:        "duplicate-node-ids",
+		Description: "A node, class or participant ID is declared more than once with different definitions",
+		Severity:    SeverityError,
+		Check:       lintDuplicateIDs,
+	},
+	{
+		Name:        "orphan-nodes",
+		Description: "A node or class isn't connected to anything",
+		Severity:    SeverityWarning,
+		Check:       lintOrphans,
+	},
+	{
+		Name:        "unreachable-subgraphs",
+		Description: "A subgraph is empty or has no links to the rest of the flowchart",
+		Severity:    SeverityWarning,
+		Check:       lintUnreachableSubgraphs,
+	},
+	{
+		Name:        "empty-classes",
+		Description: "A class has no fields or methods",
+		Severity:    SeverityInfo,
+		Check:       lintEmptyClasses,
+	},
+	{
+		Name:        "long-labels",
+		Description: "A label or message is too long to render readably",
+		Severity:    SeverityWarning,
+		Check:       lintLongLabels,
+	},
+	{
+		Name:        "too-many-nodes",
+		Description: "The diagram has too many nodes to render readably",
+		Severity:    SeverityWarning,
+		Check:       lintTooManyNodes,
+	},
+	{
+		Name:        "inconsistent-arrows",
+		Description: "The same kind of link is written with different arrow styles",
+		Severity:    SeverityInfo,
+		Check:       lintInconsistentArrows,
+	},
+	{
+		Name:        "undeclared-participants",
+		Description: "A sequence participant is used without a participant or actor declaration",
+		Severity:    SeverityWarning,
+		Check:       lintUndeclaredParticipants,
+	},
+}
+
+// Lint parses a diagram and runs the lint rules on it. Rules can be disabled for the rest of
+// the diagram with a "%% mm-gen-disable rule" comment and enabled again with
+// "%% mm-gen-enable rule"; without a rule name every rule is affected.
+func Lint(src string, cfg LintConfig) ([]LintIssue, error) {
+	d, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	suppressions := parseSuppressions(src)
+
+	var issues []LintIssue
+	for _, rule := range LintRules {
+		severity := rule.Severity
+		if s, ok := cfg.Severities[rule.Name]; ok {
+			severity = s
+		}
+		if severity == SeverityOff {
+			continue
+		}
+		for _, issue := range rule.Check(d, cfg) {
+			if suppressions.suppressed(rule.Name, issue.Line) {
+				continue
+			}
+			issue.Rule = rule.Name
+			issue.Severity = severity
+			issues = append(issues, issue)
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+	return issues, nil
+}
+
+// HasErrors reports whether any issue has error severity
+func HasErrors(issues []LintIssue) bool {
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatLintIssues formats lint issues one per line, prefixed by the file name
+func FormatLintIssues(file string, issues []LintIssue) string {
+	var b strings.Builder
+	for _, issue := range issues {
+		b.WriteString(fmt.Sprintf("%s:%d: %s: %s (%s)\n", file, issue.Line, issue.Severity, issue.Message, issue.Rule))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+var suppressionRe = regexp.MustCompile(`^%%\s*mm-gen-(disable|enable)\b(.*)$`)
+
+// suppressionRange is a range of lines a rule is disabled for, end is 0 when open ended
+type suppressionRange struct {
+	rule       string
+	start, end int
+}
+
+type suppressions []suppressionRange
+
+func parseSuppressions(src string) suppressions {
+	var ranges suppressions
+	open := make(map[string]int)
+
+	for i, line := range strings.Split(src, "\n") {
+		m := suppressionRe.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		rules := strings.FieldsFunc(m[2], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+		if len(rules) == 0 {
+			rules = []string{"*"}
+		}
+		for _, rule := range rules {
+			switch m[1] {
+			case "disable":
+				if _, ok := open[rule]; !ok {
+					open[rule] = i + 1
+				}
+			case "enable":
+				if start, ok := open[rule]; ok {
+					ranges = append(ranges, suppressionRange{rule: rule, start: start, end: i + 1})
+					delete(open, rule)
+				}
+			}
+		}
+	}
+	for rule, start := range open {
+		ranges = append(ranges, suppressionRange{rule: rule, start: start})
+	}
+	return ranges
+}
+
+// suppressed reports whether the rule is disabled at the line. Issues about the whole
+// diagram are suppressed by a disable comment anywhere.
+func (s suppressions) suppressed(rule string, line int) bool {
+	for _, r := range s {
+		if r.rule != rule && r.rule != "*" {
+			continue
+		}
+		if line == 0 || (line >= r.start && (r.end == 0 || line <= r.end)) {
+			return true
+		}
+	}
+	return false
+}
+
+func lintDuplicateIDs(d *Diagram, cfg LintConfig) []LintIssue {
+	var issues []LintIssue
+	add := func(kind, id string, first int, lines []int) {
+		for _, line := range lines {
+			issues = append(issues, LintIssue{Line: line, Message: fmt.Sprintf("%s %s is already declared on line %d", kind, id, first)})
+		}
+	}
+	for _, n := range d.Nodes {
+		add("node", n.ID, n.Line, n.Redeclared)
+	}
+	for _, c := range d.Classes {
+		add("class", c.ID, c.Line, c.Redeclared)
+	}
+	for _, p := range d.Participants {
+		add("participant", p.ID, p.Line, p.Redeclared)
+	}
+	return issues
+}
+
+func lintOrphans(d *Diagram, cfg LintConfig) []LintIssue {
+	var issues []LintIssue
+	switch d.Kind {
+	case KindFlowchart:
+		// A single node diagram has nothing to connect to
+		if len(d.Nodes) < 2 {
+			return nil
+		}
+		linked := make(map[string]bool)
+		for _, e := range d.Edges {
+			linked[e.From] = true
+			linked[e.To] = true
+		}
+		for _, n := range d.Nodes {
+			if !linked[n.ID] && d.Subgraph(n.ID) == nil && !linked[n.Subgraph] {
+				issues = append(issues, LintIssue{Line: n.Line, Message: fmt.Sprintf("node %s has no links", n.ID)})
+			}
+		}
+	case KindClass:
+		if len(d.Relations) == 0 {
+			return nil
+		}
+		related := make(map[string]bool)
+		for _, r := range d.Relations {
+			related[r.From] = true
+			related[r.To] = true
+		}
+		for _, n := range d.Notes {
+			related[n.For] = true
+		}
+		for _, c := range d.Classes {
+			if !related[c.ID] {
+				issues = append(issues, LintIssue{Line: c.Line, Message: fmt.Sprintf("class %s has no relationships", c.ID)})
+			}
+		}
+	}
+	return issues
+}
+
+func lintUnreachableSubgraphs(d *Diagram, cfg LintConfig) []LintIssue {
+	if d.Kind != KindFlowchart {
+		return nil
+	}
+
+	// inside reports whether a node or subgraph ID is the subgraph or nested in it
+	var inside func(id, subgraph string) bool
+	inside = func(id, subgraph string) bool {
+		if id == subgraph {
+			return true
+		}
+		parent := ""
+		if n := d.Node(id); n != nil {
+			parent = n.Subgraph
+		}
+		if sg := d.Subgraph(id); sg != nil {
+			parent = sg.Parent
+		}
+		return parent != "" && inside(parent, subgraph)
+	}
+
+	var issues []LintIssue
+	for _, sg := range d.Subgraphs {
+		empty := true
+		for _, n := range d.Nodes {
+			if inside(n.ID, sg.ID) && n.ID != sg.ID {
+				empty = false
+				break
+			}
+		}
+		if empty {
+			issues = append(issues, LintIssue{Line: sg.Line, Message: fmt.Sprintf("subgraph %s is empty", sg.ID)})
+			continue
+		}
+
+		connected := false
+		for _, e := range d.Edges {
+			if inside(e.From, sg.ID) != inside(e.To, sg.ID) {
+				connected = true
+				break
+			}
+		}
+		if !connected && len(d.Subgraphs)+len(d.Nodes) > 1 && hasOutsideNodes(d, sg.ID, inside) {
+			issues = append(issues, LintIssue{Line: sg.Line, Message: fmt.Sprintf("subgraph %s has no links to the rest of the diagram", sg.ID)})
+		}
+	}
+	return issues
+}
+
+func hasOutsideNodes(d *Diagram, subgraph string, inside func(id, subgraph string) bool) bool {
+	for _, n := range d.Nodes {
+		if !inside(n.ID, subgraph) {
+			return true
+		}
+	}
+	return false
+}
+
+func lintEmptyClasses(d *Diagram, cfg LintConfig) []LintIssue {
+	var issues []LintIssue
+	for _, c := range d.Classes {
+		if len(c.Members) == 0 {
+			issues = append(issues, LintIssue{Line: c.Line, Message: fmt.Sprintf("class %s has no members", c.ID)})
+		}
+	}
+	return issues
+}
+
+func lintLongLabels(d *Diagram, cfg LintConfig) []LintIssue {
+	var issues []LintIssue
+	check := func(kind, text string, line int) {
+		if n := len([]rune(text)); n > cfg.MaxLabelLength {
+			issues = append(issues, LintIssue{Line: line, Message: fmt.Sprintf("%s is %d characters long, the maximum is %d", kind, n, cfg.MaxLabelLength)})
+		}
+	}
+	for _, n := range d.Nodes {
+		check("label of node "+n.ID, n.Label, n.Line)
+	}
+	for _, e := range d.Edges {
+		check(fmt.Sprintf("label of link %s %s %s", e.From, e.Arrow, e.To), e.Label, e.Line)
+	}
+	for _, c := range d.Classes {
+		check("label of class "+c.ID, c.Label, c.Line)
+	}
+	for _, r := range d.Relations {
+		check(fmt.Sprintf("label of relation %s %s %s", r.From, r.Arrow, r.To), r.Label, r.Line)
+	}
+	for _, m := range d.Messages() {
+		check(fmt.Sprintf("message %s%s%s", m.From, m.Arrow, m.To), m.Text, m.Line)
+	}
+	return issues
+}
+
+func lintTooManyNodes(d *Diagram, cfg LintConfig) []LintIssue {
+	count := len(d.Nodes) + len(d.Classes) + len(d.Participants)
+	if count > cfg.MaxNodes {
+		return []LintIssue{{Message: fmt.Sprintf("diagram has %d nodes, more than %d render poorly; consider splitting it", count, cfg.MaxNodes)}}
+	}
+	return nil
+}
+
+// classArrowKinds maps class diagram arrows to the relationship they draw
+var classArrowKinds = map[string]string{
+	"<|--": "inheritance", "--|>": "inheritance",
+	"<|..": "realization", "..|>": "realization",
+	"*--": "composition", "--*": "composition",
+	"o--": "aggregation", "--o": "aggregation",
+	"<--": "association", "-->": "association",
+	"<..": "dependency", "..>": "dependency",
+}
+
+var arrowRunRe = regexp.MustCompile(`(-{2,}|={2,}|\.{2,})`)
+
+func lintInconsistentArrows(d *Diagram, cfg LintConfig) []LintIssue {
+	type usage struct {
+		arrow string
+		line  int
+	}
+	families := make(map[string][]usage)
+
+	switch d.Kind {
+	case KindClass:
+		for _, r := range d.Relations {
+			if kind, ok := classArrowKinds[r.Arrow]; ok {
+				families[kind] = append(families[kind], usage{r.Arrow, r.Line})
+			}
+		}
+	case KindFlowchart:
+		for _, e := range d.Edges {
+			// Arrows of different lengths draw the same kind of link
+			family := arrowRunRe.ReplaceAllStringFunc(e.Arrow, func(run string) string { return run[:1] })
+			families[family] = append(families[family], usage{e.Arrow, e.Line})
+		}
+	default:
+		return nil
+	}
+
+	var names []string
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var issues []LintIssue
+	for _, name := range names {
+		uses := families[name]
+		counts := make(map[string]int)
+		for _, u := range uses {
+			counts[u.arrow]++
+		}
+		if len(counts) < 2 {
+			continue
+		}
+		// The first of the most used spellings is the preferred one
+		preferred := uses[0].arrow
+		for _, u := range uses {
+			if counts[u.arrow] > counts[preferred] {
+				preferred = u.arrow
+			}
+		}
+		for _, u := range uses {
+			if u.arrow != preferred {
+				issues = append(issues, LintIssue{Line: u.line, Message: fmt.Sprintf("arrow %s is written as %s elsewhere", u.arrow, preferred)})
+			}
+		}
+	}
+	return issues
+}
+
+func lintUndeclaredParticipants(d *Diagram, cfg LintConfig) []LintIssue {
+	var issues []LintIssue
+	for _, p := range d.Participants {
+		if !p.Declared {
+			issues = append(issues, LintIssue{Line: p.Line, Message: fmt.Sprintf("participant %s is used without being declared", p.ID)})
+		}
+	}
+	return issues
+}
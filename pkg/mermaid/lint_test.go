@@ -0,0 +1,131 @@
+package mermaid
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// LintTestSuite is a test suite for the lint rules
+type LintTestSuite struct {
+	suite.Suite
+}
+
+// lint returns the lines of the issues reported by each rule
+func (s *LintTestSuite) lint(src string, cfg LintConfig) map[string][]int {
+	issues, err := Lint(src, cfg)
+	s.Require().NoError(err)
+
+	lines := make(map[string][]int)
+	for _, issue := range issues {
+		lines[issue.Rule] = append(lines[issue.Rule], issue.Line)
+	}
+	return lines
+}
+
+// TestFlowchartRules checks the rules reported for a flowchart
+func (s *LintTestSuite) TestFlowchartRules() {
+	src := `flowchart TD
+    A[Start] --> B[Run]
+    A ---> C
+    B[Other] --> C
+    D
+    subgraph S1
+      E
+    end
+    subgraph S2
+    end
+`
+	lines := s.lint(src, DefaultLintConfig())
+	s.Equal([]int{4}, lines["duplicate-node-ids"])
+	s.Equal([]int{5, 7}, lines["orphan-nodes"])
+	s.Equal([]int{6, 9}, lines["unreachable-subgraphs"])
+	s.Equal([]int{3}, lines["inconsistent-arrows"])
+}
+
+// TestClassRules checks the rules reported for a class diagram
+func (s *LintTestSuite) TestClassRules() {
+	src := `classDiagram
+    class Service {
+        +Run() error
+    }
+    class Store
+    class Cache
+    Service --> Store
+    Service <|.. Cache
+    Cache ..|> Service
+`
+	lines := s.lint(src, DefaultLintConfig())
+	s.Equal([]int{5, 6}, lines["empty-classes"])
+	s.Equal([]int{9}, lines["inconsistent-arrows"])
+	s.Empty(lines["orphan-nodes"])
+}
+
+// TestSequenceRules checks the rules reported for a sequence diagram
+func (s *LintTestSuite) TestSequenceRules() {
+	src := `sequenceDiagram
+    participant A
+    participant A
+    A->>B: a message that is far too long to be shown on a single line
+`
+	lines := s.lint(src, DefaultLintConfig())
+	s.Equal([]int{3}, lines["duplicate-node-ids"])
+	s.Equal([]int{4}, lines["long-labels"])
+	s.Equal([]int{4}, lines["undeclared-participants"])
+}
+
+// TestConfig checks severity overrides and limits
+func (s *LintTestSuite) TestConfig() {
+	src := "flowchart TD\n    A --> B\n    B --> C\n    C --> D\n"
+
+	cfg := DefaultLintConfig()
+	cfg.MaxNodes = 3
+	issues, err := Lint(src, cfg)
+	s.Require().NoError(err)
+	s.Require().Len(issues, 1)
+	s.Equal("too-many-nodes", issues[0].Rule)
+	s.Equal(SeverityWarning, issues[0].Severity)
+	s.False(HasErrors(issues))
+
+	cfg.Severities["too-many-nodes"] = SeverityError
+	issues, err = Lint(src, cfg)
+	s.Require().NoError(err)
+	s.True(HasErrors(issues))
+
+	cfg.Severities["too-many-nodes"] = SeverityOff
+	issues, err = Lint(src, cfg)
+	s.Require().NoError(err)
+	s.Empty(issues)
+}
+
+// TestSuppressions checks disable and enable comments
+func (s *LintTestSuite) TestSuppressions() {
+	src := `flowchart TD
+    A --> B
+    %% mm-gen-disable orphan-nodes
+    C
+    %% mm-gen-enable orphan-nodes
+    D
+    %% mm-gen-disable
+    E
+`
+	lines := s.lint(src, DefaultLintConfig())
+	s.Equal([]int{6}, lines["orphan-nodes"])
+}
+
+// TestValidExampleIsClean checks that the valid example has no errors
+func (s *LintTestSuite) TestValidExampleIsClean() {
+	content, err := os.ReadFile(filepath.Join("..", "..", "examples", "valid.mmd"))
+	s.Require().NoError(err)
+
+	issues, err := Lint(string(content), DefaultLintConfig())
+	s.Require().NoError(err)
+	s.False(HasErrors(issues), FormatLintIssues("valid.mmd", issues))
+}
+
+// TestLintTestSuite runs the lint test suite
+func TestLintTestSuite(t *testing.T) {
+	suite.Run(t, new(LintTestSuite))
+}
@@ -0,0 +1,274 @@
+package mermaid
+
+import "strings"
+
+// DiagramKind is the kind of a parsed Mermaid diagram
+type DiagramKind string
+
+const (
+	// KindClass is a classDiagram
+	KindClass DiagramKind = "classDiagram"
+	// KindFlowchart is a flowchart or graph
+	KindFlowchart DiagramKind = "flowchart"
+	// KindSequence is a sequenceDiagram
+	KindSequence DiagramKind = "sequenceDiagram"
+	// KindOther is any other diagram kind, kept as raw statements
+	KindOther DiagramKind = "other"
+)
+
+// Diagram is a parsed Mermaid diagram.
+// Class, flowchart and sequence diagrams are modelled, statements that aren't
+// (styles, click handlers, other diagram kinds) are kept as raw Extra statements.
+type Diagram struct {
+	Kind DiagramKind
+	// Header is the diagram declaration, e.g. "classDiagram" or "flowchart LR"
+	Header string
+	// Preamble holds front matter and %%{init}%% directives preceding the header
+	Preamble []string
+	// HeaderComments are the comments preceding the header
+	HeaderComments []string
+
+	// Class diagrams
+	Classes    []*ClassEntity
+	Relations  []*Relation
+	Namespaces []*Namespace
+	Notes      []*Note
+
+	// Flowcharts
+	Nodes     []*Node
+	Edges     []*Edge
+	Subgraphs []*Subgraph
+
+	// Sequence diagrams
+	Participants []*Participant
+	Events       []*SeqEvent
+
+	// Extra holds unmodelled statements in their original order
+	Extra []*Statement
+	// TrailingComments are the comments after the last statement
+	TrailingComments []string
+}
+
+// Position is the source location of an element, Line is 1-based
+type Position struct {
+	Line int
+	// Comments are the comment lines directly preceding the element
+	Comments []string
+}
+
+// ClassEntity is a class declared in a class diagram
+type ClassEntity struct {
+	Position
+	ID          string
+	Label       string
+	Generic     string
+	Annotations []string
+	Members     []*ClassMember
+	Namespace   string
+	CSSClass    string
+	// Explicit is set when the class is declared with the class keyword,
+	// otherwise it only appears in relations or member statements
+	Explicit bool
+	// Redeclared are the lines declaring the class again
+	Redeclared []int
+}
+
+// ClassMember is a field or method of a class
+type ClassMember struct {
+	Position
+	Text       string
+	Visibility string
+	Name       string
+	Type       string
+	IsMethod   bool
+}
+
+// Names returns the candidate names of the member. Fields may be written either as
+// "Name Type" or "Type Name", so both words are returned for fields.
+func (m *ClassMember) Names() []string {
+	names := []string{m.Name}
+	if !m.IsMethod && m.Type != "" {
+		names = append(names, strings.Fields(m.Type)[0])
+	}
+	return names
+}
+
+// Relation is a relationship between two classes
+type Relation struct {
+	Position
+	From            string
+	To              string
+	Arrow           string
+	Label           string
+	FromCardinality string
+	ToCardinality   string
+}
+
+// Namespace groups classes in a class diagram
+type Namespace struct {
+	Position
+	Name    string
+	Classes []string
+}
+
+// Note is a note in a class diagram, For is empty for a floating note
+type Note struct {
+	Position
+	For  string
+	Text string
+}
+
+// Node is a flowchart node
+type Node struct {
+	Position
+	ID string
+	// Shape is the pair of shape delimiters around the label, e.g. "[]", "(())" or "{{}}"
+	Shape string
+	Label string
+	// Subgraph is the ID of the innermost subgraph the node is declared in
+	Subgraph string
+	CSSClass string
+	// Explicit is set when the node has a shape or label somewhere in the diagram
+	Explicit bool
+	// Redeclared are the lines giving the node a different shape or label
+	Redeclared []int
+}
+
+// Edge is a flowchart link between two nodes
+type Edge struct {
+	Position
+	From  string
+	To    string
+	Arrow string
+	Label string
+}
+
+// Subgraph is a flowchart subgraph
+type Subgraph struct {
+	Position
+	ID     string
+	Label  string
+	Parent string
+	// Direction is the optional direction statement inside the subgraph
+	Direction string
+	// EndLine is the line of the closing end keyword
+	EndLine int
+}
+
+// Participant is a sequence diagram participant or actor
+type Participant struct {
+	Position
+	ID    string
+	Alias string
+	// Type is "participant" or "actor"
+	Type string
+	// Declared is set when the participant has an explicit declaration
+	Declared bool
+	// Created is set when the declaration is a "create participant" statement
+	Created bool
+	// Redeclared are the lines declaring the participant again
+	Redeclared []int
+}
+
+// SeqEventType is the type of a sequence diagram statement
+type SeqEventType string
+
+const (
+	// EventMessage is a message between two participants
+	EventMessage SeqEventType = "message"
+	// EventNote is a note over or beside participants
+	EventNote SeqEventType = "note"
+	// EventBlockStart opens a loop, alt, opt, par, critical, break, rect or box block
+	EventBlockStart SeqEventType = "block"
+	// EventBlockElse continues a block with else, and or option
+	EventBlockElse SeqEventType = "else"
+	// EventBlockEnd closes a block
+	EventBlockEnd SeqEventType = "end"
+	// EventActivation is an activate or deactivate statement
+	EventActivation SeqEventType = "activation"
+	// EventOther is any other statement such as autonumber
+	EventOther SeqEventType = "other"
+)
+
+// SeqEvent is an ordered sequence diagram statement
+type SeqEvent struct {
+	Position
+	Type SeqEventType
+	// Keyword is the block, note placement or activation keyword
+	Keyword string
+	From    string
+	To      string
+	Arrow   string
+	// Activation is "+" or "-" when the message activates or deactivates the target
+	Activation string
+	Text       string
+}
+
+// Statement is a raw, unmodelled statement
+type Statement struct {
+	Position
+	Text string
+	// Depth is the block nesting depth the statement was found at
+	Depth int
+}
+
+// Messages returns the messages of a sequence diagram in order
+func (d *Diagram) Messages() []*SeqEvent {
+	var messages []*SeqEvent
+	for _, e := range d.Events {
+		if e.Type == EventMessage {
+			messages = append(messages, e)
+		}
+	}
+	return messages
+}
+
+// Class returns the class with the given ID or nil
+func (d *Diagram) Class(id string) *ClassEntity {
+	for _, c := range d.Classes {
+		if c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// Node returns the flowchart node with the given ID or nil
+func (d *Diagram) Node(id string) *Node {
+	for _, n := range d.Nodes {
+		if n.ID == id {
+			return n
+		}
+	}
+	return nil
+}
+
+// Participant returns the sequence participant with the given ID or nil
+func (d *Diagram) Participant(id string) *Participant {
+	for _, p := range d.Participants {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// Subgraph returns the flowchart subgraph with the given ID or nil
+func (d *Diagram) Subgraph(id string) *Subgraph {
+	for _, s := range d.Subgraphs {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// Namespace returns the namespace with the given name or nil
+func (d *Diagram) Namespace(name string) *Namespace {
+	for _, ns := range d.Namespaces {
+		if ns.Name == name {
+			return ns
+		}
+	}
+	return nil
+}
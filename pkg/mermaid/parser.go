@@ -0,0 +1,697 @@
+package mermaid
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	classDeclRe     = regexp.MustCompile(`^class\s+([\w-]+)(?:~([^~]*)~)?(?:\s*\["([^"]*)"\])?(?::::([\w-]+))?\s*(\{)?\s*(\})?$`)
+	classAnnotRe    = regexp.MustCompile(`^<<\s*([^>]+?)\s*>>\s*([\w-]+)?$`)
+	classMemberRe   = regexp.MustCompile(`^([\w-]+)(?:~[^~]*~)?\s*:\s*(.+)$`)
+	classRelationRe = regexp.MustCompile(`^([\w-]+)(?:~[^~]*~)?\s*(?:"([^"]*)"\s*)?((?:<\||<|\*|o)?(?:--|\.\.)(?:\|>|>|\*|o)?)\s*(?:"([^"]*)"\s*)?([\w-]+)(?:~[^~]*~)?\s*(?::\s*(.*))?$`)
+	classNoteRe     = regexp.MustCompile(`^note\s+(?:for\s+([\w-]+)\s+)?"(.*)"$`)
+	namespaceRe     = regexp.MustCompile(`^namespace\s+([\w.-]+)\s*\{$`)
+
+	subgraphRe   = regexp.MustCompile(`^subgraph(?:\s+(.*))?$`)
+	subgraphIDRe = regexp.MustCompile(`^([\w-]+)\s*\[(.*)\]$`)
+	nodeIDRe     = regexp.MustCompile(`^\w+`)
+	linkTextRe   = regexp.MustCompile(`^(<)?(--|==|-\.)\s+(.+?)\s+(-{2,}>|-{3,}|-{2,}[ox]|={2,}>|={3,}|={2,}[ox]|\.+->|\.+-)`)
+	linkRe       = regexp.MustCompile(`^(<)?(-{2,}>|-{3,}|-{2,}[ox]|={2,}>|={3,}|={2,}[ox]|-\.+->|-\.+-|~{3,})`)
+	linkLabelRe  = regexp.MustCompile(`^\s*\|([^|]*)\|`)
+
+	participantRe = regexp.MustCompile(`^(create\s+)?(participant|actor)\s+(\S+?)(?:\s+as\s+(.+))?$`)
+	messageRe     = regexp.MustCompile(`^([^\s:+<>-][^:]*?)\s*(<<-->>|<<->>|-->>|->>|--x|-x|--\)|-\)|-->|->)\s*([+-]?)\s*([^:+-][^:]*?)\s*(?::(.*))?$`)
+	seqNoteRe     = regexp.MustCompile(`(?i)^note\s+(left of|right of|over)\s+([^:,]+?)(?:\s*,\s*([^:]+?))?\s*:\s*(.*)$`)
+	blockStartRe  = regexp.MustCompile(`^(loop|alt|opt|par|critical|break|rect|box)\b\s*(.*)$`)
+	blockElseRe   = regexp.MustCompile(`^(else|and|option)\b\s*(.*)$`)
+	activationRe  = regexp.MustCompile(`^(activate|deactivate)\s+(\S+)$`)
+)
+
+// nodeShapes lists the flowchart node delimiters, longest openings first
+var nodeShapes = [][2]string{
+	{"(((", ")))"},
+	{"([", "])"},
+	{"[[", "]]"},
+	{"[(", ")]"},
+	{"((", "))"},
+	{"{{", "}}"},
+	{"[/", "/]"},
+	{"[/", "\\]"},
+	{"[\\", "\\]"},
+	{"[\\", "/]"},
+	{">", "]"},
+	{"[", "]"},
+	{"(", ")"},
+	{"{", "}"},
+}
+
+// Parse parses Mermaid diagram source into a Diagram.
+// Surrounding ```mermaid fences are ignored. Statements that can't be parsed are kept
+// as raw Extra statements (or EventOther events in sequence diagrams), so Parse only
+// fails when the diagram has no header.
+func Parse(src string) (*Diagram, error) {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	blankFences(lines)
+
+	d := &Diagram{}
+	p := &diagramParser{d: d}
+
+	i := 0
+	// Front matter
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+	if i < len(lines) && strings.TrimSpace(lines[i]) == "---" {
+		start := i
+		for i++; i < len(lines) && strings.TrimSpace(lines[i]) != "---"; i++ {
+		}
+		end := i
+		if end >= len(lines) {
+			end = len(lines) - 1
+		}
+		d.Preamble = append(d.Preamble, lines[start:end+1]...)
+		i = end + 1
+	}
+
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		lineNum := i + 1
+
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "%%{") {
+			if d.Header == "" {
+				d.Preamble = append(d.Preamble, line)
+			} else {
+				p.extra(line, lineNum)
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "%%") {
+			p.comments = append(p.comments, line)
+			continue
+		}
+
+		if d.Header == "" {
+			p.parseHeader(line, lineNum)
+			continue
+		}
+
+		p.parseLine(line, lineNum)
+	}
+
+	if d.Header == "" {
+		return nil, fmt.Errorf("missing diagram type declaration")
+	}
+
+	d.TrailingComments = p.comments
+	return d, nil
+}
+
+// blankFences replaces markdown code fence lines with empty lines, keeping line numbers stable
+func blankFences(lines []string) {
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			lines[i] = ""
+		}
+	}
+}
+
+// diagramParser holds the state of the statement parser
+type diagramParser struct {
+	d        *Diagram
+	comments []string
+
+	// Class diagrams
+	class      *ClassEntity
+	namespaces []string
+
+	// Flowcharts
+	subgraphs []*Subgraph
+
+	// Depth of unmodelled block statements
+	depth int
+}
+
+// position consumes the pending comments for an element on the given line
+func (p *diagramParser) position(line int) Position {
+	pos := Position{Line: line, Comments: p.comments}
+	p.comments = nil
+	return pos
+}
+
+func (p *diagramParser) extra(text string, line int) {
+	p.d.Extra = append(p.d.Extra, &Statement{Position: p.position(line), Text: text, Depth: p.depth})
+}
+
+func (p *diagramParser) parseHeader(line string, lineNum int) {
+	d := p.d
+	d.HeaderComments = p.comments
+	p.comments = nil
+
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case "classDiagram", "classDiagram-v2":
+		d.Kind = KindClass
+	case "flowchart", "graph":
+		d.Kind = KindFlowchart
+		// "graph TD; A-->B" puts statements on the header line
+		if idx := strings.Index(line, ";"); idx >= 0 {
+			d.Header = strings.TrimSpace(line[:idx])
+			for _, stmt := range splitStatements(line[idx+1:]) {
+				p.parseFlowchart(stmt, lineNum)
+			}
+			return
+		}
+	case "sequenceDiagram":
+		d.Kind = KindSequence
+	default:
+		d.Kind = KindOther
+	}
+	d.Header = line
+}
+
+func (p *diagramParser) parseLine(line string, lineNum int) {
+	switch p.d.Kind {
+	case KindClass:
+		p.parseClass(line, lineNum)
+	case KindFlowchart:
+		for _, stmt := range splitStatements(line) {
+			p.parseFlowchart(stmt, lineNum)
+		}
+	case KindSequence:
+		p.parseSequence(strings.TrimSuffix(line, ";"), lineNum)
+	default:
+		if strings.HasPrefix(line, "}") || line == "end" {
+			p.depth--
+			if p.depth < 0 {
+				p.depth = 0
+			}
+		}
+		p.extra(line, lineNum)
+		if strings.HasSuffix(line, "{") {
+			p.depth++
+		}
+	}
+}
+
+// ensureClass returns the class with the given ID, creating an implicit one if needed
+func (p *diagramParser) ensureClass(id string, line int) *ClassEntity {
+	if c := p.d.Class(id); c != nil {
+		return c
+	}
+	c := &ClassEntity{ID: id, Position: Position{Line: line}}
+	p.d.Classes = append(p.d.Classes, c)
+	if len(p.namespaces) > 0 {
+		p.addToNamespace(c, p.namespaces[len(p.namespaces)-1])
+	}
+	return c
+}
+
+func (p *diagramParser) addToNamespace(c *ClassEntity, name string) {
+	c.Namespace = name
+	if ns := p.d.Namespace(name); ns != nil {
+		ns.Classes = append(ns.Classes, c.ID)
+	}
+}
+
+func (p *diagramParser) parseClass(line string, lineNum int) {
+	// Inside a class body
+	if p.class != nil {
+		if line == "}" {
+			p.class = nil
+			p.comments = nil
+			return
+		}
+		if m := classAnnotRe.FindStringSubmatch(line); m != nil && m[2] == "" {
+			p.class.Annotations = append(p.class.Annotations, m[1])
+			return
+		}
+		member := parseMember(line)
+		member.Position = p.position(lineNum)
+		p.class.Members = append(p.class.Members, member)
+		return
+	}
+
+	if m := namespaceRe.FindStringSubmatch(line); m != nil {
+		if p.d.Namespace(m[1]) == nil {
+			p.d.Namespaces = append(p.d.Namespaces, &Namespace{Position: p.position(lineNum), Name: m[1]})
+		}
+		p.namespaces = append(p.namespaces, m[1])
+		return
+	}
+	if line == "}" && len(p.namespaces) > 0 {
+		p.namespaces = p.namespaces[:len(p.namespaces)-1]
+		return
+	}
+
+	if m := classDeclRe.FindStringSubmatch(line); m != nil {
+		pos := p.position(lineNum)
+		c := p.d.Class(m[1])
+		if c == nil {
+			c = p.ensureClass(m[1], lineNum)
+			c.Position = pos
+		} else if len(p.namespaces) > 0 && c.Namespace == "" {
+			p.addToNamespace(c, p.namespaces[len(p.namespaces)-1])
+		}
+		if !c.Explicit {
+			c.Position = pos
+		} else {
+			c.Redeclared = append(c.Redeclared, lineNum)
+		}
+		c.Explicit = true
+		if m[2] != "" {
+			c.Generic = m[2]
+		}
+		if m[3] != "" {
+			c.Label = m[3]
+		}
+		if m[4] != "" {
+			c.CSSClass = m[4]
+		}
+		if m[5] != "" && m[6] == "" {
+			p.class = c
+		}
+		return
+	}
+
+	if m := classAnnotRe.FindStringSubmatch(line); m != nil && m[2] != "" {
+		c := p.ensureClass(m[2], lineNum)
+		c.Annotations = append(c.Annotations, m[1])
+		p.comments = nil
+		return
+	}
+
+	if m := classRelationRe.FindStringSubmatch(line); m != nil {
+		pos := p.position(lineNum)
+		p.ensureClass(m[1], lineNum)
+		p.ensureClass(m[5], lineNum)
+		p.d.Relations = append(p.d.Relations, &Relation{
+			Position:        pos,
+			From:            m[1],
+			FromCardinality: m[2],
+			Arrow:           m[3],
+			ToCardinality:   m[4],
+			To:              m[5],
+			Label:           strings.TrimSpace(m[6]),
+		})
+		return
+	}
+
+	if m := classNoteRe.FindStringSubmatch(line); m != nil {
+		p.d.Notes = append(p.d.Notes, &Note{Position: p.position(lineNum), For: m[1], Text: m[2]})
+		return
+	}
+
+	if m := classMemberRe.FindStringSubmatch(line); m != nil {
+		c := p.ensureClass(m[1], lineNum)
+		member := parseMember(m[2])
+		member.Position = p.position(lineNum)
+		c.Members = append(c.Members, member)
+		return
+	}
+
+	p.extra(line, lineNum)
+}
+
+// parseMember parses a class member such as "+Name string", "-int count" or "+Run(ctx) error"
+func parseMember(text string) *ClassMember {
+	m := &ClassMember{Text: text}
+	body := strings.TrimSpace(text)
+	if body != "" && strings.ContainsRune("+-#~", rune(body[0])) {
+		m.Visibility = body[:1]
+		body = strings.TrimSpace(body[1:])
+	}
+	body = strings.TrimRight(body, "$*")
+
+	if idx := strings.Index(body, "("); idx >= 0 {
+		m.IsMethod = true
+		m.Name = strings.TrimSpace(body[:idx])
+		if end := strings.LastIndex(body, ")"); end > idx {
+			m.Type = strings.TrimSpace(strings.TrimRight(body[end+1:], "$*"))
+		}
+		return m
+	}
+
+	if idx := strings.Index(body, ":"); idx >= 0 {
+		m.Name = strings.TrimSpace(body[:idx])
+		m.Type = strings.TrimSpace(body[idx+1:])
+		return m
+	}
+
+	fields := strings.Fields(body)
+	switch len(fields) {
+	case 0:
+	case 1:
+		m.Name = fields[0]
+	default:
+		m.Name = fields[len(fields)-1]
+		m.Type = strings.Join(fields[:len(fields)-1], " ")
+	}
+	return m
+}
+
+// splitStatements splits a flowchart line on semicolons outside of quotes and brackets
+func splitStatements(line string) []string {
+	var stmts []string
+	depth := 0
+	inQuote := false
+	start := 0
+	for i, r := range line {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case r == '[' || r == '(' || r == '{':
+			depth++
+		case r == ']' || r == ')' || r == '}':
+			depth--
+		case r == ';' && depth <= 0:
+			if stmt := strings.TrimSpace(line[start:i]); stmt != "" {
+				stmts = append(stmts, stmt)
+			}
+			start = i + 1
+		}
+	}
+	if stmt := strings.TrimSpace(line[start:]); stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+func (p *diagramParser) currentSubgraph() string {
+	if len(p.subgraphs) == 0 {
+		return ""
+	}
+	return p.subgraphs[len(p.subgraphs)-1].ID
+}
+
+func (p *diagramParser) parseFlowchart(line string, lineNum int) {
+	if m := subgraphRe.FindStringSubmatch(line); m != nil {
+		sg := &Subgraph{Position: p.position(lineNum), Parent: p.currentSubgraph()}
+		rest := strings.TrimSpace(m[1])
+		if sm := subgraphIDRe.FindStringSubmatch(rest); sm != nil {
+			sg.ID = sm[1]
+			sg.Label = sm[2]
+		} else {
+			sg.ID = rest
+		}
+		p.d.Subgraphs = append(p.d.Subgraphs, sg)
+		p.subgraphs = append(p.subgraphs, sg)
+		return
+	}
+
+	if line == "end" && len(p.subgraphs) > 0 {
+		p.subgraphs[len(p.subgraphs)-1].EndLine = lineNum
+		p.subgraphs = p.subgraphs[:len(p.subgraphs)-1]
+		p.comments = nil
+		return
+	}
+
+	if strings.HasPrefix(line, "direction ") && len(p.subgraphs) > 0 {
+		p.subgraphs[len(p.subgraphs)-1].Direction = strings.TrimSpace(strings.TrimPrefix(line, "direction "))
+		p.comments = nil
+		return
+	}
+
+	for _, keyword := range []string{"style ", "classDef ", "class ", "click ", "linkStyle ", "direction "} {
+		if strings.HasPrefix(line, keyword) {
+			p.extra(line, lineNum)
+			return
+		}
+	}
+
+	if !p.parseChain(line, lineNum) {
+		p.extra(line, lineNum)
+	}
+}
+
+// flowNode is a node reference inside a statement
+type flowNode struct {
+	id       string
+	shape    string
+	label    string
+	cssClass string
+}
+
+// parseChain parses "A[x] & B --> C -->|y| D" style statements
+func (p *diagramParser) parseChain(line string, lineNum int) bool {
+	rest := line
+	var groups [][]flowNode
+	var links [][2]string // arrow, label
+
+	group, rest, ok := parseNodeGroup(rest)
+	if !ok {
+		return false
+	}
+	groups = append(groups, group)
+
+	for strings.TrimSpace(rest) != "" {
+		var arrow, label string
+		arrow, label, rest, ok = parseLink(strings.TrimSpace(rest))
+		if !ok {
+			return false
+		}
+		group, rest, ok = parseNodeGroup(strings.TrimSpace(rest))
+		if !ok {
+			return false
+		}
+		links = append(links, [2]string{arrow, label})
+		groups = append(groups, group)
+	}
+
+	// The comments are kept once, above the first node the statement declares or else
+	// above its first edge, since the printer declares nodes apart from the edges
+	comments := p.position(lineNum).Comments
+	pos := Position{Line: lineNum}
+	for _, g := range groups {
+		for _, ref := range g {
+			n, declared := p.ensureNode(ref, pos)
+			if comments != nil && ((declared && n.Explicit) || len(links) == 0) {
+				n.Comments = append(n.Comments, comments...)
+				comments = nil
+			}
+		}
+	}
+	for i, link := range links {
+		for _, from := range groups[i] {
+			for _, to := range groups[i+1] {
+				p.d.Edges = append(p.d.Edges, &Edge{
+					Position: Position{Line: lineNum, Comments: comments},
+					From:     from.id,
+					To:       to.id,
+					Arrow:    link[0],
+					Label:    link[1],
+				})
+				comments = nil
+			}
+		}
+	}
+	return true
+}
+
+// ensureNode adds or updates the node of a reference and reports whether the node took the
+// position, because it is new or declared with a shape for the first time
+func (p *diagramParser) ensureNode(ref flowNode, pos Position) (*Node, bool) {
+	declared := false
+	n := p.d.Node(ref.id)
+	if n == nil {
+		n = &Node{Position: pos, ID: ref.id, Subgraph: p.currentSubgraph()}
+		p.d.Nodes = append(p.d.Nodes, n)
+		declared = true
+	}
+	if ref.shape != "" {
+		if !n.Explicit {
+			n.Position = pos
+			declared = true
+		} else if n.Shape != ref.shape || n.Label != ref.label {
+			n.Redeclared = append(n.Redeclared, pos.Line)
+		}
+		n.Shape = ref.shape
+		n.Label = ref.label
+		n.Explicit = true
+	}
+	if ref.cssClass != "" {
+		n.CSSClass = ref.cssClass
+	}
+	return n, declared
+}
+
+// parseNodeGroup parses one or more nodes joined by &
+func parseNodeGroup(s string) ([]flowNode, string, bool) {
+	var nodes []flowNode
+	for {
+		node, rest, ok := parseNode(strings.TrimSpace(s))
+		if !ok {
+			return nil, s, false
+		}
+		nodes = append(nodes, node)
+		rest = strings.TrimSpace(rest)
+		if !strings.HasPrefix(rest, "&") {
+			return nodes, rest, true
+		}
+		s = rest[1:]
+	}
+}
+
+// parseNode parses a node ID with an optional shape and :::class suffix
+func parseNode(s string) (flowNode, string, bool) {
+	id := nodeIDRe.FindString(s)
+	if id == "" {
+		return flowNode{}, s, false
+	}
+	// Keywords can't be node IDs
+	if id == "end" || id == "subgraph" {
+		return flowNode{}, s, false
+	}
+	node := flowNode{id: id}
+	rest := s[len(id):]
+
+	for _, shape := range nodeShapes {
+		if !strings.HasPrefix(rest, shape[0]) {
+			continue
+		}
+		inner := rest[len(shape[0]):]
+		end := -1
+		if strings.HasPrefix(inner, "\"") {
+			if q := strings.Index(inner[1:], "\""); q >= 0 && strings.HasPrefix(inner[q+2:], shape[1]) {
+				end = q + 2
+			}
+		} else {
+			end = strings.Index(inner, shape[1])
+		}
+		if end < 0 {
+			continue
+		}
+		node.shape = shape[0] + shape[1]
+		node.label = inner[:end]
+		rest = inner[end+len(shape[1]):]
+		break
+	}
+
+	if strings.HasPrefix(rest, ":::") {
+		class := nodeIDRe.FindString(rest[3:])
+		node.cssClass = class
+		rest = rest[3+len(class):]
+	}
+
+	return node, rest, true
+}
+
+// parseLink parses an arrow with an optional label, returning the canonical arrow
+func parseLink(s string) (string, string, string, bool) {
+	if m := linkTextRe.FindStringSubmatch(s); m != nil {
+		arrow := m[1] + m[4]
+		switch m[2] {
+		case "-.":
+			arrow = m[1] + "-" + m[4]
+		case "==":
+			if !strings.HasPrefix(m[4], "==") {
+				return "", "", s, false
+			}
+		case "--":
+			if !strings.HasPrefix(m[4], "--") {
+				return "", "", s, false
+			}
+		}
+		return arrow, strings.TrimSpace(m[3]), s[len(m[0]):], true
+	}
+
+	m := linkRe.FindStringSubmatch(s)
+	if m == nil {
+		return "", "", s, false
+	}
+	rest := s[len(m[0]):]
+	label := ""
+	if lm := linkLabelRe.FindStringSubmatch(rest); lm != nil {
+		label = strings.TrimSpace(lm[1])
+		rest = rest[len(lm[0]):]
+	}
+	return m[1] + m[2], label, rest, true
+}
+
+func (p *diagramParser) ensureParticipant(id string, line int) {
+	if p.d.Participant(id) == nil {
+		p.d.Participants = append(p.d.Participants, &Participant{
+			Position: Position{Line: line},
+			ID:       id,
+			Type:     "participant",
+		})
+	}
+}
+
+func (p *diagramParser) event(e *SeqEvent, line int) {
+	e.Position = p.position(line)
+	p.d.Events = append(p.d.Events, e)
+}
+
+func (p *diagramParser) parseSequence(line string, lineNum int) {
+	if m := participantRe.FindStringSubmatch(line); m != nil {
+		existing := p.d.Participant(m[3])
+		if existing == nil {
+			existing = &Participant{ID: m[3]}
+			p.d.Participants = append(p.d.Participants, existing)
+		}
+		if existing.Declared {
+			// Keep the first declaration's line and the comments of both
+			pos := p.position(lineNum)
+			existing.Comments = append(existing.Comments, pos.Comments...)
+			existing.Redeclared = append(existing.Redeclared, lineNum)
+		} else {
+			existing.Position = p.position(lineNum)
+		}
+		existing.Type = m[2]
+		existing.Alias = strings.TrimSpace(m[4])
+		existing.Created = existing.Created || m[1] != ""
+		existing.Declared = true
+		return
+	}
+
+	if line == "end" {
+		p.event(&SeqEvent{Type: EventBlockEnd, Keyword: "end"}, lineNum)
+		return
+	}
+	if m := blockStartRe.FindStringSubmatch(line); m != nil {
+		p.event(&SeqEvent{Type: EventBlockStart, Keyword: m[1], Text: m[2]}, lineNum)
+		return
+	}
+	if m := blockElseRe.FindStringSubmatch(line); m != nil {
+		p.event(&SeqEvent{Type: EventBlockElse, Keyword: m[1], Text: m[2]}, lineNum)
+		return
+	}
+	if m := activationRe.FindStringSubmatch(line); m != nil {
+		p.ensureParticipant(m[2], lineNum)
+		p.event(&SeqEvent{Type: EventActivation, Keyword: m[1], From: m[2]}, lineNum)
+		return
+	}
+	if m := seqNoteRe.FindStringSubmatch(line); m != nil {
+		from := strings.TrimSpace(m[2])
+		to := strings.TrimSpace(m[3])
+		p.ensureParticipant(from, lineNum)
+		if to != "" {
+			p.ensureParticipant(to, lineNum)
+		}
+		p.event(&SeqEvent{Type: EventNote, Keyword: strings.ToLower(m[1]), From: from, To: to, Text: strings.TrimSpace(m[4])}, lineNum)
+		return
+	}
+	if m := messageRe.FindStringSubmatch(line); m != nil {
+		from := strings.TrimSpace(m[1])
+		to := strings.TrimSpace(m[4])
+		p.ensureParticipant(from, lineNum)
+		p.ensureParticipant(to, lineNum)
+		p.event(&SeqEvent{
+			Type:       EventMessage,
+			From:       from,
+			To:         to,
+			Arrow:      m[2],
+			Activation: m[3],
+			Text:       strings.TrimSpace(m[5]),
+		}, lineNum)
+		return
+	}
+
+	p.event(&SeqEvent{Type: EventOther, Text: line}, lineNum)
+}
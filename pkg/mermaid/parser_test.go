@@ -0,0 +1,204 @@
+package mermaid
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ParserTestSuite is a test suite for parsing diagrams into the model and printing them back
+type ParserTestSuite struct {
+	suite.Suite
+}
+
+// parseFixture parses an input fixture of testdata/format
+func (s *ParserTestSuite) parseFixture(name string) *Diagram {
+	src, err := os.ReadFile(filepath.Join("testdata", "format", name+".input.mmd"))
+	s.Require().NoError(err)
+	d, err := Parse(string(src))
+	s.Require().NoError(err)
+	return d
+}
+
+// TestClassDiagram checks classes, members and relations. Fields follow Mermaid's "Type Name"
+// order, Names gives both words for Go style fields.
+func (s *ParserTestSuite) TestClassDiagram() {
+	d := s.parseFixture("class")
+
+	s.Equal(KindClass, d.Kind)
+	s.Require().Len(d.Classes, 2)
+	service := d.Class("Service")
+	s.True(service.Explicit)
+	s.Equal(2, service.Line)
+	s.Equal([]*ClassMember{
+		{Position: Position{Line: 3}, Text: "+Run() error", Visibility: "+", Name: "Run", Type: "error", IsMethod: true},
+		{Position: Position{Line: 4}, Text: "-store Store", Visibility: "-", Name: "Store", Type: "store"},
+		{Position: Position{Line: 5}, Text: "+Name string", Visibility: "+", Name: "string", Type: "Name"},
+	}, service.Members)
+	s.Equal([]string{"Store", "store"}, service.Members[1].Names())
+	s.True(d.Class("Store").Explicit)
+
+	s.Equal([]*Relation{{Position: Position{Line: 7}, From: "Service", To: "Store", Arrow: "-->", Label: "uses"}}, d.Relations)
+}
+
+// TestFlowchart checks nodes, edges, subgraphs and comments
+func (s *ParserTestSuite) TestFlowchart() {
+	d := s.parseFixture("flowchart")
+
+	s.Equal(KindFlowchart, d.Kind)
+	s.Equal("flowchart LR", d.Header)
+	s.Equal([]*Subgraph{{Position: Position{Line: 2}, ID: "api", Label: "API", Direction: "TB", EndLine: 5}}, d.Subgraphs)
+
+	s.Equal("[]", d.Node("handler").Shape)
+	s.Equal("Handler", d.Node("handler").Label)
+	s.Equal("api", d.Node("handler").Subgraph)
+	s.Equal("[()]", d.Node("db").Shape)
+	s.Empty(d.Node("db").Subgraph)
+	s.False(d.Node("cache").Explicit)
+
+	s.Require().Len(d.Edges, 3)
+	s.Equal("reads", d.Edges[1].Label)
+	s.Equal("-.->", d.Edges[2].Arrow)
+	s.Equal([]string{"%% cache is optional"}, d.Edges[2].Comments)
+}
+
+// TestSequence checks participants, messages with activations and blocks
+func (s *ParserTestSuite) TestSequence() {
+	d := s.parseFixture("sequence")
+
+	s.Equal(KindSequence, d.Kind)
+	s.Equal("Client", d.Participant("C").Alias)
+	s.True(d.Participant("S").Declared)
+
+	var types []SeqEventType
+	for _, e := range d.Events {
+		types = append(types, e.Type)
+	}
+	s.Equal([]SeqEventType{EventMessage, EventBlockStart, EventMessage, EventBlockElse, EventMessage, EventBlockEnd}, types)
+
+	messages := d.Messages()
+	s.Require().Len(messages, 3)
+	s.Equal(&SeqEvent{Position: Position{Line: 4}, Type: EventMessage, From: "C", To: "S", Arrow: "->>", Activation: "+", Text: "GET /users"}, messages[0])
+	s.Equal("-", messages[2].Activation)
+}
+
+// TestMissingHeader checks that a diagram without a type declaration is rejected
+func (s *ParserTestSuite) TestMissingHeader() {
+	_, err := Parse("%% just a comment\n")
+	s.ErrorContains(err, "missing diagram type declaration")
+
+	d, err := Parse("pie\n  \"a\" : 1")
+	s.Require().NoError(err)
+	s.Equal(KindOther, d.Kind)
+	s.Equal("pie\n  \"a\" : 1", d.String())
+}
+
+// TestRoundTrip checks that printing a parsed fixture and parsing it again keeps the model, and
+// that the printed diagram is stable
+func (s *ParserTestSuite) TestRoundTrip() {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "format", "*.input.mmd"))
+	s.Require().NoError(err)
+	s.Require().NotEmpty(inputs)
+
+	for _, input := range inputs {
+		s.Run(filepath.Base(input), func() {
+			src, err := os.ReadFile(input)
+			s.Require().NoError(err)
+			first, err := Parse(string(src))
+			s.Require().NoError(err)
+
+			printed := first.String()
+			second, err := Parse(printed)
+			s.Require().NoError(err)
+			s.Equal(printed, second.String())
+
+			// Lines change with the layout, everything else is kept
+			s.Equal(withoutLines(first), withoutLines(second))
+		})
+	}
+}
+
+// withoutLines returns a copy of the model of the diagram with all line numbers cleared
+func withoutLines(d *Diagram) Diagram {
+	c := *d
+	clear := func(p *Position) { p.Line = 0 }
+	c.Classes = nil
+	for _, class := range d.Classes {
+		class := *class
+		clear(&class.Position)
+		class.Redeclared = nil
+		var members []*ClassMember
+		for _, m := range class.Members {
+			m := *m
+			clear(&m.Position)
+			members = append(members, &m)
+		}
+		class.Members = members
+		c.Classes = append(c.Classes, &class)
+	}
+	c.Relations = nil
+	for _, r := range d.Relations {
+		r := *r
+		clear(&r.Position)
+		c.Relations = append(c.Relations, &r)
+	}
+	c.Namespaces = nil
+	for _, ns := range d.Namespaces {
+		ns := *ns
+		clear(&ns.Position)
+		c.Namespaces = append(c.Namespaces, &ns)
+	}
+	c.Notes = nil
+	for _, n := range d.Notes {
+		n := *n
+		clear(&n.Position)
+		c.Notes = append(c.Notes, &n)
+	}
+	c.Nodes = nil
+	for _, n := range d.Nodes {
+		n := *n
+		clear(&n.Position)
+		n.Redeclared = nil
+		c.Nodes = append(c.Nodes, &n)
+	}
+	c.Edges = nil
+	for _, e := range d.Edges {
+		e := *e
+		clear(&e.Position)
+		c.Edges = append(c.Edges, &e)
+	}
+	c.Subgraphs = nil
+	for _, sg := range d.Subgraphs {
+		sg := *sg
+		clear(&sg.Position)
+		sg.EndLine = 0
+		c.Subgraphs = append(c.Subgraphs, &sg)
+	}
+	c.Participants = nil
+	for _, p := range d.Participants {
+		p := *p
+		clear(&p.Position)
+		p.Redeclared = nil
+		c.Participants = append(c.Participants, &p)
+	}
+	c.Events = nil
+	for _, e := range d.Events {
+		e := *e
+		clear(&e.Position)
+		c.Events = append(c.Events, &e)
+	}
+	c.Extra = nil
+	for _, stmt := range d.Extra {
+		stmt := *stmt
+		clear(&stmt.Position)
+		c.Extra = append(c.Extra, &stmt)
+	}
+	return c
+}
+
+// TestParserTestSuite runs the parser test suite
+func TestParserTestSuite(t *testing.T) {
+	suite.Run(t, new(ParserTestSuite))
+}
@@ -0,0 +1,380 @@
+package mermaid
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// printer renders a Diagram back into Mermaid syntax with canonical layout:
+// two space indentation and one statement per line
+type printer struct {
+	b           strings.Builder
+	indent      string
+	sortMembers bool
+	sortNodes   bool
+}
+
+// String renders the diagram as canonical Mermaid syntax
+func (d *Diagram) String() string {
+	return d.Format(FormatOptions{})
+}
+
+func (p *printer) line(depth int, text string) {
+	p.b.WriteString(strings.Repeat(p.indent, depth))
+	p.b.WriteString(text)
+	p.b.WriteString("\n")
+}
+
+func (p *printer) comments(depth int, comments []string) {
+	for _, c := range comments {
+		p.line(depth, c)
+	}
+}
+
+func (p *printer) print(d *Diagram) string {
+	for _, line := range d.Preamble {
+		p.b.WriteString(line)
+		p.b.WriteString("\n")
+	}
+	for _, c := range d.HeaderComments {
+		p.line(0, c)
+	}
+	p.line(0, d.Header)
+
+	// Unmodelled statements before the first modelled one, such as a direction, stay at the top
+	leading := 0
+	first := firstLine(d)
+	for leading < len(d.Extra) && d.Extra[leading].Line > 0 && (first == 0 || d.Extra[leading].Line < first) {
+		leading++
+	}
+	p.statements(d.Extra[:leading])
+
+	switch d.Kind {
+	case KindClass:
+		p.printClassDiagram(d)
+	case KindFlowchart:
+		p.printFlowchart(d)
+	case KindSequence:
+		p.printSequence(d)
+	}
+
+	p.statements(d.Extra[leading:])
+	p.comments(1, d.TrailingComments)
+
+	return strings.TrimRight(p.b.String(), "\n")
+}
+
+func (p *printer) statements(stmts []*Statement) {
+	for _, stmt := range stmts {
+		p.comments(stmt.Depth+1, stmt.Comments)
+		p.line(stmt.Depth+1, stmt.Text)
+	}
+}
+
+// firstLine returns the line of the first modelled statement, 0 when there is none
+func firstLine(d *Diagram) int {
+	var positions []Position
+	for _, c := range d.Classes {
+		positions = append(positions, c.Position)
+	}
+	for _, r := range d.Relations {
+		positions = append(positions, r.Position)
+	}
+	for _, ns := range d.Namespaces {
+		positions = append(positions, ns.Position)
+	}
+	for _, n := range d.Notes {
+		positions = append(positions, n.Position)
+	}
+	for _, n := range d.Nodes {
+		positions = append(positions, n.Position)
+	}
+	for _, e := range d.Edges {
+		positions = append(positions, e.Position)
+	}
+	for _, sg := range d.Subgraphs {
+		positions = append(positions, sg.Position)
+	}
+	for _, part := range d.Participants {
+		positions = append(positions, part.Position)
+	}
+	for _, e := range d.Events {
+		positions = append(positions, e.Position)
+	}
+
+	first := 0
+	for _, pos := range positions {
+		if pos.Line > 0 && (first == 0 || pos.Line < first) {
+			first = pos.Line
+		}
+	}
+	return first
+}
+
+func (p *printer) printClassDiagram(d *Diagram) {
+	for _, ns := range d.Namespaces {
+		p.comments(1, ns.Comments)
+		p.line(1, fmt.Sprintf("namespace %s {", ns.Name))
+		for _, c := range p.orderedClasses(d) {
+			if c.Namespace == ns.Name {
+				p.printClass(c, 2)
+			}
+		}
+		p.line(1, "}")
+	}
+
+	for _, c := range p.orderedClasses(d) {
+		if c.Namespace == "" || d.Namespace(c.Namespace) == nil {
+			p.printClass(c, 1)
+		}
+	}
+
+	for _, r := range d.Relations {
+		p.comments(1, r.Comments)
+		p.line(1, formatRelation(r))
+	}
+
+	for _, n := range d.Notes {
+		p.comments(1, n.Comments)
+		if n.For != "" {
+			p.line(1, fmt.Sprintf("note for %s \"%s\"", n.For, n.Text))
+		} else {
+			p.line(1, fmt.Sprintf("note \"%s\"", n.Text))
+		}
+	}
+}
+
+func (p *printer) orderedClasses(d *Diagram) []*ClassEntity {
+	classes := append([]*ClassEntity(nil), d.Classes...)
+	if p.sortNodes {
+		sort.SliceStable(classes, func(i, j int) bool { return classes[i].ID < classes[j].ID })
+	}
+	return classes
+}
+
+func (p *printer) printClass(c *ClassEntity, depth int) {
+	// Classes that only appear in relations need no declaration
+	if !c.Explicit && len(c.Members) == 0 && len(c.Annotations) == 0 && c.Namespace == "" {
+		return
+	}
+
+	p.comments(depth, c.Comments)
+	decl := "class " + c.ID
+	if c.Generic != "" {
+		decl += "~" + c.Generic + "~"
+	}
+	if c.Label != "" {
+		decl += fmt.Sprintf("[\"%s\"]", c.Label)
+	}
+	if c.CSSClass != "" {
+		decl += ":::" + c.CSSClass
+	}
+
+	if len(c.Members) == 0 && len(c.Annotations) == 0 {
+		p.line(depth, decl)
+		return
+	}
+
+	p.line(depth, decl+" {")
+	for _, a := range c.Annotations {
+		p.line(depth+1, fmt.Sprintf("<<%s>>", a))
+	}
+	members := c.Members
+	if p.sortMembers {
+		members = sortedMembers(members)
+	}
+	for _, m := range members {
+		p.comments(depth+1, m.Comments)
+		p.line(depth+1, strings.TrimSpace(m.Text))
+	}
+	p.line(depth, "}")
+}
+
+// sortedMembers orders fields before methods, each alphabetically by name
+func sortedMembers(members []*ClassMember) []*ClassMember {
+	sorted := append([]*ClassMember(nil), members...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].IsMethod != sorted[j].IsMethod {
+			return !sorted[i].IsMethod
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+func formatRelation(r *Relation) string {
+	var b strings.Builder
+	b.WriteString(r.From)
+	if r.FromCardinality != "" {
+		b.WriteString(fmt.Sprintf(" \"%s\"", r.FromCardinality))
+	}
+	b.WriteString(" " + r.Arrow + " ")
+	if r.ToCardinality != "" {
+		b.WriteString(fmt.Sprintf("\"%s\" ", r.ToCardinality))
+	}
+	b.WriteString(r.To)
+	if r.Label != "" {
+		b.WriteString(" : " + r.Label)
+	}
+	return b.String()
+}
+
+func (p *printer) printFlowchart(d *Diagram) {
+	p.printSubgraphs(d, "", 1)
+
+	for _, n := range p.orderedNodes(d) {
+		if n.Subgraph == "" && p.needsDeclaration(d, n) {
+			p.comments(1, n.Comments)
+			p.line(1, formatNode(n))
+		}
+	}
+
+	for _, e := range d.Edges {
+		p.comments(1, e.Comments)
+		p.line(1, formatEdge(e))
+	}
+}
+
+func (p *printer) printSubgraphs(d *Diagram, parent string, depth int) {
+	for _, sg := range d.Subgraphs {
+		if sg.Parent != parent {
+			continue
+		}
+		p.comments(depth, sg.Comments)
+		if sg.Label != "" {
+			p.line(depth, fmt.Sprintf("subgraph %s [%s]", sg.ID, sg.Label))
+		} else {
+			p.line(depth, "subgraph "+sg.ID)
+		}
+		if sg.Direction != "" {
+			p.line(depth+1, "direction "+sg.Direction)
+		}
+		p.printSubgraphs(d, sg.ID, depth+1)
+		for _, n := range p.orderedNodes(d) {
+			if n.Subgraph == sg.ID {
+				p.comments(depth+1, n.Comments)
+				p.line(depth+1, formatNode(n))
+			}
+		}
+		p.line(depth, "end")
+	}
+}
+
+func (p *printer) orderedNodes(d *Diagram) []*Node {
+	nodes := append([]*Node(nil), d.Nodes...)
+	if p.sortNodes {
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	}
+	return nodes
+}
+
+// needsDeclaration reports whether a top level node must be declared on its own line
+func (p *printer) needsDeclaration(d *Diagram, n *Node) bool {
+	if n.Explicit || n.CSSClass != "" {
+		return true
+	}
+	for _, e := range d.Edges {
+		if e.From == n.ID || e.To == n.ID {
+			return false
+		}
+	}
+	return true
+}
+
+func formatNode(n *Node) string {
+	text := n.ID
+	if n.Shape != "" {
+		half := len(n.Shape) / 2
+		text += n.Shape[:half] + n.Label + n.Shape[half:]
+	}
+	if n.CSSClass != "" {
+		text += ":::" + n.CSSClass
+	}
+	return text
+}
+
+func formatEdge(e *Edge) string {
+	if e.Label != "" {
+		return fmt.Sprintf("%s %s|%s| %s", e.From, e.Arrow, e.Label, e.To)
+	}
+	return fmt.Sprintf("%s %s %s", e.From, e.Arrow, e.To)
+}
+
+func (p *printer) printSequence(d *Diagram) {
+	// Declarations are printed in source order between the events, so that they stay in
+	// their box and after statements such as autonumber. Those without a line come first.
+	var participants []*Participant
+	for _, part := range d.Participants {
+		if part.Declared {
+			participants = append(participants, part)
+		}
+	}
+	sort.SliceStable(participants, func(i, j int) bool { return participants[i].Line < participants[j].Line })
+
+	depth := 1
+	declare := func(line int) {
+		for len(participants) > 0 && participants[0].Line < line {
+			p.printParticipant(participants[0], depth)
+			participants = participants[1:]
+		}
+	}
+
+	for _, e := range d.Events {
+		declare(max(e.Line, 1))
+		switch e.Type {
+		case EventBlockEnd:
+			if depth > 1 {
+				depth--
+			}
+			p.comments(depth, e.Comments)
+			p.line(depth, "end")
+		case EventBlockElse:
+			p.comments(depth-1, e.Comments)
+			p.line(depth-1, strings.TrimSpace(e.Keyword+" "+e.Text))
+		case EventBlockStart:
+			p.comments(depth, e.Comments)
+			p.line(depth, strings.TrimSpace(e.Keyword+" "+e.Text))
+			depth++
+		default:
+			p.comments(depth, e.Comments)
+			p.line(depth, FormatEvent(e))
+		}
+	}
+	for _, part := range participants {
+		p.printParticipant(part, depth)
+	}
+}
+
+func (p *printer) printParticipant(part *Participant, depth int) {
+	p.comments(depth, part.Comments)
+	decl := fmt.Sprintf("%s %s", part.Type, part.ID)
+	if part.Created {
+		decl = "create " + decl
+	}
+	if part.Alias != "" {
+		decl += " as " + part.Alias
+	}
+	p.line(depth, decl)
+}
+
+// FormatEvent renders a single sequence diagram statement
+func FormatEvent(e *SeqEvent) string {
+	switch e.Type {
+	case EventMessage:
+		return fmt.Sprintf("%s%s%s%s: %s", e.From, e.Arrow, e.Activation, e.To, e.Text)
+	case EventNote:
+		target := e.From
+		if e.To != "" {
+			target += "," + e.To
+		}
+		return fmt.Sprintf("Note %s %s: %s", e.Keyword, target, e.Text)
+	case EventActivation:
+		return fmt.Sprintf("%s %s", e.Keyword, e.From)
+	case EventBlockStart, EventBlockElse:
+		return strings.TrimSpace(e.Keyword + " " + e.Text)
+	case EventBlockEnd:
+		return "end"
+	}
+	return e.Text
+}
@@ -20,6 +20,14 @@ const (
 	Config DiagramType = "config"
 	// Adapters diagram type for showing inbound/outbound communications
 	Adapters DiagramType = "adapters"
+	// Proto diagram type for showing gRPC services, RPCs and messages from .proto files
+	Proto DiagramType = "proto"
+	// GRPC diagram type for showing gRPC client/server wiring found in Go code
+	GRPC DiagramType = "grpc"
+	// Concurrency diagram type for showing goroutines and the channels they communicate over
+	Concurrency DiagramType = "concurrency"
+	// Implements diagram type for showing interfaces and the concrete types that satisfy them
+	Implements DiagramType = "implements"
 )
 
 // CreatePrompt creates a prompt for generating a Mermaid diagram
@@ -52,3 +60,8 @@ func CreatePrompt(codeContent string, diagramType DiagramType) string {
 func FormatOutput(diagram string) string {
 	return fmt.Sprintf("```mermaid\n%s\n```", diagram)
 }
+
+// CreateHybridPrompt creates a prompt for annotating a class diagram skeleton extracted from code
+func CreateHybridPrompt(skeleton, codeContent string) string {
+	return fmt.Sprintf("The following Mermaid class diagram was extracted from Go code by static analysis and is complete:\n\n```mermaid\n%s\n```\n\nSource code:\n\n```go\n%s\n```\n\nReturn the same class diagram with namespaces grouping related classes, a short label on every relationship and notes for non-obvious classes. Do not add, rename or remove classes, members or relationships. Provide only the Mermaid diagram syntax without any explanation or markdown formatting.", skeleton, codeContent)
+}
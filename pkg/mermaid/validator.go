@@ -60,10 +60,6 @@ func ValidateSyntax(diagram string) ValidationResult {
 
 	// Use mmdc to validate the syntax
 	cmd := exec.Command("mmdc", "-i", tempFile, "-o", filepath.Join(tempDir, "output.svg"))
-	// tmp svg file
-	tmpSvgFile := filepath.Join(tempDir, "output.svg")
-	// Print tmp svg file
-	fmt.Println("Temp svg file:", tmpSvgFile)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
@@ -121,6 +117,7 @@ func basicSyntaxCheck(diagram string) ValidationResult {
 	}
 
 	// Check for common syntax errors
+	var openBraces []int
 	for i, line := range lines {
 		trimmedLine := strings.TrimSpace(line)
 
@@ -158,16 +155,35 @@ func basicSyntaxCheck(diagram string) ValidationResult {
 			})
 		}
 
-		if strings.Count(trimmedLine, "{") != strings.Count(trimmedLine, "}") {
-			result.IsValid = false
-			result.Errors = append(result.Errors, SyntaxError{
-				Line:    i + 1,
-				Message: "Mismatched curly braces",
-				Text:    trimmedLine,
-			})
+		// Class and state blocks span lines, so curly braces are matched across lines
+		for _, r := range trimmedLine {
+			switch r {
+			case '{':
+				openBraces = append(openBraces, i)
+			case '}':
+				if len(openBraces) == 0 {
+					result.IsValid = false
+					result.Errors = append(result.Errors, SyntaxError{
+						Line:    i + 1,
+						Message: "Mismatched curly braces",
+						Text:    trimmedLine,
+					})
+					continue
+				}
+				openBraces = openBraces[:len(openBraces)-1]
+			}
 		}
 	}
 
+	for _, i := range openBraces {
+		result.IsValid = false
+		result.Errors = append(result.Errors, SyntaxError{
+			Line:    i + 1,
+			Message: "Unclosed curly brace",
+			Text:    strings.TrimSpace(lines[i]),
+		})
+	}
+
 	return result
 }
 
@@ -0,0 +1,73 @@
+package mermaid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ValidatorTestSuite is a test suite for the syntax check without the Mermaid CLI
+type ValidatorTestSuite struct {
+	suite.Suite
+}
+
+// errorLines returns the line and message of every error of the check
+func (s *ValidatorTestSuite) errorLines(diagram string) map[int]string {
+	lines := make(map[int]string)
+	for _, err := range basicSyntaxCheck(diagram).Errors {
+		lines[err.Line] = err.Message
+	}
+	return lines
+}
+
+// TestBlocksAcrossLines checks that class and state blocks spanning lines are valid
+func (s *ValidatorTestSuite) TestBlocksAcrossLines() {
+	result := basicSyntaxCheck(`classDiagram
+  class Repository {
+    <<interface>>
+    +Find(id string) User
+  }
+  class Cache { +Get() }`)
+	s.True(result.IsValid, result.Errors)
+
+	result = basicSyntaxCheck(`stateDiagram-v2
+  state Active {
+    [*] --> Running
+    state Running {
+      Idle --> Busy
+    }
+  }`)
+	s.True(result.IsValid, result.Errors)
+}
+
+// TestMismatchedBraces checks the lines reported for braces without a partner
+func (s *ValidatorTestSuite) TestMismatchedBraces() {
+	s.Equal(map[int]string{2: "Unclosed curly brace"}, s.errorLines(`classDiagram
+  class Order {
+    +ID string
+  class Invoice`))
+
+	s.Equal(map[int]string{3: "Mismatched curly braces"}, s.errorLines(`classDiagram
+  class Order
+  }`))
+
+	// A brace in a comment isn't counted
+	s.Empty(s.errorLines(`flowchart TD
+  %% {
+  A --> B{Decision}`))
+}
+
+// TestPerLineChecks checks that quotes and brackets are still matched on each line
+func (s *ValidatorTestSuite) TestPerLineChecks() {
+	s.Equal(map[int]string{
+		2: "Mismatched square brackets",
+		3: "Unclosed quotes",
+	}, s.errorLines(`flowchart TD
+  A[Start --> B
+  B --> C["End]`))
+}
+
+// TestValidatorTestSuite runs the validator test suite
+func TestValidatorTestSuite(t *testing.T) {
+	suite.Run(t, new(ValidatorTestSuite))
+}
@@ -0,0 +1,131 @@
+package prompt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultMaxExamples is the number of examples added to a prompt unless configured otherwise
+	DefaultMaxExamples = 2
+	// MaxExampleSize is the number of characters of code and diagrams the examples of a prompt may use
+	MaxExampleSize = 12000
+)
+
+// Example is a Go snippet and the diagram it should produce, used as few-shot context
+type Example struct {
+	// DiagramType is the diagram type the example applies to, e.g. class
+	DiagramType string `yaml:"type"`
+	Name        string `yaml:"name"`
+	Code        string `yaml:"code"`
+	Diagram     string `yaml:"diagram"`
+	// Source is the file the example was read from
+	Source string `yaml:"-"`
+}
+
+// size returns the number of characters the example adds to a prompt
+func (e Example) size() int {
+	return len(e.Code) + len(e.Diagram)
+}
+
+// LoadExamples reads the examples in dir. Each subdirectory is named after a diagram type and
+// holds pairs of files with the same name: the Go code in name.go and the diagram in name.mmd.
+func LoadExamples(dir string) ([]Example, error) {
+	typeDirs, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read examples: %w", err)
+	}
+
+	var examples []Example
+	for _, typeDir := range typeDirs {
+		if !typeDir.IsDir() {
+			continue
+		}
+
+		codeFiles, err := filepath.Glob(filepath.Join(dir, typeDir.Name(), "*.go"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list examples in %s: %w", typeDir.Name(), err)
+		}
+		for _, codeFile := range codeFiles {
+			code, err := os.ReadFile(codeFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read example %s: %w", codeFile, err)
+			}
+
+			diagramFile := strings.TrimSuffix(codeFile, ".go") + ".mmd"
+			diagram, err := os.ReadFile(diagramFile)
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("example %s has no diagram %s", codeFile, filepath.Base(diagramFile))
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to read example %s: %w", diagramFile, err)
+			}
+
+			examples = append(examples, Example{
+				DiagramType: typeDir.Name(),
+				Name:        strings.TrimSuffix(filepath.Base(codeFile), ".go"),
+				Code:        strings.TrimSpace(string(code)),
+				Diagram:     strings.TrimSpace(string(diagram)),
+				Source:      codeFile,
+			})
+		}
+	}
+	return examples, nil
+}
+
+// UseExamples sets the examples prompts are given, at most max per prompt (DefaultMaxExamples if
+// max is 0)
+func (m *TemplateManager) UseExamples(examples []Example, max int) {
+	if max <= 0 {
+		max = DefaultMaxExamples
+	}
+	m.examples = examples
+	m.maxExamples = max
+}
+
+// Examples returns the examples prompts are given
+func (m *TemplateManager) Examples() []Example {
+	return m.examples
+}
+
+// selectExamples returns the examples of a diagram type whose code is closest in size to the
+// code of the prompt, as many as the maximum number and the size budget allow
+func (m *TemplateManager) selectExamples(diagramType string, codeSize int) []Example {
+	var candidates []Example
+	for _, example := range m.examples {
+		if example.DiagramType == diagramType {
+			candidates = append(candidates, example)
+		}
+	}
+
+	distance := func(e Example) int {
+		d := len(e.Code) - codeSize
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if di, dj := distance(candidates[i]), distance(candidates[j]); di != dj {
+			return di < dj
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+
+	var selected []Example
+	size := 0
+	for _, example := range candidates {
+		if len(selected) == m.maxExamples {
+			break
+		}
+		if size+example.size() > MaxExampleSize {
+			continue
+		}
+		selected = append(selected, example)
+		size += example.size()
+	}
+	return selected
+}
@@ -1,55 +1,173 @@
 package prompt
 
 import (
+	"embed"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 
 	"mm-go-agent/pkg/mermaid"
 )
 
+// EnvTemplatesDir is the environment variable naming a directory of templates that shadow the
+// embedded ones
+const EnvTemplatesDir = "MM_GEN_TEMPLATES"
+
+//go:embed templates/*.tmpl
+var embedded embed.FS
+
+// funcs are the functions available to templates
+var funcs = template.FuncMap{
+	"join": strings.Join,
+}
+
 // TemplateManager handles the loading and execution of prompt templates
 type TemplateManager struct {
 	templates *template.Template
+	// sources maps template names to the override file they were read from, embedded
+	// templates have no entry
+	sources map[string]string
+	// examples are the few-shot examples prompts are selected from
+	examples    []Example
+	maxExamples int
 }
 
-// New creates a new TemplateManager
+// New creates a new TemplateManager with the embedded templates, shadowed by the templates in
+// the directory named by EnvTemplatesDir if it is set
 func New() (*TemplateManager, error) {
-	// Get the current working directory
-	cwd, err := os.Getwd()
+	return Load(os.Getenv(EnvTemplatesDir))
+}
+
+// Load creates a new TemplateManager with the embedded templates. Every *.tmpl file in dir
+// replaces the embedded template of the same name or adds a new one. An empty dir loads the
+// embedded templates only.
+func Load(dir string) (*TemplateManager, error) {
+	templates, err := template.New("").Funcs(funcs).ParseFS(embedded, "templates/*.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse templates: %w", err)
+	}
+
+	m := &TemplateManager{templates: templates, sources: make(map[string]string)}
+	if dir == "" {
+		return m, nil
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to read templates: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get current directory: %w", err)
-	}
-
-	// Find the templates directory relative to the current directory
-	// Try different relative paths to handle various test environments
-	templatesDir := filepath.Join(cwd, "templates")
-	if _, err := os.Stat(templatesDir); os.IsNotExist(err) {
-		// If running from project root
-		templatesDir = filepath.Join(cwd, "pkg", "prompt", "templates")
-		if _, err := os.Stat(templatesDir); os.IsNotExist(err) {
-			// If running from a test directory
-			templatesDir = filepath.Join(cwd, "..", "prompt", "templates")
+		return nil, fmt.Errorf("failed to list templates in %s: %w", dir, err)
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %s: %w", file, err)
+		}
+		name := filepath.Base(file)
+		if _, err := m.templates.New(name).Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", file, err)
+		}
+		m.sources[name] = file
+	}
+	return m, nil
+}
+
+// Names returns the names of the loaded templates in alphabetical order
+func (m *TemplateManager) Names() []string {
+	if m.templates == nil {
+		return nil
+	}
+
+	var names []string
+	for _, t := range m.templates.Templates() {
+		if strings.HasSuffix(t.Name(), ".tmpl") {
+			names = append(names, t.Name())
 		}
 	}
+	sort.Strings(names)
+	return names
+}
 
-	// Load all templates from the directory
-	templates, err := template.ParseGlob(filepath.Join(templatesDir, "*.tmpl"))
+// Source returns the override file a template was read from, or an empty string for an
+// embedded template
+func (m *TemplateManager) Source(name string) string {
+	return m.sources[templateName(name)]
+}
+
+// Text returns the unparsed content of a template. The .tmpl extension may be omitted.
+func (m *TemplateManager) Text(name string) (string, error) {
+	name = templateName(name)
+	if file, ok := m.sources[name]; ok {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read template %s: %w", file, err)
+		}
+		return string(content), nil
+	}
+
+	content, err := embedded.ReadFile(path.Join("templates", name))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse templates: %w", err)
+		return "", fmt.Errorf("template %q not found", name)
+	}
+	return string(content), nil
+}
+
+// Export writes the embedded templates to dir, creating it if needed, and returns the written
+// files. Existing files are only replaced with overwrite.
+func Export(dir string, overwrite bool) ([]string, error) {
+	entries, err := embedded.ReadDir("templates")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
 	}
 
-	return &TemplateManager{
-		templates: templates,
-	}, nil
+	if !overwrite {
+		for _, entry := range entries {
+			target := filepath.Join(dir, entry.Name())
+			if _, err := os.Stat(target); err == nil {
+				return nil, fmt.Errorf("%s already exists", target)
+			}
+		}
+	}
+
+	var written []string
+	for _, entry := range entries {
+		content, err := embedded.ReadFile(path.Join("templates", entry.Name()))
+		if err != nil {
+			return written, fmt.Errorf("failed to read template %s: %w", entry.Name(), err)
+		}
+		target := filepath.Join(dir, entry.Name())
+		if err := os.WriteFile(target, content, 0644); err != nil {
+			return written, fmt.Errorf("failed to write %s: %w", target, err)
+		}
+		written = append(written, target)
+	}
+	return written, nil
+}
+
+// templateName adds the .tmpl extension to a template name without one
+func templateName(name string) string {
+	if strings.HasSuffix(name, ".tmpl") {
+		return name
+	}
+	return name + ".tmpl"
 }
 
 // DiagramPromptData contains the data for generating a diagram prompt
 type DiagramPromptData struct {
 	CodeContent string
 	DiagramType string
+	// Notes are notes about the code given by the user
+	Notes    string
+	Examples []Example
 }
 
 // ValidationPromptData contains the data for generating a validation prompt
@@ -62,20 +180,129 @@ type ValidationPromptData struct {
 }
 
 // GetDiagramPrompt generates a prompt for creating a mermaid diagram
-func (m *TemplateManager) GetDiagramPrompt(codeContent string, diagramType mermaid.DiagramType) (string, error) {
+func (m *TemplateManager) GetDiagramPrompt(codeContent string, diagramType mermaid.DiagramType, notes string) (string, error) {
 	templateName := fmt.Sprintf("%s_diagram.tmpl", diagramType)
 
 	data := DiagramPromptData{
 		CodeContent: codeContent,
 		DiagramType: string(diagramType),
+		Notes:       notes,
+		Examples:    m.selectExamples(string(diagramType), len(codeContent)),
 	}
 
-	var buf strings.Builder
-	if err := m.templates.ExecuteTemplate(&buf, templateName, data); err != nil {
-		return "", fmt.Errorf("failed to execute template %q: %w", templateName, err)
+	return m.execute(templateName, data)
+}
+
+// SourceFile is a source file included in a prompt
+type SourceFile struct {
+	Path    string
+	Package string
+	// Language is the language of the code block, go or proto
+	Language string
+	Content  string
+}
+
+// ScopePromptData contains the data for generating a diagram of several files, such as a
+// component, packages or the whole project
+type ScopePromptData struct {
+	DiagramType string
+	// Scope describes the files, e.g. "the service 'user'"
+	Scope         string
+	ComponentType string
+	ModulePath    string
+	// Packages are the names of the packages of the files, sorted and without duplicates
+	Packages []string
+	Files    []SourceFile
+	Notes    string
+	// Examples are selected by the template manager
+	Examples []Example
+}
+
+// withExamples returns the data with the examples for its diagram type and files
+func (m *TemplateManager) withExamples(data ScopePromptData) ScopePromptData {
+	size := 0
+	for _, file := range data.Files {
+		size += len(file.Content)
 	}
+	data.Examples = m.selectExamples(data.DiagramType, size)
+	return data
+}
 
-	return buf.String(), nil
+// GetComponentPrompt generates a prompt for creating a diagram of the files of a component,
+// directory or target
+func (m *TemplateManager) GetComponentPrompt(data ScopePromptData) (string, error) {
+	return m.execute("component.tmpl", m.withExamples(data))
+}
+
+// GetProjectPrompt generates a prompt for creating a project-wide diagram. Diagram types
+// without a project_<type>.tmpl template use project_overview.tmpl.
+func (m *TemplateManager) GetProjectPrompt(data ScopePromptData) (string, error) {
+	name := fmt.Sprintf("project_%s.tmpl", data.DiagramType)
+	if m.templates == nil || m.templates.Lookup(name) == nil {
+		name = "project_overview.tmpl"
+	}
+	return m.execute(name, m.withExamples(data))
+}
+
+// GetComponentClassPrompt generates a prompt for creating the class diagram of one component
+// type of a project class diagram
+func (m *TemplateManager) GetComponentClassPrompt(data ScopePromptData) (string, error) {
+	return m.execute("component_class.tmpl", m.withExamples(data))
+}
+
+// ComponentDiagram is the diagram generated for a component type
+type ComponentDiagram struct {
+	ComponentType string
+	Diagram       string
+}
+
+// RelationshipsPromptData contains the data for relating the diagrams of component types
+type RelationshipsPromptData struct {
+	ModulePath        string
+	ComponentDiagrams []ComponentDiagram
+	Notes             string
+}
+
+// GetRelationshipsPrompt generates a prompt for the relationships between the class diagrams of
+// component types
+func (m *TemplateManager) GetRelationshipsPrompt(data RelationshipsPromptData) (string, error) {
+	return m.execute("relationships.tmpl", data)
+}
+
+// HybridPromptData contains the data for annotating a class diagram skeleton
+type HybridPromptData struct {
+	Skeleton    string
+	CodeContent string
+	Scope       string
+}
+
+// GetHybridPrompt generates a prompt for annotating a class diagram skeleton extracted from code
+func (m *TemplateManager) GetHybridPrompt(skeleton, codeContent, scope string) (string, error) {
+	data := HybridPromptData{
+		Skeleton:    skeleton,
+		CodeContent: codeContent,
+		Scope:       scope,
+	}
+
+	return m.execute("hybrid_annotate.tmpl", data)
+}
+
+// CorrectionPromptData contains the data for correcting a diagram that failed verification
+type CorrectionPromptData struct {
+	Diagram     string
+	Report      string
+	CodeContent string
+}
+
+// GetCorrectionPrompt generates a prompt for correcting a diagram that doesn't match the source code
+func (m *TemplateManager) GetCorrectionPrompt(diagram, report, codeContent string) (string, error) {
+	data := CorrectionPromptData{
+		Diagram:     diagram,
+		Report:      report,
+		CodeContent: codeContent,
+	}
+
+	return m.execute("correct_diagram.tmpl", data)
 }
 
 // GetFixPrompt generates a prompt for fixing a mermaid diagram
@@ -93,12 +320,36 @@ func (m *TemplateManager) GetFixPrompt(diagram string, validationResult mermaid.
 		MaxRetries:       maxRetries,
 	}
 
-	var buf strings.Builder
-	if err := m.templates.ExecuteTemplate(&buf, "fix_diagram.tmpl", data); err != nil {
-		return "", fmt.Errorf("failed to execute template %q: %w", "fix_diagram.tmpl", err)
-	}
+	return m.execute("fix_diagram.tmpl", data)
+}
 
-	return buf.String(), nil
+// GraphPromptData contains the data for asking for a diagram as a JSON graph
+type GraphPromptData struct {
+	// Prompt is the prompt generating the diagram as Mermaid text
+	Prompt string
+	Kind   string
+	Schema string
+	// Tool is the name of the tool the graph is returned with, where the LLM supports tool use
+	Tool string
+}
+
+// GetGraphPrompt generates a prompt asking for the diagram of a generation prompt as a JSON graph
+func (m *TemplateManager) GetGraphPrompt(data GraphPromptData) (string, error) {
+	return m.execute("graph_output.tmpl", data)
+}
+
+// GraphFixPromptData contains the data for fixing the schema violations of a JSON graph
+type GraphFixPromptData struct {
+	Kind       string
+	Schema     string
+	Tool       string
+	Graph      string
+	Violations []string
+}
+
+// GetGraphFixPrompt generates a prompt for fixing the schema violations of a JSON graph
+func (m *TemplateManager) GetGraphFixPrompt(data GraphFixPromptData) (string, error) {
+	return m.execute("fix_graph.tmpl", data)
 }
 
 // GetExplanationPrompt generates a prompt for explaining mermaid diagram errors
@@ -108,9 +359,18 @@ func (m *TemplateManager) GetExplanationPrompt(diagram string, validationResult
 		ValidationResult: mermaid.ValidationResultAsContext(validationResult),
 	}
 
+	return m.execute("explain_errors.tmpl", data)
+}
+
+// execute runs the named template with the given data
+func (m *TemplateManager) execute(name string, data any) (string, error) {
+	if m.templates == nil {
+		return "", fmt.Errorf("template %q not loaded", name)
+	}
+
 	var buf strings.Builder
-	if err := m.templates.ExecuteTemplate(&buf, "explain_errors.tmpl", data); err != nil {
-		return "", fmt.Errorf("failed to execute template %q: %w", "explain_errors.tmpl", err)
+	if err := m.templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("failed to execute template %q: %w", name, err)
 	}
 
 	return buf.String(), nil
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"text/template"
 
@@ -143,7 +144,7 @@ func (s *PromptTestSuite) TestGetDiagramPrompt() {
 	for _, tc := range testCases {
 		tc := tc // Capture range variable
 		s.T().Run(fmt.Sprintf("DiagramType=%s", tc.diagramType), func(t *testing.T) {
-			prompt, err := s.templateManager.GetDiagramPrompt(s.sampleGoCode, tc.diagramType)
+			prompt, err := s.templateManager.GetDiagramPrompt(s.sampleGoCode, tc.diagramType, "")
 
 			if tc.expectError {
 				assert.Error(t, err, "Expected error for diagram type %s, but got none", tc.diagramType)
@@ -209,6 +210,177 @@ func (s *PromptTestSuite) TestGetExplanationPrompt() {
 	}
 }
 
+// TestEmbeddedTemplates checks that the templates load without a templates directory
+func (s *PromptTestSuite) TestEmbeddedTemplates() {
+	m, err := Load("")
+	s.Require().NoError(err)
+	s.Contains(m.Names(), "class_diagram.tmpl")
+	s.Contains(m.Names(), "fix_diagram.tmpl")
+	s.Empty(m.Source("class_diagram"))
+
+	prompt, err := m.GetDiagramPrompt(s.sampleGoCode, mermaid.Class, "")
+	s.Require().NoError(err)
+	s.Contains(prompt, s.sampleGoCode)
+
+	text, err := m.Text("class_diagram")
+	s.Require().NoError(err)
+	s.Contains(text, "{{.CodeContent}}")
+
+	_, err = m.Text("missing")
+	s.ErrorContains(err, `template "missing.tmpl" not found`)
+}
+
+// TestOverrideTemplates checks that templates in a directory shadow the embedded ones
+func (s *PromptTestSuite) TestOverrideTemplates() {
+	dir := filepath.Join(s.tempDir, "templates")
+	m, err := Load(dir)
+	s.Require().NoError(err)
+	s.Equal(filepath.Join(dir, "class_diagram.tmpl"), m.Source("class_diagram.tmpl"))
+	s.Empty(m.Source("hybrid_annotate.tmpl"))
+
+	prompt, err := m.GetDiagramPrompt(s.sampleGoCode, mermaid.Class, "")
+	s.Require().NoError(err)
+	s.Contains(prompt, "Create a class diagram from this Go code")
+
+	_, err = m.GetHybridPrompt("classDiagram", s.sampleGoCode, "the file")
+	s.NoError(err, "templates without override should still be embedded")
+
+	s.T().Setenv(EnvTemplatesDir, dir)
+	m, err = New()
+	s.Require().NoError(err)
+	s.NotEmpty(m.Source("class_diagram.tmpl"))
+
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "broken_diagram.tmpl"), []byte("{{.CodeContent"), 0644))
+	_, err = Load(dir)
+	s.ErrorContains(err, "failed to parse template")
+
+	_, err = Load(filepath.Join(s.tempDir, "missing"))
+	s.Error(err)
+}
+
+// TestExport checks that the embedded templates are exported without replacing files
+func (s *PromptTestSuite) TestExport() {
+	dir := filepath.Join(s.tempDir, "exported")
+	written, err := Export(dir, false)
+	s.Require().NoError(err)
+	s.Contains(written, filepath.Join(dir, "class_diagram.tmpl"))
+
+	m, err := Load(dir)
+	s.Require().NoError(err)
+	s.Equal(len(written), len(m.Names()))
+
+	_, err = Export(dir, false)
+	s.ErrorContains(err, "already exists")
+
+	_, err = Export(dir, true)
+	s.NoError(err)
+}
+
+// TestScopePrompts checks the prompts of components, projects and relationships
+func (s *PromptTestSuite) TestScopePrompts() {
+	m, err := Load("")
+	s.Require().NoError(err)
+
+	data := ScopePromptData{
+		DiagramType:   "class",
+		Scope:         "the service 'user'",
+		ComponentType: "service",
+		ModulePath:    "example.com/users",
+		Packages:      []string{"services", "store"},
+		Files: []SourceFile{
+			{Path: "internal/services/user.go", Package: "services", Language: "go", Content: s.sampleGoCode},
+			{Path: "api/user.proto", Language: "proto", Content: "service Users {}"},
+		},
+		Notes: "Ignore the metrics",
+	}
+
+	prompt, err := m.GetComponentPrompt(data)
+	s.Require().NoError(err)
+	s.Contains(prompt, "The code below is the service 'user' in the Go module example.com/users. It is part of the packages services, store.")
+	s.Contains(prompt, "```go\n// File: internal/services/user.go\n")
+	s.Contains(prompt, "```proto\n// File: api/user.proto\nservice Users {}\n```")
+	s.Contains(prompt, "# NOTES\nTake these notes about the code into account:\nIgnore the metrics")
+
+	prompt, err = m.GetComponentClassPrompt(data)
+	s.Require().NoError(err)
+	s.Contains(prompt, "class diagram for the 'service' components")
+
+	data.DiagramType = "sequence"
+	prompt, err = m.GetProjectPrompt(data)
+	s.Require().NoError(err)
+	s.Contains(prompt, "Create a sequence diagram showing the interactions")
+
+	data.DiagramType = "grpc"
+	prompt, err = m.GetProjectPrompt(data)
+	s.Require().NoError(err)
+	s.Contains(prompt, "overall architecture of this Go project")
+
+	prompt, err = m.GetRelationshipsPrompt(RelationshipsPromptData{
+		ComponentDiagrams: []ComponentDiagram{
+			{ComponentType: "service", Diagram: "classDiagram\n  class UserService"},
+			{ComponentType: "model", Diagram: "classDiagram\n  class User"},
+		},
+	})
+	s.Require().NoError(err)
+	s.Contains(prompt, "different component types (service, model)")
+	s.Contains(prompt, "%% model components\nclassDiagram\n  class User")
+	s.NotContains(prompt, "# NOTES")
+}
+
+// TestExamples checks loading examples and selecting them by diagram type and size
+func (s *PromptTestSuite) TestExamples() {
+	dir := filepath.Join(s.tempDir, "examples")
+	files := map[string]string{
+		"class/small.go":      "type A struct{}",
+		"class/small.mmd":     "classDiagram\n  class A",
+		"class/large.go":      strings.Repeat("type B struct{}\n", 20),
+		"class/large.mmd":     "classDiagram\n  class B",
+		"sequence/flow.go":    "func main() { run() }",
+		"sequence/flow.mmd":   "sequenceDiagram\n  main->>run: call",
+		"sequence/README.txt": "not an example",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		s.Require().NoError(os.MkdirAll(filepath.Dir(path), 0755))
+		s.Require().NoError(os.WriteFile(path, []byte(content), 0644))
+	}
+
+	examples, err := LoadExamples(dir)
+	s.Require().NoError(err)
+	s.Len(examples, 3)
+
+	m, err := Load("")
+	s.Require().NoError(err)
+	m.UseExamples(examples, 1)
+
+	selected := m.selectExamples("class", 10)
+	s.Require().Len(selected, 1)
+	s.Equal("small", selected[0].Name)
+
+	selected = m.selectExamples("class", 400)
+	s.Require().Len(selected, 1)
+	s.Equal("large", selected[0].Name)
+	s.Empty(m.selectExamples("flowchart", 10))
+
+	prompt, err := m.GetDiagramPrompt("type C struct{}", mermaid.Class, "")
+	s.Require().NoError(err)
+	s.Contains(prompt, "## small\n```go\ntype A struct{}\n```")
+	s.NotContains(prompt, "UserRepository", "curated examples should replace the built-in example")
+
+	prompt, err = m.GetComponentPrompt(ScopePromptData{DiagramType: "sequence", Scope: "the service 'user'"})
+	s.Require().NoError(err)
+	s.Contains(prompt, "main->>run: call")
+
+	m.UseExamples(nil, 0)
+	prompt, err = m.GetDiagramPrompt("type C struct{}", mermaid.Class, "")
+	s.Require().NoError(err)
+	s.Contains(prompt, "UserRepository")
+
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "class", "lonely.go"), []byte("type D struct{}"), 0644))
+	_, err = LoadExamples(dir)
+	s.ErrorContains(err, "has no diagram lonely.mmd")
+}
+
 // TestPromptSuite runs the test suite
 func TestPromptSuite(t *testing.T) {
 	suite.Run(t, new(PromptTestSuite))
@@ -0,0 +1,169 @@
+package proto
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ClassDiagram renders the services, messages and enums of the given files as a Mermaid class diagram
+func ClassDiagram(files ...*File) string {
+	var b strings.Builder
+	b.WriteString("classDiagram\n")
+
+	// Declared types by name and by package qualified name
+	known := make(map[string]string)
+	for _, f := range files {
+		declare := func(name string) {
+			known[name] = name
+			if f.Package != "" {
+				known[f.Package+"."+name] = name
+			}
+		}
+		for _, m := range f.Messages {
+			declare(m.Name)
+		}
+		for _, e := range f.Enums {
+			declare(e.Name)
+		}
+	}
+
+	var relations []string
+	seen := make(map[string]bool)
+	addRelation := func(rel string) {
+		if !seen[rel] {
+			seen[rel] = true
+			relations = append(relations, rel)
+		}
+	}
+
+	for _, f := range files {
+		if len(files) > 1 {
+			b.WriteString(fmt.Sprintf("  %%%% %s\n", f.Name))
+		}
+
+		for _, svc := range f.Services {
+			b.WriteString(fmt.Sprintf("  class %s {\n", classID(svc.Name)))
+			b.WriteString("    <<service>>\n")
+			for _, rpc := range svc.RPCs {
+				b.WriteString(fmt.Sprintf("    +%s(%s) %s\n", rpc.Name,
+					streamType(rpc.Request, rpc.ClientStreaming), streamType(rpc.Response, rpc.ServerStreaming)))
+
+				if name := resolve(rpc.Request, f.Package, "", known); name != "" {
+					addRelation(fmt.Sprintf("  %s ..> %s : %s request", classID(svc.Name), classID(name), rpc.Name))
+				}
+				if name := resolve(rpc.Response, f.Package, "", known); name != "" {
+					addRelation(fmt.Sprintf("  %s ..> %s : %s response", classID(svc.Name), classID(name), rpc.Name))
+				}
+			}
+			b.WriteString("  }\n")
+		}
+
+		for _, msg := range f.Messages {
+			b.WriteString(fmt.Sprintf("  class %s {\n", classID(msg.Name)))
+			b.WriteString("    <<message>>\n")
+			for _, field := range msg.Fields {
+				b.WriteString(fmt.Sprintf("    +%s %s\n", fieldType(field), field.Name))
+
+				for _, ref := range referencedTypes(field.Type) {
+					if name := resolve(ref, f.Package, msg.Name, known); name != "" && name != msg.Name {
+						addRelation(fmt.Sprintf("  %s --> %s : %s", classID(msg.Name), classID(name), field.Name))
+					}
+				}
+			}
+			b.WriteString("  }\n")
+		}
+
+		for _, enum := range f.Enums {
+			b.WriteString(fmt.Sprintf("  class %s {\n", classID(enum.Name)))
+			b.WriteString("    <<enumeration>>\n")
+			for _, value := range enum.Values {
+				b.WriteString(fmt.Sprintf("    %s\n", value))
+			}
+			b.WriteString("  }\n")
+		}
+	}
+
+	for _, rel := range relations {
+		b.WriteString(rel)
+		b.WriteString("\n")
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// classID converts a (possibly nested) proto type name into a valid Mermaid class identifier
+func classID(name string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(name, "."), ".", "_")
+}
+
+func streamType(typeName string, stream bool) string {
+	if stream {
+		return fmt.Sprintf("stream~%s~", classID(typeName))
+	}
+	return classID(typeName)
+}
+
+func fieldType(field Field) string {
+	typeName := field.Type
+	if !strings.HasPrefix(typeName, "map~") {
+		typeName = classID(typeName)
+	}
+	if field.Repeated {
+		return fmt.Sprintf("List~%s~", typeName)
+	}
+	return typeName
+}
+
+// referencedTypes returns the type names referenced by a field type, including map values
+func referencedTypes(typeName string) []string {
+	if strings.HasPrefix(typeName, "map~") {
+		inner := strings.TrimSuffix(strings.TrimPrefix(typeName, "map~"), "~")
+		parts := strings.SplitN(inner, ",", 2)
+		if len(parts) == 2 {
+			return []string{strings.TrimSpace(parts[1])}
+		}
+		return nil
+	}
+	return []string{typeName}
+}
+
+// resolve maps a referenced type name onto a message or enum declared in the parsed files.
+// Relative references are looked up from the scope of the referencing message outward, as
+// protobuf does, then qualified with the package of any file. A nested type referenced by a short
+// name that isn't in scope resolves to the first match by qualified name, so the result doesn't
+// depend on map order.
+func resolve(ref, pkg, scope string, known map[string]string) string {
+	if strings.HasPrefix(ref, ".") {
+		return known[strings.TrimPrefix(ref, ".")]
+	}
+
+	// pkg.A.B.Item, pkg.A.Item, pkg.Item, then Item
+	var parts []string
+	if pkg != "" {
+		parts = append(parts, strings.Split(pkg, ".")...)
+	}
+	if scope != "" {
+		parts = append(parts, strings.Split(scope, ".")...)
+	}
+	for i := len(parts); i > 0; i-- {
+		if name, ok := known[strings.Join(parts[:i], ".")+"."+ref]; ok {
+			return name
+		}
+	}
+	if name, ok := known[ref]; ok {
+		return name
+	}
+
+	qualified := make([]string, 0, len(known))
+	for q := range known {
+		qualified = append(qualified, q)
+	}
+	sort.Strings(qualified)
+	for _, q := range qualified {
+		if strings.HasSuffix(q, "."+ref) {
+			return known[q]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,82 @@
+package proto
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// DiagramTestSuite is a test suite for rendering parsed .proto files
+type DiagramTestSuite struct {
+	suite.Suite
+}
+
+// TestClassDiagram compares the class diagram of a file with its golden file
+func (s *DiagramTestSuite) TestClassDiagram() {
+	content, err := os.ReadFile(filepath.Join("testdata", "users.proto"))
+	s.Require().NoError(err)
+	file, err := Parse("users.proto", string(content))
+	s.Require().NoError(err)
+	golden, err := os.ReadFile(filepath.Join("testdata", "users.golden.mmd"))
+	s.Require().NoError(err)
+
+	s.Equal(string(golden), ClassDiagram(file)+"\n")
+}
+
+// TestAcrossFiles checks that messages of another file are resolved by their qualified name
+func (s *DiagramTestSuite) TestAcrossFiles() {
+	orders, err := Parse("orders.proto", `package shop.orders;
+message Order {
+  shop.common.Money total = 1;
+  repeated Line lines = 2;
+  message Line { string sku = 1; }
+}`)
+	s.Require().NoError(err)
+	common, err := Parse("common.proto", `package shop.common;
+message Money { int64 cents = 1; }`)
+	s.Require().NoError(err)
+
+	diagram := ClassDiagram(orders, common)
+	s.Contains(diagram, "  %% orders.proto\n")
+	s.Contains(diagram, "  %% common.proto\n")
+	s.Contains(diagram, "  Order --> Order_Line : lines")
+	s.Contains(diagram, "  Order --> Money : total")
+}
+
+// TestNestedScopes checks that nested types with the same short name are resolved from the scope
+// of the referencing message, and the same way on every run when none is in scope
+func (s *DiagramTestSuite) TestNestedScopes() {
+	file, err := Parse("lists.proto", `package lists;
+message A {
+  Item item = 1;
+  message Item { string id = 1; }
+}
+message B {
+  repeated Item items = 1;
+  message Item { string id = 1; }
+  message Page { Item first = 1; }
+}
+message C {
+  Item item = 1;
+  B.Item other = 2;
+}`)
+	s.Require().NoError(err)
+
+	for i := 0; i < 20; i++ {
+		diagram := ClassDiagram(file)
+		s.Contains(diagram, "  A --> A_Item : item")
+		s.Contains(diagram, "  B --> B_Item : items")
+		s.Contains(diagram, "  B_Page --> B_Item : first")
+		s.Contains(diagram, "  C --> A_Item : item")
+		s.Contains(diagram, "  C --> B_Item : other")
+		s.NotContains(diagram, "  A --> B_Item")
+		s.NotContains(diagram, "  B --> A_Item")
+	}
+}
+
+// TestDiagramTestSuite runs the diagram test suite
+func TestDiagramTestSuite(t *testing.T) {
+	suite.Run(t, new(DiagramTestSuite))
+}
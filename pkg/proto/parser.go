@@ -0,0 +1,441 @@
+package proto
+
+import (
+	"fmt"
+	"strings"
+	"text/scanner"
+)
+
+// File represents the parts of a .proto file that are relevant for diagrams
+type File struct {
+	Name     string
+	Package  string
+	Services []Service
+	Messages []Message
+	Enums    []Enum
+}
+
+// Service represents a gRPC service definition
+type Service struct {
+	Name string
+	RPCs []RPC
+}
+
+// RPC represents a single rpc method of a service
+type RPC struct {
+	Name            string
+	Request         string
+	Response        string
+	ClientStreaming bool
+	ServerStreaming bool
+}
+
+// Message represents a protobuf message definition
+type Message struct {
+	Name   string
+	Fields []Field
+}
+
+// Field represents a field of a protobuf message
+type Field struct {
+	Name     string
+	Type     string
+	Repeated bool
+	Optional bool
+}
+
+// Enum represents a protobuf enum definition
+type Enum struct {
+	Name   string
+	Values []string
+}
+
+// Parse parses the content of a .proto file.
+// Options, imports and extensions are skipped, nested messages are flattened
+// using their dotted name (e.g. Outer.Inner).
+func Parse(name, content string) (*File, error) {
+	p := newParser(name, content)
+	file := &File{Name: name}
+
+	for p.tok != scanner.EOF {
+		switch p.text() {
+		case "syntax", "edition", "import", "option":
+			p.skipStatement()
+		case "package":
+			p.next()
+			file.Package = p.qualifiedIdent()
+			p.skipStatement()
+		case "service":
+			svc, err := p.parseService()
+			if err != nil {
+				return nil, err
+			}
+			file.Services = append(file.Services, svc)
+		case "message":
+			if err := p.parseMessage("", file); err != nil {
+				return nil, err
+			}
+		case "enum":
+			enum, err := p.parseEnum("")
+			if err != nil {
+				return nil, err
+			}
+			file.Enums = append(file.Enums, enum)
+		case "extend":
+			p.skipBlock()
+		case ";":
+			p.next()
+		default:
+			return nil, p.errorf("unexpected token %q", p.text())
+		}
+	}
+
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	return file, nil
+}
+
+// protoParser is a minimal recursive descent parser for proto3/proto2 definitions
+type protoParser struct {
+	s   scanner.Scanner
+	tok rune
+	err error
+}
+
+func newParser(name, content string) *protoParser {
+	p := &protoParser{}
+	p.s.Init(strings.NewReader(content))
+	p.s.Filename = name
+	p.s.Mode = scanner.ScanIdents | scanner.ScanInts | scanner.ScanFloats |
+		scanner.ScanStrings | scanner.ScanRawStrings | scanner.ScanComments | scanner.SkipComments
+	p.s.Error = func(s *scanner.Scanner, msg string) {
+		if p.err == nil {
+			p.err = fmt.Errorf("%s: %s", s.Position, msg)
+		}
+	}
+	p.next()
+	return p
+}
+
+func (p *protoParser) next() {
+	p.tok = p.s.Scan()
+}
+
+func (p *protoParser) text() string {
+	return p.s.TokenText()
+}
+
+func (p *protoParser) errorf(format string, args ...interface{}) error {
+	if p.err != nil {
+		return p.err
+	}
+	return fmt.Errorf("%s: %s", p.s.Position, fmt.Sprintf(format, args...))
+}
+
+func (p *protoParser) expect(text string) error {
+	if p.text() != text {
+		return p.errorf("expected %q, found %q", text, p.text())
+	}
+	p.next()
+	return nil
+}
+
+// ident reads a single identifier
+func (p *protoParser) ident() (string, error) {
+	if p.tok != scanner.Ident {
+		return "", p.errorf("expected identifier, found %q", p.text())
+	}
+	name := p.text()
+	p.next()
+	return name, nil
+}
+
+// qualifiedIdent reads a dotted identifier such as google.protobuf.Empty
+func (p *protoParser) qualifiedIdent() string {
+	var b strings.Builder
+	if p.text() == "." {
+		b.WriteString(".")
+		p.next()
+	}
+	for p.tok == scanner.Ident {
+		b.WriteString(p.text())
+		p.next()
+		if p.text() != "." {
+			break
+		}
+		b.WriteString(".")
+		p.next()
+	}
+	return b.String()
+}
+
+// skipStatement skips everything up to and including the next semicolon outside of brackets and
+// braces, so that options such as [(validate.rules).string = {min_len: 1}] are skipped whole. It
+// stops before a closing brace ending the enclosing block.
+func (p *protoParser) skipStatement() {
+	depth := 0
+	for p.tok != scanner.EOF {
+		switch p.text() {
+		case "[", "{", "(":
+			depth++
+		case "]", "}", ")":
+			if depth == 0 {
+				return
+			}
+			depth--
+		case ";":
+			if depth == 0 {
+				p.next()
+				return
+			}
+		}
+		p.next()
+	}
+}
+
+// skipBlock skips a balanced {...} block, starting anywhere before the opening brace
+func (p *protoParser) skipBlock() {
+	for p.tok != scanner.EOF && p.text() != "{" {
+		p.next()
+	}
+	depth := 0
+	for p.tok != scanner.EOF {
+		switch p.text() {
+		case "{":
+			depth++
+		case "}":
+			depth--
+			if depth == 0 {
+				p.next()
+				return
+			}
+		}
+		p.next()
+	}
+}
+
+func (p *protoParser) parseService() (Service, error) {
+	p.next() // service
+	name, err := p.ident()
+	if err != nil {
+		return Service{}, err
+	}
+	if err := p.expect("{"); err != nil {
+		return Service{}, err
+	}
+
+	svc := Service{Name: name}
+	for p.tok != scanner.EOF && p.text() != "}" {
+		switch p.text() {
+		case "rpc":
+			rpc, err := p.parseRPC()
+			if err != nil {
+				return Service{}, err
+			}
+			svc.RPCs = append(svc.RPCs, rpc)
+		case "option":
+			p.skipStatement()
+		case ";":
+			p.next()
+		default:
+			return Service{}, p.errorf("unexpected token %q in service %s", p.text(), name)
+		}
+	}
+
+	return svc, p.expect("}")
+}
+
+func (p *protoParser) parseRPC() (RPC, error) {
+	p.next() // rpc
+	name, err := p.ident()
+	if err != nil {
+		return RPC{}, err
+	}
+	rpc := RPC{Name: name}
+
+	rpc.Request, rpc.ClientStreaming, err = p.parseRPCType()
+	if err != nil {
+		return RPC{}, err
+	}
+	if err := p.expect("returns"); err != nil {
+		return RPC{}, err
+	}
+	rpc.Response, rpc.ServerStreaming, err = p.parseRPCType()
+	if err != nil {
+		return RPC{}, err
+	}
+
+	// Either a plain ';' or an options block
+	if p.text() == "{" {
+		p.skipBlock()
+	} else {
+		p.skipStatement()
+	}
+
+	return rpc, nil
+}
+
+// parseRPCType parses "(stream Type)" and reports whether the type is streamed
+func (p *protoParser) parseRPCType() (string, bool, error) {
+	if err := p.expect("("); err != nil {
+		return "", false, err
+	}
+	stream := false
+	if p.text() == "stream" {
+		stream = true
+		p.next()
+	}
+	typeName := p.qualifiedIdent()
+	if typeName == "" {
+		return "", false, p.errorf("expected message type, found %q", p.text())
+	}
+	return typeName, stream, p.expect(")")
+}
+
+func (p *protoParser) parseMessage(prefix string, file *File) error {
+	p.next() // message
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	if prefix != "" {
+		name = prefix + "." + name
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	msg := Message{Name: name}
+	for p.tok != scanner.EOF && p.text() != "}" {
+		switch p.text() {
+		case "message":
+			if err := p.parseMessage(name, file); err != nil {
+				return err
+			}
+		case "enum":
+			enum, err := p.parseEnum(name)
+			if err != nil {
+				return err
+			}
+			file.Enums = append(file.Enums, enum)
+		case "oneof":
+			p.next()
+			if _, err := p.ident(); err != nil {
+				return err
+			}
+			if err := p.expect("{"); err != nil {
+				return err
+			}
+			for p.tok != scanner.EOF && p.text() != "}" {
+				if p.text() == "option" || p.text() == ";" {
+					p.skipStatement()
+					continue
+				}
+				field, err := p.parseField()
+				if err != nil {
+					return err
+				}
+				field.Optional = true
+				msg.Fields = append(msg.Fields, field)
+			}
+			if err := p.expect("}"); err != nil {
+				return err
+			}
+		case "option", "reserved", "extensions":
+			p.skipStatement()
+		case "extend":
+			p.skipBlock()
+		case ";":
+			p.next()
+		default:
+			field, err := p.parseField()
+			if err != nil {
+				return err
+			}
+			msg.Fields = append(msg.Fields, field)
+		}
+	}
+
+	file.Messages = append(file.Messages, msg)
+	return p.expect("}")
+}
+
+// parseField parses "[repeated|optional|required] type name = N [options];" and map fields
+func (p *protoParser) parseField() (Field, error) {
+	field := Field{}
+	switch p.text() {
+	case "repeated":
+		field.Repeated = true
+		p.next()
+	case "optional":
+		field.Optional = true
+		p.next()
+	case "required":
+		p.next()
+	}
+
+	if p.text() == "map" {
+		p.next()
+		if err := p.expect("<"); err != nil {
+			return Field{}, err
+		}
+		key := p.qualifiedIdent()
+		if err := p.expect(","); err != nil {
+			return Field{}, err
+		}
+		value := p.qualifiedIdent()
+		if err := p.expect(">"); err != nil {
+			return Field{}, err
+		}
+		field.Type = fmt.Sprintf("map~%s, %s~", key, value)
+	} else {
+		field.Type = p.qualifiedIdent()
+		if field.Type == "" {
+			return Field{}, p.errorf("expected field type, found %q", p.text())
+		}
+	}
+
+	name, err := p.ident()
+	if err != nil {
+		return Field{}, err
+	}
+	field.Name = name
+	p.skipStatement()
+
+	return field, nil
+}
+
+func (p *protoParser) parseEnum(prefix string) (Enum, error) {
+	p.next() // enum
+	name, err := p.ident()
+	if err != nil {
+		return Enum{}, err
+	}
+	if prefix != "" {
+		name = prefix + "." + name
+	}
+	if err := p.expect("{"); err != nil {
+		return Enum{}, err
+	}
+
+	enum := Enum{Name: name}
+	for p.tok != scanner.EOF && p.text() != "}" {
+		switch p.text() {
+		case "option", "reserved":
+			p.skipStatement()
+		case ";":
+			p.next()
+		default:
+			value, err := p.ident()
+			if err != nil {
+				return Enum{}, err
+			}
+			enum.Values = append(enum.Values, value)
+			p.skipStatement()
+		}
+	}
+
+	return enum, p.expect("}")
+}
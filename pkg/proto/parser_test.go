@@ -0,0 +1,87 @@
+package proto
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ParserTestSuite is a test suite for the .proto parser
+type ParserTestSuite struct {
+	suite.Suite
+}
+
+// parseFixture parses a .proto file of testdata
+func (s *ParserTestSuite) parseFixture(name string) *File {
+	content, err := os.ReadFile(filepath.Join("testdata", name))
+	s.Require().NoError(err)
+	file, err := Parse(name, string(content))
+	s.Require().NoError(err)
+	return file
+}
+
+// TestParse checks the services, messages and enums of a file
+func (s *ParserTestSuite) TestParse() {
+	file := s.parseFixture("users.proto")
+
+	s.Equal("users.v1", file.Package)
+	s.Require().Len(file.Services, 1)
+	s.Equal([]RPC{
+		{Name: "GetUser", Request: "GetUserRequest", Response: "User"},
+		{Name: "ListUsers", Request: "ListUsersRequest", Response: "User", ServerStreaming: true},
+		{Name: "UploadAvatars", Request: "Avatar", Response: "google.protobuf.Empty", ClientStreaming: true},
+	}, file.Services[0].RPCs)
+
+	var names []string
+	for _, m := range file.Messages {
+		names = append(names, m.Name)
+	}
+	s.Equal([]string{"GetUserRequest", "ListUsersRequest", "User.Address", "User", "Avatar"}, names)
+
+	user := file.Messages[3]
+	s.Equal([]Field{
+		{Name: "id", Type: "string"},
+		{Name: "name", Type: "string"},
+		{Name: "role", Type: "Role"},
+		{Name: "addresses", Type: "Address", Repeated: true},
+		{Name: "labeled", Type: "map~string, Address~"},
+		{Name: "email", Type: "string", Optional: true},
+		{Name: "phone", Type: "string", Optional: true},
+	}, user.Fields)
+
+	s.Equal([]Enum{{Name: "Role", Values: []string{"ROLE_UNSPECIFIED", "ROLE_ADMIN", "ROLE_USER"}}}, file.Enums)
+}
+
+// TestOptions checks that field, enum value and aggregate options are skipped whole
+func (s *ParserTestSuite) TestOptions() {
+	file, err := Parse("options.proto", `syntax = "proto3";
+message Page {
+  int32 size = 1 [(validate.rules).int32 = {gt: 0, lte: 100}];
+  string token = 2 [(a) = {b: {c: "]"}}, (d) = "e"];
+  option (custom.message) = {names: ["x", "y"]};
+}
+enum Kind {
+  KIND_A = 0 [(custom.label) = {text: "a"}];
+  KIND_B = 1;
+}`)
+	s.Require().NoError(err)
+	s.Require().Len(file.Messages, 1)
+	s.Equal([]Field{{Name: "size", Type: "int32"}, {Name: "token", Type: "string"}}, file.Messages[0].Fields)
+	s.Equal([]Enum{{Name: "Kind", Values: []string{"KIND_A", "KIND_B"}}}, file.Enums)
+}
+
+// TestErrors checks that malformed definitions are reported with their position
+func (s *ParserTestSuite) TestErrors() {
+	_, err := Parse("broken.proto", "message User {\n  string = 1;\n}")
+	s.ErrorContains(err, "broken.proto:2")
+
+	_, err = Parse("broken.proto", "service Users {\n  get User;\n}")
+	s.ErrorContains(err, `unexpected token "get" in service Users`)
+}
+
+// TestParserTestSuite runs the parser test suite
+func TestParserTestSuite(t *testing.T) {
+	suite.Run(t, new(ParserTestSuite))
+}
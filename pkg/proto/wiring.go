@@ -0,0 +1,318 @@
+package proto
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Wiring describes the gRPC client and server wiring found in Go source code
+type Wiring struct {
+	Servers []ServerRegistration
+	Clients []ClientBinding
+	Calls   []Call
+}
+
+// ServerRegistration is a Register<Service>Server call binding an implementation to a service
+type ServerRegistration struct {
+	Service string
+	Impl    string
+	Caller  string
+}
+
+// ClientBinding is a New<Service>Client call and the name the client is stored under
+type ClientBinding struct {
+	Service string
+	Name    string
+	Caller  string
+}
+
+// Call is an RPC invoked through a generated client
+type Call struct {
+	Caller  string
+	Service string
+	Method  string
+	Line    int
+}
+
+// SourceFile is a Go file scanned for gRPC wiring
+type SourceFile struct {
+	Path    string
+	Content string
+}
+
+// FindWiring scans Go source files for generated gRPC registration, client construction and RPC
+// calls. Clients are resolved across the files of a package, so a client created in a
+// constructor is found when its RPCs are called from another file.
+func FindWiring(files ...SourceFile) (*Wiring, error) {
+	fset := token.NewFileSet()
+	packages := make(map[string][]*ast.File)
+	var order []string
+	for _, f := range files {
+		file, err := parser.ParseFile(fset, f.Path, f.Content, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", f.Path, err)
+		}
+		key := filepath.Dir(f.Path) + ":" + file.Name.Name
+		if _, ok := packages[key]; !ok {
+			order = append(order, key)
+		}
+		packages[key] = append(packages[key], file)
+	}
+
+	w := &Wiring{}
+	for _, key := range order {
+		w.findPackage(fset, packages[key])
+	}
+	return w, nil
+}
+
+// findPackage adds the wiring of the files of a package
+func (w *Wiring) findPackage(fset *token.FileSet, files []*ast.File) {
+	clients := make(map[string]string) // client name -> service
+
+	// First pass: registrations and client constructors
+	forEachFunc(files, func(fn *ast.FuncDecl, caller string) {
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			switch node := n.(type) {
+			case *ast.CallExpr:
+				name := calleeName(node.Fun)
+				if svc, ok := trimAffixes(name, "Register", "Server"); ok && len(node.Args) == 2 {
+					w.Servers = append(w.Servers, ServerRegistration{
+						Service: svc,
+						Impl:    implName(node.Args[1]),
+						Caller:  caller,
+					})
+				}
+			case *ast.AssignStmt:
+				for i, rhs := range node.Rhs {
+					if svc, ok := clientConstructor(rhs); ok && i < len(node.Lhs) {
+						name := exprName(node.Lhs[i])
+						clients[name] = svc
+						w.Clients = append(w.Clients, ClientBinding{Service: svc, Name: name, Caller: caller})
+					}
+				}
+			case *ast.ValueSpec:
+				for i, value := range node.Values {
+					if svc, ok := clientConstructor(value); ok && i < len(node.Names) {
+						name := node.Names[i].Name
+						clients[name] = svc
+						w.Clients = append(w.Clients, ClientBinding{Service: svc, Name: name, Caller: caller})
+					}
+				}
+			case *ast.KeyValueExpr:
+				if svc, ok := clientConstructor(node.Value); ok {
+					name := exprName(node.Key)
+					clients[name] = svc
+					w.Clients = append(w.Clients, ClientBinding{Service: svc, Name: name, Caller: caller})
+				}
+			}
+			return true
+		})
+	})
+
+	// Second pass: RPC calls through known clients
+	forEachFunc(files, func(fn *ast.FuncDecl, caller string) {
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			if svc, ok := clients[exprName(sel.X)]; ok {
+				w.Calls = append(w.Calls, Call{
+					Caller:  caller,
+					Service: svc,
+					Method:  sel.Sel.Name,
+					Line:    fset.Position(call.Pos()).Line,
+				})
+			}
+			return true
+		})
+	})
+}
+
+// forEachFunc calls fn for every function with a body in the files, with its caller name
+func forEachFunc(files []*ast.File, fn func(decl *ast.FuncDecl, caller string)) {
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			if funcDecl, ok := decl.(*ast.FuncDecl); ok && funcDecl.Body != nil {
+				fn(funcDecl, callerName(funcDecl))
+			}
+		}
+	}
+}
+
+// Empty reports whether no gRPC wiring was found
+func (w *Wiring) Empty() bool {
+	return len(w.Servers) == 0 && len(w.Clients) == 0 && len(w.Calls) == 0
+}
+
+// SequenceDiagram renders the RPC calls found in the given wirings as a Mermaid sequence diagram.
+// Parsed proto files are used to annotate calls with their request and response messages.
+func SequenceDiagram(wirings []*Wiring, files []*File) string {
+	rpcs := make(map[string]RPC)
+	for _, f := range files {
+		for _, svc := range f.Services {
+			for _, rpc := range svc.RPCs {
+				rpcs[svc.Name+"."+rpc.Name] = rpc
+			}
+		}
+	}
+
+	impls := make(map[string][]string)
+	var participants []string
+	seen := make(map[string]bool)
+	addParticipant := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			participants = append(participants, name)
+		}
+	}
+
+	var calls []Call
+	for _, w := range wirings {
+		for _, reg := range w.Servers {
+			if reg.Impl != "" {
+				impls[reg.Service] = appendUnique(impls[reg.Service], reg.Impl)
+			}
+		}
+		for _, call := range w.Calls {
+			addParticipant(call.Caller)
+			addParticipant(call.Service)
+			calls = append(calls, call)
+		}
+	}
+	for _, w := range wirings {
+		for _, reg := range w.Servers {
+			addParticipant(reg.Service)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("sequenceDiagram\n")
+	for _, p := range participants {
+		b.WriteString(fmt.Sprintf("  participant %s\n", participantID(p)))
+	}
+
+	services := make([]string, 0, len(impls))
+	for svc := range impls {
+		services = append(services, svc)
+	}
+	sort.Strings(services)
+	for _, svc := range services {
+		b.WriteString(fmt.Sprintf("  Note over %s: implemented by %s\n", participantID(svc), strings.Join(impls[svc], ", ")))
+	}
+
+	for _, call := range calls {
+		rpc, known := rpcs[call.Service+"."+call.Method]
+		request, response := "", "response"
+		if known {
+			request = classID(rpc.Request)
+			response = classID(rpc.Response)
+			if rpc.ServerStreaming {
+				response = fmt.Sprintf("stream of %s", response)
+			}
+		}
+
+		b.WriteString(fmt.Sprintf("  %s->>%s: %s(%s)\n", participantID(call.Caller), participantID(call.Service), call.Method, request))
+		b.WriteString(fmt.Sprintf("  %s-->>%s: %s\n", participantID(call.Service), participantID(call.Caller), response))
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// clientConstructor reports whether expr is a New<Service>Client call
+func clientConstructor(expr ast.Expr) (string, bool) {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return "", false
+	}
+	return trimAffixes(calleeName(call.Fun), "New", "Client")
+}
+
+// trimAffixes strips a prefix and suffix from name, requiring a non-empty remainder
+func trimAffixes(name, prefix, suffix string) (string, bool) {
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return "", false
+	}
+	inner := strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix)
+	if inner == "" || inner == "Unimplemented" {
+		return "", false
+	}
+	return inner, true
+}
+
+// calleeName returns the unqualified name of the called function
+func calleeName(fun ast.Expr) string {
+	switch f := fun.(type) {
+	case *ast.Ident:
+		return f.Name
+	case *ast.SelectorExpr:
+		return f.Sel.Name
+	}
+	return ""
+}
+
+// exprName returns the last identifier of an expression such as s.client or client
+func exprName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	case *ast.StarExpr:
+		return exprName(e.X)
+	}
+	return ""
+}
+
+// implName returns the name of the type registered as a server implementation
+func implName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.UnaryExpr:
+		return implName(e.X)
+	case *ast.CompositeLit:
+		return exprName(e.Type)
+	case *ast.CallExpr:
+		return calleeName(e.Fun)
+	}
+	return exprName(expr)
+}
+
+// callerName returns the receiver type for methods or the function name otherwise
+func callerName(fn *ast.FuncDecl) string {
+	if fn.Recv != nil && len(fn.Recv.List) > 0 {
+		recv := fn.Recv.List[0].Type
+		if star, ok := recv.(*ast.StarExpr); ok {
+			recv = star.X
+		}
+		if index, ok := recv.(*ast.IndexExpr); ok {
+			recv = index.X
+		}
+		if ident, ok := recv.(*ast.Ident); ok {
+			return ident.Name
+		}
+	}
+	return fn.Name.Name
+}
+
+func participantID(name string) string {
+	return strings.ReplaceAll(name, ".", "_")
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
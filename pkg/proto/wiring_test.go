@@ -0,0 +1,108 @@
+package proto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// WiringTestSuite is a test suite for finding gRPC wiring in Go code
+type WiringTestSuite struct {
+	suite.Suite
+}
+
+// TestFindWiring checks registrations, clients and calls within a file
+func (s *WiringTestSuite) TestFindWiring() {
+	w, err := FindWiring(
+		SourceFile{Path: "cmd/server/main.go", Content: `package main
+
+func main() {
+	s := grpc.NewServer()
+	usersv1.RegisterUserServiceServer(s, &userServer{})
+}
+`},
+		SourceFile{Path: "internal/gateway/gateway.go", Content: `package gateway
+
+type Gateway struct {
+	users usersv1.UserServiceClient
+}
+
+func Dial(conn *grpc.ClientConn) *Gateway {
+	return &Gateway{users: usersv1.NewUserServiceClient(conn)}
+}
+
+func (g *Gateway) Profile(ctx context.Context, id string) {
+	g.users.GetUser(ctx, &usersv1.GetUserRequest{Id: id})
+}
+`},
+	)
+	s.Require().NoError(err)
+	s.Equal([]ServerRegistration{{Service: "UserService", Impl: "userServer", Caller: "main"}}, w.Servers)
+	s.Equal([]ClientBinding{{Service: "UserService", Name: "users", Caller: "Dial"}}, w.Clients)
+	s.Equal([]Call{{Caller: "Gateway", Service: "UserService", Method: "GetUser", Line: 12}}, w.Calls)
+}
+
+// TestAcrossFiles checks that a client created in one file of a package is resolved in the others,
+// and not in other packages
+func (s *WiringTestSuite) TestAcrossFiles() {
+	w, err := FindWiring(
+		SourceFile{Path: "internal/billing/client.go", Content: `package billing
+
+func NewService(conn *grpc.ClientConn) *Service {
+	s := &Service{}
+	s.accounts = accountsv1.NewAccountServiceClient(conn)
+	return s
+}
+`},
+		SourceFile{Path: "internal/billing/charge.go", Content: `package billing
+
+func (s *Service) Charge(ctx context.Context) {
+	s.accounts.Debit(ctx, nil)
+}
+`},
+		SourceFile{Path: "internal/reports/reports.go", Content: `package reports
+
+func (r *Reports) Monthly(ctx context.Context) {
+	r.accounts.Debit(ctx, nil)
+}
+`},
+	)
+	s.Require().NoError(err)
+	s.Equal([]Call{{Caller: "Service", Service: "AccountService", Method: "Debit", Line: 4}}, w.Calls)
+
+	_, err = FindWiring(SourceFile{Path: "broken.go", Content: "package broken\nfunc {"})
+	s.ErrorContains(err, "failed to parse broken.go")
+}
+
+// TestSequenceDiagram checks the calls with the messages of the parsed services
+func (s *WiringTestSuite) TestSequenceDiagram() {
+	file, err := Parse("users.proto", `service UserService {
+  rpc GetUser(GetUserRequest) returns (User);
+  rpc ListUsers(ListUsersRequest) returns (stream User);
+}`)
+	s.Require().NoError(err)
+	w := &Wiring{
+		Servers: []ServerRegistration{{Service: "UserService", Impl: "userServer", Caller: "main"}},
+		Calls: []Call{
+			{Caller: "Gateway", Service: "UserService", Method: "GetUser"},
+			{Caller: "Gateway", Service: "UserService", Method: "ListUsers"},
+			{Caller: "Gateway", Service: "UserService", Method: "Unknown"},
+		},
+	}
+
+	s.Equal(`sequenceDiagram
+  participant Gateway
+  participant UserService
+  Note over UserService: implemented by userServer
+  Gateway->>UserService: GetUser(GetUserRequest)
+  UserService-->>Gateway: User
+  Gateway->>UserService: ListUsers(ListUsersRequest)
+  UserService-->>Gateway: stream of User
+  Gateway->>UserService: Unknown()
+  UserService-->>Gateway: response`, SequenceDiagram([]*Wiring{w}, []*File{file}))
+}
+
+// TestWiringTestSuite runs the wiring test suite
+func TestWiringTestSuite(t *testing.T) {
+	suite.Run(t, new(WiringTestSuite))
+}
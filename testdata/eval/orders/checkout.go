@@ -0,0 +1,41 @@
+package orders
+
+import (
+	"context"
+	"fmt"
+)
+
+// Store persists orders
+type Store interface {
+	Save(ctx context.Context, order *Order) error
+}
+
+// PaymentGateway charges customers
+type PaymentGateway interface {
+	Charge(ctx context.Context, orderID string, amount int64) error
+}
+
+// Checkout places and pays orders
+type Checkout struct {
+	store    Store
+	payments PaymentGateway
+}
+
+// NewCheckout creates a checkout
+func NewCheckout(store Store, payments PaymentGateway) *Checkout {
+	return &Checkout{store: store, payments: payments}
+}
+
+// Place saves the order and charges its total
+func (c *Checkout) Place(ctx context.Context, order *Order) error {
+	order.Status = StatusPending
+	if err := c.store.Save(ctx, order); err != nil {
+		return fmt.Errorf("failed to save order: %w", err)
+	}
+	if err := c.payments.Charge(ctx, order.ID, order.Total()); err != nil {
+		return fmt.Errorf("failed to charge order: %w", err)
+	}
+
+	order.Status = StatusPaid
+	return c.store.Save(ctx, order)
+}
@@ -0,0 +1,37 @@
+package orders
+
+import "time"
+
+// Status is the state of an order
+type Status string
+
+const (
+	// StatusPending orders are waiting for payment
+	StatusPending Status = "pending"
+	// StatusPaid orders are ready to ship
+	StatusPaid Status = "paid"
+)
+
+// Order is a placed order
+type Order struct {
+	ID        string
+	Items     []Item
+	Status    Status
+	CreatedAt time.Time
+}
+
+// Item is a line of an order
+type Item struct {
+	SKU      string
+	Quantity int
+	Price    int64
+}
+
+// Total returns the price of all items
+func (o *Order) Total() int64 {
+	var total int64
+	for _, item := range o.Items {
+		total += item.Price * int64(item.Quantity)
+	}
+	return total
+}
@@ -0,0 +1,55 @@
+package users
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned for unknown users
+var ErrNotFound = errors.New("user not found")
+
+// User is a registered user
+type User struct {
+	ID    string
+	Email string
+	Name  string
+}
+
+// UserRepository persists users
+type UserRepository interface {
+	FindByID(ctx context.Context, id string) (*User, error)
+	Save(ctx context.Context, user *User) error
+}
+
+// Notifier sends messages to users
+type Notifier interface {
+	Notify(ctx context.Context, user *User, message string) error
+}
+
+// UserService manages users
+type UserService struct {
+	repo     UserRepository
+	notifier Notifier
+}
+
+// NewUserService creates a user service
+func NewUserService(repo UserRepository, notifier Notifier) *UserService {
+	return &UserService{repo: repo, notifier: notifier}
+}
+
+// Rename changes the name of a user and notifies them
+func (s *UserService) Rename(ctx context.Context, id, name string) error {
+	user, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrNotFound
+	}
+
+	user.Name = name
+	if err := s.repo.Save(ctx, user); err != nil {
+		return err
+	}
+	return s.notifier.Notify(ctx, user, "your name was changed to "+name)
+}
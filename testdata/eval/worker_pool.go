@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+)
+
+// Job is a unit of work
+type Job struct {
+	ID      int
+	Payload string
+}
+
+// Result is the outcome of a job
+type Result struct {
+	JobID int
+	Err   error
+}
+
+// Handler processes a job
+type Handler func(ctx context.Context, job Job) error
+
+// Pool runs jobs on a fixed number of workers
+type Pool struct {
+	workers int
+	handler Handler
+}
+
+// NewPool creates a pool with the given number of workers
+func NewPool(workers int, handler Handler) *Pool {
+	return &Pool{workers: workers, handler: handler}
+}
+
+// Run processes the jobs until the channel is closed and returns their results
+func (p *Pool) Run(ctx context.Context, jobs <-chan Job) <-chan Result {
+	results := make(chan Result)
+
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				select {
+				case <-ctx.Done():
+					return
+				case results <- Result{JobID: job.ID, Err: p.handler(ctx, job)}:
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}